@@ -13,6 +13,7 @@ import (
 	"github.com/overmindtech/discovery"
 	"github.com/overmindtech/multiconn"
 	"github.com/overmindtech/overmind-agent/sources"
+	"github.com/overmindtech/overmind-agent/sources/langpkg"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
@@ -40,6 +41,15 @@ Edit this once you have created your source
 		overmindTokenAPI := viper.GetString("overmind-token-api")
 		maxParallel := viper.GetInt("max-parallel")
 		startConnectRetries := viper.GetInt("start-connect-retries")
+		processLibraries := viper.GetBool("process-libraries")
+		packageFileLinks := viper.GetInt("package-file-links")
+		packageVerify := viper.GetBool("package-verify")
+		connectionAllStates := viper.GetBool("connection-all-states")
+		pythonPaths := viper.GetStringSlice("python-paths")
+		nodePaths := viper.GetStringSlice("node-paths")
+		rubyPaths := viper.GetStringSlice("ruby-paths")
+		binaryPaths := viper.GetStringSlice("binary-paths")
+		javaPaths := viper.GetStringSlice("java-paths")
 		hostname, err := os.Hostname()
 
 		if err != nil {
@@ -65,6 +75,15 @@ Edit this once you have created your source
 			"overmind-auth-url":     overmindAuthURL,
 			"start-connect-retries": startConnectRetries,
 			"overmind-token-api":    overmindTokenAPI,
+			"process-libraries":     processLibraries,
+			"package-file-links":    packageFileLinks,
+			"package-verify":        packageVerify,
+			"connection-all-states": connectionAllStates,
+			"python-paths":          pythonPaths,
+			"node-paths":            nodePaths,
+			"ruby-paths":            rubyPaths,
+			"binary-paths":          binaryPaths,
+			"java-paths":            javaPaths,
 		}).Info("Got config")
 
 		e := discovery.Engine{
@@ -105,6 +124,18 @@ Edit this once you have created your source
 			e.NATSOptions.TokenClient = oauthClient
 		}
 
+		sources.Configure(sources.Config{
+			ProcessLibraries:    processLibraries,
+			PackageFileLinks:    packageFileLinks,
+			PackageVerify:       packageVerify,
+			ConnectionAllStates: connectionAllStates,
+			PythonPaths:         pythonPaths,
+			NodePaths:           nodePaths,
+			RubyPaths:           rubyPaths,
+			BinaryPaths:         binaryPaths,
+			JavaPaths:           javaPaths,
+		})
+
 		// ⚠️ Here is where you add your sources
 		e.AddSources(sources.Sources...)
 
@@ -179,6 +210,17 @@ func init() {
 	rootCmd.PersistentFlags().String("overmind-auth-url", "https://app.overmind.tech/todo/fix/this", "The URL to send Overmind authentication requests to")
 	rootCmd.PersistentFlags().String("overmind-token-api", "https://app.overmind.tech/todo/v1", "The root URL of the overmind token API which is used to obtain NATS tokens")
 
+	// Config for individual sources
+	rootCmd.PersistentFlags().Bool("process-libraries", false, "List the shared libraries that each process has mapped, and link them to their files and packages")
+	rootCmd.PersistentFlags().Int("package-file-links", 100, "The maximum number of files that each package links to. Config files, systemd units and binaries are linked first. Set to -1 to disable")
+	rootCmd.PersistentFlags().Bool("package-verify", false, "Verify the files owned by each package against the checksums recorded when it was installed, and report modified, missing and permission-changed files. This reads every file so can be slow")
+	rootCmd.PersistentFlags().Bool("connection-all-states", false, "Return connections in every state, such as TIME_WAIT and CLOSE_WAIT, rather than only those that are established")
+	rootCmd.PersistentFlags().StringSlice("python-paths", langpkg.DefaultPythonPaths, "The directories that are searched for Python packages, including those in virtualenvs")
+	rootCmd.PersistentFlags().StringSlice("node-paths", langpkg.DefaultNodePaths, "The directories that are searched for Node packages in node_modules directories")
+	rootCmd.PersistentFlags().StringSlice("ruby-paths", langpkg.DefaultRubyPaths, "The directories that are searched for Ruby gems")
+	rootCmd.PersistentFlags().StringSlice("binary-paths", langpkg.DefaultBinaryPaths, "The directories that are searched for Go and Rust binaries, whose modules and crates are returned as packages")
+	rootCmd.PersistentFlags().StringSlice("java-paths", langpkg.DefaultJavaPaths, "The directories that are searched for Java archives. The classpaths of running java processes are always searched too")
+
 	// Bind these to viper
 	viper.BindPFlags(rootCmd.PersistentFlags())
 
@@ -0,0 +1,232 @@
+package apk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedb"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/sdp-go"
+)
+
+// ApkSource struct on which all methods are registered. Packages are read
+// directly from the apk database of installed packages so the apk command
+// isn't needed
+type ApkSource struct {
+	// The location of the apk database of installed packages. Defaults to
+	// DefaultInstalledLocation
+	InstalledLocation string
+
+	// The maximum number of files that each package links to. Zero uses
+	// packagefiles.DefaultLinkLimit and a negative number disables links
+	FileLinkLimit int
+
+	database     *Database
+	databaseOnce sync.Once
+}
+
+// Type is the type of items that this returns (Required)
+func (s *ApkSource) Type() string {
+	return "package"
+}
+
+// Descriptive name for the source, used in logging and metadata
+func (s *ApkSource) Name() string {
+	return "apk"
+}
+
+// Weighting of duplicate sources
+func (s *ApkSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *ApkSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get takes the UniqueAttribute value as a parameter (also referred to as the
+// "name" of the item) and returns a full item will all details. This function
+// must return an item whose UniqueAttribute value exactly matches the supplied
+// parameter. If the item cannot be found it should return an ItemNotFoundError
+// (Required)
+func (s *ApkSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	p, err := s.db().Package(query)
+
+	if e, ok := err.(NotFoundError); ok {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: e.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return mapPackageToItem(p, s.FileLinkLimit)
+}
+
+// Find Gets information about all item that the source can possibly find. If
+// nothing is found then just return an empty list (Required)
+func (s *ApkSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	ps, err := s.db().Packages()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return packagedb.MapItems(ps, func(p Package) (*sdp.Item, error) {
+		return mapPackageToItem(p, s.FileLinkLimit)
+	}), nil
+}
+
+// Search takes a file path and returns the package that owns it. Paths
+// containing shell wildcards return all packages that own a matching file. If
+// the query isn't a path it returns the packages that provide it, which can be
+// a package name or a capability such as so:libc.musl-x86_64.so.1. If the
+// query is in the format rdepends:{name} it instead returns the packages that
+// depend on the given package
+func (s *ApkSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	var ps []Package
+	var err error
+
+	switch {
+	case strings.HasPrefix(query, "rdepends:"):
+		ps, err = s.db().ReverseDepends(strings.TrimPrefix(query, "rdepends:"))
+	case strings.HasPrefix(query, "/"):
+		ps, err = s.db().Search(query)
+
+		// Paths such as /bin/sh can also be provided without being owned
+		if _, ok := err.(NotFoundError); ok {
+			ps, err = s.db().WhatProvides(query)
+		}
+	default:
+		ps, err = s.db().WhatProvides(query)
+	}
+
+	if e, ok := err.(NotFoundError); ok {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: e.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return packagedb.MapItems(ps, func(p Package) (*sdp.Item, error) {
+		return mapPackageToItem(p, s.FileLinkLimit)
+	}), nil
+}
+
+// Supported Returns whether the apk database of installed packages exists
+func (s *ApkSource) Supported() bool {
+	return s.db().Available()
+}
+
+// db Returns the database of installed packages
+func (s *ApkSource) db() *Database {
+	s.databaseOnce.Do(func() {
+		s.database = &Database{
+			InstalledLocation: s.InstalledLocation,
+		}
+	})
+
+	return s.database
+}
+
+func mapPackageToItem(p Package, fileLinkLimit int) (*sdp.Item, error) {
+	attrMap := map[string]interface{}{
+		"name":         p.Name,
+		"status":       "installed",
+		"version":      p.Version,
+		"architecture": p.Architecture,
+		"size":         p.InstalledSize,
+		"license":      p.License,
+		"origin":       p.Origin,
+		"maintainer":   p.Maintainer,
+		"buildtime":    p.BuildTime,
+		"summary":      p.Summary,
+		"depends":      packagedeps.Attributes(p.Depends),
+		"conflicts":    packagedeps.Attributes(p.Conflicts),
+		"provides":     packagedeps.Attributes(p.Provides),
+		"replaces":     packagedeps.Attributes(p.Replaces),
+		"installIf":    packagedeps.Attributes(p.InstallIf),
+	}
+
+	// The URL is parsed in the same way as the other package sources
+	if p.URL != nil {
+		attrMap["url"] = p.URL
+	}
+
+	for k, v := range packagefiles.Attributes(p.Files) {
+		attrMap[k] = v
+	}
+
+	attributes, err := sdp.ToAttributes(attrMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependencies are often capabilities such as so:libc.musl-x86_64.so.1
+	// rather than package names, so they are linked using a search which
+	// returns the package that provides them. Dependencies that the package
+	// satisfies itself aren't linked
+	linkedItemRequests := packagedeps.LinkedItemRequests(sdp.RequestMethod_SEARCH, p.entry().Matches, p.Depends)
+	linkedItemRequests = append(linkedItemRequests, packagefiles.LinkedItemRequests(p.Files, fileLinkLimit)...)
+
+	return &sdp.Item{
+		Type:               "package",
+		UniqueAttribute:    "name",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: linkedItemRequests,
+	}, nil
+}
@@ -0,0 +1,160 @@
+package apk
+
+import (
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestApkSource(t *testing.T) {
+	src := ApkSource{
+		InstalledLocation: "test/installed",
+	}
+
+	if !src.Supported() {
+		t.Fatal("expected source to be supported")
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get",
+			ItemContext: util.LocalContext,
+			Query:       "nginx",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"version":       "1.24.0-r15",
+						"status":        "installed",
+						"fileCount":     float64(2),
+						"conffileCount": float64(1),
+						"url.Host":      "www.nginx.org",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing",
+			ItemContext: util.LocalContext,
+			Query:       "notapackage",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get wrong context",
+			ItemContext: "foo",
+			Query:       "nginx",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search file",
+			ItemContext: util.LocalContext,
+			Query:       "/lib/libc.musl-x86_64.so.1",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name": "musl",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search provided path",
+			ItemContext: util.LocalContext,
+			Query:       "/bin/sh",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name": "busybox-binsh",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search capability",
+			ItemContext: util.LocalContext,
+			Query:       "so:libpcre2-8.so.0",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name": "pcre2",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search rdepends",
+			ItemContext: util.LocalContext,
+			Query:       "rdepends:pcre2",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search missing",
+			ItemContext: util.LocalContext,
+			Query:       "/usr/bin/notafile",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+func TestLinkedItemRequests(t *testing.T) {
+	db := Database{
+		InstalledLocation: "test/installed",
+	}
+
+	p, err := db.Package("busybox")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := mapPackageToItem(p, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One dependency plus 4 files, with the conffiles first
+	if len(item.LinkedItemRequests) != 5 {
+		t.Fatalf("expected 5 links, got %v", len(item.LinkedItemRequests))
+	}
+
+	dep := item.LinkedItemRequests[0]
+
+	if dep.Type != "package" || dep.Method != sdp.RequestMethod_SEARCH || dep.Query != "so:libc.musl-x86_64.so.1" {
+		t.Errorf("unexpected dependency link %v", dep)
+	}
+
+	if file := item.LinkedItemRequests[1]; file.Type != "file" || file.Query != "/etc/logrotate.d/acpid" && file.Query != "/etc/securetty" && file.Query != "/etc/udhcpd.conf" {
+		t.Errorf("expected a conffile to be linked first, got %v", file)
+	}
+}
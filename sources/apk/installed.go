@@ -0,0 +1,336 @@
+package apk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedb"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// DefaultInstalledLocation The default location of the apk database of
+// installed packages
+const DefaultInstalledLocation = "/lib/apk/db/installed"
+
+// maxLineLength The longest line that can be parsed, this needs to be larger
+// than the default since some packages have very long dependency lists
+const maxLineLength = 1024 * 1024
+
+// Package represents information about an apk package
+type Package struct {
+	Name          string
+	Version       string
+	Architecture  string
+	Size          int
+	InstalledSize int
+	Summary       string
+	URL           *url.URL
+	License       string
+	Origin        string
+	Maintainer    string
+	BuildTime     time.Time
+	Commit        string
+	Depends       []packagedeps.Dependency
+	Conflicts     []packagedeps.Dependency
+	Provides      []packagedeps.Dependency
+	Replaces      []packagedeps.Dependency
+	InstallIf     []packagedeps.Dependency
+	Files         []packagefiles.File
+}
+
+// entry Returns the parts of the package that it is looked up by
+func (p Package) entry() packagedb.Entry {
+	return packagedb.Entry{
+		Name:     p.Name,
+		Provides: p.Provides,
+		Depends:  p.Depends,
+		Files:    p.Files,
+	}
+}
+
+// NotFoundError will be returned when the package isn't installed
+type NotFoundError = packagedb.NotFoundError
+
+// Database Reads packages from the apk database of installed packages. The
+// database is parsed once and kept in memory until the file changes
+type Database struct {
+	// The location of the installed database. Defaults to
+	// DefaultInstalledLocation
+	InstalledLocation string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	index   *packagedb.Index[Package]
+}
+
+// Available Returns whether the installed database can be read
+func (d *Database) Available() bool {
+	_, err := os.Stat(d.installedLocation())
+
+	return err == nil
+}
+
+// Packages Returns all installed packages
+func (d *Database) Packages() ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	return d.index.Packages(), nil
+}
+
+// Package Returns a package by name
+func (d *Database) Package(name string) (Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return Package{}, err
+	}
+
+	p, ok := d.index.Package(name)
+
+	if !ok {
+		return Package{}, NotFoundError{
+			Message: fmt.Sprintf("package %v is not in %v", name, d.installedLocation()),
+		}
+	}
+
+	return p, nil
+}
+
+// Search Returns the packages that own files matching a pattern. If the
+// pattern contains shell wildcards it must match the whole path, otherwise it
+// must match exactly
+func (d *Database) Search(pattern string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	ps := d.index.Owners(pattern)
+
+	if len(ps) == 0 {
+		return nil, NotFoundError{
+			Message: fmt.Sprintf("no path found matching pattern %v", pattern),
+		}
+	}
+
+	return ps, nil
+}
+
+// WhatProvides Returns the packages that provide a capability. This can be the
+// name of a package, or something that it provides such as a shared library
+// (so:libc.musl-x86_64.so.1), a command (cmd:sh) or a path (/bin/sh)
+func (d *Database) WhatProvides(capability string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	ps := d.index.WhatProvides(capability)
+
+	if len(ps) == 0 {
+		return nil, NotFoundError{
+			Message: fmt.Sprintf("no package provides %v", capability),
+		}
+	}
+
+	return ps, nil
+}
+
+// ReverseDepends Returns the packages that depend on the named package, either
+// directly or through something that it provides
+func (d *Database) ReverseDepends(name string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	return d.index.ReverseDepends(name), nil
+}
+
+// installedLocation Returns the location of the installed database, or the
+// default
+func (d *Database) installedLocation() string {
+	if d.InstalledLocation == "" {
+		return DefaultInstalledLocation
+	}
+
+	return d.InstalledLocation
+}
+
+// refresh Re-reads the installed database if it has changed since it was last
+// read. The mutex must be held when calling this
+func (d *Database) refresh() error {
+	info, err := os.Stat(d.installedLocation())
+
+	if err != nil {
+		return err
+	}
+
+	if d.index != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return nil
+	}
+
+	file, err := os.Open(d.installedLocation())
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	packages, err := ParseInstalled(file)
+
+	if err != nil {
+		return err
+	}
+
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.index = packagedb.NewIndex(packages, Package.entry)
+
+	return nil
+}
+
+// ParseInstalled Parses the apk database of installed packages. Each package
+// is a block of lines separated by a blank line, and each line is a single
+// letter key followed by a colon and the value e.g. "P:musl". Files are listed
+// as a directory (F) followed by the files (R) that it contains. See
+// https://wiki.alpinelinux.org/wiki/Apk_spec for details
+func ParseInstalled(r io.Reader) ([]Package, error) {
+	packages := make([]Package, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var p Package
+	var directory string
+
+	done := func() {
+		if p.Name != "" {
+			packages = append(packages, p)
+		}
+
+		p = Package{}
+		directory = ""
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			done()
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+
+		if !found || len(key) != 1 {
+			return nil, fmt.Errorf("could not parse line %q, expected {key}:{value}", line)
+		}
+
+		switch key {
+		case "P":
+			p.Name = value
+		case "V":
+			p.Version = value
+		case "A":
+			p.Architecture = value
+		case "S":
+			p.Size, _ = strconv.Atoi(value)
+		case "I":
+			p.InstalledSize, _ = strconv.Atoi(value)
+		case "T":
+			p.Summary = value
+		case "U":
+			p.URL, _ = url.Parse(value)
+		case "L":
+			p.License = value
+		case "o":
+			p.Origin = value
+		case "m":
+			p.Maintainer = value
+		case "t":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				p.BuildTime = time.Unix(seconds, 0)
+			}
+		case "c":
+			p.Commit = value
+		case "D":
+			p.Depends, p.Conflicts = parseDependencies(value)
+		case "p":
+			p.Provides, _ = parseDependencies(value)
+		case "r":
+			p.Replaces, _ = parseDependencies(value)
+		case "i":
+			p.InstallIf, _ = parseDependencies(value)
+		case "F":
+			directory = "/" + value
+
+			p.Files = append(p.Files, packagefiles.File{
+				Path:      directory,
+				Directory: true,
+			})
+		case "R":
+			path := filepath.Join("/", directory, value)
+
+			// apk doesn't mark config files, instead it protects everything
+			// in /etc by writing new versions of changed files to .apk-new
+			p.Files = append(p.Files, packagefiles.File{
+				Path:     path,
+				Conffile: strings.HasPrefix(path, "/etc/"),
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	done()
+
+	return packages, nil
+}
+
+// parseDependencies Parses a space separated list of dependencies e.g.
+// "so:libc.musl-x86_64.so.1 pcre2>=10.40 !nginx-mainline". Dependencies
+// starting with ! are conflicts, and are returned separately
+func parseDependencies(field string) ([]packagedeps.Dependency, []packagedeps.Dependency) {
+	depends := make([]packagedeps.Dependency, 0)
+	conflicts := make([]packagedeps.Dependency, 0)
+
+	for _, d := range strings.Fields(field) {
+		conflict := strings.HasPrefix(d, "!")
+		d = strings.TrimPrefix(d, "!")
+
+		dep := packagedeps.ParseConstraint(d)
+
+		if conflict {
+			conflicts = append(conflicts, dep)
+		} else {
+			depends = append(depends, dep)
+		}
+	}
+
+	return depends, conflicts
+}
@@ -0,0 +1,226 @@
+package apk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+func TestParseInstalled(t *testing.T) {
+	f, err := os.Open("test/installed")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	packages, err := ParseInstalled(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 5 {
+		t.Fatalf("expected 5 packages, got %v", len(packages))
+	}
+
+	musl := packages[0]
+
+	if musl.Name != "musl" || musl.Version != "1.2.4-r2" || musl.Architecture != "x86_64" || musl.InstalledSize != 622592 || musl.License != "MIT" {
+		t.Errorf("unexpected package %+v", musl)
+	}
+
+	if !musl.BuildTime.Equal(time.Unix(1698229282, 0)) {
+		t.Errorf("unexpected build time %v", musl.BuildTime)
+	}
+
+	if musl.URL == nil || musl.URL.Host != "musl.libc.org" {
+		t.Errorf("unexpected url %v", musl.URL)
+	}
+
+	expectedFiles := []packagefiles.File{
+		{Path: "/lib", Directory: true},
+		{Path: "/lib/ld-musl-x86_64.so.1"},
+		{Path: "/lib/libc.musl-x86_64.so.1"},
+	}
+
+	if !reflect.DeepEqual(musl.Files, expectedFiles) {
+		t.Errorf("expected files %+v, got %+v", expectedFiles, musl.Files)
+	}
+
+	busybox := packages[1]
+
+	if len(busybox.Files) != 7 || !busybox.Files[4].Conffile || busybox.Files[4].Path != "/etc/udhcpd.conf" {
+		t.Errorf("unexpected files %+v", busybox.Files)
+	}
+
+	nginx := packages[3]
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "/bin/sh"},
+		{Name: "so:libc.musl-x86_64.so.1"},
+		{Name: "so:libcrypto.so.3"},
+		{Name: "so:libpcre2-8.so.0"},
+		{Name: "so:libssl.so.3"},
+		{Name: "so:libz.so.1"},
+		{Name: "pcre2", Operator: ">=", Version: "10.40"},
+	}
+
+	if !reflect.DeepEqual(nginx.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, nginx.Depends)
+	}
+
+	if expected := []packagedeps.Dependency{{Name: "nginx-mainline"}}; !reflect.DeepEqual(nginx.Conflicts, expected) {
+		t.Errorf("expected conflicts %+v, got %+v", expected, nginx.Conflicts)
+	}
+
+	if expected := []packagedeps.Dependency{{Name: "cmd:nginx", Operator: "=", Version: "1.24.0-r15"}}; !reflect.DeepEqual(nginx.Provides, expected) {
+		t.Errorf("expected provides %+v, got %+v", expected, nginx.Provides)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	db := Database{
+		InstalledLocation: "test/installed",
+	}
+
+	names := func(ps []Package) []string {
+		n := make([]string, 0)
+
+		for _, p := range ps {
+			n = append(n, p.Name)
+		}
+
+		return n
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		if p, err := db.Package("pcre2"); err != nil || p.Version != "10.42-r1" {
+			t.Errorf("unexpected package %+v, error %v", p, err)
+		}
+
+		if _, err := db.Package("notapackage"); err == nil {
+			t.Error("expected an error")
+		} else if _, ok := err.(NotFoundError); !ok {
+			t.Errorf("expected NotFoundError, got %T", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tests := map[string][]string{
+			"/usr/sbin/nginx":  {"nginx"},
+			"/lib/*":           {"musl"},
+			"/etc/*":           {"busybox"},
+			"/etc/*/*":         {"busybox", "nginx"},
+			"/usr/lib":         nil,
+			"/usr/sbin/apache": nil,
+		}
+
+		for pattern, expected := range tests {
+			ps, err := db.Search(pattern)
+
+			if expected == nil {
+				if _, ok := err.(NotFoundError); !ok {
+					t.Errorf("%v: expected NotFoundError, got %v", pattern, err)
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%v: %v", pattern, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", pattern, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("WhatProvides", func(t *testing.T) {
+		tests := map[string][]string{
+			"so:libc.musl-x86_64.so.1": {"musl"},
+			"/bin/sh":                  {"busybox-binsh"},
+			"pcre2":                    {"pcre2"},
+		}
+
+		for capability, expected := range tests {
+			ps, err := db.WhatProvides(capability)
+
+			if err != nil {
+				t.Errorf("%v: %v", capability, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", capability, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("ReverseDepends", func(t *testing.T) {
+		tests := map[string][]string{
+			"musl":          {"busybox", "pcre2", "nginx"},
+			"busybox-binsh": {"nginx"},
+		}
+
+		for name, expected := range tests {
+			ps, err := db.ReverseDepends(name)
+
+			if err != nil {
+				t.Errorf("%v: %v", name, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", name, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		installed := filepath.Join(t.TempDir(), "installed")
+		original, err := os.ReadFile("test/installed")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(installed, original, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db := Database{
+			InstalledLocation: installed,
+		}
+
+		if ps, _ := db.Packages(); len(ps) != 5 {
+			t.Fatalf("expected 5 packages, got %v", len(ps))
+		}
+
+		updated := string(original) + "\nP:newpkg\nV:2.0-r0\n"
+
+		if err = os.WriteFile(installed, []byte(updated), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Make sure that the mtime changes even on filesystems with coarse
+		// timestamps
+		future := time.Now().Add(time.Minute)
+
+		if err = os.Chtimes(installed, future, future); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Package("newpkg"); err != nil {
+			t.Errorf("expected newpkg to be found after the database changed: %v", err)
+		}
+	})
+}
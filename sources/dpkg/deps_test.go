@@ -0,0 +1,128 @@
+package dpkg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/overmindtech/sdp-go"
+)
+
+func testPackages(t *testing.T) []Package {
+	b, err := os.ReadFile("test/dpkg-query")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := parseDpkgOutput(string(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packages
+}
+
+func TestParseDependencies(t *testing.T) {
+	packages := testPackages(t)
+
+	if len(packages) != 5 {
+		t.Fatalf("expected 5 packages, got %v", len(packages))
+	}
+
+	openssl := packages[2]
+
+	if openssl.Name != "openssl" || len(openssl.Depends) != 2 {
+		t.Fatalf("unexpected package %v with dependencies %v", openssl.Name, openssl.Depends)
+	}
+
+	if d := openssl.Depends[1]; d.Name != "libssl3" || d.Constraint() != ">= 3.0.9" {
+		t.Errorf("unexpected dependency %+v", d)
+	}
+
+	if openssl.Summary != "Secure Sockets Layer toolkit - cryptographic utility" {
+		t.Errorf("unexpected summary %v", openssl.Summary)
+	}
+
+	postfix := packages[3]
+
+	if len(postfix.PreDepends) != 1 || len(postfix.Recommends) != 1 || len(postfix.Conflicts) != 2 || len(postfix.Provides) != 2 {
+		t.Errorf("unexpected relationships for postfix: %+v", postfix)
+	}
+}
+
+func TestReverseDepends(t *testing.T) {
+	packages := testPackages(t)
+
+	t.Run("direct", func(t *testing.T) {
+		rdepends := reverseDepends(packages, "libssl3")
+
+		var names []string
+
+		for _, p := range rdepends {
+			names = append(names, p.Name)
+		}
+
+		if len(names) != 2 || names[0] != "openssl" || names[1] != "postfix" {
+			t.Errorf("expected openssl and postfix, got %v", names)
+		}
+	})
+
+	t.Run("virtual", func(t *testing.T) {
+		// bsd-mailx depends on mail-transport-agent, which postfix provides
+		rdepends := reverseDepends(packages, "postfix")
+
+		if len(rdepends) != 1 || rdepends[0].Name != "bsd-mailx" {
+			t.Errorf("expected bsd-mailx, got %v", rdepends)
+		}
+	})
+}
+
+func TestWhatProvides(t *testing.T) {
+	packages := testPackages(t)
+
+	// bsd-mailx depends on mail-transport-agent, which postfix provides
+	if provides := whatProvides(packages, "mail-transport-agent"); len(provides) != 1 || provides[0].Name != "postfix" {
+		t.Errorf("expected postfix, got %v", provides)
+	}
+
+	if provides := whatProvides(packages, "openssl"); len(provides) != 1 || provides[0].Name != "openssl" {
+		t.Errorf("expected openssl, got %v", provides)
+	}
+
+	if provides := whatProvides(packages, "notapackage"); len(provides) != 0 {
+		t.Errorf("expected no packages, got %v", provides)
+	}
+}
+
+func TestMapPackageDependencies(t *testing.T) {
+	packages := testPackages(t)
+
+	item, err := mapPackageToItem(packages[3], nil, 0, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	depends, _ := item.Attributes.Get("depends")
+
+	if d, ok := depends.([]interface{}); !ok || len(d) != 9 {
+		t.Fatalf("expected 9 dependencies, got %v", depends)
+	}
+
+	var foundPreDepends bool
+
+	for _, r := range item.LinkedItemRequests {
+		if r.Type == "package" && r.Method == sdp.RequestMethod_SEARCH && r.Query == "provides:init-system-helpers" {
+			foundPreDepends = true
+		}
+
+		if r.Query == "default-mta" {
+			t.Error("conflicts should not be linked")
+		}
+	}
+
+	if !foundPreDepends {
+		t.Error("expected a link to init-system-helpers")
+	}
+}
@@ -0,0 +1,90 @@
+package dpkg
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Diversion A file that has been moved out of the way with dpkg-divert so
+// that another package (or the administrator) can install its own version
+// e.g. /bin/sh is diverted to /bin/sh.distrib by dash
+type Diversion struct {
+	// The path that the file is usually installed to
+	From string
+
+	// Where the file is installed instead
+	To string
+
+	// The package that added the diversion, which installs its own file at
+	// From. This is ":" for diversions that were added by the administrator
+	Package string
+}
+
+// Path Returns where the file at a diverted path that is owned by a given
+// package is actually installed. This is To for every package apart from the
+// one that added the diversion
+func (d Diversion) Path(owner string) string {
+	if d.Package == owner {
+		return d.From
+	}
+
+	return d.To
+}
+
+// ReadDiversions Reads the diversions file, which is next to the info
+// directory (i.e. /var/lib/dpkg/diversions), and returns the diversions keyed
+// by the path that they divert. If there aren't any diversions the file
+// doesn't exist, so an empty map is returned
+func ReadDiversions(infoLocation string) (map[string]Diversion, error) {
+	if infoLocation == "" {
+		infoLocation = DefaultInfoLocation
+	}
+
+	file, err := os.Open(filepath.Join(filepath.Dir(infoLocation), "diversions"))
+
+	if os.IsNotExist(err) {
+		return map[string]Diversion{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseDiversions(file)
+}
+
+// ParseDiversions Parses the dpkg diversions file. Each diversion is three
+// lines: the path that is diverted, where it is diverted to and the package
+// that diverted it e.g.
+//
+//	/bin/sh
+//	/bin/sh.distrib
+//	dash
+func ParseDiversions(r io.Reader) (map[string]Diversion, error) {
+	diversions := make(map[string]Diversion)
+	lines := make([]string, 0, 3)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+
+		if len(lines) < 3 {
+			continue
+		}
+
+		diversions[lines[0]] = Diversion{
+			From:    lines[0],
+			To:      lines[1],
+			Package: lines[2],
+		}
+
+		lines = lines[:0]
+	}
+
+	return diversions, scanner.Err()
+}
@@ -3,13 +3,48 @@ package dpkg
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 
 	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
 	"github.com/overmindtech/sdp-go"
 )
 
-// DpkgSource struct on which all methods are registered
-type DpkgSource struct{}
+// DpkgSource struct on which all methods are registered. Packages are read
+// directly from the dpkg status database if it exists, otherwise dpkg-query is
+// used. Whether updates are available is read from the indexes that apt has
+// already downloaded
+type DpkgSource struct {
+	// The location of the dpkg status database. Defaults to
+	// DefaultStatusLocation
+	StatusLocation string
+
+	// The location of the dpkg info directory, which contains the files
+	// owned by each package. Defaults to DefaultInfoLocation
+	InfoLocation string
+
+	// The maximum number of files that each package links to. Zero uses
+	// packagefiles.DefaultLinkLimit and a negative number disables links
+	FileLinkLimit int
+
+	// Whether to verify the files owned by each package against the checksums
+	// recorded when it was installed. This reads every file so is disabled by
+	// default
+	Verify bool
+
+	// The location of the package indexes downloaded by apt, which are used
+	// to find updates. Defaults to DefaultListsLocation
+	ListsLocation string
+
+	database     *Database
+	databaseOnce sync.Once
+	lists        *Lists
+	listsOnce    sync.Once
+}
 
 // Type is the type of items that this returns (Required)
 func (s *DpkgSource) Type() string {
@@ -50,12 +85,17 @@ func (s *DpkgSource) Get(ctx context.Context, itemContext string, query string)
 	var p Package
 	var err error
 
-	p, err = Show(ctx, query)
+	if db := s.db(); db != nil {
+		p, err = db.Package(query)
+	} else {
+		p, err = Show(ctx, query)
+	}
 
 	if e, ok := err.(NotFoundError); ok {
 		return nil, &sdp.ItemRequestError{
 			ErrorType:   sdp.ItemRequestError_NOTFOUND,
 			ErrorString: e.Error(),
+			Context:     itemContext,
 		}
 	}
 
@@ -63,7 +103,7 @@ func (s *DpkgSource) Get(ctx context.Context, itemContext string, query string)
 		return nil, err
 	}
 
-	return mapPackageToItem(p)
+	return s.mapPackageToItem(ctx, p, s.candidates())
 }
 
 // Find Gets information about all item that the source can possibly find. If
@@ -82,14 +122,16 @@ func (s *DpkgSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item,
 	var item *sdp.Item
 	var items []*sdp.Item
 
-	ps, err = ShowAll(ctx)
+	ps, err = s.all(ctx)
 
 	if err != nil {
 		return nil, err
 	}
 
+	candidates := s.candidates()
+
 	for _, p := range ps {
-		item, err = mapPackageToItem(p)
+		item, err = s.mapPackageToItem(ctx, p, candidates)
 
 		if err == nil {
 			items = append(items, item)
@@ -99,8 +141,15 @@ func (s *DpkgSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item,
 	return items, nil
 }
 
-// Search takes a file name or binary name and runs this through rpm -q
-// --whatprovides
+// Search takes a file name and runs this through dpkg-query --search. If the
+// query is in the format rdepends:{name} it instead returns the packages that
+// depend on the given package, and if it is in the format provides:{name} it
+// returns the packages that are called it or provide it, which is how
+// dependencies are linked. The query integrity:failed verifies all
+// packages and returns only those with modified or missing files, regardless
+// of whether Verify is enabled. The queries update:available and
+// update:security return the packages that have an update, or a security
+// update, available
 func (s *DpkgSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
 	if itemContext != util.LocalContext {
 		return nil, &sdp.ItemRequestError{
@@ -115,14 +164,34 @@ func (s *DpkgSource) Search(ctx context.Context, itemContext string, query strin
 	var item *sdp.Item
 	var items []*sdp.Item
 
-	ps, err = Search(ctx, query)
+	if query == "integrity:failed" {
+		return s.integrityFailures(ctx)
+	}
+
+	if query == "update:available" || query == "update:security" {
+		return s.updates(ctx, query == "update:security")
+	}
+
+	if strings.HasPrefix(query, "rdepends:") {
+		ps, err = s.all(ctx)
+		ps = reverseDepends(ps, strings.TrimPrefix(query, "rdepends:"))
+	} else if strings.HasPrefix(query, "provides:") {
+		ps, err = s.all(ctx)
+		ps = whatProvides(ps, strings.TrimPrefix(query, "provides:"))
+	} else if db := s.db(); db != nil {
+		ps, err = db.Search(query)
+	} else {
+		ps, err = Search(ctx, query)
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	candidates := s.candidates()
+
 	for _, p := range ps {
-		item, err = mapPackageToItem(p)
+		item, err = s.mapPackageToItem(ctx, p, candidates)
 
 		if err == nil {
 			items = append(items, item)
@@ -133,11 +202,189 @@ func (s *DpkgSource) Search(ctx context.Context, itemContext string, query strin
 }
 
 func (s *DpkgSource) Supported() bool {
-	return Supported()
+	return Supported() || s.db() != nil
+}
+
+// db Returns the status database, or nil if it doesn't exist in which case
+// dpkg-query should be used instead
+func (s *DpkgSource) db() *Database {
+	s.databaseOnce.Do(func() {
+		db := Database{
+			StatusLocation: s.StatusLocation,
+			InfoLocation:   s.InfoLocation,
+		}
+
+		if db.Available() {
+			s.database = &db
+		}
+	})
+
+	return s.database
+}
+
+// candidates Returns the candidate version of each package that is available
+// from the apt repositories, or nil if there aren't any indexes to read them
+// from
+func (s *DpkgSource) candidates() *packageupdates.Index {
+	s.listsOnce.Do(func() {
+		s.lists = &Lists{
+			Location: s.ListsLocation,
+		}
+	})
+
+	index, err := s.lists.Candidates()
+
+	if err != nil {
+		return nil
+	}
+
+	return index
+}
+
+// all Returns all packages
+func (s *DpkgSource) all(ctx context.Context) ([]Package, error) {
+	if db := s.db(); db != nil {
+		return db.Packages()
+	}
+
+	return ShowAll(ctx)
+}
+
+// files Returns the files owned by a package. When the status database is
+// being read the lists in the info directory are the only source of files,
+// since dpkg-query reads the same lists, so it isn't run for packages that
+// don't have one (e.g. those in the config-files state). This avoids running
+// dpkg-query once for each of these packages
+func (s *DpkgSource) files(ctx context.Context, p Package) ([]packagefiles.File, error) {
+	if s.db() != nil {
+		return ReadFiles(s.InfoLocation, p.Name, p.Architecture)
+	}
+
+	return Files(ctx, s.InfoLocation, p.Name, p.Architecture)
+}
+
+// mapPackageToItem Converts a package to an item, including the files that it
+// owns and whether it can be updated using the given candidates
+func (s *DpkgSource) mapPackageToItem(ctx context.Context, p Package, candidates *packageupdates.Index) (*sdp.Item, error) {
+	// Packages that aren't fully installed might not have a list of files,
+	// in which case the item is still returned without them
+	files, _ := s.files(ctx, p)
+
+	var problems []packagefiles.Problem
+
+	if s.Verify {
+		problems = s.verify(p)
+	}
+
+	return mapPackageToItem(p, files, s.FileLinkLimit, problems, packageUpdate(candidates, p))
+}
+
+// integrityFailures Verifies all packages and returns items for the ones that
+// have failed
+func (s *DpkgSource) integrityFailures(ctx context.Context) ([]*sdp.Item, error) {
+	ps, err := s.all(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := s.candidates()
+	items := make([]*sdp.Item, 0)
+
+	for _, p := range ps {
+		problems := s.verify(p)
+
+		if !packagefiles.Failed(problems) {
+			continue
+		}
+
+		files, _ := s.files(ctx, p)
+		item, err := mapPackageToItem(p, files, s.FileLinkLimit, problems, packageUpdate(candidates, p))
+
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// updates Returns items for the packages that have an update available. If
+// security is true only security updates are included. Returns an error if
+// there aren't any indexes, since then it isn't known which packages can be
+// updated
+func (s *DpkgSource) updates(ctx context.Context, security bool) ([]*sdp.Item, error) {
+	candidates := s.candidates()
+
+	if candidates == nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: fmt.Sprintf("no package indexes found in %v, run apt update to download them", s.lists.location()),
+			Context:     util.LocalContext,
+		}
+	}
+
+	ps, err := s.all(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, p := range ps {
+		update := packageUpdate(candidates, p)
+
+		if !update.Available || (security && !update.Security()) {
+			continue
+		}
+
+		item, err := s.mapPackageToItem(ctx, p, candidates)
+
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
 }
 
-func mapPackageToItem(p Package) (*sdp.Item, error) {
-	attributes, err := sdp.ToAttributes(map[string]interface{}{
+// packageUpdate Returns whether a package can be updated. Packages that
+// aren't architecture specific are in the indexes with the architecture all,
+// which is also checked in case a package has changed to be architecture
+// independent in a newer version
+func packageUpdate(candidates *packageupdates.Index, p Package) *packageupdates.Update {
+	return candidates.Update(p.Name, p.Version, p.Architecture, "all")
+}
+
+// verify Verifies the files of a package. Packages without an md5sums file
+// (such as metapackages) have nothing to verify so return no problems. If the
+// package can't be verified for any other reason this returns nil
+func (s *DpkgSource) verify(p Package) []packagefiles.Problem {
+	diversions, err := ReadDiversions(s.InfoLocation)
+
+	if err != nil {
+		return nil
+	}
+
+	problems, err := Verify(s.InfoLocation, "/", p.Name, p.Architecture, diversions)
+
+	if os.IsNotExist(err) {
+		return []packagefiles.Problem{}
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	return problems
+}
+
+// mapPackageToItem Converts a package to an item. If problems is not nil the
+// package has been verified and the results are included, and if update is
+// not nil so is whether it can be updated
+func mapPackageToItem(p Package, files []packagefiles.File, fileLinkLimit int, problems []packagefiles.Problem, update *packageupdates.Update) (*sdp.Item, error) {
+	attrMap := map[string]interface{}{
 		"name":         p.Name,
 		"status":       p.Status,
 		"priority":     p.Priority,
@@ -149,16 +396,54 @@ func mapPackageToItem(p Package) (*sdp.Item, error) {
 		"url":          p.Homepage,
 		"summary":      p.Summary,
 		"description":  p.Description,
-	})
+		"depends":      packagedeps.Attributes(p.Depends),
+		"preDepends":   packagedeps.Attributes(p.PreDepends),
+		"recommends":   packagedeps.Attributes(p.Recommends),
+		"conflicts":    packagedeps.Attributes(p.Conflicts),
+		"provides":     packagedeps.Attributes(p.Provides),
+	}
+
+	if files != nil {
+		for k, v := range packagefiles.Attributes(files) {
+			attrMap[k] = v
+		}
+	}
+
+	if problems != nil {
+		for k, v := range packagefiles.VerificationAttributes(problems) {
+			attrMap[k] = v
+		}
+	}
+
+	if update != nil {
+		for k, v := range packageupdates.Attributes(update) {
+			attrMap[k] = v
+		}
+	}
+
+	attributes, err := sdp.ToAttributes(attrMap)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Link to the packages that this one depends on. These are searched for
+	// since the dependency might be a virtual package that is provided by
+	// another one. Conflicts aren't linked since they can't be installed at
+	// the same time
+	linkedItemRequests := packagedeps.LinkedItemRequests(sdp.RequestMethod_SEARCH, nil, p.PreDepends, p.Depends, p.Recommends)
+
+	for _, r := range linkedItemRequests {
+		r.Query = "provides:" + r.Query
+	}
+
+	linkedItemRequests = append(linkedItemRequests, packagefiles.LinkedItemRequests(files, fileLinkLimit)...)
+
 	return &sdp.Item{
-		Type:            "package",
-		UniqueAttribute: "name",
-		Attributes:      attributes,
-		Context:         util.LocalContext,
+		Type:               "package",
+		UniqueAttribute:    "name",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: linkedItemRequests,
 	}, nil
 }
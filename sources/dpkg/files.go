@@ -0,0 +1,159 @@
+package dpkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// DefaultInfoLocation The default location of the dpkg info directory, which
+// contains the list of files and conffiles for each package
+const DefaultInfoLocation = "/var/lib/dpkg/info"
+
+// Files Returns the files that are owned by a package. These are read from
+// the info directory using ReadFiles, falling back to `dpkg-query -L` if the
+// list can't be read
+func Files(ctx context.Context, infoLocation string, name string, arch string) ([]packagefiles.File, error) {
+	files, err := ReadFiles(infoLocation, name, arch)
+
+	if err == nil {
+		return files, nil
+	}
+
+	paths, conffiles, err := queryFiles(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapFiles(paths, conffiles), nil
+}
+
+// ReadFiles Returns the files that are owned by a package from {name}.list
+// and {name}.conffiles in the info directory, without running dpkg-query.
+// Packages that are installed for more than one architecture use
+// {name}:{arch} as the file name. Returns an error if there is no list, e.g.
+// for packages that have been removed but still have config files
+func ReadFiles(infoLocation string, name string, arch string) ([]packagefiles.File, error) {
+	if infoLocation == "" {
+		infoLocation = DefaultInfoLocation
+	}
+
+	var paths []string
+	var conffiles []string
+	var err error
+
+	for _, base := range []string{fmt.Sprintf("%v:%v", name, arch), name} {
+		paths, err = readLines(filepath.Join(infoLocation, base+".list"))
+
+		if err == nil {
+			// Not all packages have conffiles
+			conffiles, _ = readLines(filepath.Join(infoLocation, base+".conffiles"))
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mapFiles(paths, conffiles), nil
+}
+
+// mapFiles Converts the paths owned by a package to files, marking which are
+// conffiles and which are directories
+func mapFiles(paths []string, conffiles []string) []packagefiles.File {
+	isConffile := make(map[string]bool)
+
+	for _, c := range conffiles {
+		isConffile[c] = true
+	}
+
+	files := make([]packagefiles.File, 0, len(paths))
+
+	for _, path := range paths {
+		files = append(files, packagefiles.File{
+			Path:     path,
+			Conffile: isConffile[path],
+		})
+	}
+
+	packagefiles.MarkDirectories(files)
+
+	return files
+}
+
+// queryFiles Gets the files and conffiles of a package using dpkg-query
+func queryFiles(ctx context.Context, name string) ([]string, []string, error) {
+	args := []string{"-L", name}
+	output, err := exec.CommandContext(ctx, "dpkg-query", args...).Output()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error running command `dpkg-query %v`: %v", strings.Join(args, " "), err)
+	}
+
+	paths, err := ParseList(strings.NewReader(string(output)))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args = []string{"--show", "-f", `${Conffiles}\n`, name}
+	output, err = exec.CommandContext(ctx, "dpkg-query", args...).Output()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error running command `dpkg-query %v`: %v", strings.Join(args, " "), err)
+	}
+
+	return paths, ParseConffiles(string(output)), nil
+}
+
+// ParseList Parses a list of files as found in the info directory or returned
+// by `dpkg-query -L`, one absolute path per line. Anything else (such as the
+// diversion notes that dpkg-query prints) is ignored
+func ParseList(r io.Reader) ([]string, error) {
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "/") {
+			paths = append(paths, line)
+		}
+	}
+
+	return paths, scanner.Err()
+}
+
+// ParseConffiles Parses the ${Conffiles} field from dpkg-query. Each line is
+// in the format " {path} {md5sum}", optionally followed by "obsolete"
+func ParseConffiles(field string) []string {
+	conffiles := make([]string, 0)
+
+	for _, line := range strings.Split(field, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && strings.HasPrefix(fields[0], "/") {
+			conffiles = append(conffiles, fields[0])
+		}
+	}
+
+	return conffiles
+}
+
+// readLines Reads a file from the info directory
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseList(file)
+}
@@ -0,0 +1,109 @@
+package dpkg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	t.Run("with conffiles", func(t *testing.T) {
+		files, err := Files(context.Background(), "test/info", "nginx-common", "all")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		var conffiles []string
+
+		for _, f := range files {
+			if f.Directory {
+				continue
+			}
+
+			paths = append(paths, f.Path)
+
+			if f.Conffile {
+				conffiles = append(conffiles, f.Path)
+			}
+		}
+
+		if len(paths) != 7 {
+			t.Errorf("expected 7 files, got %v: %v", len(paths), paths)
+		}
+
+		expected := []string{
+			"/etc/default/nginx",
+			"/etc/init.d/nginx",
+			"/etc/nginx/nginx.conf",
+			"/etc/nginx/sites-available/default",
+		}
+
+		if !reflect.DeepEqual(conffiles, expected) {
+			t.Errorf("expected conffiles %v, got %v", expected, conffiles)
+		}
+	})
+
+	t.Run("multi-arch", func(t *testing.T) {
+		files, err := Files(context.Background(), "test/info", "libc6", "amd64")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(files) != 10 {
+			t.Errorf("expected 10 entries, got %v", len(files))
+		}
+	})
+	t.Run("without a list", func(t *testing.T) {
+		// Packages in the config-files state don't have a list. ReadFiles
+		// must not fall back to dpkg-query for these
+		_, err := ReadFiles("test/info", "not-installed", "amd64")
+
+		if err == nil {
+			t.Error("expected an error for a package without a list")
+		}
+	})
+}
+
+func TestParseConffiles(t *testing.T) {
+	field := " /etc/adduser.conf cc3493ecd2d09837ffdcc3e25fdfff18\n /etc/old.conf 11a06baf8245fd8d690b99024d228c1f obsolete\n"
+
+	expected := []string{"/etc/adduser.conf", "/etc/old.conf"}
+
+	if conffiles := ParseConffiles(field); !reflect.DeepEqual(conffiles, expected) {
+		t.Errorf("expected %v, got %v", expected, conffiles)
+	}
+}
+
+func TestMapPackageFiles(t *testing.T) {
+	files, err := Files(context.Background(), "test/info", "nginx-common", "all")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := mapPackageToItem(Package{Name: "nginx-common"}, files, 5, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fileCount, _ := item.Attributes.Get("fileCount"); fileCount != float64(7) {
+		t.Errorf("expected fileCount 7, got %v", fileCount)
+	}
+
+	if conffileCount, _ := item.Attributes.Get("conffileCount"); conffileCount != float64(4) {
+		t.Errorf("expected conffileCount 4, got %v", conffileCount)
+	}
+
+	if len(item.LinkedItemRequests) != 5 {
+		t.Fatalf("expected links to be limited to 5, got %v", len(item.LinkedItemRequests))
+	}
+
+	// Conffiles come first, then systemd units
+	if q := item.LinkedItemRequests[4].Query; q != "/lib/systemd/system/nginx.service" {
+		t.Errorf("expected the unit to be linked after the conffiles, got %v", q)
+	}
+}
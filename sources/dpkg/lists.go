@@ -0,0 +1,177 @@
+package dpkg
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
+)
+
+// DefaultListsLocation The default location of the package indexes that apt
+// downloads when running `apt update`
+const DefaultListsLocation = "/var/lib/apt/lists"
+
+// Lists Reads the versions of packages that are available from the apt
+// repositories using the Packages indexes that apt has already downloaded, so
+// that updates can be found without accessing the repositories. The indexes
+// are parsed once and kept in memory until apt downloads new ones
+type Lists struct {
+	// The directory containing the indexes. Defaults to DefaultListsLocation
+	Location string
+
+	mutex     sync.Mutex
+	signature string
+	index     *packageupdates.Index
+}
+
+// Candidates Returns the newest version of each package that is available,
+// keyed by name and architecture. Returns nil if there aren't any indexes,
+// e.g. because `apt update` hasn't been run or the lists have been deleted to
+// save space, since it isn't known which updates are available
+func (l *Lists) Candidates() (*packageupdates.Index, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	indexes, signature, err := l.indexes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(indexes) == 0 {
+		l.index = nil
+		l.signature = ""
+
+		return nil, nil
+	}
+
+	if l.index != nil && signature == l.signature {
+		return l.index, nil
+	}
+
+	index := packageupdates.Index{
+		Compare: CompareVersions,
+	}
+
+	// An index that can't be read (e.g. because apt is writing it) is left
+	// out rather than hiding the others
+	for _, path := range indexes {
+		readPackagesIndex(path, &index)
+	}
+
+	l.index = &index
+	l.signature = signature
+
+	return l.index, nil
+}
+
+// location Returns the location of the lists directory, or the default
+func (l *Lists) location() string {
+	if l.Location == "" {
+		return DefaultListsLocation
+	}
+
+	return l.Location
+}
+
+// indexes Returns the paths of the Packages indexes, along with a signature
+// made from their names, sizes and modification times that changes whenever
+// apt updates them
+func (l *Lists) indexes() ([]string, string, error) {
+	entries, err := os.ReadDir(l.location())
+
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	paths := make([]string, 0)
+	signature := new(strings.Builder)
+
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), "_Packages") && !strings.HasSuffix(e.Name(), "_Packages.gz") {
+			continue
+		}
+
+		info, err := e.Info()
+
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(l.location(), e.Name()))
+		fmt.Fprintf(signature, "%v %v %v\n", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+
+	sort.Strings(paths)
+
+	return paths, signature.String(), nil
+}
+
+// readPackagesIndex Adds the packages from a Packages index to the index of
+// candidates. The index can be gzipped if apt is configured to keep them
+// compressed (Acquire::GzipIndexes)
+func readPackagesIndex(path string, index *packageupdates.Index) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var r io.Reader = file
+
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+
+		if err != nil {
+			return err
+		}
+
+		defer gz.Close()
+
+		r = gz
+	}
+
+	suite := ListSuite(filepath.Base(path))
+	security := strings.HasSuffix(suite, "-security")
+
+	return ReadStanzas(r, func(s Stanza) bool {
+		if s["Package"] != "" && s["Version"] != "" {
+			index.Add(s["Package"], s["Architecture"], packageupdates.Candidate{
+				Version:  s["Version"],
+				Origin:   suite,
+				Security: security,
+			})
+		}
+
+		return true
+	})
+}
+
+// ListSuite Returns the suite of a file in the lists directory from its name.
+// apt names these after their URL with / replaced by _ e.g.
+// archive.ubuntu.com_ubuntu_dists_jammy-security_main_binary-amd64_Packages
+// is in the jammy-security suite. Returns an empty string for flat
+// repositories, which don't have suites
+func ListSuite(name string) string {
+	_, after, found := strings.Cut(name, "_dists_")
+
+	if !found {
+		return ""
+	}
+
+	suite, _, _ := strings.Cut(after, "_")
+
+	return suite
+}
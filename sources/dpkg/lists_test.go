@@ -0,0 +1,185 @@
+package dpkg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestListSuite(t *testing.T) {
+	tests := map[string]string{
+		"archive.ubuntu.com_ubuntu_dists_jammy-security_main_binary-amd64_Packages": "jammy-security",
+		"deb.debian.org_debian_dists_bookworm_main_binary-amd64_Packages":           "bookworm",
+		"ppa.example.com_flat_Packages":                                             "",
+	}
+
+	for name, expected := range tests {
+		if suite := ListSuite(name); suite != expected {
+			t.Errorf("expected suite of %v to be %q, got %q", name, expected, suite)
+		}
+	}
+}
+
+func TestListsCandidates(t *testing.T) {
+	lists := Lists{
+		Location: "test/lists",
+	}
+
+	index, err := lists.Candidates()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index.Len() != 4 {
+		t.Errorf("expected 4 packages, got %v", index.Len())
+	}
+
+	tests := []struct {
+		Name     string
+		Arch     string
+		Expected *packageupdates.Candidate
+	}{
+		{
+			// The security index is gzipped
+			Name:     "libc6",
+			Arch:     "amd64",
+			Expected: &packageupdates.Candidate{Version: "2.36-9+deb12u7", Origin: "bookworm-security", Security: true},
+		},
+		{
+			Name:     "libc6",
+			Arch:     "i386",
+			Expected: &packageupdates.Candidate{Version: "2.36-9+deb12u4", Origin: "bookworm"},
+		},
+		{
+			// The version in the security suite is a release candidate so
+			// is older
+			Name:     "nginx-common",
+			Arch:     "all",
+			Expected: &packageupdates.Candidate{Version: "1.22.1-9+deb12u1", Origin: "bookworm-updates"},
+		},
+	}
+
+	for _, test := range tests {
+		if c := index.Candidate(test.Name, test.Arch); !reflect.DeepEqual(c, test.Expected) {
+			t.Errorf("expected candidate for %v:%v to be %v, got %v", test.Name, test.Arch, test.Expected, c)
+		}
+	}
+
+	// The index is kept until the lists change
+	if again, _ := lists.Candidates(); again != index {
+		t.Error("expected the index to be reused")
+	}
+
+	missing := Lists{
+		Location: "test/missing",
+	}
+
+	if index, err := missing.Candidates(); index != nil || err != nil {
+		t.Errorf("expected no index and no error without lists, got %v, %v", index, err)
+	}
+}
+
+func TestUpdatesSource(t *testing.T) {
+	src := DpkgSource{
+		StatusLocation: "test/status",
+		InfoLocation:   "test/info",
+		ListsLocation:  "test/lists",
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get security update",
+			ItemContext: util.LocalContext,
+			Query:       "libc6:amd64",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"updateAvailable":   true,
+						"candidateVersion":  "2.36-9+deb12u7",
+						"candidateOrigin":   "bookworm-security",
+						"candidateSecurity": true,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get up to date",
+			ItemContext: util.LocalContext,
+			Query:       "libc6:i386",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"updateAvailable":  false,
+						"candidateVersion": "2.36-9+deb12u4",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get not in any repository",
+			ItemContext: util.LocalContext,
+			Query:       "oldpkg",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"updateAvailable": false,
+					},
+				},
+			},
+		},
+		{
+			Name:        "search updates",
+			ItemContext: util.LocalContext,
+			Query:       "update:available",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 2,
+			},
+		},
+		{
+			Name:        "search security updates",
+			ItemContext: util.LocalContext,
+			Query:       "update:security",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":         "libc6",
+						"architecture": "amd64",
+					},
+				},
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+
+	noLists := DpkgSource{
+		StatusLocation: "test/status",
+		InfoLocation:   "test/info",
+		ListsLocation:  "test/missing",
+	}
+
+	util.RunSourceTests(t, []util.SourceTest{
+		{
+			Name:        "search updates without lists",
+			ItemContext: util.LocalContext,
+			Query:       "update:available",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+	}, &noLists)
+}
@@ -0,0 +1,104 @@
+package dpkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Stanza A paragraph from a file in the Debian control (RFC-822 like) format,
+// such as /var/lib/dpkg/status or an apt Packages file. Fields are keyed by
+// name. Multi-line fields contain the first line, followed by each
+// continuation line (including its leading whitespace) separated by newlines
+type Stanza map[string]string
+
+// Lines Returns the lines of a multi-line field. The value on the same line as
+// the field name is first, followed by the continuation lines with their
+// leading whitespace removed. A continuation line that is just "." is an empty
+// line, as described in deb-control(5)
+func (s Stanza) Lines(field string) []string {
+	value, ok := s[field]
+
+	if !ok {
+		return nil
+	}
+
+	lines := strings.Split(value, "\n")
+
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+
+		if i > 0 && lines[i] == "." {
+			lines[i] = ""
+		}
+	}
+
+	return lines
+}
+
+// maxLineLength The longest line that can be parsed, this needs to be larger
+// than the default since some packages have very long dependency lists
+const maxLineLength = 1024 * 1024
+
+// ReadStanzas Reads a file in the Debian control format and calls f for each
+// stanza. If f returns false reading stops
+func ReadStanzas(r io.Reader, f func(Stanza) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	stanza := make(Stanza)
+	var field string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// A blank line ends the stanza
+		if strings.TrimSpace(line) == "" {
+			if len(stanza) > 0 && !f(stanza) {
+				return nil
+			}
+
+			stanza = make(Stanza)
+			field = ""
+
+			continue
+		}
+
+		// Continuation of a multi-line field
+		if line[0] == ' ' || line[0] == '\t' {
+			if field == "" {
+				return fmt.Errorf("continuation line %q without a field", line)
+			}
+
+			stanza[field] = stanza[field] + "\n" + line
+
+			continue
+		}
+
+		// Comments are allowed in some control files but not in the status
+		// file, so just ignore them
+		if line[0] == '#' {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+
+		if !found {
+			return fmt.Errorf("could not parse line %q, expected {field}: {value}", line)
+		}
+
+		field = name
+		stanza[field] = strings.TrimSpace(value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(stanza) > 0 {
+		f(stanza)
+	}
+
+	return nil
+}
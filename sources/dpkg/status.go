@@ -0,0 +1,310 @@
+package dpkg
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+)
+
+// DefaultStatusLocation The default location of the dpkg status database
+const DefaultStatusLocation = "/var/lib/dpkg/status"
+
+// Database Reads packages directly from the dpkg status database rather than
+// running dpkg-query. This means that it works when dpkg-query isn't
+// installed (e.g. distroless images) and is much faster. The database is
+// parsed once and kept in memory until the status file changes
+type Database struct {
+	// The location of the status file. Defaults to DefaultStatusLocation
+	StatusLocation string
+
+	// The location of the info directory, which contains the lists of files.
+	// Defaults to DefaultInfoLocation
+	InfoLocation string
+
+	mutex    sync.Mutex
+	modTime  time.Time
+	size     int64
+	packages []Package
+	byName   map[string][]int
+
+	// Map of file path to the packages that own it. This is only built when
+	// it is needed since it requires reading the list of every package
+	owners map[string][]string
+}
+
+// Available Returns whether the status database can be read
+func (d *Database) Available() bool {
+	_, err := os.Stat(d.statusLocation())
+
+	return err == nil
+}
+
+// Packages Returns all packages in the database
+func (d *Database) Packages() ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	return d.packages, nil
+}
+
+// Package Returns a package by name. The name can include an architecture
+// e.g. libc6:amd64 to select one of a package that is installed for more than
+// one architecture
+func (d *Database) Package(name string) (Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return Package{}, err
+	}
+
+	name, arch, _ := strings.Cut(name, ":")
+	indexes := d.byName[name]
+
+	if arch != "" {
+		filtered := make([]int, 0)
+
+		for _, i := range indexes {
+			if d.packages[i].Architecture == arch {
+				filtered = append(filtered, i)
+			}
+		}
+
+		indexes = filtered
+	}
+
+	switch len(indexes) {
+	case 0:
+		return Package{}, NotFoundError{
+			Message: fmt.Sprintf("package %v is not in %v", name, d.statusLocation()),
+		}
+	case 1:
+		return d.packages[indexes[0]], nil
+	default:
+		return Package{}, fmt.Errorf("found >1 package for name %v, specify the architecture e.g. %v:%v", name, name, d.packages[indexes[0]].Architecture)
+	}
+}
+
+// Search Returns the packages that own files matching a pattern, in the same
+// way as `dpkg-query --search`. If the pattern contains shell wildcards it
+// must match the whole path, otherwise absolute paths must match exactly and
+// anything else can match any part of the path
+func (d *Database) Search(pattern string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	if d.owners == nil {
+		d.owners = d.readOwners()
+	}
+
+	var match func(string) bool
+
+	switch {
+	case strings.ContainsAny(pattern, "*?["):
+		match = func(path string) bool {
+			matched, _ := filepath.Match(pattern, path)
+			return matched
+		}
+	case strings.HasPrefix(pattern, "/"):
+		match = func(path string) bool {
+			return path == pattern
+		}
+	default:
+		match = func(path string) bool {
+			return strings.Contains(path, pattern)
+		}
+	}
+
+	found := make(map[int]bool)
+
+	for path, owners := range d.owners {
+		if !match(path) {
+			continue
+		}
+
+		for _, owner := range owners {
+			name, arch, _ := strings.Cut(owner, ":")
+
+			for _, i := range d.byName[name] {
+				if arch == "" || d.packages[i].Architecture == arch {
+					found[i] = true
+				}
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, NotFoundError{
+			Message: fmt.Sprintf("no path found matching pattern %v", pattern),
+		}
+	}
+
+	indexes := make([]int, 0, len(found))
+
+	for i := range found {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	packages := make([]Package, 0, len(indexes))
+
+	for _, i := range indexes {
+		packages = append(packages, d.packages[i])
+	}
+
+	return packages, nil
+}
+
+// statusLocation Returns the location of the status file, or the default
+func (d *Database) statusLocation() string {
+	if d.StatusLocation == "" {
+		return DefaultStatusLocation
+	}
+
+	return d.StatusLocation
+}
+
+// refresh Re-reads the status file if it has changed since it was last read.
+// The mutex must be held when calling this
+func (d *Database) refresh() error {
+	info, err := os.Stat(d.statusLocation())
+
+	if err != nil {
+		return err
+	}
+
+	if d.packages != nil && info.ModTime().Equal(d.modTime) && info.Size() == d.size {
+		return nil
+	}
+
+	file, err := os.Open(d.statusLocation())
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	packages, err := ParseStatus(file)
+
+	if err != nil {
+		return err
+	}
+
+	d.packages = packages
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.byName = make(map[string][]int)
+	d.owners = nil
+
+	for i, p := range packages {
+		d.byName[p.Name] = append(d.byName[p.Name], i)
+	}
+
+	return nil
+}
+
+// readOwners Reads the list of files for every package and returns a map of
+// path to the names of the packages that own it. Packages that are installed
+// for more than one architecture are qualified e.g. libc6:amd64
+func (d *Database) readOwners() map[string][]string {
+	owners := make(map[string][]string)
+
+	infoLocation := d.InfoLocation
+
+	if infoLocation == "" {
+		infoLocation = DefaultInfoLocation
+	}
+
+	lists, _ := filepath.Glob(filepath.Join(infoLocation, "*.list"))
+
+	for _, list := range lists {
+		owner := strings.TrimSuffix(filepath.Base(list), ".list")
+		paths, err := readLines(list)
+
+		if err != nil {
+			continue
+		}
+
+		for _, path := range paths {
+			owners[path] = append(owners[path], owner)
+		}
+	}
+
+	return owners
+}
+
+// ParseStatus Parses the dpkg status database. Packages whose status is
+// not-installed (i.e. those that have been purged but are still known to
+// dpkg) are skipped in the same way as `dpkg-query --show`
+func ParseStatus(r io.Reader) ([]Package, error) {
+	packages := make([]Package, 0)
+
+	err := ReadStanzas(r, func(s Stanza) bool {
+		if p := stanzaToPackage(s); p.Name != "" && p.Status != "not-installed" {
+			packages = append(packages, p)
+		}
+
+		return true
+	})
+
+	return packages, err
+}
+
+// stanzaToPackage Converts a stanza from the status file into a package
+func stanzaToPackage(s Stanza) Package {
+	p := Package{
+		Name:         s["Package"],
+		Priority:     s["Priority"],
+		Section:      s["Section"],
+		Maintainer:   s["Maintainer"],
+		Architecture: s["Architecture"],
+		Version:      s["Version"],
+		Depends:      packagedeps.ParseDebian(s["Depends"]),
+		PreDepends:   packagedeps.ParseDebian(s["Pre-Depends"]),
+		Recommends:   packagedeps.ParseDebian(s["Recommends"]),
+		Conflicts:    packagedeps.ParseDebian(s["Conflicts"]),
+		Provides:     packagedeps.ParseDebian(s["Provides"]),
+	}
+
+	// The status is in the format "{want} {flag} {status}" e.g. "install ok
+	// installed", the same as ${db:Status-Status} only includes the last part
+	if fields := strings.Fields(s["Status"]); len(fields) == 3 {
+		p.Status = fields[2]
+	}
+
+	if size, err := strconv.Atoi(s["Installed-Size"]); err == nil {
+		p.InstalledSize = size
+	}
+
+	if u, err := url.Parse(s["Homepage"]); err == nil {
+		p.Homepage = u
+	}
+
+	// The first line of the description is the summary and the rest is the
+	// extended description
+	if lines := s.Lines("Description"); len(lines) > 0 {
+		p.Summary = lines[0]
+		p.Description = strings.Join(lines[1:], "\n")
+	}
+
+	return p
+}
@@ -0,0 +1,328 @@
+package dpkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestReadStanzas(t *testing.T) {
+	input := "Package: a\nDescription: summary\n line one\n .\n line two\n\n\nPackage: b\nVersion: 1.0\n"
+
+	var stanzas []Stanza
+
+	err := ReadStanzas(strings.NewReader(input), func(s Stanza) bool {
+		stanzas = append(stanzas, s)
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stanzas) != 2 {
+		t.Fatalf("expected 2 stanzas, got %v", len(stanzas))
+	}
+
+	if lines := stanzas[0].Lines("Description"); len(lines) != 4 || lines[0] != "summary" || lines[3] != "line two" {
+		t.Errorf("unexpected description %q", lines)
+	}
+
+	// The " ." line separates paragraphs so is empty
+	if lines := stanzas[0].Lines("Description"); len(lines) == 4 && lines[2] != "" {
+		t.Errorf("expected an empty line between paragraphs, got %q", lines[2])
+	}
+
+	if stanzas[1]["Version"] != "1.0" {
+		t.Errorf("unexpected stanza %v", stanzas[1])
+	}
+
+	if err := ReadStanzas(strings.NewReader(" orphaned continuation\n"), func(Stanza) bool { return true }); err == nil {
+		t.Error("expected error for continuation without a field")
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	f, err := os.Open("test/status")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	packages, err := ParseStatus(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The purged package should be skipped
+	if len(packages) != 4 {
+		t.Fatalf("expected 4 packages, got %v", len(packages))
+	}
+
+	nginx := packages[2]
+
+	if nginx.Name != "nginx-common" || nginx.Status != "installed" || nginx.InstalledSize != 1097 || nginx.Version != "1.22.1-9" {
+		t.Errorf("unexpected package %+v", nginx)
+	}
+
+	if nginx.Summary != "small, powerful, scalable web/proxy server - common files" {
+		t.Errorf("unexpected summary %v", nginx.Summary)
+	}
+
+	if !strings.HasPrefix(nginx.Description, "Nginx (\"engine X\")") || !strings.Contains(nginx.Description, "mail servers.\n\nThis package") {
+		t.Errorf("unexpected description %q", nginx.Description)
+	}
+
+	if nginx.Homepage == nil || nginx.Homepage.Host != "nginx.org" {
+		t.Errorf("unexpected homepage %v", nginx.Homepage)
+	}
+
+	if len(nginx.Depends) != 2 || len(nginx.Depends[0].Alternatives) != 1 {
+		t.Errorf("unexpected dependencies %+v", nginx.Depends)
+	}
+
+	if packages[3].Status != "config-files" {
+		t.Errorf("expected config-files status, got %v", packages[3].Status)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	db := Database{
+		StatusLocation: "test/status",
+		InfoLocation:   "test/info",
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		p, err := db.Package("nginx-common")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Version != "1.22.1-9" {
+			t.Errorf("unexpected version %v", p.Version)
+		}
+
+		if p, err := db.Package("libc6:i386"); err != nil || p.InstalledSize != 12072 {
+			t.Errorf("unexpected package %+v, error %v", p, err)
+		}
+
+		if _, err := db.Package("libc6"); err == nil {
+			t.Error("expected an error for an ambiguous name")
+		}
+
+		if _, err := db.Package("purgedpkg"); err == nil {
+			t.Error("expected an error for a purged package")
+		} else if _, ok := err.(NotFoundError); !ok {
+			t.Errorf("expected NotFoundError, got %T", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tests := map[string][]string{
+			"/etc/nginx/nginx.conf":            {"nginx-common"},
+			"x86_64-linux-gnu/libc.so":         {"libc6:amd64"},
+			"/usr/share/doc/*/copyright":       {"libc6:amd64", "libc6:i386", "nginx-common"},
+			"/lib/i386-linux-gnu/libc.so.6":    {"libc6:i386"},
+			"/usr/share/doc/libc6/copyright":   {"libc6:amd64", "libc6:i386"},
+			"/var/www/html/index.nginx-debian": nil,
+		}
+
+		for pattern, expected := range tests {
+			packages, err := db.Search(pattern)
+
+			if expected == nil {
+				if _, ok := err.(NotFoundError); !ok {
+					t.Errorf("%v: expected NotFoundError, got %v", pattern, err)
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%v: %v", pattern, err)
+				continue
+			}
+
+			var names []string
+
+			for _, p := range packages {
+				name := p.Name
+
+				if p.Architecture != "all" {
+					name = name + ":" + p.Architecture
+				}
+
+				names = append(names, name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(expected, ",") {
+				t.Errorf("%v: expected %v, got %v", pattern, expected, names)
+			}
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		status := filepath.Join(t.TempDir(), "status")
+		original, err := os.ReadFile("test/status")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(status, original, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db := Database{
+			StatusLocation: status,
+			InfoLocation:   "test/info",
+		}
+
+		if packages, _ := db.Packages(); len(packages) != 4 {
+			t.Fatalf("expected 4 packages, got %v", len(packages))
+		}
+
+		updated := string(original) + "\nPackage: newpkg\nStatus: install ok installed\nVersion: 2.0\n"
+
+		if err = os.WriteFile(status, []byte(updated), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Make sure that the mtime changes even on filesystems with coarse
+		// timestamps
+		future := time.Now().Add(time.Minute)
+
+		if err = os.Chtimes(status, future, future); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Package("newpkg"); err != nil {
+			t.Errorf("expected newpkg to be found after the status file changed: %v", err)
+		}
+	})
+}
+
+func TestDatabaseSource(t *testing.T) {
+	src := DpkgSource{
+		StatusLocation: "test/status",
+		InfoLocation:   "test/info",
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get",
+			ItemContext: util.LocalContext,
+			Query:       "nginx-common",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"version":       "1.22.1-9",
+						"conffileCount": float64(4),
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing",
+			ItemContext: util.LocalContext,
+			Query:       "notapackage",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type:    sdp.ItemRequestError_NOTFOUND,
+				Context: util.LocalContext,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search file",
+			ItemContext: util.LocalContext,
+			Query:       "/etc/nginx/nginx.conf",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search provides",
+			ItemContext: util.LocalContext,
+			Query:       "provides:nginx-common",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search file name",
+			ItemContext: util.LocalContext,
+			Query:       "nginx.conf",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search rdepends",
+			ItemContext: util.LocalContext,
+			Query:       "rdepends:libgcc-s1",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 2,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+// TestDatabaseMatchesDpkgQuery Makes sure that the native parser returns the
+// same packages as dpkg-query on systems that have it
+func TestDatabaseMatchesDpkgQuery(t *testing.T) {
+	if !Supported() {
+		t.Skip("dpkg-query not present, skipping tests")
+	}
+
+	db := Database{}
+
+	if !db.Available() {
+		t.Skip("dpkg status database not present, skipping tests")
+	}
+
+	native, err := db.Packages()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !HasPackages() {
+		t.Skip("dpkg-query has no packages, skipping test")
+	}
+
+	p, err := Show(context.Background(), native[0].Name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Version != native[0].Version || p.Status != native[0].Status || p.Summary != native[0].Summary {
+		t.Errorf("native package %+v does not match dpkg-query %+v", native[0], p)
+	}
+}
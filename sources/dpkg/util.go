@@ -8,10 +8,12 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
 )
 
 // QueryFormat is used when querying the dpkg database
-const QueryFormat = `${Package}\n${db:Status-Status}\n${Priority}\n${Section}\n${Installed-Size}\n${Maintainer}\n${Architecture}\n${Version}\n${Homepage}\n${binary:Summary}\n${Description}\n---OVERMINDEND---\n`
+const QueryFormat = `${Package}\n${db:Status-Status}\n${Priority}\n${Section}\n${Installed-Size}\n${Maintainer}\n${Architecture}\n${Version}\n${Homepage}\n${Depends}\n${Pre-Depends}\n${Recommends}\n${Conflicts}\n${Provides}\n${binary:Summary}\n${Description}\n---OVERMINDEND---\n`
 
 // Package represents information about an dpkg package
 type Package struct {
@@ -24,6 +26,11 @@ type Package struct {
 	Architecture  string
 	Version       string
 	Homepage      *url.URL
+	Depends       []packagedeps.Dependency
+	PreDepends    []packagedeps.Dependency
+	Recommends    []packagedeps.Dependency
+	Conflicts     []packagedeps.Dependency
+	Provides      []packagedeps.Dependency
 	Summary       string
 	Description   string
 }
@@ -168,26 +175,124 @@ func Search(ctx context.Context, pattern string) ([]Package, error) {
 		return nil, err
 	}
 
-	// Parse the output and then find packages for each. The output should be as follows:
-	//
-	// login: /usr/bin/faillog
-	// dpkg-dev: /usr/bin/dpkg-genchanges
-	for _, line := range strings.Split(string(searchOutput), "\n") {
-		// For each line extract the package name
-		fields := strings.Split(line, ":")
-		name := fields[0]
-
-		// Get the package details
-		pkg, err := Show(ctx, name)
+	names := parseSearchOutput(string(searchOutput))
 
-		if err == nil {
-			packages = append(packages, pkg)
+	if len(names) == 0 {
+		return nil, NotFoundError{
+			Message: string(searchOutput),
 		}
 	}
 
+	// Get the details of all of the packages at once rather than running
+	// dpkg-query for each one
+	args := append([]string{"-f", QueryFormat, "--show"}, names...)
+	output, err := exec.CommandContext(ctx, "dpkg-query", args...).Output()
+
+	if err != nil {
+		return nil, fmt.Errorf(
+			"Error running command `dpkg-query %v`: %v\nOutput: %v",
+			strings.Join(args, " "),
+			err,
+			string(output),
+		)
+	}
+
+	packages, err = parseDpkgOutput(string(output))
+
+	if err != nil {
+		return nil, err
+	}
+
 	return packages, nil
 }
 
+// parseSearchOutput Returns the names of the packages in the output of
+// `dpkg-query --search`. The output should be as follows:
+//
+//	login: /usr/bin/faillog
+//	libc6:amd64, libc6:i386: /usr/share/doc/libc6
+//	diversion by dash from: /bin/sh
+//
+// Diversions are ignored since they are reported again with the owner
+func parseSearchOutput(out string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "diversion by") {
+			continue
+		}
+
+		owners, _, found := strings.Cut(line, ": ")
+
+		if !found {
+			continue
+		}
+
+		for _, name := range strings.Split(owners, ",") {
+			if name = strings.TrimSpace(name); name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// whatProvides Filters a list of packages to those that are called name or
+// provide it as a virtual package, which are the packages that satisfy a
+// dependency on name e.g. postfix for mail-transport-agent
+func whatProvides(all []Package, name string) []Package {
+	packages := make([]Package, 0)
+
+	for _, p := range all {
+		if p.Name == name {
+			packages = append(packages, p)
+			continue
+		}
+
+		for _, provided := range p.Provides {
+			if provided.Name == name {
+				packages = append(packages, p)
+				break
+			}
+		}
+	}
+
+	return packages
+}
+
+// reverseDepends Filters a list of packages to those that depend on a given
+// package
+func reverseDepends(all []Package, name string) []Package {
+	names := map[string]bool{
+		name: true,
+	}
+
+	for _, p := range all {
+		if p.Name == name {
+			for _, provided := range p.Provides {
+				names[provided.Name] = true
+			}
+		}
+	}
+
+	packages := make([]Package, 0)
+
+	for _, p := range all {
+		if p.Name == name {
+			continue
+		}
+
+		if packagedeps.Satisfies(p.Depends, names) || packagedeps.Satisfies(p.PreDepends, names) {
+			packages = append(packages, p)
+		}
+	}
+
+	return packages
+}
+
 func parseDpkgOutput(out string) ([]Package, error) {
 	var packages []Package
 
@@ -204,8 +309,8 @@ func parseDpkgOutput(out string) ([]Package, error) {
 		lines := strings.Split(infoString, "\n")
 
 		// Ensure that we have enough sections
-		if len(lines) < 11 {
-			return nil, fmt.Errorf("could not parse output, expected at least 11 lines, got %v. Output: %v", len(lines), lines)
+		if len(lines) < 16 {
+			return nil, fmt.Errorf("could not parse output, expected at least 16 lines, got %v. Output: %v", len(lines), lines)
 		}
 
 		// Extract data
@@ -226,13 +331,18 @@ func parseDpkgOutput(out string) ([]Package, error) {
 			pkg.Homepage = u
 		}
 
-		pkg.Summary = lines[9]
+		pkg.Depends = packagedeps.ParseDebian(lines[9])
+		pkg.PreDepends = packagedeps.ParseDebian(lines[10])
+		pkg.Recommends = packagedeps.ParseDebian(lines[11])
+		pkg.Conflicts = packagedeps.ParseDebian(lines[12])
+		pkg.Provides = packagedeps.ParseDebian(lines[13])
+		pkg.Summary = lines[14]
 
 		// The description that dpkg returns appears to be the summary on the
 		// first line, then the description on the other lines, indented by some
 		// number of spaces. We will need to trim off the summary then trim off
 		// the indentation and re-join to a single string
-		descLines := lines[10:]
+		descLines := lines[15:]
 
 		// Make sure we actually have description before doing anything drastic
 		if len(descLines) > 1 {
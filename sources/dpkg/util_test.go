@@ -178,3 +178,13 @@ func TestSearch(t *testing.T) {
 		t.Fatal("Found no error with bad query")
 	}
 }
+
+func TestParseSearchOutput(t *testing.T) {
+	out := "diversion by dash from: /bin/sh\ndiversion by dash to: /bin/sh.distrib\ndash: /bin/sh\nlibc6:amd64, libc6:i386: /usr/share/doc/libc6\ndash: /usr/share/man/man1/sh.1.gz\n"
+
+	names := parseSearchOutput(out)
+
+	if strings.Join(names, ",") != "dash,libc6:amd64,libc6:i386" {
+		t.Errorf("unexpected names %v", names)
+	}
+}
@@ -0,0 +1,128 @@
+package dpkg
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// Checksum The expected MD5 sum of a file that is owned by a package
+type Checksum struct {
+	Path string
+	MD5  string
+}
+
+// Verify Checks the files owned by a package against the checksums in
+// {name}.md5sums in the info directory, in the same way as `dpkg --verify`.
+// Paths are relative to root, which is usually "/". Files that have been
+// diverted are checked where they were diverted to, unless this package added
+// the diversion. The md5sums file doesn't include conffiles and dpkg doesn't
+// record permissions, so only missing and modified files are reported
+func Verify(infoLocation string, root string, name string, arch string, diversions map[string]Diversion) ([]packagefiles.Problem, error) {
+	if infoLocation == "" {
+		infoLocation = DefaultInfoLocation
+	}
+
+	var file *os.File
+	var err error
+
+	for _, base := range []string{fmt.Sprintf("%v:%v", name, arch), name} {
+		file, err = os.Open(filepath.Join(infoLocation, base+".md5sums"))
+
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	checksums, err := ParseMd5sums(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]packagefiles.Problem, 0)
+
+	for _, c := range checksums {
+		path := c.Path
+
+		if d, ok := diversions[path]; ok {
+			path = d.Path(name)
+		}
+
+		sum, err := md5sum(filepath.Join(root, path))
+
+		switch {
+		case os.IsNotExist(err):
+			problems = append(problems, packagefiles.Problem{
+				Path:    path,
+				Reasons: []string{packagefiles.ReasonMissing},
+			})
+		case err != nil:
+			// Files that can't be read (e.g. due to permissions) can't be
+			// verified, this isn't the same as them having been modified
+			continue
+		case sum != c.MD5:
+			problems = append(problems, packagefiles.Problem{
+				Path:    path,
+				Reasons: []string{packagefiles.ReasonChecksum},
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+// ParseMd5sums Parses a {name}.md5sums file from the dpkg info directory. Each
+// line is in the format "{md5}  {path}" where the path is relative to the root
+// of the filesystem. The returned paths are absolute
+func ParseMd5sums(r io.Reader) ([]Checksum, error) {
+	checksums := make([]Checksum, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		sum, path, found := strings.Cut(scanner.Text(), " ")
+
+		if !found || len(sum) != 32 {
+			continue
+		}
+
+		checksums = append(checksums, Checksum{
+			Path: "/" + strings.TrimLeft(path, " /"),
+			MD5:  sum,
+		})
+	}
+
+	return checksums, scanner.Err()
+}
+
+// md5sum Returns the MD5 sum of a file as a hex string
+func md5sum(path string) (string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	hash := md5.New()
+
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
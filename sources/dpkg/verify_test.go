@@ -0,0 +1,148 @@
+package dpkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParseMd5sums(t *testing.T) {
+	checksums, err := ParseMd5sums(strings.NewReader("60b41e2ad5af5cbd2128ff60cde8d782  usr/share/doc/a b/copyright\nnot a checksum\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Checksum{
+		{Path: "/usr/share/doc/a b/copyright", MD5: "60b41e2ad5af5cbd2128ff60cde8d782"},
+	}
+
+	if !reflect.DeepEqual(checksums, expected) {
+		t.Errorf("expected %v, got %v", expected, checksums)
+	}
+}
+
+func TestParseDiversions(t *testing.T) {
+	diversions, err := ParseDiversions(strings.NewReader("/bin/sh\n/bin/sh.distrib\ndash\n/etc/issue\n/etc/issue.orig\n:\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Diversion{
+		"/bin/sh":    {From: "/bin/sh", To: "/bin/sh.distrib", Package: "dash"},
+		"/etc/issue": {From: "/etc/issue", To: "/etc/issue.orig", Package: ":"},
+	}
+
+	if !reflect.DeepEqual(diversions, expected) {
+		t.Errorf("expected %v, got %v", expected, diversions)
+	}
+
+	// dash installs its own /bin/sh, everyone else's is moved out of the way
+	if path := diversions["/bin/sh"].Path("dash"); path != "/bin/sh" {
+		t.Errorf("expected dash's file to be at /bin/sh, got %v", path)
+	}
+
+	if path := diversions["/bin/sh"].Path("bash"); path != "/bin/sh.distrib" {
+		t.Errorf("expected bash's file to be at /bin/sh.distrib, got %v", path)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// /bin/sh is diverted so is checked at /bin/sh.distrib, where it matches
+	diversions, err := ReadDiversions("test/info")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diversions) != 1 {
+		t.Fatalf("expected 1 diversion, got %v", diversions)
+	}
+
+	problems, err := Verify("test/info", "test/root", "nginx-common", "all", diversions)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []packagefiles.Problem{
+		{Path: "/lib/systemd/system/nginx.service", Reasons: []string{packagefiles.ReasonChecksum}},
+		{Path: "/var/www/html/index.nginx-debian.html", Reasons: []string{packagefiles.ReasonMissing}},
+	}
+
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %v, got %v", expected, problems)
+	}
+
+	// Without the diversion it would be reported as missing
+	problems, err = Verify("test/info", "test/root", "nginx-common", "all", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(problems) != 3 || problems[2].Path != "/bin/sh" {
+		t.Errorf("expected /bin/sh to be missing without the diversion, got %v", problems)
+	}
+
+	if _, err := Verify("test/info", "test/root", "libc6", "amd64", diversions); err == nil {
+		t.Error("expected an error for a package without md5sums")
+	}
+}
+
+func TestVerifySource(t *testing.T) {
+	src := DpkgSource{
+		StatusLocation: "test/status",
+		InfoLocation:   "test/info",
+		Verify:         true,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get without md5sums",
+			ItemContext: util.LocalContext,
+			Query:       "oldpkg",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"integrity": "ok",
+					},
+				},
+			},
+		},
+		{
+			// The files in the fixture don't exist on the host so will be
+			// reported as missing
+			Name:        "get failed",
+			ItemContext: util.LocalContext,
+			Query:       "nginx-common",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"integrity": "failed",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search integrity failures",
+			ItemContext: util.LocalContext,
+			Query:       "integrity:failed",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
@@ -0,0 +1,112 @@
+package dpkg
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions Compares two Debian package versions in the same way as
+// `dpkg --compare-versions`. Returns a negative number if a is older than b,
+// zero if they are the same and a positive number if a is newer. Versions are
+// in the format [epoch:]upstream[-revision], see
+// https://www.debian.org/doc/debian-policy/ch-controlfields.html#version
+func CompareVersions(a, b string) int {
+	epochA, upstreamA, revisionA := parseVersion(a)
+	epochB, upstreamB, revisionB := parseVersion(b)
+
+	if epochA != epochB {
+		return epochA - epochB
+	}
+
+	if cmp := compareVersionPart(upstreamA, upstreamB); cmp != 0 {
+		return cmp
+	}
+
+	return compareVersionPart(revisionA, revisionB)
+}
+
+// parseVersion Splits a version into its epoch, upstream version and
+// revision. The epoch is everything before the first colon and the revision
+// is everything after the last hyphen, both of which are optional
+func parseVersion(v string) (int, string, string) {
+	v = strings.TrimSpace(v)
+	epoch := 0
+
+	if e, rest, found := strings.Cut(v, ":"); found {
+		epoch, _ = strconv.Atoi(e)
+		v = rest
+	}
+
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+
+	return epoch, v, ""
+}
+
+// isDigit Returns whether a character is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// charOrder Returns the sort order of the first character of a version. Tilde
+// sorts before anything, even the end of the version (so 1.0~rc1 is older
+// than 1.0), then the end of the version and digits, then letters, then
+// everything else
+func charOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case (s[0] >= 'a' && s[0] <= 'z') || (s[0] >= 'A' && s[0] <= 'Z'):
+		return int(s[0])
+	default:
+		return int(s[0]) + 256
+	}
+}
+
+// compareVersionPart Compares upstream versions or revisions. These are made
+// up of alternating non-digit and digit parts. Non-digit parts are compared
+// character by character using charOrder, and digit parts are compared
+// numerically
+func compareVersionPart(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			if cmp := charOrder(a) - charOrder(b); cmp != 0 {
+				return cmp
+			}
+
+			a, b = a[1:], b[1:]
+		}
+
+		a = strings.TrimLeft(a, "0")
+		b = strings.TrimLeft(b, "0")
+
+		// Numbers without leading zeros are compared by their length first,
+		// then by their first differing digit
+		firstDiff := 0
+
+		for a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[0]) - int(b[0])
+			}
+
+			a, b = a[1:], b[1:]
+		}
+
+		if a != "" && isDigit(a[0]) {
+			return 1
+		}
+
+		if b != "" && isDigit(b[0]) {
+			return -1
+		}
+
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+
+	return 0
+}
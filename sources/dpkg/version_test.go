@@ -0,0 +1,51 @@
+package dpkg
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		A        string
+		B        string
+		Expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.01", "1.1", 0},
+		{"1:1.0", "2.0", 1},
+		{"0:1.0", "1.0", 0},
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-10", "1.0-9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0", "1.0a", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.0+dfsg", "1.0.1", -1},
+		{"2.36-9+deb12u4", "2.36-9+deb12u7", -1},
+		{"1.22.1-9+deb12u1~rc1", "1.22.1-9+deb12u1", -1},
+		{"1.22.1-9+deb12u1", "1.22.1-9", 1},
+		{"1:2.34-0ubuntu3", "2.35-0ubuntu3", 1},
+		{"1.2-3-4", "1.2-3-5", -1},
+		{"2.0-1ubuntu0.22.04.1", "2.0-1ubuntu0.22.04.10", -1},
+	}
+
+	for _, test := range tests {
+		cmp := CompareVersions(test.A, test.B)
+
+		switch {
+		case cmp < 0:
+			cmp = -1
+		case cmp > 0:
+			cmp = 1
+		}
+
+		if cmp != test.Expected {
+			t.Errorf("expected %v compared to %v to be %v, got %v", test.A, test.B, test.Expected, cmp)
+		}
+
+		if reverse := CompareVersions(test.B, test.A); (reverse < 0) != (test.Expected > 0) || (reverse == 0) != (test.Expected == 0) {
+			t.Errorf("expected %v compared to %v to be the opposite of %v, got %v", test.B, test.A, test.Expected, reverse)
+		}
+	}
+}
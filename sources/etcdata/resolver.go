@@ -0,0 +1,579 @@
+package etcdata
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultResolvConfLocation The default location of resolv.conf
+const DefaultResolvConfLocation = "/etc/resolv.conf"
+
+// DefaultResolvedLocation The default location of the systemd-resolved
+// runtime state
+const DefaultResolvedLocation = "/run/systemd/resolve"
+
+// DefaultNsswitchLocation The default location of nsswitch.conf
+const DefaultNsswitchLocation = "/etc/nsswitch.conf"
+
+// ResolverSource Returns DNS resolver configuration. There is an item for
+// resolv.conf, each of the resolv.conf files managed by systemd-resolved, and
+// each network link that systemd-resolved has DNS settings for
+type ResolverSource struct {
+	// Where to find resolv.conf if not the default location (optional)
+	ResolvConfLocation string
+
+	// Where to find the systemd-resolved runtime directory if not the
+	// default location (optional)
+	ResolvedLocation string
+
+	// Where to find nsswitch.conf if not the default location (optional)
+	NsswitchLocation string
+
+	// The function that is used to list interfaces so that the indexes of
+	// systemd-resolved links can be converted to names (optional)
+	InterfacesFunction func() ([]net.Interface, error)
+}
+
+// ResolvConf The contents of a resolv.conf file
+type ResolvConf struct {
+	Nameservers []string
+	Search      []string
+	Options     []string
+	Sortlist    []string
+}
+
+// resolvConfLocation Returns the location of resolv.conf
+func (s *ResolverSource) resolvConfLocation() string {
+	if s.ResolvConfLocation != "" {
+		return s.ResolvConfLocation
+	}
+
+	return DefaultResolvConfLocation
+}
+
+// resolvedLocation Returns the location of the systemd-resolved runtime
+// directory
+func (s *ResolverSource) resolvedLocation() string {
+	if s.ResolvedLocation != "" {
+		return s.ResolvedLocation
+	}
+
+	return DefaultResolvedLocation
+}
+
+// nsswitchLocation Returns the location of nsswitch.conf
+func (s *ResolverSource) nsswitchLocation() string {
+	if s.NsswitchLocation != "" {
+		return s.NsswitchLocation
+	}
+
+	return DefaultNsswitchLocation
+}
+
+// Type is the type of items that this returns (Required)
+func (s *ResolverSource) Type() string {
+	return "resolver"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *ResolverSource) Name() string {
+	return "etcdata-resolver"
+}
+
+// Weighting of duplicate sources
+func (s *ResolverSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *ResolverSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a resolver by name. This is either the path to a resolv.conf file
+// e.g. /etc/resolv.conf, or link/{interface} for the per-link settings of
+// systemd-resolved e.g. link/eth0
+func (s *ResolverSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.resolverItems()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	for _, item := range items {
+		if item.UniqueAttributeValue() == query {
+			return item, nil
+		}
+	}
+
+	return nil, &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOTFOUND,
+		ErrorString: fmt.Sprintf("resolver %v not found", query),
+		Context:     itemContext,
+	}
+}
+
+// Find Returns all resolvers
+func (s *ResolverSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.resolverItems()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Search Accepts a nameserver IP and returns all resolvers that use it
+func (s *ResolverSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	ip := net.ParseIP(query)
+
+	if ip == nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: fmt.Sprintf("%v is not an IP address", query),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.resolverItems()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	results := make([]*sdp.Item, 0)
+
+	for _, item := range items {
+		nameservers, err := item.Attributes.Get("nameservers")
+
+		if err != nil {
+			continue
+		}
+
+		if list, ok := nameservers.([]interface{}); ok {
+			for _, nameserver := range list {
+				if s, ok := nameserver.(string); ok && ip.Equal(nameserverIP(s)) {
+					results = append(results, item)
+					break
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Supported Returns whether resolv.conf exists
+func (s *ResolverSource) Supported() bool {
+	_, err := os.Stat(s.resolvConfLocation())
+
+	return err == nil
+}
+
+// resolverItems Returns items for all resolvers
+func (s *ResolverSource) resolverItems() ([]*sdp.Item, error) {
+	items := make([]*sdp.Item, 0)
+	resolvConf := s.resolvConfLocation()
+
+	// resolv.conf is used by libc so it's required, the files managed by
+	// systemd-resolved are optional
+	conf, err := ReadResolvConf(resolvConf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := conf.attributes(resolvConf)
+
+	if order, err := ReadNsswitchHosts(s.nsswitchLocation()); err == nil {
+		attrs["lookupOrder"] = order
+	}
+
+	var target string
+
+	if t, err := filepath.EvalSymlinks(resolvConf); err == nil && t != resolvConf {
+		target = t
+		attrs["target"] = target
+	}
+
+	item, err := resolverToItem(attrs, conf.Nameservers, resolvConf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(s.nsswitchLocation()); err == nil {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "file",
+			Method:  sdp.RequestMethod_GET,
+			Query:   s.nsswitchLocation(),
+			Context: util.LocalContext,
+		})
+	}
+
+	items = append(items, item)
+
+	for _, name := range []string{"resolv.conf", "stub-resolv.conf"} {
+		path := filepath.Join(s.resolvedLocation(), name)
+		conf, err := ReadResolvConf(path)
+
+		if err != nil {
+			continue
+		}
+
+		attrs := conf.attributes(path)
+		attrs["managedBy"] = "systemd-resolved"
+
+		if item, err := resolverToItem(attrs, conf.Nameservers, path); err == nil {
+			items = append(items, item)
+		}
+
+		// Link resolv.conf to the file it points to if it is managed by
+		// systemd-resolved
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved == target {
+			items[0].LinkedItemRequests = append(items[0].LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "resolver",
+				Method:  sdp.RequestMethod_GET,
+				Query:   path,
+				Context: util.LocalContext,
+			})
+		}
+	}
+
+	items = append(items, s.linkItems()...)
+
+	return items, nil
+}
+
+// linkItems Returns items for the per-link DNS settings of systemd-resolved,
+// which are stored in /run/systemd/resolve/netif/{ifindex}
+func (s *ResolverSource) linkItems() []*sdp.Item {
+	items := make([]*sdp.Item, 0)
+	dir := filepath.Join(s.resolvedLocation(), "netif")
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return items
+	}
+
+	interfacesFunction := s.InterfacesFunction
+
+	if interfacesFunction == nil {
+		interfacesFunction = net.Interfaces
+	}
+
+	names := make(map[int]string)
+
+	if interfaces, err := interfacesFunction(); err == nil {
+		for _, iface := range interfaces {
+			names[iface.Index] = iface.Name
+		}
+	}
+
+	// Sort numerically so that items are returned in interface order
+	indexes := make([]int, 0, len(entries))
+
+	for _, entry := range entries {
+		if index, err := strconv.Atoi(entry.Name()); err == nil {
+			indexes = append(indexes, index)
+		}
+	}
+
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		path := filepath.Join(dir, strconv.Itoa(index))
+		state, err := readKeyValueFile(path)
+
+		if err != nil {
+			continue
+		}
+
+		iface, ok := names[index]
+
+		if !ok {
+			iface = strconv.Itoa(index)
+		}
+
+		nameservers := strings.Fields(state["SERVERS"])
+
+		attrs := map[string]interface{}{
+			"name":        "link/" + iface,
+			"path":        path,
+			"interface":   iface,
+			"nameservers": nameservers,
+			"search":      strings.Fields(state["DOMAINS"]),
+			"managedBy":   "systemd-resolved",
+		}
+
+		options := make([]string, 0)
+
+		for _, key := range []string{"LLMNR", "MDNS", "DNSSEC", "DNS_OVER_TLS", "DEFAULT_ROUTE"} {
+			if value, ok := state[key]; ok {
+				options = append(options, strings.ToLower(key)+"="+value)
+			}
+		}
+
+		attrs["options"] = options
+
+		if current, ok := state["CURRENT_DNS"]; ok {
+			attrs["currentNameserver"] = current
+		}
+
+		item, err := resolverToItem(attrs, nameservers, "")
+
+		if err != nil {
+			continue
+		}
+
+		if ok {
+			item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "interface",
+				Method:  sdp.RequestMethod_GET,
+				Query:   iface,
+				Context: util.LocalContext,
+			})
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// attributes Returns the attributes of a resolv.conf file
+func (c ResolvConf) attributes(path string) map[string]interface{} {
+	attrs := map[string]interface{}{
+		"name":        path,
+		"path":        path,
+		"nameservers": c.Nameservers,
+		"search":      c.Search,
+		"options":     c.Options,
+	}
+
+	if len(c.Sortlist) > 0 {
+		attrs["sortlist"] = c.Sortlist
+	}
+
+	return attrs
+}
+
+// resolverToItem Creates an item from a set of attributes, linking to the
+// nameservers and the file that the config was read from
+func resolverToItem(attrs map[string]interface{}, nameservers []string, file string) (*sdp.Item, error) {
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "resolver",
+		UniqueAttribute: "name",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	if file != "" {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "file",
+			Method:  sdp.RequestMethod_GET,
+			Query:   file,
+			Context: util.LocalContext,
+		})
+	}
+
+	for _, nameserver := range nameservers {
+		ip := nameserverIP(nameserver)
+
+		// Loopback nameservers (e.g. the systemd-resolved stub) and link-local
+		// nameservers aren't globally unique
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "ip",
+			Method:  sdp.RequestMethod_GET,
+			Query:   ip.String(),
+			Context: "global",
+		})
+	}
+
+	return &item, nil
+}
+
+// nameserverIP Extracts the IP from a nameserver. systemd-resolved allows
+// nameservers to include a port, interface and server name e.g.
+// [fe80::1%2]:53#dns.example.com
+func nameserverIP(nameserver string) net.IP {
+	if i := strings.Index(nameserver, "#"); i >= 0 {
+		nameserver = nameserver[:i]
+	}
+
+	if host, _, err := net.SplitHostPort(nameserver); err == nil {
+		nameserver = host
+	}
+
+	if i := strings.Index(nameserver, "%"); i >= 0 {
+		nameserver = nameserver[:i]
+	}
+
+	return net.ParseIP(strings.Trim(nameserver, "[]"))
+}
+
+// ReadResolvConf Reads and parses a resolv.conf file
+func ReadResolvConf(path string) (ResolvConf, error) {
+	conf := ResolvConf{
+		Nameservers: make([]string, 0),
+		Search:      make([]string, 0),
+		Options:     make([]string, 0),
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return conf, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			conf.Nameservers = append(conf.Nameservers, fields[1])
+		case "search":
+			conf.Search = fields[1:]
+		case "domain":
+			// The last of domain and search wins
+			conf.Search = fields[1:2]
+		case "options":
+			conf.Options = append(conf.Options, fields[1:]...)
+		case "sortlist":
+			conf.Sortlist = fields[1:]
+		}
+	}
+
+	return conf, scanner.Err()
+}
+
+// ReadNsswitchHosts Reads the hosts: line of nsswitch.conf, which is the
+// order in which name lookups are done e.g. ["files", "dns"]
+func ReadNsswitchHosts(path string) ([]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		database, services, found := strings.Cut(line, ":")
+
+		if found && strings.TrimSpace(database) == "hosts" {
+			return strings.Fields(services), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("no hosts database in %v", path)
+}
+
+// readKeyValueFile Reads a file of KEY=value lines, as written by systemd
+func readKeyValueFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if key, value, found := strings.Cut(line, "="); found {
+			values[key] = value
+		}
+	}
+
+	return values, scanner.Err()
+}
@@ -0,0 +1,226 @@
+package etcdata
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func testResolverSource() *ResolverSource {
+	return &ResolverSource{
+		ResolvConfLocation: "test/resolver/etc/resolv.conf",
+		ResolvedLocation:   "test/resolver/run/systemd/resolve",
+		NsswitchLocation:   "test/resolver/etc/nsswitch.conf",
+		InterfacesFunction: func() ([]net.Interface, error) {
+			return []net.Interface{
+				{Index: 1, Name: "lo"},
+				{Index: 2, Name: "eth0"},
+			}, nil
+		},
+	}
+}
+
+func TestReadResolvConf(t *testing.T) {
+	conf, err := ReadResolvConf("test/resolver/static/resolv.conf")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ResolvConf{
+		Nameservers: []string{"192.168.1.1", "2001:4860:4860::8888"},
+		Search:      []string{"one.example.com", "two.example.com"},
+		Options:     []string{"ndots:2", "timeout:1", "rotate"},
+		Sortlist:    []string{"130.155.160.0/255.255.240.0"},
+	}
+
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("expected %+v, got %+v", expected, conf)
+	}
+}
+
+func TestReadNsswitchHosts(t *testing.T) {
+	order, err := ReadNsswitchHosts("test/resolver/etc/nsswitch.conf")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"files", "mdns4_minimal", "[NOTFOUND=return]", "resolve", "[!UNAVAIL=return]", "dns", "myhostname"}
+
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+
+	if _, err := ReadNsswitchHosts("test/hosts"); err == nil {
+		t.Error("expected error for file without hosts database")
+	}
+}
+
+func TestNameserverIP(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.2":                         "10.0.0.2",
+		"fe80::1%2":                        "fe80::1",
+		"1.1.1.1#cloudflare-dns.com":       "1.1.1.1",
+		"1.1.1.1:853":                      "1.1.1.1",
+		"[2606:4700::1111]:853#cloudflare": "2606:4700::1111",
+	}
+
+	for nameserver, expected := range tests {
+		if ip := nameserverIP(nameserver); ip.String() != expected {
+			t.Errorf("expected %v to be %v, got %v", nameserver, expected, ip)
+		}
+	}
+}
+
+func TestResolverSource(t *testing.T) {
+	tests := []util.SourceTest{
+		{
+			Name:        "get resolv.conf",
+			ItemContext: util.LocalContext,
+			Query:       "test/resolver/etc/resolv.conf",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"nameservers": []interface{}{"127.0.0.53"},
+						"search":      []interface{}{"corp.example.com"},
+						"options":     []interface{}{"edns0", "trust-ad"},
+						"target":      "test/resolver/run/systemd/resolve/stub-resolv.conf",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get link",
+			ItemContext: util.LocalContext,
+			Query:       "link/eth0",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"interface":         "eth0",
+						"nameservers":       []interface{}{"10.0.0.2", "fe80::1%2"},
+						"currentNameserver": "10.0.0.2",
+						"options":           []interface{}{"llmnr=yes", "mdns=no", "dnssec=allow-downgrade", "default_route=yes"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "get unknown link",
+			ItemContext: util.LocalContext,
+			Query:       "link/3",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "get missing",
+			ItemContext: util.LocalContext,
+			Query:       "link/eth9",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search by nameserver",
+			ItemContext: util.LocalContext,
+			Query:       "1.1.1.1",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 2,
+			},
+		},
+		{
+			Name:        "search by non-IP",
+			ItemContext: util.LocalContext,
+			Query:       "eth0",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, testResolverSource())
+}
+
+func TestResolverLinks(t *testing.T) {
+	s := testResolverSource()
+
+	item, err := s.Get(context.Background(), util.LocalContext, "test/resolver/etc/resolv.conf")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := item.Attributes.Get("lookupOrder")
+
+	if err != nil || len(order.([]interface{})) != 7 {
+		t.Errorf("expected lookup order, got %v", order)
+	}
+
+	// The stub nameserver is loopback so isn't linked
+	expected := []struct {
+		Type  string
+		Query string
+	}{
+		{"file", "test/resolver/etc/resolv.conf"},
+		{"file", "test/resolver/etc/nsswitch.conf"},
+		{"resolver", "test/resolver/run/systemd/resolve/stub-resolv.conf"},
+	}
+
+	if len(item.LinkedItemRequests) != len(expected) {
+		t.Fatalf("expected %v links, got %v", len(expected), item.LinkedItemRequests)
+	}
+
+	for i, e := range expected {
+		if link := item.LinkedItemRequests[i]; link.Type != e.Type || link.Query != e.Query {
+			t.Errorf("expected link %v to be %v %v, got %v %v", i, e.Type, e.Query, link.Type, link.Query)
+		}
+	}
+
+	item, err = s.Get(context.Background(), util.LocalContext, "test/resolver/run/systemd/resolve/resolv.conf")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The link-local nameserver isn't linked
+	ips := make([]string, 0)
+
+	for _, link := range item.LinkedItemRequests {
+		if link.Type == "ip" {
+			ips = append(ips, link.Query)
+		}
+	}
+
+	if !reflect.DeepEqual(ips, []string{"10.0.0.2", "1.1.1.1"}) {
+		t.Errorf("expected links to 10.0.0.2 and 1.1.1.1, got %v", ips)
+	}
+}
@@ -0,0 +1,136 @@
+package firewall
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Backends that rules can be read from
+const (
+	BackendIptables  = "iptables"
+	BackendIp6tables = "ip6tables"
+	BackendNftables  = "nftables"
+)
+
+// Rule A single firewall rule. The fields that are commonly needed to work out
+// whether traffic is allowed are parsed out, everything else is kept in
+// Matches as text
+type Rule struct {
+	Backend string
+	Family  string
+	Table   string
+	Chain   string
+
+	// The default policy of the chain e.g. DROP, empty for chains without a
+	// policy
+	ChainPolicy string
+
+	// The position of the rule in its chain, starting at 1
+	Position int
+
+	// Negated criteria are prefixed with "!"
+	Protocol         string
+	Source           string
+	Destination      string
+	InInterface      string
+	OutInterface     string
+	SourcePorts      []string
+	DestinationPorts []string
+
+	// All other match criteria e.g. "conntrack --ctstate RELATED,ESTABLISHED"
+	Matches []string
+	Comment string
+
+	// The target or verdict of the rule e.g. ACCEPT, DROP, or the name of a
+	// chain that is jumped to
+	Target        string
+	TargetOptions string
+
+	// Counters, only set if the backend reported them
+	Packets  *uint64
+	Bytes    *uint64
+	Original string
+}
+
+// ID Returns the unique ID of the rule in the format
+// {backend}/{table}/{chain}/{position}. nftables tables include the family
+// e.g. nftables/inet/filter/input/3
+func (r Rule) ID() string {
+	table := r.Table
+
+	if r.Backend == BackendNftables {
+		table = r.Family + "/" + r.Table
+	}
+
+	return fmt.Sprintf("%v/%v/%v/%v", r.Backend, table, r.Chain, r.Position)
+}
+
+// MatchesPort Returns whether the rule explicitly matches a destination port
+// for a given protocol, either directly or as part of a range or list
+func (r Rule) MatchesPort(protocol string, port int) bool {
+	if r.Protocol != "" && r.Protocol != protocol {
+		return false
+	}
+
+	for _, spec := range r.DestinationPorts {
+		if strings.HasPrefix(spec, "!") {
+			continue
+		}
+
+		if low, high, ok := parsePortRange(spec); ok && port >= low && port <= high {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LinkedPorts Returns the individual destination ports that this rule matches.
+// Ranges are not expanded
+func (r Rule) LinkedPorts() []int {
+	ports := make([]int, 0)
+
+	if r.Protocol != "tcp" && r.Protocol != "udp" {
+		return ports
+	}
+
+	for _, spec := range r.DestinationPorts {
+		if low, high, ok := parsePortRange(spec); ok && low == high {
+			ports = append(ports, low)
+		}
+	}
+
+	return ports
+}
+
+// parsePortRange Parses a port or range of ports. iptables uses "1000:2000"
+// and nftables uses "1000-2000"
+func parsePortRange(spec string) (int, int, bool) {
+	lowString, highString, isRange := strings.Cut(spec, ":")
+
+	if !isRange {
+		lowString, highString, isRange = strings.Cut(spec, "-")
+	}
+
+	low, err := strconv.Atoi(lowString)
+
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if !isRange {
+		return low, low, true
+	}
+
+	// Open ended ranges e.g. 1024: are allowed by iptables
+	high := 65535
+
+	if highString != "" {
+		if high, err = strconv.Atoi(highString); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	return low, high, true
+}
@@ -0,0 +1,295 @@
+package firewall
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// FirewallRuleSource Returns host firewall rules from iptables, ip6tables and
+// nftables. Each backend whose command is available is read, and backends
+// that fail (e.g. due to permissions) are skipped
+type FirewallRuleSource struct {
+	// The function used to run commands (optional)
+	RunFunction util.RunFunction
+}
+
+// Type is the type of items that this returns (Required)
+func (s *FirewallRuleSource) Type() string {
+	return "firewallrule"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *FirewallRuleSource) Name() string {
+	return "firewall"
+}
+
+// Weighting of duplicate sources
+func (s *FirewallRuleSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *FirewallRuleSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a rule by its ID e.g. iptables/filter/INPUT/3 or
+// nftables/inet/filter/input/3
+func (s *FirewallRuleSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	backend, _, _ := strings.Cut(query, "/")
+
+	rules, err := s.backendRules(ctx, backend)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.ID() == query {
+			return ruleToItem(rule)
+		}
+	}
+
+	return nil, &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOTFOUND,
+		ErrorString: fmt.Sprintf("firewall rule %v not found", query),
+		Context:     itemContext,
+	}
+}
+
+// Find Returns all rules from all available backends
+func (s *FirewallRuleSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	return s.ruleItems(ctx, func(r Rule) bool {
+		return true
+	})
+}
+
+// Search Accepts a port in the same format as the port source (e.g. 22 for
+// TCP or udp/53) and returns the rules that explicitly match that destination
+// port, including ranges and lists. Any other query is treated as a chain name
+// e.g. INPUT, and returns all rules in chains with that name
+func (s *FirewallRuleSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	if protocol, port, ok := parsePortID(query); ok {
+		return s.ruleItems(ctx, func(r Rule) bool {
+			return r.MatchesPort(protocol, port)
+		})
+	}
+
+	return s.ruleItems(ctx, func(r Rule) bool {
+		return r.Chain == query
+	})
+}
+
+// Supported Returns whether any firewall commands are available
+func (s *FirewallRuleSource) Supported() bool {
+	for _, command := range backendCommands {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backendCommands The commands that are used to dump the rules of each backend
+var backendCommands = map[string][]string{
+	BackendIptables:  {"iptables-save", "-c"},
+	BackendIp6tables: {"ip6tables-save", "-c"},
+	BackendNftables:  {"nft", "-j", "list", "ruleset"},
+}
+
+// backends The order in which backends are read
+var backends = []string{BackendIptables, BackendIp6tables, BackendNftables}
+
+// backendRules Runs the command for a single backend and parses its output
+func (s *FirewallRuleSource) backendRules(ctx context.Context, backend string) ([]Rule, error) {
+	command, ok := backendCommands[backend]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown firewall backend %v", backend)
+	}
+
+	run := s.RunFunction
+
+	if run == nil {
+		run = util.RunContext
+	}
+
+	output, err := run(ctx, command[0], command[1:]...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if backend == BackendNftables {
+		return ParseNftJSON(output)
+	}
+
+	return ParseIptablesSave(bytes.NewReader(output), backend)
+}
+
+// ruleItems Returns items for all rules from all backends that match a filter.
+// Backends that can't be read are skipped
+func (s *FirewallRuleSource) ruleItems(ctx context.Context, filter func(r Rule) bool) ([]*sdp.Item, error) {
+	items := make([]*sdp.Item, 0)
+
+	for _, backend := range backends {
+		rules, err := s.backendRules(ctx, backend)
+
+		if err != nil {
+			continue
+		}
+
+		for _, rule := range rules {
+			if !filter(rule) {
+				continue
+			}
+
+			if item, err := ruleToItem(rule); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// parsePortID Parses a port in the format used by the port source, where TCP
+// ports are just the number and other protocols are prefixed e.g. udp/53
+func parsePortID(id string) (string, int, bool) {
+	protocol := "tcp"
+	portString := id
+
+	if p, n, found := strings.Cut(id, "/"); found {
+		protocol = p
+		portString = n
+	}
+
+	port, err := strconv.Atoi(portString)
+
+	if err != nil {
+		return "", 0, false
+	}
+
+	return protocol, port, true
+}
+
+// ruleToItem Converts a rule to an item
+func ruleToItem(r Rule) (*sdp.Item, error) {
+	attrs := map[string]interface{}{
+		"id":       r.ID(),
+		"backend":  r.Backend,
+		"family":   r.Family,
+		"table":    r.Table,
+		"chain":    r.Chain,
+		"position": r.Position,
+		"target":   r.Target,
+		"rule":     r.Original,
+	}
+
+	optional := map[string]string{
+		"chainPolicy":   r.ChainPolicy,
+		"protocol":      r.Protocol,
+		"source":        r.Source,
+		"destination":   r.Destination,
+		"inInterface":   r.InInterface,
+		"outInterface":  r.OutInterface,
+		"comment":       r.Comment,
+		"targetOptions": r.TargetOptions,
+	}
+
+	for key, value := range optional {
+		if value != "" {
+			attrs[key] = value
+		}
+	}
+
+	if len(r.DestinationPorts) > 0 {
+		attrs["destinationPorts"] = r.DestinationPorts
+	}
+
+	if len(r.SourcePorts) > 0 {
+		attrs["sourcePorts"] = r.SourcePorts
+	}
+
+	if len(r.Matches) > 0 {
+		attrs["matches"] = r.Matches
+	}
+
+	if r.Packets != nil {
+		attrs["packets"] = *r.Packets
+	}
+
+	if r.Bytes != nil {
+		attrs["bytes"] = *r.Bytes
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "firewallrule",
+		UniqueAttribute: "id",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	for _, port := range r.LinkedPorts() {
+		query := strconv.Itoa(port)
+
+		// This matches the format of IDs from the port source
+		if r.Protocol != "tcp" {
+			query = r.Protocol + "/" + query
+		}
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "port",
+			Method:  sdp.RequestMethod_GET,
+			Query:   query,
+			Context: util.LocalContext,
+		})
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,168 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// testRun Returns captured command output from the test directory
+func testRun(ctx context.Context, name string, args ...string) ([]byte, error) {
+	switch name {
+	case "iptables-save":
+		return os.ReadFile("test/iptables-save")
+	case "ip6tables-save":
+		return os.ReadFile("test/ip6tables-save")
+	case "nft":
+		return os.ReadFile("test/nft.json")
+	}
+
+	return nil, errors.New("command not found")
+}
+
+func TestFirewallRuleSource(t *testing.T) {
+	source := FirewallRuleSource{
+		RunFunction: testRun,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get iptables rule",
+			ItemContext: util.LocalContext,
+			Query:       "iptables/filter/INPUT/3",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"protocol":         "tcp",
+						"destinationPorts": []interface{}{"22"},
+						"target":           "ACCEPT",
+						"chainPolicy":      "DROP",
+						"packets":          float64(17),
+					},
+				},
+			},
+		},
+		{
+			Name:        "get nftables rule",
+			ItemContext: util.LocalContext,
+			Query:       "nftables/inet/filter/web/1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"family": "inet",
+						"target": "accept",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing rule",
+			ItemContext: util.LocalContext,
+			Query:       "ip6tables/filter/INPUT/9",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 20,
+			},
+		},
+		{
+			Name:        "search by tcp port",
+			ItemContext: util.LocalContext,
+			Query:       "22",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				// iptables, ip6tables and nftables
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "search by udp port",
+			ItemContext: util.LocalContext,
+			Query:       "udp/53",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search by chain",
+			ItemContext: util.LocalContext,
+			Query:       "DOCKER",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestFirewallRuleSourceMissingBackend(t *testing.T) {
+	source := FirewallRuleSource{
+		RunFunction: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			if name == "nft" {
+				return nil, errors.New("permission denied")
+			}
+
+			return testRun(ctx, name, args...)
+		},
+	}
+
+	items, err := source.Find(context.Background(), util.LocalContext)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 13 {
+		t.Errorf("expected 13 rules without nftables, got %v", len(items))
+	}
+}
+
+func TestFirewallRuleLinks(t *testing.T) {
+	source := FirewallRuleSource{
+		RunFunction: testRun,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "iptables/filter/INPUT/4")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"udp/53", "udp/123"}
+
+	if len(item.LinkedItemRequests) != len(expected) {
+		t.Fatalf("expected %v links, got %v", len(expected), item.LinkedItemRequests)
+	}
+
+	for i, query := range expected {
+		if link := item.LinkedItemRequests[i]; link.Type != "port" || link.Query != query {
+			t.Errorf("expected link to port %v, got %v %v", query, link.Type, link.Query)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package firewall
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseIptablesSave Parses the output of iptables-save or ip6tables-save. The
+// backend should be BackendIptables or BackendIp6tables. Counters are read if
+// the output was generated with -c
+func ParseIptablesSave(r io.Reader, backend string) ([]Rule, error) {
+	family := "ipv4"
+
+	if backend == BackendIp6tables {
+		family = "ipv6"
+	}
+
+	rules := make([]Rule, 0)
+	scanner := bufio.NewScanner(r)
+	table := ""
+	policies := make(map[string]string)
+	positions := make(map[string]int)
+	lineNumber := 0
+
+	// Rules can be long, especially with comments
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case line == "COMMIT":
+			table = ""
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+			policies = make(map[string]string)
+			positions = make(map[string]int)
+		case strings.HasPrefix(line, ":"):
+			// :INPUT DROP [0:0]
+			fields := strings.Fields(strings.TrimPrefix(line, ":"))
+
+			if len(fields) >= 2 && fields[1] != "-" {
+				policies[fields[0]] = fields[1]
+			}
+		default:
+			if table == "" {
+				return nil, fmt.Errorf("line %v: rule outside of a table: %v", lineNumber, line)
+			}
+
+			rule, err := parseIptablesRule(line)
+
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", lineNumber, err)
+			}
+
+			positions[rule.Chain]++
+
+			rule.Backend = backend
+			rule.Family = family
+			rule.Table = table
+			rule.ChainPolicy = policies[rule.Chain]
+			rule.Position = positions[rule.Chain]
+
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// parseIptablesRule Parses a single rule line e.g.
+// [0:0] -A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+func parseIptablesRule(line string) (Rule, error) {
+	rule := Rule{}
+
+	if strings.HasPrefix(line, "[") {
+		end := strings.Index(line, "]")
+
+		if end < 0 {
+			return rule, fmt.Errorf("unterminated counters: %v", line)
+		}
+
+		packetsString, bytesString, _ := strings.Cut(line[1:end], ":")
+
+		if packets, err := strconv.ParseUint(packetsString, 10, 64); err == nil {
+			rule.Packets = &packets
+		}
+
+		if b, err := strconv.ParseUint(bytesString, 10, 64); err == nil {
+			rule.Bytes = &b
+		}
+
+		line = strings.TrimSpace(line[end+1:])
+	}
+
+	rule.Original = line
+
+	tokens, err := splitArgs(line)
+
+	if err != nil {
+		return rule, err
+	}
+
+	if len(tokens) < 2 || (tokens[0] != "-A" && tokens[0] != "--append") {
+		return rule, fmt.Errorf("expected rule to start with -A: %v", line)
+	}
+
+	rule.Chain = tokens[1]
+	tokens = tokens[2:]
+
+	// The current match module and its options e.g. ["conntrack", "--ctstate",
+	// "RELATED,ESTABLISHED"]
+	var match []string
+	negate := ""
+
+	flush := func() {
+		if len(match) > 1 || (len(match) == 1 && !implicitModules[match[0]]) {
+			rule.Matches = append(rule.Matches, strings.Join(match, " "))
+		}
+
+		match = nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+
+		// Returns the value of the current option
+		value := func() string {
+			if i+1 < len(tokens) {
+				i++
+				return tokens[i]
+			}
+
+			return ""
+		}
+
+		if token == "!" {
+			negate = "!"
+			continue
+		}
+
+		switch token {
+		case "-p", "--protocol":
+			rule.Protocol = negate + value()
+		case "-s", "--source":
+			rule.Source = negate + value()
+		case "-d", "--destination":
+			rule.Destination = negate + value()
+		case "-i", "--in-interface":
+			rule.InInterface = negate + value()
+		case "-o", "--out-interface":
+			rule.OutInterface = negate + value()
+		case "--dport", "--destination-port":
+			rule.DestinationPorts = append(rule.DestinationPorts, negate+value())
+		case "--sport", "--source-port":
+			rule.SourcePorts = append(rule.SourcePorts, negate+value())
+		case "--dports", "--destination-ports":
+			rule.DestinationPorts = append(rule.DestinationPorts, splitList(negate, value())...)
+		case "--sports", "--source-ports":
+			rule.SourcePorts = append(rule.SourcePorts, splitList(negate, value())...)
+		case "--comment":
+			rule.Comment = value()
+		case "-m", "--match":
+			flush()
+			match = []string{value()}
+		case "-j", "--jump", "-g", "--goto":
+			flush()
+			rule.Target = value()
+			rule.TargetOptions = joinArgs(tokens[i+1:])
+			i = len(tokens)
+		default:
+			if negate != "" {
+				match = append(match, negate)
+			}
+
+			match = append(match, token)
+		}
+
+		negate = ""
+	}
+
+	flush()
+
+	return rule, nil
+}
+
+// implicitModules Match modules whose options are all parsed into fields, so
+// they don't need to be included in Matches
+var implicitModules = map[string]bool{
+	"tcp":       true,
+	"udp":       true,
+	"multiport": true,
+	"comment":   true,
+}
+
+// splitList Splits a comma separated list of ports, applying negation to all
+// of them
+func splitList(negate string, list string) []string {
+	items := strings.Split(list, ",")
+
+	for i := range items {
+		items[i] = negate + items[i]
+	}
+
+	return items
+}
+
+// splitArgs Splits a line into arguments in the same way as a shell, which is
+// how iptables-save quotes arguments that contain spaces e.g. comments
+func splitArgs(line string) ([]string, error) {
+	args := make([]string, 0)
+	var current bytes.Buffer
+	inArg := false
+	inQuotes := false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case c == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+			inArg = true
+		case c == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote: %v", line)
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// joinArgs Joins arguments back together, quoting those that contain spaces
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"") {
+			quoted[i] = strconv.Quote(arg)
+		} else {
+			quoted[i] = arg
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,139 @@
+package firewall
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stringReader(s string) io.Reader {
+	return strings.NewReader(s)
+}
+
+func TestParseIptablesSave(t *testing.T) {
+	f, err := os.Open("test/iptables-save")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	rules, err := ParseIptablesSave(f, BackendIptables)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 11 {
+		t.Fatalf("expected 11 rules, got %v", len(rules))
+	}
+
+	t.Run("nat rule", func(t *testing.T) {
+		r := rules[3]
+
+		if r.ID() != "iptables/nat/DOCKER/2" {
+			t.Errorf("unexpected ID %v", r.ID())
+		}
+
+		if r.InInterface != "!docker0" {
+			t.Errorf("expected negated interface, got %v", r.InInterface)
+		}
+
+		if r.Target != "DNAT" || r.TargetOptions != "--to-destination 172.17.0.2:80" {
+			t.Errorf("unexpected target %v %v", r.Target, r.TargetOptions)
+		}
+
+		if r.ChainPolicy != "" {
+			t.Errorf("expected user chain to have no policy, got %v", r.ChainPolicy)
+		}
+
+		if r.Packets == nil || *r.Packets != 3 || *r.Bytes != 180 {
+			t.Errorf("unexpected counters %v %v", r.Packets, r.Bytes)
+		}
+	})
+
+	t.Run("conntrack rule", func(t *testing.T) {
+		r := rules[5]
+
+		if !reflect.DeepEqual(r.Matches, []string{"conntrack --ctstate RELATED,ESTABLISHED"}) {
+			t.Errorf("unexpected matches %v", r.Matches)
+		}
+
+		if r.ChainPolicy != "DROP" || r.Table != "filter" || r.Position != 2 {
+			t.Errorf("unexpected rule %+v", r)
+		}
+	})
+
+	t.Run("comment", func(t *testing.T) {
+		r := rules[6]
+
+		if r.Comment != `allow "ssh" from anywhere` {
+			t.Errorf("unexpected comment %v", r.Comment)
+		}
+
+		if len(r.Matches) != 0 {
+			t.Errorf("expected no extra matches, got %v", r.Matches)
+		}
+
+		if !reflect.DeepEqual(r.LinkedPorts(), []int{22}) {
+			t.Errorf("expected port 22, got %v", r.LinkedPorts())
+		}
+	})
+
+	t.Run("multiport", func(t *testing.T) {
+		r := rules[7]
+
+		if !reflect.DeepEqual(r.DestinationPorts, []string{"53", "123"}) {
+			t.Errorf("unexpected ports %v", r.DestinationPorts)
+		}
+
+		if !r.MatchesPort("udp", 123) || r.MatchesPort("tcp", 123) {
+			t.Error("expected rule to match only udp/123")
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		r := rules[8]
+
+		if !r.MatchesPort("tcp", 8050) || r.MatchesPort("tcp", 8101) {
+			t.Error("expected rule to match 8000-8100")
+		}
+
+		if len(r.LinkedPorts()) != 0 {
+			t.Errorf("expected ranges not to be linked, got %v", r.LinkedPorts())
+		}
+	})
+}
+
+func TestParseIptablesSaveErrors(t *testing.T) {
+	tests := map[string]string{
+		"outside table": "-A INPUT -j ACCEPT\n",
+		"unterminated":  "*filter\n-A INPUT -m comment --comment \"oops -j ACCEPT\n",
+		"not a rule":    "*filter\n-I INPUT -j ACCEPT\n",
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseIptablesSave(stringReader(input), BackendIptables); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args, err := splitArgs(`-A INPUT -m comment --comment "two words" -j ACCEPT`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"-A", "INPUT", "-m", "comment", "--comment", "two words", "-j", "ACCEPT"}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+}
@@ -0,0 +1,334 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nftRuleset The top level of the output of `nft -j list ruleset`. Each
+// object contains a single key which is the type of object e.g. "rule"
+type nftRuleset struct {
+	Nftables []map[string]json.RawMessage `json:"nftables"`
+}
+
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Policy string `json:"policy"`
+}
+
+type nftRule struct {
+	Family  string                       `json:"family"`
+	Table   string                       `json:"table"`
+	Chain   string                       `json:"chain"`
+	Handle  int                          `json:"handle"`
+	Comment string                       `json:"comment"`
+	Expr    []map[string]json.RawMessage `json:"expr"`
+}
+
+type nftMatch struct {
+	Op    string      `json:"op"`
+	Left  interface{} `json:"left"`
+	Right interface{} `json:"right"`
+}
+
+type nftCounter struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// nftVerdicts Statements that decide what happens to a packet, these are used
+// as the target of the rule
+var nftVerdicts = map[string]bool{
+	"accept":     true,
+	"drop":       true,
+	"reject":     true,
+	"return":     true,
+	"continue":   true,
+	"jump":       true,
+	"goto":       true,
+	"queue":      true,
+	"masquerade": true,
+	"snat":       true,
+	"dnat":       true,
+	"redirect":   true,
+	"notrack":    true,
+}
+
+// ParseNftJSON Parses the output of `nft -j list ruleset`
+func ParseNftJSON(b []byte) ([]Rule, error) {
+	var ruleset nftRuleset
+
+	if err := json.Unmarshal(b, &ruleset); err != nil {
+		return nil, fmt.Errorf("could not parse nftables ruleset: %v", err)
+	}
+
+	policies := make(map[string]string)
+	positions := make(map[string]int)
+	rules := make([]Rule, 0)
+
+	for _, object := range ruleset.Nftables {
+		if raw, ok := object["chain"]; ok {
+			var chain nftChain
+
+			if err := json.Unmarshal(raw, &chain); err != nil {
+				return nil, fmt.Errorf("could not parse nftables chain: %v", err)
+			}
+
+			policies[nftChainKey(chain.Family, chain.Table, chain.Name)] = chain.Policy
+		}
+
+		if raw, ok := object["rule"]; ok {
+			var r nftRule
+
+			if err := json.Unmarshal(raw, &r); err != nil {
+				return nil, fmt.Errorf("could not parse nftables rule: %v", err)
+			}
+
+			key := nftChainKey(r.Family, r.Table, r.Chain)
+			positions[key]++
+
+			rule := parseNftRule(r)
+			rule.ChainPolicy = policies[key]
+			rule.Position = positions[key]
+
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// nftChainKey Returns a key that uniquely identifies a chain
+func nftChainKey(family, table, chain string) string {
+	return family + "/" + table + "/" + chain
+}
+
+// parseNftRule Converts an nftables rule to a Rule
+func parseNftRule(r nftRule) Rule {
+	rule := Rule{
+		Backend: BackendNftables,
+		Family:  r.Family,
+		Table:   r.Table,
+		Chain:   r.Chain,
+		Comment: r.Comment,
+	}
+
+	statements := make([]string, 0, len(r.Expr))
+
+	for _, expr := range r.Expr {
+		for key, raw := range expr {
+			var value interface{}
+			_ = json.Unmarshal(raw, &value)
+
+			switch {
+			case key == "match":
+				var m nftMatch
+
+				if err := json.Unmarshal(raw, &m); err == nil {
+					statements = append(statements, renderNftMatch(m))
+					parseNftMatch(&rule, m)
+				}
+			case key == "counter":
+				var c nftCounter
+
+				if err := json.Unmarshal(raw, &c); err == nil {
+					rule.Packets = &c.Packets
+					rule.Bytes = &c.Bytes
+				}
+
+				statements = append(statements, "counter")
+			case nftVerdicts[key]:
+				rule.Target = key
+
+				switch v := value.(type) {
+				case map[string]interface{}:
+					// Jumps have the chain as their target
+					if target, ok := v["target"].(string); ok {
+						rule.Target = key + " " + target
+					} else {
+						rule.TargetOptions = renderNftValue(v)
+					}
+				}
+
+				statements = append(statements, rule.Target)
+			default:
+				rendered := key
+
+				if value != nil {
+					rendered = key + " " + renderNftValue(value)
+				}
+
+				rule.Matches = append(rule.Matches, rendered)
+				statements = append(statements, rendered)
+			}
+		}
+	}
+
+	rule.Original = strings.Join(statements, " ")
+
+	return rule
+}
+
+// parseNftMatch Extracts the well known fields from a match expression, other
+// matches are stored as text
+func parseNftMatch(rule *Rule, m nftMatch) {
+	negate := ""
+
+	if m.Op == "!=" {
+		negate = "!"
+	}
+
+	left, ok := m.Left.(map[string]interface{})
+
+	if !ok {
+		rule.Matches = append(rule.Matches, renderNftMatch(m))
+		return
+	}
+
+	if payload, ok := left["payload"].(map[string]interface{}); ok {
+		protocol, _ := payload["protocol"].(string)
+		field, _ := payload["field"].(string)
+
+		switch field {
+		case "dport", "sport":
+			// "th" is the transport header, which matches any protocol
+			if protocol != "th" {
+				rule.Protocol = protocol
+			}
+
+			ports := nftValueList(m.Right)
+
+			for i := range ports {
+				ports[i] = negate + ports[i]
+			}
+
+			if field == "dport" {
+				rule.DestinationPorts = append(rule.DestinationPorts, ports...)
+			} else {
+				rule.SourcePorts = append(rule.SourcePorts, ports...)
+			}
+
+			return
+		case "saddr":
+			rule.Source = negate + renderNftValue(m.Right)
+			return
+		case "daddr":
+			rule.Destination = negate + renderNftValue(m.Right)
+			return
+		}
+	}
+
+	if meta, ok := left["meta"].(map[string]interface{}); ok {
+		switch meta["key"] {
+		case "l4proto":
+			rule.Protocol = negate + renderNftValue(m.Right)
+			return
+		case "iifname", "iif":
+			rule.InInterface = negate + renderNftValue(m.Right)
+			return
+		case "oifname", "oif":
+			rule.OutInterface = negate + renderNftValue(m.Right)
+			return
+		}
+	}
+
+	rule.Matches = append(rule.Matches, renderNftMatch(m))
+}
+
+// renderNftMatch Renders a match expression in a similar format to `nft list
+// ruleset` e.g. "ct state in established,related"
+func renderNftMatch(m nftMatch) string {
+	op := m.Op
+
+	if op == "" || op == "==" {
+		return renderNftValue(m.Left) + " " + renderNftValue(m.Right)
+	}
+
+	return renderNftValue(m.Left) + " " + op + " " + renderNftValue(m.Right)
+}
+
+// renderNftValue Renders the value of an expression as text
+func renderNftValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		return strings.Join(nftValueList(v), ",")
+	case map[string]interface{}:
+		if payload, ok := v["payload"].(map[string]interface{}); ok {
+			return fmt.Sprintf("%v %v", payload["protocol"], payload["field"])
+		}
+
+		for _, key := range []string{"meta", "ct"} {
+			if expr, ok := v[key].(map[string]interface{}); ok {
+				return fmt.Sprintf("%v %v", key, expr["key"])
+			}
+		}
+
+		if prefix, ok := v["prefix"].(map[string]interface{}); ok {
+			return fmt.Sprintf("%v/%v", renderNftValue(prefix["addr"]), renderNftValue(prefix["len"]))
+		}
+
+		if r, ok := v["range"].([]interface{}); ok && len(r) == 2 {
+			return renderNftValue(r[0]) + "-" + renderNftValue(r[1])
+		}
+
+		if set, ok := v["set"]; ok {
+			return "{ " + strings.Join(nftValueList(set), ", ") + " }"
+		}
+
+		// Fall back to key=value pairs for anything else
+		keys := make([]string, 0, len(v))
+
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		parts := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			if v[key] == nil {
+				parts = append(parts, key)
+			} else {
+				parts = append(parts, key+" "+renderNftValue(v[key]))
+			}
+		}
+
+		return strings.Join(parts, " ")
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// nftValueList Returns the members of a value that may be a single value, a
+// list, or an anonymous set
+func nftValueList(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]string, 0, len(v))
+
+		for _, item := range v {
+			list = append(list, renderNftValue(item))
+		}
+
+		return list
+	case map[string]interface{}:
+		if set, ok := v["set"]; ok {
+			return nftValueList(set)
+		}
+	}
+
+	return []string{renderNftValue(value)}
+}
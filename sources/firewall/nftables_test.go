@@ -0,0 +1,109 @@
+package firewall
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseNftJSON(t *testing.T) {
+	b, err := os.ReadFile("test/nft.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ParseNftJSON(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 7 {
+		t.Fatalf("expected 7 rules, got %v", len(rules))
+	}
+
+	t.Run("conntrack", func(t *testing.T) {
+		r := rules[0]
+
+		if r.ID() != "nftables/inet/filter/input/1" {
+			t.Errorf("unexpected ID %v", r.ID())
+		}
+
+		if !reflect.DeepEqual(r.Matches, []string{"ct state in established,related"}) {
+			t.Errorf("unexpected matches %v", r.Matches)
+		}
+
+		if r.Target != "accept" || r.ChainPolicy != "drop" {
+			t.Errorf("unexpected rule %+v", r)
+		}
+	})
+
+	t.Run("interface", func(t *testing.T) {
+		if rules[1].InInterface != "lo" {
+			t.Errorf("expected lo, got %v", rules[1].InInterface)
+		}
+	})
+
+	t.Run("ssh", func(t *testing.T) {
+		r := rules[2]
+
+		if r.Source != "192.168.1.0/24" || r.Protocol != "tcp" || r.Comment != "ssh from the office" {
+			t.Errorf("unexpected rule %+v", r)
+		}
+
+		if r.Packets == nil || *r.Packets != 10 {
+			t.Errorf("unexpected counters %v", r.Packets)
+		}
+
+		if r.Original != "ip saddr 192.168.1.0/24 tcp dport 22 counter accept" {
+			t.Errorf("unexpected text %v", r.Original)
+		}
+	})
+
+	t.Run("set and jump", func(t *testing.T) {
+		r := rules[3]
+
+		if !reflect.DeepEqual(r.LinkedPorts(), []int{80, 443}) {
+			t.Errorf("expected ports 80 and 443, got %v", r.LinkedPorts())
+		}
+
+		if r.Target != "jump web" {
+			t.Errorf("unexpected target %v", r.Target)
+		}
+	})
+
+	t.Run("range and log", func(t *testing.T) {
+		r := rules[4]
+
+		if !r.MatchesPort("udp", 60001) || r.MatchesPort("tcp", 60001) {
+			t.Error("expected rule to match udp/60001")
+		}
+
+		if !reflect.DeepEqual(r.Matches, []string{"log prefix mosh "}) {
+			t.Errorf("unexpected matches %q", r.Matches)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		r := rules[5]
+
+		if r.Target != "reject" || r.TargetOptions != "expr admin-prohibited type icmpx" {
+			t.Errorf("unexpected target %v %v", r.Target, r.TargetOptions)
+		}
+	})
+
+	t.Run("user chain", func(t *testing.T) {
+		r := rules[6]
+
+		if r.Source != "!10.0.0.1" || r.ChainPolicy != "" || r.Position != 1 {
+			t.Errorf("unexpected rule %+v", r)
+		}
+	})
+}
+
+func TestParseNftJSONError(t *testing.T) {
+	if _, err := ParseNftJSON([]byte("not json")); err == nil {
+		t.Error("expected error")
+	}
+}
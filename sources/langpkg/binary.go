@@ -0,0 +1,67 @@
+package langpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBinaryPaths The directories that are searched for Go and Rust
+// binaries by default. These are only the system binary directories, since
+// every executable that is found is opened to read its build information.
+// Application directories such as /opt should be added with --binary-paths
+var DefaultBinaryPaths = []string{
+	"/usr/bin",
+	"/usr/sbin",
+	"/usr/local/bin",
+	"/usr/local/sbin",
+}
+
+// findExecutables Finds the executable files in each directory, which are
+// then read to check whether they were built by the right toolchain
+func findExecutables(dir string, name string) ([]string, bool) {
+	switch name {
+	case "node_modules", "site-packages", "dist-packages", ".git":
+		return nil, true
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, false
+	}
+
+	locations := make([]string, 0)
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		if info, err := e.Info(); err == nil && info.Mode().Perm()&0111 != 0 {
+			locations = append(locations, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return locations, false
+}
+
+// embeddedLocation Returns the location of a package that is embedded in a
+// binary e.g. /usr/bin/app!github.com/spf13/cobra@v1.7.0. The main package
+// uses the location of the binary itself
+func embeddedLocation(binary string, name string, version string) string {
+	return binary + "!" + name + "@" + version
+}
+
+// embedded Converts a function that reads the packages in a binary into one
+// that can be used by an ecosystem, which also accepts the locations of
+// packages in the binary
+func embedded(read func(binary string) ([]Package, error)) func(location string) ([]Package, error) {
+	return func(location string) ([]Package, error) {
+		if i := strings.LastIndex(location, "!"); i >= 0 {
+			location = location[:i]
+		}
+
+		return read(location)
+	}
+}
@@ -0,0 +1,171 @@
+package langpkg
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache Remembers the locations of the packages below the search paths and
+// the packages that were read from each location, so that they aren't found
+// and read again for every request. Walking the search paths and parsing
+// binaries and archives is slow, and most requests are for packages that
+// haven't changed. The locations are found again if the modification time of
+// any directory that was walked has changed, since that is when entries are
+// added or removed, and a location is read again if its modification time or
+// size has changed. A nil cache doesn't cache anything
+type cache struct {
+	walkMutex sync.Mutex
+	roots     string
+	locations []string
+	dirs      map[string]time.Time
+
+	readMutex sync.Mutex
+	reads     map[string]cachedRead
+}
+
+// cachedRead The result of reading a location, along with the modification
+// time and size of the file or directory when it was read
+type cachedRead struct {
+	modTime  time.Time
+	size     int64
+	packages []Package
+	err      error
+}
+
+// walk Returns the locations below the roots, only walking them again if a
+// directory has changed since they were last walked
+func (c *cache) walk(ctx context.Context, roots []string, find func(dir string, name string) ([]string, bool)) ([]string, error) {
+	if c == nil {
+		locations, _, err := walk(ctx, roots, find)
+		return locations, err
+	}
+
+	// Requests that arrive while the roots are being walked wait for that
+	// walk rather than starting another one
+	c.walkMutex.Lock()
+	defer c.walkMutex.Unlock()
+
+	key := strings.Join(roots, "\n")
+
+	if c.dirs != nil && c.roots == key && unchanged(c.dirs) {
+		return append([]string(nil), c.locations...), nil
+	}
+
+	locations, dirs, err := walk(ctx, roots, find)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.roots = key
+	c.locations = locations
+	c.dirs = dirs
+
+	// Forget the packages of locations that no longer exist
+	found := make(map[string]bool)
+
+	for _, l := range locations {
+		found[l] = true
+	}
+
+	c.readMutex.Lock()
+
+	for l := range c.reads {
+		if !found[l] {
+			delete(c.reads, l)
+		}
+	}
+
+	c.readMutex.Unlock()
+
+	return append([]string(nil), locations...), nil
+}
+
+// read Returns the packages at a location, only reading it again if it has
+// changed since it was last read. Packages that are embedded in another file
+// e.g. /usr/bin/app!github.com/spf13/cobra@v1.7.0 are checked using that file
+func (c *cache) read(location string, read func(location string) ([]Package, error)) ([]Package, error) {
+	if c == nil {
+		return read(location)
+	}
+
+	path := location
+
+	if i := strings.Index(path, "!"); i >= 0 {
+		path = path[:i]
+	}
+
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return read(location)
+	}
+
+	c.readMutex.Lock()
+	cached, ok := c.reads[location]
+	c.readMutex.Unlock()
+
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.packages, cached.err
+	}
+
+	packages, err := read(location)
+
+	c.readMutex.Lock()
+
+	if c.reads == nil {
+		c.reads = make(map[string]cachedRead)
+	}
+
+	c.reads[location] = cachedRead{
+		modTime:  info.ModTime(),
+		size:     info.Size(),
+		packages: packages,
+		err:      err,
+	}
+
+	c.readMutex.Unlock()
+
+	return packages, err
+}
+
+// unchanged Returns whether every directory still has the modification time
+// that it had when it was walked, and directories that didn't exist still
+// don't
+func unchanged(dirs map[string]time.Time) bool {
+	for dir, modTime := range dirs {
+		info, err := os.Lstat(dir)
+
+		if err != nil {
+			if modTime.IsZero() && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return false
+		}
+
+		if !info.ModTime().Equal(modTime) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// anyExists Returns whether any of the paths exist. There can't be any
+// packages below search paths that don't exist, so sources whose search paths
+// are all missing aren't supported
+func anyExists(paths []string) bool {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
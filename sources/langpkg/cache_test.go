@@ -0,0 +1,133 @@
+package langpkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCacheRead(t *testing.T) {
+	dir := t.TempDir()
+	location := filepath.Join(dir, "app")
+
+	if err := os.WriteFile(location, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cache
+	reads := 0
+
+	read := func(location string) ([]Package, error) {
+		reads++
+		return []Package{{Name: "app", Location: location}}, nil
+	}
+
+	c.read(location, read)
+	c.read(location, read)
+
+	// Modules embedded in the binary are checked using the binary
+	c.read(location+"!github.com/spf13/cobra@v1.7.0", read)
+	c.read(location+"!github.com/spf13/cobra@v1.7.0", read)
+
+	if reads != 2 {
+		t.Errorf("expected 2 reads, got %v", reads)
+	}
+
+	if err := os.WriteFile(location, []byte("v2 is longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c.read(location, read)
+
+	if reads != 3 {
+		t.Errorf("expected the changed file to be read again, got %v reads", reads)
+	}
+
+	// A nil cache reads every time
+	var n *cache
+
+	n.read(location, read)
+
+	if reads != 4 {
+		t.Errorf("expected a nil cache to read, got %v reads", reads)
+	}
+}
+
+func TestCacheWalk(t *testing.T) {
+	root := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "missing")
+	past := time.Now().Add(-time.Hour)
+
+	if err := os.Mkdir(filepath.Join(root, "one.pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Set the modification time explicitly so that changes are seen even on
+	// filesystems with coarse timestamps
+	if err := os.Chtimes(root, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	var c cache
+	walks := 0
+
+	find := func(dir string, name string) ([]string, bool) {
+		if dir == root {
+			walks++
+		}
+
+		if filepath.Ext(name) == ".pkg" {
+			return []string{dir}, true
+		}
+
+		return nil, false
+	}
+
+	roots := []string{root, missing}
+
+	walkTo := func(expected ...string) {
+		t.Helper()
+
+		locations, err := c.walk(context.Background(), roots, find)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(locations, expected) {
+			t.Errorf("expected %v, got %v", expected, locations)
+		}
+	}
+
+	walkTo(filepath.Join(root, "one.pkg"))
+	walkTo(filepath.Join(root, "one.pkg"))
+
+	if walks != 1 {
+		t.Errorf("expected the roots to be walked once, got %v", walks)
+	}
+
+	// Adding a package changes the directory's modification time
+	if err := os.Mkdir(filepath.Join(root, "two.pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	walkTo(filepath.Join(root, "one.pkg"), filepath.Join(root, "two.pkg"))
+
+	if walks != 2 {
+		t.Errorf("expected the roots to be walked again, got %v", walks)
+	}
+
+	// A search path that is created later is walked too
+	if err := os.Mkdir(missing, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(filepath.Join(missing, "three.pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	walkTo(filepath.Join(root, "one.pkg"), filepath.Join(root, "two.pkg"), filepath.Join(missing, "three.pkg"))
+}
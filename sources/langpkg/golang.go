@@ -0,0 +1,192 @@
+package langpkg
+
+import (
+	"context"
+	"debug/buildinfo"
+	"errors"
+	"io/fs"
+	"runtime/debug"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// GoSource Returns the modules that Go binaries were built from, which are
+// read from the build information that the Go toolchain embeds in every
+// binary. Statically linked binaries don't appear in any package database, so
+// this is the only way to know what they contain. The main module uses the
+// binary as its location, and the modules that it depends on use the binary
+// followed by the module e.g. /usr/bin/app!github.com/spf13/cobra@v1.7.0
+type GoSource struct {
+	// The directories to search for binaries. Defaults to DefaultBinaryPaths
+	SearchPaths []string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *GoSource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *GoSource) Name() string {
+	return "langpkg-go"
+}
+
+// Weighting of duplicate sources
+func (s *GoSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *GoSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the main module of a binary, or a module in a binary
+func (s *GoSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns the modules of all binaries below the search paths
+func (s *GoSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the modules with the given path e.g. golang.org/x/net. If
+// the query is the absolute path of a binary it returns all of the modules
+// that it was built from, or of all binaries below a directory
+func (s *GoSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Go binaries can be anywhere, so this is supported as long as one of
+// the search paths exists
+func (s *GoSource) Supported() bool {
+	return anyExists(s.searchPaths())
+}
+
+func (s *GoSource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultBinaryPaths
+	}
+
+	return s.SearchPaths
+}
+
+// ecosystem Returns the ecosystem with the source's cache, which is kept
+// between requests
+func (s *GoSource) ecosystem() ecosystem {
+	e := golang
+	e.cache = &s.cache
+
+	return e
+}
+
+var golang = ecosystem{
+	find:      findExecutables,
+	read:      embedded(ReadGo),
+	normalize: identity,
+}
+
+// ReadGo Reads the modules that a Go binary was built from. Returns
+// ErrNotPackage if the file isn't a Go binary
+func ReadGo(binary string) ([]Package, error) {
+	info, err := buildinfo.ReadFile(binary)
+
+	if err != nil {
+		var pathError *fs.PathError
+
+		// Anything other than failing to open the file means that it isn't a
+		// Go binary, or is too old to contain build information
+		if errors.As(err, &pathError) {
+			return nil, err
+		}
+
+		return nil, ErrNotPackage
+	}
+
+	return GoModules(info, binary), nil
+}
+
+// GoModules Converts the build information of a binary into packages. The
+// first is the main module, followed by the standard library, which is
+// versioned with the toolchain (e.g. go1.21.3), and the modules that the main
+// module depends on. The main module is called command-line-arguments if the
+// binary was built from files rather than a module
+func GoModules(info *debug.BuildInfo, binary string) []Package {
+	main := Package{
+		Ecosystem:     "go",
+		Name:          info.Main.Path,
+		Version:       info.Main.Version,
+		Location:      binary,
+		Binary:        binary,
+		Toolchain:     info.GoVersion,
+		Checksum:      info.Main.Sum,
+		BuildSettings: make(map[string]string),
+		Depends:       make([]packagedeps.Dependency, 0, len(info.Deps)),
+	}
+
+	if main.Name == "" {
+		main.Name = info.Path
+	}
+
+	for _, setting := range info.Settings {
+		main.BuildSettings[setting.Key] = setting.Value
+	}
+
+	packages := []Package{
+		main,
+		{
+			Ecosystem: "go",
+			Name:      "stdlib",
+			Version:   info.GoVersion,
+			Location:  embeddedLocation(binary, "stdlib", info.GoVersion),
+			Binary:    binary,
+			Toolchain: info.GoVersion,
+			Depends:   make([]packagedeps.Dependency, 0),
+		},
+	}
+
+	for _, dep := range info.Deps {
+		p := Package{
+			Ecosystem: "go",
+			Name:      dep.Path,
+			Version:   dep.Version,
+			Location:  embeddedLocation(binary, dep.Path, dep.Version),
+			Binary:    binary,
+			Toolchain: info.GoVersion,
+			Checksum:  dep.Sum,
+			Depends:   make([]packagedeps.Dependency, 0),
+		}
+
+		// The code that was built is from the replacement, which can be a
+		// local directory without a version
+		if dep.Replace != nil {
+			p.Replacement = dep.Replace.Path
+
+			if dep.Replace.Version != "" {
+				p.Replacement += "@" + dep.Replace.Version
+			}
+
+			p.Checksum = dep.Replace.Sum
+		}
+
+		// Build information doesn't say which modules depend on which, so the
+		// main module depends on all of them
+		packages[0].Depends = append(packages[0].Depends, packagedeps.Dependency{
+			Name:     dep.Path,
+			Operator: "=",
+			Version:  dep.Version,
+		})
+
+		packages = append(packages, p)
+	}
+
+	return packages
+}
@@ -0,0 +1,231 @@
+package langpkg
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestGoModules(t *testing.T) {
+	data, err := os.ReadFile("test/go/buildinfo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := debug.ParseBuildInfo(string(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Go version is read from the binary rather than from the build
+	// information
+	info.GoVersion = "go1.21.3"
+
+	packages := GoModules(info, "/usr/bin/app")
+
+	if len(packages) != 5 {
+		t.Fatalf("expected 5 packages, got %v", len(packages))
+	}
+
+	main := packages[0]
+
+	if main.Name != "github.com/example/app" || main.Version != "v1.2.3" || main.Location != "/usr/bin/app" || main.Toolchain != "go1.21.3" {
+		t.Errorf("unexpected main module %+v", main)
+	}
+
+	if main.BuildSettings["GOOS"] != "linux" || main.BuildSettings["vcs.revision"] != "4b9d7a6c1c1e4c6f0a6f5f8e0b1d2c3e4f5a6b7c" {
+		t.Errorf("unexpected build settings %v", main.BuildSettings)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "github.com/spf13/cobra", Operator: "=", Version: "v1.7.0"},
+		{Name: "github.com/spf13/pflag", Operator: "=", Version: "v1.0.5"},
+		{Name: "golang.org/x/sys", Operator: "=", Version: "v0.13.0"},
+	}
+
+	if !reflect.DeepEqual(main.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, main.Depends)
+	}
+
+	if stdlib := packages[1]; stdlib.Name != "stdlib" || stdlib.Version != "go1.21.3" || stdlib.Location != "/usr/bin/app!stdlib@go1.21.3" {
+		t.Errorf("unexpected stdlib %+v", stdlib)
+	}
+
+	if cobra := packages[2]; cobra.Location != "/usr/bin/app!github.com/spf13/cobra@v1.7.0" || cobra.Binary != "/usr/bin/app" || cobra.Checksum != "h1:hyqWnYt1ZQShIddO5kBpj3vu05/++x6tJ6dg8EC572I=" {
+		t.Errorf("unexpected module %+v", cobra)
+	}
+
+	if sys := packages[4]; sys.Replacement != "../sys" || sys.Checksum != "" {
+		t.Errorf("unexpected replaced module %+v", sys)
+	}
+}
+
+func TestReadGo(t *testing.T) {
+	// The test binary is a Go binary
+	exe, err := os.Executable()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := ReadGo(exe)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if packages[0].Name != "github.com/overmindtech/overmind-agent" {
+		t.Errorf("expected main module github.com/overmindtech/overmind-agent, got %v", packages[0].Name)
+	}
+
+	if _, err := ReadGo("test/rust/bin/hello"); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+
+	if _, err := ReadGo("test/go/buildinfo"); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+
+	if _, err := ReadGo("test/go/notafile"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	exe, err := os.Executable()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rust, err := filepath.Abs("test/rust")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := GoSource{
+		SearchPaths: []string{filepath.Dir(exe)},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get main module",
+			ItemContext: util.LocalContext,
+			Query:       exe,
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "github.com/overmindtech/overmind-agent",
+						"ecosystem": "go",
+						"binary":    exe,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get stdlib",
+			ItemContext: util.LocalContext,
+			Query:       embeddedLocation(exe, "stdlib", runtimeVersion(t)),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":    "stdlib",
+						"version": runtimeVersion(t),
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing module",
+			ItemContext: util.LocalContext,
+			Query:       embeddedLocation(exe, "example.com/notamodule", "v1.0.0"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get not a go binary",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(rust, "bin/rg"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "search module",
+			ItemContext: util.LocalContext,
+			Query:       "github.com/overmindtech/sdp-go",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"binary": exe,
+					},
+				},
+			},
+		},
+		{
+			Name:        "search not a go binary",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(rust, "bin/rg"),
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "search wrong context",
+			ItemContext: "foo",
+			Query:       exe,
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+
+	t.Run("search binary", func(t *testing.T) {
+		items, err := src.Search(context.Background(), util.LocalContext, exe)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The main module, the standard library and at least sdp-go
+		if len(items) < 3 {
+			t.Errorf("expected at least 3 items, got %v", len(items))
+		}
+	})
+}
+
+// runtimeVersion Returns the version of Go that the test binary was built with
+func runtimeVersion(t *testing.T) string {
+	info, ok := debug.ReadBuildInfo()
+
+	if !ok {
+		t.Fatal("test binary has no build information")
+	}
+
+	return info.GoVersion
+}
@@ -0,0 +1,504 @@
+package langpkg
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultJavaPaths The directories that are searched for Java archives by
+// default. This includes the system's shared jars and applications in common
+// application and home directories. The classpaths of running java processes
+// are always searched too
+var DefaultJavaPaths = []string{
+	"/usr/share/java",
+	"/usr/local/lib",
+	"/opt",
+	"/srv",
+	"/home",
+	"/root",
+}
+
+// JavaSource Returns the libraries in Java archives (jar, war and ear files),
+// including the jars that are nested inside other archives such as
+// WEB-INF/lib in a war or BOOT-INF/lib in a Spring Boot jar. Each archive is
+// identified by its META-INF/maven/**/pom.properties as groupId:artifactId,
+// or by its file name if it doesn't have one. Archives on disk use their path
+// as their location, and nested archives use the path of the archive that
+// contains them followed by their path inside it e.g.
+// /srv/app.war!/WEB-INF/lib/log4j-core-2.14.1.jar
+type JavaSource struct {
+	// The directories to search for archives. Defaults to DefaultJavaPaths
+	SearchPaths []string
+
+	// Where procfs is mounted if not the default location, which is used to
+	// find the classpaths of java processes (optional)
+	ProcLocation string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *JavaSource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *JavaSource) Name() string {
+	return "langpkg-java"
+}
+
+// Weighting of duplicate sources
+func (s *JavaSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *JavaSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the library at a location, which can be an archive or an
+// archive nested inside one
+func (s *JavaSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns the libraries in all archives below the search paths and on
+// the classpaths of running java processes
+func (s *JavaSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the libraries with the given groupId:artifactId e.g.
+// org.apache.logging.log4j:log4j-core. If the query is an absolute path it
+// returns all of the libraries in that archive, or in all archives below a
+// directory
+func (s *JavaSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Java archives can be anywhere, so this is supported as long as
+// one of the search paths exists or the classpaths of java processes can be
+// read from procfs
+func (s *JavaSource) Supported() bool {
+	proc := procfs.ProcFS{Location: s.ProcLocation}
+
+	if _, err := proc.PIDs(); err == nil {
+		return true
+	}
+
+	return anyExists(s.searchPaths())
+}
+
+func (s *JavaSource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultJavaPaths
+	}
+
+	return s.SearchPaths
+}
+
+func (s *JavaSource) ecosystem() ecosystem {
+	return ecosystem{
+		find:       findArchives,
+		read:       readJava,
+		normalize:  identity,
+		discover:   s.classpaths,
+		cache:      &s.cache,
+		colonNames: true,
+	}
+}
+
+// classpaths Returns the archives on the classpaths of all running java
+// processes. Stops early if the context is cancelled
+func (s *JavaSource) classpaths(ctx context.Context) []string {
+	proc := procfs.ProcFS{Location: s.ProcLocation}
+
+	pids, err := proc.PIDs()
+
+	if err != nil {
+		return nil
+	}
+
+	archives := make([]string, 0)
+
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if classpath, err := proc.JavaClasspath(pid); err == nil {
+			archives = append(archives, classpath...)
+		}
+	}
+
+	return archives
+}
+
+// isArchive Returns whether a file name is that of a Java archive
+func isArchive(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jar", ".war", ".ear":
+		return true
+	}
+
+	return false
+}
+
+// findArchives Finds the archives in each directory. Exploded archives are
+// directories so their contents are searched too
+func findArchives(dir string, name string) ([]string, bool) {
+	switch name {
+	case "node_modules", "site-packages", "dist-packages", ".git":
+		return nil, true
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, false
+	}
+
+	locations := make([]string, 0)
+
+	for _, e := range entries {
+		if e.Type().IsRegular() && isArchive(e.Name()) {
+			locations = append(locations, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return locations, false
+}
+
+// readJava Reads the archive on disk that contains a location
+func readJava(location string) ([]Package, error) {
+	archive, _, _ := strings.Cut(location, "!/")
+
+	return ReadJava(archive)
+}
+
+const (
+	// maxArchiveDepth How many levels of nested archives are read
+	maxArchiveDepth = 4
+
+	// maxNestedArchiveSize The largest nested archive that will be read.
+	// These have to be read into memory since zip files can't be streamed
+	maxNestedArchiveSize = 64 * 1024 * 1024
+)
+
+// ReadJava Reads the libraries in an archive. The first is the archive
+// itself, followed by the libraries that are embedded in it. Returns
+// ErrNotPackage if the file isn't a zip file
+func ReadJava(archive string) ([]Package, error) {
+	r, err := zip.OpenReader(archive)
+
+	if err != nil {
+		var pathError *fs.PathError
+
+		if errors.As(err, &pathError) {
+			return nil, err
+		}
+
+		return nil, ErrNotPackage
+	}
+
+	defer r.Close()
+
+	return readArchive(&r.Reader, archive, archive, 0), nil
+}
+
+// readArchive Reads the libraries in an archive, which can be nested inside
+// other archives. The archive's own library depends on the libraries that are
+// embedded in it: nested archives, and the pom.properties of any libraries
+// that were shaded into it
+func readArchive(r *zip.Reader, location string, binary string, depth int) []Package {
+	var manifest map[string]string
+
+	poms := make([]PomProperties, 0)
+	nested := make([]Package, 0)
+	direct := make([]Package, 0)
+
+	for _, f := range r.File {
+		switch {
+		case f.Name == "META-INF/MANIFEST.MF":
+			if data, err := readZipFile(f); err == nil {
+				manifest, _ = ParseManifest(bytes.NewReader(data))
+			}
+		case strings.HasPrefix(f.Name, "META-INF/maven/") && path.Base(f.Name) == "pom.properties":
+			if data, err := readZipFile(f); err == nil {
+				if pom, err := ParsePomProperties(bytes.NewReader(data)); err == nil {
+					pom.Entry = f.Name
+					poms = append(poms, pom)
+				}
+			}
+		case isArchive(f.Name) && depth < maxArchiveDepth && f.UncompressedSize64 <= maxNestedArchiveSize:
+			if packages := readNestedArchive(f, location+"!/"+f.Name, binary, depth+1); len(packages) > 0 {
+				direct = append(direct, packages[0])
+				nested = append(nested, packages...)
+			}
+		}
+	}
+
+	base := path.Base(filepath.ToSlash(location))
+	name := strings.TrimSuffix(base, path.Ext(base))
+	own := ownPom(name, poms)
+
+	p := Package{
+		Ecosystem: "maven",
+		Location:  location,
+		Binary:    binary,
+		Depends:   make([]packagedeps.Dependency, 0),
+	}
+
+	if own >= 0 {
+		p.Name = poms[own].Name()
+		p.Version = poms[own].Version
+	} else {
+		p.Name, p.Version = parseArchiveName(name)
+
+		if p.Version == "" {
+			p.Version = firstField(manifest, "Implementation-Version", "Bundle-Version", "Specification-Version")
+		}
+	}
+
+	p.Summary = firstField(manifest, "Implementation-Title", "Bundle-Name", "Specification-Title")
+	p.Author = firstField(manifest, "Implementation-Vendor", "Bundle-Vendor", "Specification-Vendor")
+	p.URL = firstField(manifest, "Bundle-DocURL", "Implementation-URL")
+	p.License = firstField(manifest, "Bundle-License")
+
+	shaded := make([]Package, 0)
+
+	for i, pom := range poms {
+		if i == own {
+			continue
+		}
+
+		shaded = append(shaded, Package{
+			Ecosystem: "maven",
+			Name:      pom.Name(),
+			Version:   pom.Version,
+			Location:  location + "!/" + pom.Entry,
+			Binary:    binary,
+			Depends:   make([]packagedeps.Dependency, 0),
+		})
+	}
+
+	for _, d := range append(shaded, direct...) {
+		p.Depends = append(p.Depends, packagedeps.Dependency{
+			Name:     d.Name,
+			Operator: "=",
+			Version:  d.Version,
+		})
+	}
+
+	return append(append([]Package{p}, shaded...), nested...)
+}
+
+// readNestedArchive Reads the libraries in an archive that is inside another
+// archive. Archives that can't be read are skipped
+func readNestedArchive(f *zip.File, location string, binary string, depth int) []Package {
+	rc, err := f.Open()
+
+	if err != nil {
+		return nil
+	}
+
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxNestedArchiveSize))
+
+	if err != nil {
+		return nil
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+
+	if err != nil {
+		return nil
+	}
+
+	return readArchive(r, location, binary, depth)
+}
+
+// maxMetadataSize The largest manifest or pom.properties that will be read
+const maxMetadataSize = 1024 * 1024
+
+// readZipFile Reads a metadata file from an archive
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxMetadataSize))
+}
+
+// ownPom Returns the index of the pom.properties that describes the archive
+// itself. Archives that have had other libraries shaded into them have a
+// pom.properties for each one, so the archive's is the one whose artifactId
+// best matches its file name e.g. log4j-core-2.14.1. If there is only one it
+// is used regardless of the file name. Returns -1 if there isn't one
+func ownPom(name string, poms []PomProperties) int {
+	own := -1
+
+	for i, pom := range poms {
+		if name != pom.ArtifactID && !strings.HasPrefix(name, pom.ArtifactID+"-") {
+			continue
+		}
+
+		if own < 0 || len(pom.ArtifactID) > len(poms[own].ArtifactID) {
+			own = i
+		}
+	}
+
+	if own < 0 && len(poms) == 1 {
+		own = 0
+	}
+
+	return own
+}
+
+// archiveName Matches the file name of an archive that has a version e.g.
+// commons-lang3-3.12.0, the first group is the name and the second is the
+// version
+var archiveName = regexp.MustCompile(`^(.+?)-(\d.*)$`)
+
+// parseArchiveName Returns the name and version of an archive from its file
+// name, for archives that don't have a pom.properties
+func parseArchiveName(name string) (string, string) {
+	if matches := archiveName.FindStringSubmatch(name); matches != nil {
+		return matches[1], matches[2]
+	}
+
+	return name, ""
+}
+
+// firstField Returns the first of the given fields of a manifest that is set
+func firstField(manifest map[string]string, fields ...string) string {
+	for _, field := range fields {
+		if value := manifest[field]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// PomProperties The details of a library that was built by Maven, from
+// META-INF/maven/{groupId}/{artifactId}/pom.properties
+type PomProperties struct {
+	GroupID    string
+	ArtifactID string
+	Version    string
+
+	// The path of the file within the archive
+	Entry string
+}
+
+// Name Returns the name of the library as groupId:artifactId
+func (p PomProperties) Name() string {
+	if p.GroupID == "" {
+		return p.ArtifactID
+	}
+
+	return p.GroupID + ":" + p.ArtifactID
+}
+
+// ParsePomProperties Parses a pom.properties file, which is a Java properties
+// file e.g.
+//
+//	#Created by Apache Maven 3.6.3
+//	groupId=org.apache.logging.log4j
+//	artifactId=log4j-core
+//	version=2.14.1
+func ParsePomProperties(r io.Reader) (PomProperties, error) {
+	var p PomProperties
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+
+		if !found {
+			key, value, _ = strings.Cut(line, ":")
+		}
+
+		switch strings.TrimSpace(key) {
+		case "groupId":
+			p.GroupID = strings.TrimSpace(value)
+		case "artifactId":
+			p.ArtifactID = strings.TrimSpace(value)
+		case "version":
+			p.Version = strings.TrimSpace(value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return PomProperties{}, err
+	}
+
+	if p.ArtifactID == "" {
+		return PomProperties{}, fmt.Errorf("pom.properties doesn't have an artifactId")
+	}
+
+	return p, nil
+}
+
+// ParseManifest Parses the main section of a META-INF/MANIFEST.MF file. Each
+// line is a header e.g. "Implementation-Version: 2.14.1", and long values are
+// continued on the next line, which starts with a space. The main section
+// ends at the first blank line
+func ParseManifest(r io.Reader) (map[string]string, error) {
+	manifest := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+
+	var last string
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, " ") && last != "" {
+			manifest[last] += line[1:]
+			continue
+		}
+
+		if name, value, found := strings.Cut(line, ":"); found {
+			last = strings.TrimSpace(name)
+			manifest[last] = strings.TrimSpace(value)
+		}
+	}
+
+	return manifest, scanner.Err()
+}
@@ -0,0 +1,322 @@
+package langpkg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := ParseManifest(strings.NewReader("Manifest-Version: 1.0\r\nImplementation-Vendor: The Apache Software\r\n  Foundation\r\nBundle-Version: 3.12.0\r\n\r\nName: org/apache/\r\nBundle-Version: ignored\r\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Manifest-Version":      "1.0",
+		"Implementation-Vendor": "The Apache Software Foundation",
+		"Bundle-Version":        "3.12.0",
+	}
+
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("expected %v, got %v", expected, manifest)
+	}
+}
+
+func TestParsePomProperties(t *testing.T) {
+	pom, err := ParsePomProperties(strings.NewReader("#Generated by Maven\n#Tue Jan 01 00:00:00 UTC 2023\nversion=2.14.1\ngroupId=org.apache.logging.log4j\nartifactId = log4j-core\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pom.Name() != "org.apache.logging.log4j:log4j-core" || pom.Version != "2.14.1" {
+		t.Errorf("unexpected pom.properties %+v", pom)
+	}
+
+	if _, err := ParsePomProperties(strings.NewReader("version=1.0\n")); err == nil {
+		t.Error("expected an error without an artifactId")
+	}
+}
+
+func TestReadJava(t *testing.T) {
+	war := "test/java/srv/app.war"
+
+	packages, err := ReadJava(war)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locations := make([]string, 0)
+
+	for _, p := range packages {
+		locations = append(locations, p.Location)
+	}
+
+	// The broken jar is skipped
+	expectedLocations := []string{
+		war,
+		war + "!/WEB-INF/lib/log4j-core-2.14.1.jar",
+		war + "!/WEB-INF/lib/util-2.0.jar",
+		war + "!/WEB-INF/lib/util-2.0.jar!/META-INF/maven/com.google.guava/guava/pom.properties",
+		war + "!/WEB-INF/lib/commons-lang3-3.12.0.jar",
+	}
+
+	if !reflect.DeepEqual(locations, expectedLocations) {
+		t.Fatalf("expected %q, got %q", expectedLocations, locations)
+	}
+
+	if app := packages[0]; app.Name != "com.example:app" || app.Version != "1.0.0" || app.Summary != "Example App" || app.Binary != war {
+		t.Errorf("unexpected package %+v", app)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "org.apache.logging.log4j:log4j-core", Operator: "=", Version: "2.14.1"},
+		{Name: "com.example:util", Operator: "=", Version: "2.0"},
+		{Name: "commons-lang3", Operator: "=", Version: "3.12.0"},
+	}
+
+	if !reflect.DeepEqual(packages[0].Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, packages[0].Depends)
+	}
+
+	if log4j := packages[1]; log4j.Name != "org.apache.logging.log4j:log4j-core" || log4j.License != "https://www.apache.org/licenses/LICENSE-2.0.txt" || log4j.Author != "The Apache Software Foundation" || log4j.Binary != war {
+		t.Errorf("unexpected package %+v", log4j)
+	}
+
+	// Guava has been shaded into util
+	if util := packages[2]; !reflect.DeepEqual(util.Depends, []packagedeps.Dependency{{Name: "com.google.guava:guava", Operator: "=", Version: "32.1.2-jre"}}) {
+		t.Errorf("unexpected package %+v", util)
+	}
+
+	// Without a pom.properties the name and version come from the file name
+	if lang := packages[4]; lang.Name != "commons-lang3" || lang.Version != "3.12.0" || lang.Summary != "Apache Commons Lang" {
+		t.Errorf("unexpected package %+v", lang)
+	}
+
+	// Or from the manifest if the file name doesn't have a version
+	jna, err := ReadJava("test/java/usr/share/java/jna.jar")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jna[0].Name != "jna" || jna[0].Version != "5.12.1" {
+		t.Errorf("unexpected package %+v", jna[0])
+	}
+
+	if _, err := ReadJava("test/java/usr/share/java/broken.jar"); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+}
+
+func TestParseArchiveName(t *testing.T) {
+	tests := map[string][2]string{
+		"commons-lang3-3.12.0": {"commons-lang3", "3.12.0"},
+		"guava-32.1.2-jre":     {"guava", "32.1.2-jre"},
+		"jna":                  {"jna", ""},
+	}
+
+	for name, expected := range tests {
+		if n, v := parseArchiveName(name); n != expected[0] || v != expected[1] {
+			t.Errorf("%v: expected %v, got %v %v", name, expected, n, v)
+		}
+	}
+}
+
+func TestJavaSource(t *testing.T) {
+	root, err := filepath.Abs("test/java")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	war := filepath.Join(root, "srv/app.war")
+
+	src := JavaSource{
+		SearchPaths:  []string{root},
+		ProcLocation: filepath.Join(t.TempDir(), "proc"),
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get archive",
+			ItemContext: util.LocalContext,
+			Query:       war,
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "com.example:app",
+						"version":   "1.0.0",
+						"ecosystem": "maven",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get nested archive",
+			ItemContext: util.LocalContext,
+			Query:       war + "!/WEB-INF/lib/log4j-core-2.14.1.jar",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":    "org.apache.logging.log4j:log4j-core",
+						"version": "2.14.1",
+						"binary":  war,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing nested archive",
+			ItemContext: util.LocalContext,
+			Query:       war + "!/WEB-INF/lib/notajar.jar",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 6,
+			},
+		},
+		{
+			Name:        "search",
+			ItemContext: util.LocalContext,
+			Query:       "org.apache.logging.log4j:log4j-core",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search archive",
+			ItemContext: util.LocalContext,
+			Query:       war,
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search wrong context",
+			ItemContext: "foo",
+			Query:       war,
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+func TestJavaSourceClasspath(t *testing.T) {
+	war, err := filepath.Abs("test/java/srv/app.war")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A java process with the war on its classpath
+	proc := filepath.Join(t.TempDir(), "proc")
+	pid := filepath.Join(proc, "100")
+
+	if err := os.MkdirAll(pid, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(pid, "cmdline"), []byte("java\x00-cp\x00"+war+"\x00com.example.Main\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink("/", filepath.Join(pid, "root")); err != nil {
+		t.Fatal(err)
+	}
+
+	src := JavaSource{
+		SearchPaths:  []string{},
+		ProcLocation: proc,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search",
+			ItemContext: util.LocalContext,
+			Query:       "com.google.guava:guava",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"location": war + "!/WEB-INF/lib/util-2.0.jar!/META-INF/maven/com.google.guava/guava/pom.properties",
+					},
+				},
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+func TestJavaSourceSupported(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	t.Run("with procfs and no search paths", func(t *testing.T) {
+		src := JavaSource{
+			SearchPaths:  []string{},
+			ProcLocation: t.TempDir(),
+		}
+
+		if !src.Supported() {
+			t.Error("expected source to be supported since classpaths can be read")
+		}
+	})
+
+	t.Run("with a search path and no procfs", func(t *testing.T) {
+		src := JavaSource{
+			SearchPaths:  []string{t.TempDir()},
+			ProcLocation: missing,
+		}
+
+		if !src.Supported() {
+			t.Error("expected source to be supported since a search path exists")
+		}
+	})
+
+	t.Run("with neither", func(t *testing.T) {
+		src := JavaSource{
+			SearchPaths:  []string{missing},
+			ProcLocation: missing,
+		}
+
+		if src.Supported() {
+			t.Error("expected source not to be supported")
+		}
+	})
+}
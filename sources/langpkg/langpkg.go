@@ -0,0 +1,495 @@
+// Package langpkg contains sources for the packages of language ecosystems
+// such as Python, Node and Ruby, which are installed by their own package
+// managers rather than the operating system's. Each source looks for packages
+// below a list of search paths, including virtualenvs and the node_modules of
+// applications. These are returned as package items with an ecosystem, which
+// are identified by their location rather than their name like the packages of
+// the operating system's package manager, since the same package is often
+// installed in many places
+package langpkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// MaxDepth How many directories deep to look for packages below each search
+// path
+const MaxDepth = 8
+
+// ErrNotPackage is returned when a location doesn't contain a package of the
+// right ecosystem
+var ErrNotPackage = errors.New("location is not a package")
+
+// Package A package that has been installed by a language's package manager
+type Package struct {
+	// The ecosystem that the package belongs to e.g. pypi, npm or rubygems
+	Ecosystem string
+
+	Name    string
+	Version string
+	Summary string
+	License string
+	URL     string
+	Author  string
+	Depends []packagedeps.Dependency
+
+	// Where the package is installed. This is unique, since the same package
+	// can be installed in many places
+	Location string
+
+	// The virtualenv that a Python package is installed in, if any
+	Virtualenv string
+
+	// The file that a package is embedded in, such as the Go or Rust binary
+	// that it was compiled into or the Java archive that contains it. This is
+	// linked instead of the location
+	Binary string
+
+	// The toolchain that built the binary e.g. go1.21.3
+	Toolchain string
+
+	// The checksum of the package's source, such as the h1: hash of a Go
+	// module
+	Checksum string
+
+	// What the package was replaced with when it was built, such as a Go
+	// module replace directive
+	Replacement string
+
+	// Where the package came from, for ecosystems with more than one e.g.
+	// crates.io, git or local for Rust
+	Source string
+
+	// The settings that the binary was built with e.g. GOOS and vcs.revision.
+	// Only set for the main module
+	BuildSettings map[string]string
+}
+
+// ecosystem The functions that are needed to read the packages of an
+// ecosystem
+type ecosystem struct {
+	// find Is called for each directory below the search paths and returns
+	// the locations of any packages that it contains, and whether the
+	// directory's contents should be skipped
+	find func(dir string, name string) ([]string, bool)
+
+	// read Reads the packages at a location, returning ErrNotPackage if the
+	// location doesn't contain a package of this ecosystem. This is usually a
+	// single package, but binaries contain all of the packages that they were
+	// built from
+	read func(location string) ([]Package, error)
+
+	// normalize Converts names into the format used to compare them, if
+	// names in the ecosystem aren't case sensitive for example
+	normalize func(name string) string
+
+	// discover Returns the locations of packages that are found some other
+	// way than searching directories, such as from running processes
+	// (optional)
+	discover func(ctx context.Context) []string
+
+	// cache Remembers the locations below the search paths and the packages
+	// at each location between requests (optional)
+	cache *cache
+
+	// colonNames Whether package names in the ecosystem contain a colon, such
+	// as the groupId:artifactId of a Java library. Other ecosystems treat
+	// name:value queries as meant for another source
+	colonNames bool
+}
+
+// foreignPrefixes The prefixes of the package searches that are answered by
+// the operating system's package sources, such as the dependency links of
+// dpkg and apk packages. These can never match a language package
+var foreignPrefixes = []string{
+	"provides:",
+	"rdepends:",
+	"so:",
+	"cmd:",
+	"pc:",
+	"integrity:",
+	"update:",
+}
+
+// isForeignQuery Returns whether a search query is meant for another package
+// source, either because it has one of the foreignPrefixes or because it is
+// a name:value or name(...) capability e.g. libc.so.6()(64bit)
+func (e ecosystem) isForeignQuery(query string) bool {
+	for _, prefix := range foreignPrefixes {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+
+	if strings.Contains(query, "(") {
+		return true
+	}
+
+	return !e.colonNames && strings.Contains(query, ":")
+}
+
+// get Returns the package at a location
+func (e ecosystem) get(ctx context.Context, itemContext string, location string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, noContextError(itemContext)
+	}
+
+	if ctx.Err() != nil {
+		return nil, otherError(itemContext, ctx.Err())
+	}
+
+	if !filepath.IsAbs(location) {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("%v is not an absolute path", location),
+			Context:     itemContext,
+		}
+	}
+
+	packages, err := e.cache.read(location, e.read)
+
+	if errors.Is(err, ErrNotPackage) || errors.Is(err, fs.ErrNotExist) {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if err != nil {
+		return nil, otherError(itemContext, err)
+	}
+
+	for _, p := range packages {
+		if p.Location == location {
+			return mapPackageToItem(p)
+		}
+	}
+
+	return nil, &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOTFOUND,
+		ErrorString: fmt.Sprintf("no package found at %v", location),
+		Context:     itemContext,
+	}
+}
+
+// findAll Returns all packages below the search paths
+func (e ecosystem) findAll(ctx context.Context, itemContext string, searchPaths []string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, noContextError(itemContext)
+	}
+
+	locations, err := e.locations(ctx, searchPaths)
+
+	if err != nil {
+		return nil, otherError(itemContext, err)
+	}
+
+	items, err := e.items(ctx, locations, nil)
+
+	if err != nil {
+		return nil, otherError(itemContext, err)
+	}
+
+	return items, nil
+}
+
+// search Returns the packages with a given name below the search paths. If
+// the query is an absolute path it instead returns all packages below that
+// directory, e.g. the dependencies of an application, or all packages in that
+// file if it isn't a directory, e.g. the modules that a binary was built from
+func (e ecosystem) search(ctx context.Context, itemContext string, query string, searchPaths []string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, noContextError(itemContext)
+	}
+
+	// Other package sources are searched with the same queries, so these are
+	// rejected before searching every search path
+	if !filepath.IsAbs(query) && e.isForeignQuery(query) {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("%v is not a language package query", query),
+			Context:     itemContext,
+		}
+	}
+
+	var locations []string
+	var filter func(Package) bool
+	var err error
+
+	switch {
+	case filepath.IsAbs(query) && isDir(query):
+		locations, _, err = walk(ctx, []string{query}, e.find)
+	case filepath.IsAbs(query):
+		locations = []string{query}
+	default:
+		name := e.normalize(query)
+		locations, err = e.locations(ctx, searchPaths)
+		filter = func(p Package) bool {
+			return e.normalize(p.Name) == name
+		}
+	}
+
+	if err != nil {
+		return nil, otherError(itemContext, err)
+	}
+
+	items, err := e.items(ctx, locations, filter)
+
+	if err != nil {
+		return nil, otherError(itemContext, err)
+	}
+
+	if len(items) == 0 {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("no packages found matching %v", query),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// locations Returns the locations of the packages below the search paths,
+// along with any that are discovered
+func (e ecosystem) locations(ctx context.Context, searchPaths []string) ([]string, error) {
+	locations, err := e.cache.walk(ctx, searchPaths, e.find)
+
+	if err != nil || e.discover == nil {
+		return locations, err
+	}
+
+	found := make(map[string]bool)
+
+	for _, l := range locations {
+		found[l] = true
+	}
+
+	for _, l := range e.discover(ctx) {
+		if !found[l] {
+			found[l] = true
+			locations = append(locations, l)
+		}
+	}
+
+	return locations, ctx.Err()
+}
+
+// items Reads the packages at the given locations and converts them to items.
+// If filter isn't nil only packages for which it returns true are included.
+// Locations that can't be read are skipped. Returns an error if the context
+// is cancelled, since reading some packages means opening large files
+func (e ecosystem) items(ctx context.Context, locations []string, filter func(Package) bool) ([]*sdp.Item, error) {
+	items := make([]*sdp.Item, 0)
+
+	for _, location := range locations {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		packages, err := e.cache.read(location, e.read)
+
+		if err != nil {
+			continue
+		}
+
+		for _, p := range packages {
+			if filter != nil && !filter(p) {
+				continue
+			}
+
+			if item, err := mapPackageToItem(p); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// walk Walks each of the roots up to MaxDepth directories deep, calling find
+// for each directory. Returns the locations that were found, sorted and with
+// duplicates (from overlapping roots) removed, and the modification time of
+// each directory that was walked. Roots that don't exist have a zero time so
+// that it can be seen when they are created. Directories that can't be read
+// are skipped. Stops and returns an error if the context is cancelled
+func walk(ctx context.Context, roots []string, find func(dir string, name string) ([]string, bool)) ([]string, map[string]time.Time, error) {
+	found := make(map[string]bool)
+	dirs := make(map[string]time.Time)
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+
+		if _, err := os.Lstat(root); err != nil {
+			dirs[root] = time.Time{}
+		}
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil {
+				dirs[path] = info.ModTime()
+			}
+
+			locations, skip := find(path, d.Name())
+
+			for _, l := range locations {
+				found[l] = true
+			}
+
+			if skip {
+				return filepath.SkipDir
+			}
+
+			if rel, err := filepath.Rel(root, path); err == nil && rel != "." && strings.Count(rel, string(filepath.Separator)) >= MaxDepth-1 {
+				return filepath.SkipDir
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	locations := make([]string, 0, len(found))
+
+	for l := range found {
+		locations = append(locations, l)
+	}
+
+	sort.Strings(locations)
+
+	return locations, dirs, nil
+}
+
+// isDir Returns whether a path is a directory, following symlinks
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
+
+// single Converts a function that reads one package into one that can be
+// used by an ecosystem
+func single(read func(location string) (Package, error)) func(location string) ([]Package, error) {
+	return func(location string) ([]Package, error) {
+		p, err := read(location)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []Package{p}, nil
+	}
+}
+
+// identity Returns the name unchanged, for ecosystems where names are
+// compared exactly
+func identity(name string) string {
+	return name
+}
+
+func otherError(itemContext string, err error) error {
+	return &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_OTHER,
+		ErrorString: err.Error(),
+		Context:     itemContext,
+	}
+}
+
+func noContextError(itemContext string) error {
+	return &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+		ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+		Context:     itemContext,
+	}
+}
+
+func mapPackageToItem(p Package) (*sdp.Item, error) {
+	attrMap := map[string]interface{}{
+		"name":      p.Name,
+		"status":    "installed",
+		"version":   p.Version,
+		"ecosystem": p.Ecosystem,
+		"location":  p.Location,
+		"summary":   p.Summary,
+		"license":   p.License,
+		"url":       p.URL,
+		"author":    p.Author,
+		"depends":   packagedeps.Attributes(p.Depends),
+	}
+
+	optional := map[string]string{
+		"virtualenv":  p.Virtualenv,
+		"binary":      p.Binary,
+		"toolchain":   p.Toolchain,
+		"checksum":    p.Checksum,
+		"replacement": p.Replacement,
+		"source":      p.Source,
+	}
+
+	for k, v := range optional {
+		if v != "" {
+			attrMap[k] = v
+		}
+	}
+
+	if len(p.BuildSettings) > 0 {
+		settings := make(map[string]interface{})
+
+		for k, v := range p.BuildSettings {
+			settings[k] = v
+		}
+
+		attrMap["buildSettings"] = settings
+	}
+
+	// Packages that are embedded in a binary don't have a file of their own
+	file := p.Location
+
+	if p.Binary != "" {
+		file = p.Binary
+	}
+
+	attributes, err := sdp.ToAttributes(attrMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sdp.Item{
+		Type:            "package",
+		UniqueAttribute: "location",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+		LinkedItemRequests: []*sdp.ItemRequest{
+			{
+				Type:    "file",
+				Method:  sdp.RequestMethod_GET,
+				Query:   file,
+				Context: util.LocalContext,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,61 @@
+package langpkg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestSearchForeignQuery(t *testing.T) {
+	root := t.TempDir()
+
+	var walked int
+
+	e := ecosystem{
+		find: func(dir string, name string) ([]string, bool) {
+			walked++
+			return nil, false
+		},
+		read: func(location string) ([]Package, error) {
+			return nil, ErrNotPackage
+		},
+		normalize: identity,
+	}
+
+	tests := []struct {
+		Query      string
+		ColonNames bool
+		Foreign    bool
+	}{
+		{"provides:foo", false, true},
+		{"rdepends:libssl3", false, true},
+		{"so:libc.musl-x86_64.so.1", false, true},
+		{"cmd:sh", false, true},
+		{"libc.so.6()(64bit)", false, true},
+		{"perl:Carp", false, true},
+		{"provides:foo", true, true},
+		{"requests", false, false},
+		{"org.apache.logging.log4j:log4j-core", true, false},
+	}
+
+	for _, test := range tests {
+		walked = 0
+		e.colonNames = test.ColonNames
+
+		_, err := e.search(context.Background(), util.LocalContext, test.Query, []string{root})
+
+		if ire, ok := err.(*sdp.ItemRequestError); !ok || ire.ErrorType != sdp.ItemRequestError_NOTFOUND {
+			t.Errorf("%v: expected NOTFOUND, got %v", test.Query, err)
+		}
+
+		if test.Foreign && walked != 0 {
+			t.Errorf("%v: expected the search paths not to be walked", test.Query)
+		}
+
+		if !test.Foreign && walked == 0 {
+			t.Errorf("%v: expected the search paths to be walked", test.Query)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package langpkg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultNodePaths The directories that are searched for Node packages by
+// default. This includes the global node_modules and those of applications in
+// common application and home directories
+var DefaultNodePaths = []string{
+	"/usr/lib/node_modules",
+	"/usr/local/lib/node_modules",
+	"/opt",
+	"/srv",
+	"/home",
+	"/root",
+}
+
+// NodeSource Returns Node packages that are installed in node_modules
+// directories, including those nested inside other packages. The directory of
+// each package is used as its location
+type NodeSource struct {
+	// The directories to search for packages. Defaults to DefaultNodePaths
+	SearchPaths []string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *NodeSource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *NodeSource) Name() string {
+	return "langpkg-node"
+}
+
+// Weighting of duplicate sources
+func (s *NodeSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *NodeSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the package that is installed in the given directory
+func (s *NodeSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns all packages below the search paths
+func (s *NodeSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the packages with the given name e.g. express or
+// @types/node. If the query is an absolute path it returns all packages below
+// that directory, such as the dependencies of an application
+func (s *NodeSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Node packages can be anywhere, so this is supported as long as one of
+// the search paths exists
+func (s *NodeSource) Supported() bool {
+	return anyExists(s.searchPaths())
+}
+
+func (s *NodeSource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultNodePaths
+	}
+
+	return s.SearchPaths
+}
+
+// ecosystem Returns the ecosystem with the source's cache, which is kept
+// between requests
+func (s *NodeSource) ecosystem() ecosystem {
+	e := node
+	e.cache = &s.cache
+
+	return e
+}
+
+var node = ecosystem{
+	find:      findNode,
+	read:      single(ReadNode),
+	normalize: identity,
+}
+
+// findNode Finds the packages in node_modules directories. These are read
+// directly rather than walked since they are often very large
+func findNode(dir string, name string) ([]string, bool) {
+	switch name {
+	case ".git":
+		return nil, true
+	case "node_modules":
+		return nodeModules(dir, 0), true
+	}
+
+	return nil, false
+}
+
+// maxNodeNesting How many levels of nested node_modules are read
+const maxNodeNesting = 16
+
+// nodeModules Returns the packages in a node_modules directory and in the
+// node_modules of those packages. Scoped packages such as @types/node are in a
+// directory for their scope. Hidden directories such as .bin and .pnpm are
+// skipped, but symlinks to packages (as created by pnpm and npm link) are
+// followed. Nesting is limited to maxNodeNesting in case symlinks create a
+// loop
+func nodeModules(dir string, nesting int) []string {
+	entries, err := os.ReadDir(dir)
+
+	if err != nil || nesting > maxNodeNesting {
+		return nil
+	}
+
+	locations := make([]string, 0)
+
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, e.Name())
+
+		if strings.HasPrefix(e.Name(), "@") {
+			scoped, _ := os.ReadDir(path)
+
+			for _, s := range scoped {
+				locations = append(locations, nodePackage(filepath.Join(path, s.Name()), nesting)...)
+			}
+
+			continue
+		}
+
+		locations = append(locations, nodePackage(path, nesting)...)
+	}
+
+	return locations
+}
+
+// nodePackage Returns the package in a directory if it has a package.json,
+// followed by those in its own node_modules
+func nodePackage(path string, nesting int) []string {
+	locations := make([]string, 0)
+
+	if _, err := os.Stat(filepath.Join(path, "package.json")); err == nil {
+		locations = append(locations, path)
+	}
+
+	if nested := filepath.Join(path, "node_modules"); isDir(nested) {
+		locations = append(locations, nodeModules(nested, nesting+1)...)
+	}
+
+	return locations
+}
+
+// packageJSON The fields of package.json that are used. See
+// https://docs.npmjs.com/cli/configuring-npm/package-json
+type packageJSON struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Description  string            `json:"description"`
+	Homepage     string            `json:"homepage"`
+	Dependencies map[string]string `json:"dependencies"`
+
+	// These can be strings or objects
+	License json.RawMessage `json:"license"`
+	Author  json.RawMessage `json:"author"`
+}
+
+// ReadNode Reads the package.json of the package in a directory
+func ReadNode(location string) (Package, error) {
+	data, err := os.ReadFile(filepath.Join(location, "package.json"))
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	p, err := ParsePackageJSON(data)
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	p.Location = location
+
+	return p, nil
+}
+
+// ParsePackageJSON Parses a package.json file
+func ParsePackageJSON(data []byte) (Package, error) {
+	var pj packageJSON
+
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return Package{}, err
+	}
+
+	// Applications don't need a name, but packages that are installed do
+	if pj.Name == "" {
+		return Package{}, ErrNotPackage
+	}
+
+	p := Package{
+		Ecosystem: "npm",
+		Name:      pj.Name,
+		Version:   pj.Version,
+		Summary:   pj.Description,
+		URL:       pj.Homepage,
+		License:   stringOrField(pj.License, "type"),
+		Author:    stringOrField(pj.Author, "name"),
+		Depends:   make([]packagedeps.Dependency, 0, len(pj.Dependencies)),
+	}
+
+	names := make([]string, 0, len(pj.Dependencies))
+
+	for name := range pj.Dependencies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		p.Depends = append(p.Depends, parseNodeRange(name, pj.Dependencies[name]))
+	}
+
+	return p, nil
+}
+
+// parseNodeRange Converts a dependency and its semver range e.g. "^4.17.21" to
+// a dependency. Exact versions use the = operator. Ranges without an operator,
+// such as * or a git URL, are treated as unconstrained
+func parseNodeRange(name string, r string) packagedeps.Dependency {
+	r = strings.TrimSpace(r)
+	version := strings.TrimLeft(r, "^~<>=")
+	operator := r[:len(r)-len(version)]
+
+	if operator == "" && version != "" && version[0] >= '0' && version[0] <= '9' {
+		operator = "="
+	}
+
+	if operator == "" {
+		return packagedeps.Dependency{
+			Name: name,
+		}
+	}
+
+	return packagedeps.Dependency{
+		Name:     name,
+		Operator: operator,
+		Version:  strings.TrimSpace(version),
+	}
+}
+
+// stringOrField Returns a field that can either be a string or an object, in
+// which case the given field of the object is returned
+func stringOrField(raw json.RawMessage, field string) string {
+	var s string
+
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(raw, &m); err == nil {
+		if v, ok := m[field].(string); ok {
+			return v
+		}
+	}
+
+	return ""
+}
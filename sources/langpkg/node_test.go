@@ -0,0 +1,156 @@
+package langpkg
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParsePackageJSON(t *testing.T) {
+	p, err := ReadNode("test/node/srv/app/node_modules/express")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Ecosystem != "npm" || p.Name != "express" || p.Version != "4.18.2" || p.License != "MIT" || p.Author != "TJ Holowaychuk <tj@vision-media.ca>" {
+		t.Errorf("unexpected package %+v", p)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "accepts", Operator: "~", Version: "1.3.8"},
+		{Name: "body-parser", Operator: "=", Version: "1.20.1"},
+		{Name: "debug", Operator: "=", Version: "2.6.9"},
+		{Name: "path-to-regexp"},
+		{Name: "qs", Operator: ">=", Version: "6.11.0 <7"},
+	}
+
+	if !reflect.DeepEqual(p.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, p.Depends)
+	}
+
+	// The author and license can be objects
+	debug, err := ReadNode("test/node/srv/app/node_modules/express/node_modules/debug")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if debug.Author != "TJ Holowaychuk" || debug.License != "MIT" {
+		t.Errorf("unexpected package %+v", debug)
+	}
+
+	if _, err := ParsePackageJSON([]byte(`{"private": true}`)); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+}
+
+func TestNodeSource(t *testing.T) {
+	root, err := filepath.Abs("test/node")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := NodeSource{
+		SearchPaths: []string{root},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "srv/app/node_modules/@types/node"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "@types/node",
+						"version":   "20.8.10",
+						"ecosystem": "npm",
+						"license":   "MIT",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "srv/app/node_modules/notapackage"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "find wrong context",
+			ItemContext: "foo",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+		{
+			Name:        "search nested",
+			ItemContext: util.LocalContext,
+			Query:       "debug",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"location": filepath.Join(root, "srv/app/node_modules/express/node_modules/debug"),
+					},
+				},
+			},
+		},
+		{
+			Name:        "search application",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "srv/app"),
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+func TestNodeSourceCancelled(t *testing.T) {
+	root, err := filepath.Abs("test/node")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := NodeSource{
+		SearchPaths: []string{root},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := src.Find(ctx, util.LocalContext); err == nil {
+		t.Error("expected find to fail once the context is cancelled")
+	}
+
+	if _, err := src.Search(ctx, util.LocalContext, "debug"); err == nil {
+		t.Error("expected search to fail once the context is cancelled")
+	}
+}
@@ -0,0 +1,303 @@
+package langpkg
+
+import (
+	"context"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultPythonPaths The directories that are searched for Python packages by
+// default. This includes the system site-packages and virtualenvs in common
+// application and home directories
+var DefaultPythonPaths = []string{
+	"/usr/lib",
+	"/usr/lib64",
+	"/usr/local/lib",
+	"/opt",
+	"/srv",
+	"/home",
+	"/root",
+}
+
+// PythonSource Returns Python distributions that are installed in
+// site-packages (or dist-packages) directories, including those in
+// virtualenvs. Each distribution is a *.dist-info or *.egg-info directory,
+// which is used as its location
+type PythonSource struct {
+	// The directories to search for packages. Defaults to DefaultPythonPaths
+	SearchPaths []string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *PythonSource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *PythonSource) Name() string {
+	return "langpkg-python"
+}
+
+// Weighting of duplicate sources
+func (s *PythonSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *PythonSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the package whose *.dist-info or *.egg-info is at the given
+// location
+func (s *PythonSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns all packages below the search paths
+func (s *PythonSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the packages with the given name, ignoring case and treating
+// -, _ and . as the same. If the query is an absolute path it returns all
+// packages below that directory
+func (s *PythonSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Python packages can be anywhere, so this is supported as long as one of
+// the search paths exists
+func (s *PythonSource) Supported() bool {
+	return anyExists(s.searchPaths())
+}
+
+func (s *PythonSource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultPythonPaths
+	}
+
+	return s.SearchPaths
+}
+
+// ecosystem Returns the ecosystem with the source's cache, which is kept
+// between requests
+func (s *PythonSource) ecosystem() ecosystem {
+	e := python
+	e.cache = &s.cache
+
+	return e
+}
+
+var python = ecosystem{
+	find:      findPython,
+	read:      single(ReadPython),
+	normalize: NormalizePythonName,
+}
+
+// findPython Finds the distributions in site-packages and dist-packages
+// directories. Since Python packages don't contain other installs the rest of
+// the directory is skipped, as are node_modules directories
+func findPython(dir string, name string) ([]string, bool) {
+	switch name {
+	case "node_modules", ".git":
+		return nil, true
+	case "site-packages", "dist-packages":
+		entries, _ := os.ReadDir(dir)
+		locations := make([]string, 0)
+
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".dist-info") || strings.HasSuffix(e.Name(), ".egg-info") {
+				locations = append(locations, filepath.Join(dir, e.Name()))
+			}
+		}
+
+		return locations, true
+	}
+
+	return nil, false
+}
+
+// ReadPython Reads a Python distribution. Distributions installed from wheels
+// have a *.dist-info directory containing a METADATA file. Older
+// distributions have a *.egg-info directory containing PKG-INFO, or an
+// *.egg-info file which is the PKG-INFO itself
+func ReadPython(location string) (Package, error) {
+	var metadata string
+
+	switch {
+	case strings.HasSuffix(location, ".dist-info"):
+		metadata = filepath.Join(location, "METADATA")
+	case strings.HasSuffix(location, ".egg-info") && isDir(location):
+		metadata = filepath.Join(location, "PKG-INFO")
+	case strings.HasSuffix(location, ".egg-info"):
+		metadata = location
+	default:
+		return Package{}, ErrNotPackage
+	}
+
+	file, err := os.Open(metadata)
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	defer file.Close()
+
+	p, err := ParsePythonMetadata(file)
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	p.Location = location
+	p.Virtualenv = virtualenv(filepath.Dir(location))
+
+	return p, nil
+}
+
+// ParsePythonMetadata Parses the core metadata of a Python distribution, which
+// is in the same format as email headers. See
+// https://packaging.python.org/en/latest/specifications/core-metadata/
+func ParsePythonMetadata(r io.Reader) (Package, error) {
+	message, err := mail.ReadMessage(r)
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	h := message.Header
+
+	p := Package{
+		Ecosystem: "pypi",
+		Name:      h.Get("Name"),
+		Version:   h.Get("Version"),
+		Summary:   h.Get("Summary"),
+		URL:       h.Get("Home-page"),
+		Author:    h.Get("Author"),
+		Depends:   make([]packagedeps.Dependency, 0),
+	}
+
+	if p.Name == "" {
+		return Package{}, ErrNotPackage
+	}
+
+	// License-Expression is an SPDX expression that replaces License, which
+	// can contain the whole text of the license
+	if license := h.Get("License-Expression"); license != "" {
+		p.License = license
+	} else if license, _, _ := strings.Cut(h.Get("License"), "\n"); license != "UNKNOWN" {
+		p.License = strings.TrimSpace(license)
+	}
+
+	if p.Author == "" {
+		p.Author = h.Get("Author-email")
+	}
+
+	// Newer packages list their homepage as a project URL e.g. "Homepage,
+	// https://requests.readthedocs.io"
+	if p.URL == "" || p.URL == "UNKNOWN" {
+		p.URL = ""
+
+		for _, projectURL := range h["Project-Url"] {
+			if label, u, found := strings.Cut(projectURL, ","); found && strings.EqualFold(strings.TrimSpace(label), "homepage") {
+				p.URL = strings.TrimSpace(u)
+			}
+		}
+	}
+
+	for _, requirement := range h["Requires-Dist"] {
+		p.Depends = append(p.Depends, parsePythonRequirement(requirement)...)
+	}
+
+	return p, nil
+}
+
+// pythonRequirement Matches a requirement e.g. "urllib3[socks] (<3,>=1.21.1)",
+// the first group is the name and the second is the version specifier
+var pythonRequirement = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*\(?([^)]*)\)?\s*$`)
+
+// parsePythonRequirement Parses a requirement from Requires-Dist. Each clause
+// of the version specifier is returned as a separate dependency, in the same
+// way as rpm lists requirements. Requirements that are only needed for extras
+// are optional so are skipped
+func parsePythonRequirement(requirement string) []packagedeps.Dependency {
+	requirement, marker, _ := strings.Cut(requirement, ";")
+
+	if strings.Contains(marker, "extra") {
+		return nil
+	}
+
+	matches := pythonRequirement.FindStringSubmatch(requirement)
+
+	if matches == nil {
+		return nil
+	}
+
+	deps := make([]packagedeps.Dependency, 0)
+
+	for _, clause := range strings.Split(matches[2], ",") {
+		clause = strings.TrimSpace(clause)
+
+		if clause == "" {
+			continue
+		}
+
+		version := strings.TrimLeft(clause, "<>=!~")
+
+		deps = append(deps, packagedeps.Dependency{
+			Name:     matches[1],
+			Operator: clause[:len(clause)-len(version)],
+			Version:  strings.TrimSpace(version),
+		})
+	}
+
+	if len(deps) == 0 {
+		deps = append(deps, packagedeps.Dependency{
+			Name: matches[1],
+		})
+	}
+
+	return deps
+}
+
+// virtualenv Returns the root of the virtualenv that a site-packages directory
+// is in, or an empty string if it isn't in one. Virtualenvs have a pyvenv.cfg
+// file in their root, which is lib/pythonX.Y/site-packages on Unix and
+// Lib/site-packages on Windows
+func virtualenv(sitePackages string) string {
+	for _, root := range []string{
+		filepath.Dir(filepath.Dir(filepath.Dir(sitePackages))),
+		filepath.Dir(filepath.Dir(sitePackages)),
+	} {
+		if _, err := os.Stat(filepath.Join(root, "pyvenv.cfg")); err == nil {
+			return root
+		}
+	}
+
+	return ""
+}
+
+// pythonSeparators Matches runs of characters that are equivalent in Python
+// package names
+var pythonSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizePythonName Normalizes a Python package name so that it can be
+// compared, as described in PEP 503 e.g. "Flask_SQLAlchemy" becomes
+// "flask-sqlalchemy"
+func NormalizePythonName(name string) string {
+	return pythonSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
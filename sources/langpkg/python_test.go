@@ -0,0 +1,205 @@
+package langpkg
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParsePythonMetadata(t *testing.T) {
+	p, err := ReadPython("test/python/app/.venv/lib/python3.11/site-packages/requests-2.31.0.dist-info")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Ecosystem != "pypi" || p.Name != "requests" || p.Version != "2.31.0" || p.License != "Apache 2.0" || p.Author != "Kenneth Reitz" {
+		t.Errorf("unexpected package %+v", p)
+	}
+
+	if p.Virtualenv != "test/python/app/.venv" {
+		t.Errorf("expected virtualenv test/python/app/.venv, got %v", p.Virtualenv)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "charset-normalizer", Operator: "<", Version: "4"},
+		{Name: "charset-normalizer", Operator: ">=", Version: "2"},
+		{Name: "idna", Operator: "<", Version: "4"},
+		{Name: "idna", Operator: ">=", Version: "2.5"},
+		{Name: "urllib3", Operator: "<", Version: "3"},
+		{Name: "urllib3", Operator: ">=", Version: "1.21.1"},
+		{Name: "certifi", Operator: ">=", Version: "2017.4.17"},
+	}
+
+	if !reflect.DeepEqual(p.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, p.Depends)
+	}
+
+	// An egg-info file rather than directory, with the homepage in Project-URL
+	flask, err := ReadPython("test/python/usr/lib/python3/dist-packages/Flask_SQLAlchemy-2.5.1.egg-info")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flask.URL != "https://github.com/pallets-eco/flask-sqlalchemy" || flask.Virtualenv != "" || len(flask.Depends) != 2 {
+		t.Errorf("unexpected package %+v", flask)
+	}
+
+	if _, err := ParsePythonMetadata(strings.NewReader("Metadata-Version: 2.1\nVersion: 1.0\n\n")); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+}
+
+func TestParsePythonRequirement(t *testing.T) {
+	tests := map[string][]packagedeps.Dependency{
+		"Flask>=0.10":                          {{Name: "Flask", Operator: ">=", Version: "0.10"}},
+		"urllib3[socks] (<3,>=1.21.1)":         {{Name: "urllib3", Operator: "<", Version: "3"}, {Name: "urllib3", Operator: ">=", Version: "1.21.1"}},
+		"typing-extensions":                    {{Name: "typing-extensions"}},
+		"PySocks (!=1.5.7) ; extra == 'socks'": nil,
+		"colorama ; sys_platform == 'win32'":   {{Name: "colorama"}},
+	}
+
+	for requirement, expected := range tests {
+		if deps := parsePythonRequirement(requirement); !reflect.DeepEqual(deps, expected) {
+			t.Errorf("%v: expected %+v, got %+v", requirement, expected, deps)
+		}
+	}
+}
+
+func TestNormalizePythonName(t *testing.T) {
+	tests := map[string]string{
+		"Flask_SQLAlchemy": "flask-sqlalchemy",
+		"zope.interface":   "zope-interface",
+		"requests":         "requests",
+		"a-_.b":            "a-b",
+	}
+
+	for name, expected := range tests {
+		if n := NormalizePythonName(name); n != expected {
+			t.Errorf("%v: expected %v, got %v", name, expected, n)
+		}
+	}
+}
+
+func TestPythonSource(t *testing.T) {
+	root, err := filepath.Abs("test/python")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := PythonSource{
+		SearchPaths: []string{root},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "app/.venv/lib/python3.11/site-packages/requests-2.31.0.dist-info"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":       "requests",
+						"version":    "2.31.0",
+						"ecosystem":  "pypi",
+						"virtualenv": filepath.Join(root, "app/.venv"),
+					},
+				},
+			},
+		},
+		{
+			Name:        "get egg-info directory",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "usr/lib/python3/dist-packages/six-1.16.0.egg-info"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":    "six",
+						"license": "MIT",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get not a package",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "app/.venv/lib/python3.11/site-packages/requests"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get relative path",
+			ItemContext: util.LocalContext,
+			Query:       "requests",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get wrong context",
+			ItemContext: "foo",
+			Query:       filepath.Join(root, "app/.venv/lib/python3.11/site-packages/requests-2.31.0.dist-info"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "search normalized name",
+			ItemContext: util.LocalContext,
+			Query:       "flask.sqlalchemy",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":    "Flask-SQLAlchemy",
+						"version": "2.5.1",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search virtualenv",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "app"),
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search missing",
+			ItemContext: util.LocalContext,
+			Query:       "notapackage",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
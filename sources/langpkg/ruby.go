@@ -0,0 +1,286 @@
+package langpkg
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultRubyPaths The directories that are searched for Ruby gems by default.
+// This includes the system gem directories and gems that have been installed
+// by bundler into applications in common application and home directories
+var DefaultRubyPaths = []string{
+	"/usr/lib/ruby",
+	"/usr/lib64/ruby",
+	"/usr/local/lib/ruby",
+	"/usr/share/gems",
+	"/var/lib/gems",
+	"/opt",
+	"/srv",
+	"/home",
+	"/root",
+}
+
+// RubySource Returns Ruby gems, which are found using their specifications
+// (*.gemspec) in the specifications directory of each gem directory. The gem's
+// directory in the gems directory next to it (e.g. gems/rake-13.0.6) is used as
+// its location
+type RubySource struct {
+	// The directories to search for gems. Defaults to DefaultRubyPaths
+	SearchPaths []string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *RubySource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *RubySource) Name() string {
+	return "langpkg-ruby"
+}
+
+// Weighting of duplicate sources
+func (s *RubySource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *RubySource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the gem that is installed in the given directory
+func (s *RubySource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns all gems below the search paths
+func (s *RubySource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the gems with the given name. If the query is an absolute
+// path it returns all gems below that directory
+func (s *RubySource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Gems can be anywhere, so this is supported as long as one of
+// the search paths exists
+func (s *RubySource) Supported() bool {
+	return anyExists(s.searchPaths())
+}
+
+func (s *RubySource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultRubyPaths
+	}
+
+	return s.SearchPaths
+}
+
+// ecosystem Returns the ecosystem with the source's cache, which is kept
+// between requests
+func (s *RubySource) ecosystem() ecosystem {
+	e := ruby
+	e.cache = &s.cache
+
+	return e
+}
+
+var ruby = ecosystem{
+	find:      findRuby,
+	read:      single(ReadRuby),
+	normalize: identity,
+}
+
+// findRuby Finds gems using the specifications directory. Default gems, which
+// are part of Ruby itself, have their specifications in
+// specifications/default. The gems directory is skipped since it contains the
+// code of every gem
+func findRuby(dir string, name string) ([]string, bool) {
+	switch name {
+	case "node_modules", ".git":
+		return nil, true
+	case "gems":
+		return nil, isDir(filepath.Join(filepath.Dir(dir), "specifications"))
+	case "specifications":
+		locations := make([]string, 0)
+
+		for _, specifications := range []string{dir, filepath.Join(dir, "default")} {
+			specs, _ := filepath.Glob(filepath.Join(specifications, "*.gemspec"))
+
+			for _, spec := range specs {
+				locations = append(locations, filepath.Join(filepath.Dir(dir), "gems", strings.TrimSuffix(filepath.Base(spec), ".gemspec")))
+			}
+		}
+
+		return locations, true
+	}
+
+	return nil, false
+}
+
+// ReadRuby Reads the specification of the gem that is installed in a
+// directory. For gems/{name}-{version} this is
+// specifications/{name}-{version}.gemspec, or specifications/default for
+// default gems
+func ReadRuby(location string) (Package, error) {
+	gems := filepath.Dir(location)
+
+	if filepath.Base(gems) != "gems" {
+		return Package{}, ErrNotPackage
+	}
+
+	specifications := filepath.Join(filepath.Dir(gems), "specifications")
+	spec := filepath.Base(location) + ".gemspec"
+
+	file, err := os.Open(filepath.Join(specifications, spec))
+
+	if os.IsNotExist(err) {
+		file, err = os.Open(filepath.Join(specifications, "default", spec))
+	}
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	defer file.Close()
+
+	p, err := ParseGemspec(file)
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	p.Location = location
+
+	return p, nil
+}
+
+var (
+	// gemAttribute Matches an attribute in a gemspec e.g. `s.name =
+	// "rake".freeze`, the first group is the attribute and the second is the
+	// value
+	gemAttribute = regexp.MustCompile(`^\s*s\.(\w+)\s*=\s*(.+)$`)
+
+	// gemDependency Matches a dependency in a gemspec e.g.
+	// `s.add_runtime_dependency(%q<concurrent-ruby>.freeze, ["~> 1.0"])`
+	gemDependency = regexp.MustCompile(`^\s*s\.add_(runtime_)?dependency\(\s*%q<([^>]+)>(?:\.freeze)?(?:\s*,\s*\[(.*)\])?`)
+
+	// gemString Matches a string in a gemspec
+	gemString = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// ParseGemspec Parses a gem specification. These are Ruby code, but the ones
+// that are installed are generated by rubygems so always have the same
+// format, with one attribute per line e.g.
+//
+//	Gem::Specification.new do |s|
+//	  s.name = "rake".freeze
+//	  s.version = "13.0.6"
+//	  s.licenses = ["MIT".freeze]
+//	  s.add_runtime_dependency(%q<concurrent-ruby>.freeze, ["~> 1.0"])
+//	end
+func ParseGemspec(r io.Reader) (Package, error) {
+	p := Package{
+		Ecosystem: "rubygems",
+		Depends:   make([]packagedeps.Dependency, 0),
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if matches := gemDependency.FindStringSubmatch(line); matches != nil {
+			p.Depends = append(p.Depends, parseGemRequirements(matches[2], matches[3])...)
+			continue
+		}
+
+		matches := gemAttribute.FindStringSubmatch(line)
+
+		if matches == nil {
+			continue
+		}
+
+		var values []string
+
+		for _, s := range gemString.FindAllStringSubmatch(matches[2], -1) {
+			values = append(values, s[1])
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		switch matches[1] {
+		case "name":
+			p.Name = values[0]
+		case "version":
+			p.Version = values[0]
+		case "summary":
+			p.Summary = values[0]
+		case "homepage":
+			p.URL = values[0]
+		case "licenses", "license":
+			p.License = strings.Join(values, ", ")
+		case "authors", "author":
+			p.Author = strings.Join(values, ", ")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Package{}, err
+	}
+
+	if p.Name == "" {
+		return Package{}, ErrNotPackage
+	}
+
+	return p, nil
+}
+
+// parseGemRequirements Parses the requirements of a dependency e.g. `"~> 1.0",
+// ">= 1.0.2"`. Each requirement is returned as a separate dependency, in the
+// same way as rpm lists requirements
+func parseGemRequirements(name string, requirements string) []packagedeps.Dependency {
+	deps := make([]packagedeps.Dependency, 0)
+
+	for _, s := range gemString.FindAllStringSubmatch(requirements, -1) {
+		operator, version, found := strings.Cut(strings.TrimSpace(s[1]), " ")
+
+		// ">= 0" is the default and means any version
+		if !found || (operator == ">=" && version == "0") {
+			continue
+		}
+
+		deps = append(deps, packagedeps.Dependency{
+			Name:     name,
+			Operator: operator,
+			Version:  strings.TrimSpace(version),
+		})
+	}
+
+	if len(deps) == 0 {
+		deps = append(deps, packagedeps.Dependency{
+			Name: name,
+		})
+	}
+
+	return deps
+}
@@ -0,0 +1,123 @@
+package langpkg
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParseGemspec(t *testing.T) {
+	p, err := ReadRuby("test/ruby/gems/3.2.0/gems/rack-session-2.0.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Ecosystem != "rubygems" || p.Name != "rack-session" || p.Version != "2.0.0" || p.License != "MIT" || p.URL != "https://github.com/rack/rack-session" {
+		t.Errorf("unexpected package %+v", p)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "rack", Operator: ">=", Version: "3.0.0"},
+		{Name: "base64", Operator: "~>", Version: "0.1"},
+		{Name: "base64", Operator: ">=", Version: "0.1.1"},
+		{Name: "json"},
+	}
+
+	if !reflect.DeepEqual(p.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, p.Depends)
+	}
+
+	rake, err := ReadRuby("test/ruby/gems/3.2.0/gems/rake-13.0.6")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rake.Author != "Hiroshi SHIBATA, Eric Hodel, Jim Weirich" || rake.Summary != "Rake is a Make-like program implemented in Ruby" {
+		t.Errorf("unexpected package %+v", rake)
+	}
+
+	if _, err := ParseGemspec(strings.NewReader("Gem::Specification.new do |s|\nend\n")); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+}
+
+func TestRubySource(t *testing.T) {
+	root, err := filepath.Abs("test/ruby")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := RubySource{
+		SearchPaths: []string{root},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get default gem",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "gems/3.2.0/gems/json-2.6.3"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "json",
+						"version":   "2.6.3",
+						"ecosystem": "rubygems",
+						"license":   "Ruby",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get outside gems directory",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "gems/3.2.0/rake-13.0.6"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "search",
+			ItemContext: util.LocalContext,
+			Query:       "rake",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"location": filepath.Join(root, "gems/3.2.0/gems/rake-13.0.6"),
+					},
+				},
+			},
+		},
+		{
+			Name:        "search wrong context",
+			ItemContext: "foo",
+			Query:       "rake",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
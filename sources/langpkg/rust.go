@@ -0,0 +1,209 @@
+package langpkg
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+// RustSource Returns the crates that Rust binaries were built from. These are
+// only known for binaries that were built with cargo auditable, which embeds
+// the dependency tree in a .dep-v0 section. The root crate uses the binary as
+// its location, and the crates that it depends on use the binary followed by
+// the crate e.g. /usr/bin/rg!memchr@2.6.4
+type RustSource struct {
+	// The directories to search for binaries. Defaults to DefaultBinaryPaths
+	SearchPaths []string
+
+	cache cache
+}
+
+// Type is the type of items that this returns (Required)
+func (s *RustSource) Type() string {
+	return "package"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *RustSource) Name() string {
+	return "langpkg-rust"
+}
+
+// Weighting of duplicate sources
+func (s *RustSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *RustSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Returns the root crate of a binary, or a crate in a binary
+func (s *RustSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	return s.ecosystem().get(ctx, itemContext, query)
+}
+
+// Find Returns the crates of all binaries below the search paths
+func (s *RustSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.ecosystem().findAll(ctx, itemContext, s.searchPaths())
+}
+
+// Search Returns the crates with the given name. If the query is the absolute
+// path of a binary it returns all of the crates that it was built from, or of
+// all binaries below a directory
+func (s *RustSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	return s.ecosystem().search(ctx, itemContext, query, s.searchPaths())
+}
+
+// Supported Only ELF binaries can be read, so this is supported on platforms
+// that use ELF as long as one of the search paths exists
+func (s *RustSource) Supported() bool {
+	return runtime.GOOS != "darwin" && runtime.GOOS != "windows" && anyExists(s.searchPaths())
+}
+
+func (s *RustSource) searchPaths() []string {
+	if s.SearchPaths == nil {
+		return DefaultBinaryPaths
+	}
+
+	return s.SearchPaths
+}
+
+// ecosystem Returns the ecosystem with the source's cache, which is kept
+// between requests
+func (s *RustSource) ecosystem() ecosystem {
+	e := rust
+	e.cache = &s.cache
+
+	return e
+}
+
+var rust = ecosystem{
+	find:      findExecutables,
+	read:      embedded(ReadRust),
+	normalize: identity,
+}
+
+// maxAuditableSize The most data that will be read from a .dep-v0 section
+// once decompressed, which is the same limit that cargo auditable uses
+const maxAuditableSize = 8 * 1024 * 1024
+
+// ReadRust Reads the crates that a Rust binary was built from. Returns
+// ErrNotPackage if the file isn't an ELF binary that was built with cargo
+// auditable
+func ReadRust(binary string) ([]Package, error) {
+	file, err := os.Open(binary)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	f, err := elf.NewFile(file)
+
+	if err != nil {
+		return nil, ErrNotPackage
+	}
+
+	section := f.Section(".dep-v0")
+
+	if section == nil {
+		return nil, ErrNotPackage
+	}
+
+	data, err := section.Data()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCargoAuditable(bytes.NewReader(data), binary)
+}
+
+// auditable The dependency tree that cargo auditable embeds. See
+// https://github.com/rust-secure-code/cargo-auditable/blob/master/PARSING.md
+type auditable struct {
+	Packages []struct {
+		Name         string `json:"name"`
+		Version      string `json:"version"`
+		Source       string `json:"source"`
+		Kind         string `json:"kind"`
+		Dependencies []int  `json:"dependencies"`
+		Root         bool   `json:"root"`
+	} `json:"packages"`
+}
+
+// ParseCargoAuditable Parses the zlib compressed JSON from the .dep-v0 section
+// of a binary into packages. The root crate is first. Build dependencies (such
+// as cc) are left out since they aren't part of the binary
+func ParseCargoAuditable(r io.Reader, binary string) ([]Package, error) {
+	z, err := zlib.NewReader(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer z.Close()
+
+	var a auditable
+
+	if err := json.NewDecoder(io.LimitReader(z, maxAuditableSize)).Decode(&a); err != nil {
+		return nil, err
+	}
+
+	root := make([]Package, 0, 1)
+	packages := make([]Package, 0, len(a.Packages))
+
+	for _, crate := range a.Packages {
+		if crate.Kind == "build" {
+			continue
+		}
+
+		p := Package{
+			Ecosystem: "crates.io",
+			Name:      crate.Name,
+			Version:   crate.Version,
+			Location:  embeddedLocation(binary, crate.Name, crate.Version),
+			Binary:    binary,
+			Source:    crate.Source,
+			Depends:   make([]packagedeps.Dependency, 0, len(crate.Dependencies)),
+		}
+
+		for _, i := range crate.Dependencies {
+			if i < 0 || i >= len(a.Packages) {
+				return nil, fmt.Errorf("%v depends on package %v, which doesn't exist", crate.Name, i)
+			}
+
+			if dep := a.Packages[i]; dep.Kind != "build" {
+				p.Depends = append(p.Depends, packagedeps.Dependency{
+					Name:     dep.Name,
+					Operator: "=",
+					Version:  dep.Version,
+				})
+			}
+		}
+
+		if crate.Root && len(root) == 0 {
+			p.Location = binary
+			root = append(root, p)
+		} else {
+			packages = append(packages, p)
+		}
+	}
+
+	return append(root, packages...), nil
+}
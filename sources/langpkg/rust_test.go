@@ -0,0 +1,153 @@
+package langpkg
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestReadRust(t *testing.T) {
+	packages, err := ReadRust("test/rust/bin/rg")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0)
+
+	for _, p := range packages {
+		names = append(names, p.Name)
+	}
+
+	// The cc build dependency isn't included
+	if expected := []string{"rg", "memchr", "regex", "grep"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	root := packages[0]
+
+	if root.Location != "test/rust/bin/rg" || root.Version != "14.0.3" || root.Source != "local" || root.Ecosystem != "crates.io" {
+		t.Errorf("unexpected root crate %+v", root)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "memchr", Operator: "=", Version: "2.6.4"},
+		{Name: "regex", Operator: "=", Version: "1.10.2"},
+		{Name: "grep", Operator: "=", Version: "0.3.1"},
+	}
+
+	if !reflect.DeepEqual(root.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, root.Depends)
+	}
+
+	if regex := packages[2]; regex.Location != "test/rust/bin/rg!regex@1.10.2" || len(regex.Depends) != 1 {
+		t.Errorf("unexpected crate %+v", regex)
+	}
+
+	if _, err := ReadRust("test/rust/bin/hello"); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+
+	if _, err := ReadRust("test/go/buildinfo"); err != ErrNotPackage {
+		t.Errorf("expected ErrNotPackage, got %v", err)
+	}
+}
+
+func TestRustSource(t *testing.T) {
+	root, err := filepath.Abs("test/rust")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg := filepath.Join(root, "bin/rg")
+
+	src := RustSource{
+		SearchPaths: []string{root},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get root crate",
+			ItemContext: util.LocalContext,
+			Query:       rg,
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "rg",
+						"ecosystem": "crates.io",
+						"source":    "local",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get crate",
+			ItemContext: util.LocalContext,
+			Query:       rg + "!memchr@2.6.4",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":    "memchr",
+						"version": "2.6.4",
+						"binary":  rg,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing binary",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "bin/notabinary"),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search",
+			ItemContext: util.LocalContext,
+			Query:       "regex",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search binary",
+			ItemContext: util.LocalContext,
+			Query:       rg,
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search not auditable",
+			ItemContext: util.LocalContext,
+			Query:       filepath.Join(root, "bin/hello"),
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
@@ -0,0 +1,165 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Attributes of neighbour messages, see include/uapi/linux/neighbour.h
+const (
+	ndaDst    = 1
+	ndaLladdr = 2
+)
+
+// sizeofNdMsg The size of struct ndmsg
+const sizeofNdMsg = 12
+
+// Neighbour states, see include/uapi/linux/neighbour.h
+const (
+	NUDIncomplete = 0x01
+	NUDReachable  = 0x02
+	NUDStale      = 0x04
+	NUDDelay      = 0x08
+	NUDProbe      = 0x10
+	NUDFailed     = 0x20
+	NUDNoARP      = 0x40
+	NUDPermanent  = 0x80
+)
+
+// ntfRouter The flag that is set on IPv6 neighbours that are routers
+const ntfRouter = 0x80
+
+// neighbourStates Names of neighbour states as used by `ip neigh`
+var neighbourStates = map[uint16]string{
+	NUDIncomplete: "incomplete",
+	NUDReachable:  "reachable",
+	NUDStale:      "stale",
+	NUDDelay:      "delay",
+	NUDProbe:      "probe",
+	NUDFailed:     "failed",
+	NUDNoARP:      "noarp",
+	NUDPermanent:  "permanent",
+}
+
+// Neighbour An entry in the ARP or IPv6 neighbour table
+type Neighbour struct {
+	Family uint8
+	IP     net.IP
+
+	// The link layer address, empty if it hasn't been resolved
+	MAC            string
+	InterfaceIndex int
+
+	// The name of the interface, if it was known when the entry was read
+	Interface string
+	State     uint16
+	Router    bool
+}
+
+// StateNames Returns the names of the neighbour's states. This is normally a
+// single state, but the kernel stores them as flags
+func (n Neighbour) StateNames() []string {
+	names := make([]string, 0)
+
+	for state, name := range neighbourStates {
+		if n.State&state != 0 {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		names = append(names, "none")
+	}
+
+	return names
+}
+
+// ParseNeighbours Parses the messages from an RTM_GETNEIGH dump. Entries with
+// no state or that don't use ARP (e.g. multicast addresses) are skipped, which
+// matches the default output of `ip neigh`
+func ParseNeighbours(msgs []syscall.NetlinkMessage) ([]Neighbour, error) {
+	neighbours := make([]Neighbour, 0)
+
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+
+		b := msg.Data
+
+		if len(b) < sizeofNdMsg {
+			return nil, fmt.Errorf("neighbour message too short: %v bytes", len(b))
+		}
+
+		// struct ndmsg
+		n := Neighbour{
+			Family:         b[0],
+			InterfaceIndex: int(int32(nativeEndian.Uint32(b[4:8]))),
+			State:          nativeEndian.Uint16(b[8:10]),
+			Router:         b[10]&ntfRouter != 0,
+		}
+
+		if n.Family != syscall.AF_INET && n.Family != syscall.AF_INET6 {
+			continue
+		}
+
+		if n.State == 0 || n.State&NUDNoARP != 0 {
+			continue
+		}
+
+		attributes, err := parseAttributes(b[sizeofNdMsg:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range attributes {
+			switch a.Type {
+			case ndaDst:
+				n.IP = copyIP(a.Value)
+			case ndaLladdr:
+				n.MAC = net.HardwareAddr(a.Value).String()
+			}
+		}
+
+		if n.IP == nil {
+			continue
+		}
+
+		neighbours = append(neighbours, n)
+	}
+
+	return neighbours, nil
+}
+
+// NeighbourID Returns the unique ID of a neighbour in the format
+// {interface}/{ip}. The interface is required since the same IP can be a
+// neighbour on more than one link
+func NeighbourID(iface string, ip net.IP) string {
+	return iface + "/" + ip.String()
+}
+
+// parseNeighbourID Parses an ID as returned by NeighbourID
+func parseNeighbourID(id string) (string, net.IP, error) {
+	iface, ipString, found := strings.Cut(id, "/")
+
+	if !found {
+		return "", nil, fmt.Errorf("neighbour %v is not in the format {interface}/{ip}", id)
+	}
+
+	ip := net.ParseIP(ipString)
+
+	if ip == nil {
+		return "", nil, fmt.Errorf("%v is not a valid IP address", ipString)
+	}
+
+	return iface, ip, nil
+}
@@ -0,0 +1,310 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// NeighbourSource Returns the entries of the ARP and IPv6 neighbour tables,
+// i.e. the hosts on the same L2 segment that this host has recently spoken
+// to. Entries are dumped over netlink, falling back to /proc/net/arp (which
+// is IPv4 only) if that isn't possible
+type NeighbourSource struct {
+	// Where procfs is mounted if not the default location (optional)
+	ProcLocation string
+
+	// The function that is used to dump neighbours from the kernel (optional)
+	DumpFunction DumpFunction
+
+	// The function that is used to list interfaces so that their indexes can
+	// be converted to names (optional)
+	InterfacesFunction func() ([]net.Interface, error)
+}
+
+// Type is the type of items that this returns (Required)
+func (s *NeighbourSource) Type() string {
+	return "neighbour"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *NeighbourSource) Name() string {
+	return "netlink"
+}
+
+// Weighting of duplicate sources
+func (s *NeighbourSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *NeighbourSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a neighbour by its ID in the format {interface}/{ip} e.g.
+// eth0/10.0.0.1
+func (s *NeighbourSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	iface, ip, err := parseNeighbourID(query)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.neighbourItems(func(n Neighbour) bool {
+		return n.Interface == iface && n.IP.Equal(ip)
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("neighbour %v not found", query),
+			Context:     itemContext,
+		}
+	}
+
+	return items[0], nil
+}
+
+// Find Returns all neighbours
+func (s *NeighbourSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.neighbourItems(func(n Neighbour) bool {
+		return true
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Search Accepts an IP address, a MAC address or an interface name and
+// returns the neighbours that match
+func (s *NeighbourSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	ip := net.ParseIP(query)
+	mac, macErr := net.ParseMAC(query)
+
+	items, err := s.neighbourItems(func(n Neighbour) bool {
+		switch {
+		case ip != nil:
+			return n.IP.Equal(ip)
+		case macErr == nil:
+			return n.MAC == mac.String()
+		default:
+			return n.Interface == query
+		}
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether neighbours can be read on this system
+func (s *NeighbourSource) Supported() bool {
+	_, err := s.neighbours()
+
+	return err == nil
+}
+
+// neighbours Reads all neighbours, with their interface names filled in
+func (s *NeighbourSource) neighbours() ([]Neighbour, error) {
+	dumpFunction := s.DumpFunction
+
+	if dumpFunction == nil {
+		dumpFunction = Dump
+	}
+
+	msgs, err := dumpFunction(syscall.RTM_GETNEIGH)
+
+	if err != nil {
+		return s.arpNeighbours()
+	}
+
+	neighbours, err := ParseNeighbours(msgs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := interfaceNames(s.InterfacesFunction)
+
+	for i := range neighbours {
+		neighbours[i].Interface = names[neighbours[i].InterfaceIndex]
+	}
+
+	return neighbours, nil
+}
+
+// arpNeighbours Reads IPv4 neighbours from /proc/net/arp. This has less
+// detail than netlink, so the state is approximated from the entry's flags
+func (s *NeighbourSource) arpNeighbours() ([]Neighbour, error) {
+	entries, err := procfs.ProcFS{Location: s.ProcLocation}.ARP()
+
+	if err != nil {
+		return nil, err
+	}
+
+	neighbours := make([]Neighbour, 0, len(entries))
+
+	for _, entry := range entries {
+		n := Neighbour{
+			Family:    syscall.AF_INET,
+			IP:        net.ParseIP(entry.IP),
+			Interface: entry.Device,
+		}
+
+		switch {
+		case entry.Flags&procfs.ATFPermanent != 0:
+			n.State = NUDPermanent
+		case entry.Flags&procfs.ATFComplete != 0:
+			n.State = NUDReachable
+		default:
+			n.State = NUDIncomplete
+		}
+
+		if n.State != NUDIncomplete {
+			n.MAC = entry.MAC
+		}
+
+		if n.IP != nil {
+			neighbours = append(neighbours, n)
+		}
+	}
+
+	return neighbours, nil
+}
+
+// neighbourItems Returns items for all neighbours that match a filter
+func (s *NeighbourSource) neighbourItems(filter func(n Neighbour) bool) ([]*sdp.Item, error) {
+	neighbours, err := s.neighbours()
+
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, n := range neighbours {
+		if !filter(n) {
+			continue
+		}
+
+		if item, err := neighbourToItem(n); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// neighbourToItem Converts a neighbour to an item
+func neighbourToItem(n Neighbour) (*sdp.Item, error) {
+	attrs := map[string]interface{}{
+		"id":        NeighbourID(n.Interface, n.IP),
+		"family":    FamilyName(n.Family),
+		"ip":        n.IP.String(),
+		"interface": n.Interface,
+		"state":     n.StateNames(),
+	}
+
+	if n.MAC != "" {
+		attrs["mac"] = n.MAC
+	}
+
+	if n.Router {
+		attrs["router"] = true
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "neighbour",
+		UniqueAttribute: "id",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	// Link-local addresses aren't globally unique
+	if !n.IP.IsLinkLocalUnicast() {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "ip",
+			Method:  sdp.RequestMethod_GET,
+			Query:   n.IP.String(),
+			Context: "global",
+		})
+	}
+
+	if n.Interface != "" {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "interface",
+			Method:  sdp.RequestMethod_GET,
+			Query:   n.Interface,
+			Context: util.LocalContext,
+		})
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,259 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// testNdMsg Builds a struct ndmsg
+func testNdMsg(family uint8, ifindex uint32, state uint16, flags uint8) []byte {
+	b := make([]byte, sizeofNdMsg)
+
+	b[0] = family
+	nativeEndian.PutUint32(b[4:8], ifindex)
+	nativeEndian.PutUint16(b[8:10], state)
+	b[10] = flags
+
+	return b
+}
+
+func testMAC(s string) []byte {
+	mac, _ := net.ParseMAC(s)
+
+	return mac
+}
+
+func testNeighbourDump(request int) ([]syscall.NetlinkMessage, error) {
+	return []syscall.NetlinkMessage{
+		// 10.0.0.1 dev eth0 lladdr 52:54:00:12:34:56 REACHABLE
+		testMessage(syscall.RTM_NEWNEIGH,
+			testNdMsg(syscall.AF_INET, 2, NUDReachable, 0),
+			testAttr(ndaDst, testIP("10.0.0.1")),
+			testAttr(ndaLladdr, testMAC("52:54:00:12:34:56")),
+		),
+		// 10.0.0.7 dev eth0 INCOMPLETE
+		testMessage(syscall.RTM_NEWNEIGH,
+			testNdMsg(syscall.AF_INET, 2, NUDIncomplete, 0),
+			testAttr(ndaDst, testIP("10.0.0.7")),
+		),
+		// fe80::1 dev eth0 lladdr 52:54:00:12:34:56 router STALE
+		testMessage(syscall.RTM_NEWNEIGH,
+			testNdMsg(syscall.AF_INET6, 2, NUDStale, ntfRouter),
+			testAttr(ndaDst, testIP("fe80::1")),
+			testAttr(ndaLladdr, testMAC("52:54:00:12:34:56")),
+		),
+		// 2001:db8::10 dev wg0 lladdr 52:54:00:ab:cd:ef DELAY
+		testMessage(syscall.RTM_NEWNEIGH,
+			testNdMsg(syscall.AF_INET6, 3, NUDDelay, 0),
+			testAttr(ndaDst, testIP("2001:db8::10")),
+			testAttr(ndaLladdr, testMAC("52:54:00:ab:cd:ef")),
+		),
+		// Multicast entries are NOARP and should be skipped
+		testMessage(syscall.RTM_NEWNEIGH,
+			testNdMsg(syscall.AF_INET6, 2, NUDNoARP, 0),
+			testAttr(ndaDst, testIP("ff02::2")),
+		),
+	}, nil
+}
+
+func TestParseNeighbours(t *testing.T) {
+	msgs, _ := testNeighbourDump(syscall.RTM_GETNEIGH)
+
+	neighbours, err := ParseNeighbours(msgs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(neighbours) != 4 {
+		t.Fatalf("expected 4 neighbours, got %v", len(neighbours))
+	}
+
+	n := neighbours[2]
+
+	if n.IP.String() != "fe80::1" || n.MAC != "52:54:00:12:34:56" || !n.Router || n.InterfaceIndex != 2 {
+		t.Errorf("unexpected neighbour %+v", n)
+	}
+
+	if !reflect.DeepEqual(n.StateNames(), []string{"stale"}) {
+		t.Errorf("expected stale, got %v", n.StateNames())
+	}
+
+	if neighbours[1].MAC != "" {
+		t.Errorf("expected incomplete neighbour to have no MAC, got %v", neighbours[1].MAC)
+	}
+}
+
+func TestNeighbourSource(t *testing.T) {
+	source := NeighbourSource{
+		DumpFunction:       testNeighbourDump,
+		InterfacesFunction: testInterfaces,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get neighbour",
+			ItemContext: util.LocalContext,
+			Query:       "eth0/10.0.0.1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"ip":        "10.0.0.1",
+						"mac":       "52:54:00:12:34:56",
+						"interface": "eth0",
+						"family":    "ipv4",
+						"state":     []interface{}{"reachable"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "get on wrong interface",
+			ItemContext: util.LocalContext,
+			Query:       "wg0/10.0.0.1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get bad query",
+			ItemContext: util.LocalContext,
+			Query:       "10.0.0.1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search by MAC",
+			ItemContext: util.LocalContext,
+			Query:       "52:54:00:12:34:56",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 2,
+			},
+		},
+		{
+			Name:        "search by IP",
+			ItemContext: util.LocalContext,
+			Query:       "2001:db8::10",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search by interface",
+			ItemContext: util.LocalContext,
+			Query:       "eth0",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestNeighbourLinks(t *testing.T) {
+	source := NeighbourSource{
+		DumpFunction:       testNeighbourDump,
+		InterfacesFunction: testInterfaces,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "eth0/10.0.0.1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 2 {
+		t.Fatalf("expected 2 links, got %v", item.LinkedItemRequests)
+	}
+
+	if l := item.LinkedItemRequests[0]; l.Type != "ip" || l.Query != "10.0.0.1" || l.Context != "global" {
+		t.Errorf("unexpected link %v", l)
+	}
+
+	if l := item.LinkedItemRequests[1]; l.Type != "interface" || l.Query != "eth0" {
+		t.Errorf("unexpected link %v", l)
+	}
+
+	// Link-local neighbours only link to their interface
+	item, err = source.Get(context.Background(), util.LocalContext, "eth0/fe80::1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 1 {
+		t.Errorf("expected 1 link, got %v", item.LinkedItemRequests)
+	}
+}
+
+func TestNeighbourSourceARPFallback(t *testing.T) {
+	source := NeighbourSource{
+		ProcLocation: "test/proc",
+		DumpFunction: func(request int) ([]syscall.NetlinkMessage, error) {
+			return nil, errors.New("netlink not available")
+		},
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get permanent entry",
+			ItemContext: util.LocalContext,
+			Query:       "bond0.100/192.168.100.1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"mac":   "52:54:00:aa:bb:cc",
+						"state": []interface{}{"permanent"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// This package reads the kernel's networking state using rtnetlink dumps,
+// using only the standard library
+
+// nativeEndian Netlink messages are encoded in the byte order of the host
+var nativeEndian binary.ByteOrder
+
+func init() {
+	x := uint16(1)
+
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		nativeEndian = binary.LittleEndian
+	} else {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// nlaTypeMask Masks out the nested and byte order flags from an attribute type
+const nlaTypeMask = 0x3fff
+
+// DumpFunction Requests a dump of a given type (e.g. syscall.RTM_GETROUTE)
+// from the kernel and returns the resulting messages
+type DumpFunction func(request int) ([]syscall.NetlinkMessage, error)
+
+// Dump Dumps all objects of a given type from the kernel, across all address
+// families
+func Dump(request int) ([]syscall.NetlinkMessage, error) {
+	b, err := syscall.NetlinkRIB(request, syscall.AF_UNSPEC)
+
+	if err != nil {
+		return nil, fmt.Errorf("netlink dump failed: %v", err)
+	}
+
+	return syscall.ParseNetlinkMessage(b)
+}
+
+// attribute A single netlink attribute
+type attribute struct {
+	Type  uint16
+	Value []byte
+}
+
+// parseAttributes Parses the attributes that follow the fixed size header of
+// a message
+func parseAttributes(b []byte) ([]attribute, error) {
+	attributes := make([]attribute, 0)
+
+	for len(b) >= syscall.SizeofRtAttr {
+		length := int(nativeEndian.Uint16(b[0:2]))
+
+		if length < syscall.SizeofRtAttr || length > len(b) {
+			return nil, fmt.Errorf("invalid netlink attribute length %v", length)
+		}
+
+		attributes = append(attributes, attribute{
+			Type:  nativeEndian.Uint16(b[2:4]) & nlaTypeMask,
+			Value: b[syscall.SizeofRtAttr:length],
+		})
+
+		aligned := (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+
+		if aligned > len(b) {
+			break
+		}
+
+		b = b[aligned:]
+	}
+
+	return attributes, nil
+}
+
+// uint32Value Returns the value of an attribute as a uint32
+func (a attribute) uint32Value() uint32 {
+	if len(a.Value) < 4 {
+		return 0
+	}
+
+	return nativeEndian.Uint32(a.Value)
+}
+
+// stringValue Returns the value of an attribute as a null terminated string
+func (a attribute) stringValue() string {
+	for i, c := range a.Value {
+		if c == 0 {
+			return string(a.Value[:i])
+		}
+	}
+
+	return string(a.Value)
+}
+
+// FamilyName Returns the name of an address family as used in items
+func FamilyName(family uint8) string {
+	switch family {
+	case syscall.AF_INET:
+		return "ipv4"
+	case syscall.AF_INET6:
+		return "ipv6"
+	default:
+		return fmt.Sprint(family)
+	}
+}
+
+// prefix Returns an address and prefix length in CIDR notation
+func prefix(addr []byte, length uint8) string {
+	return fmt.Sprintf("%v/%v", net.IP(addr).String(), length)
+}
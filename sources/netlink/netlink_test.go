@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+// testAttr Encodes a netlink attribute, including padding
+func testAttr(attrType uint16, value []byte) []byte {
+	length := syscall.SizeofRtAttr + len(value)
+	b := make([]byte, (length+syscall.RTA_ALIGNTO-1)&^(syscall.RTA_ALIGNTO-1))
+
+	nativeEndian.PutUint16(b[0:2], uint16(length))
+	nativeEndian.PutUint16(b[2:4], attrType)
+	copy(b[syscall.SizeofRtAttr:], value)
+
+	return b
+}
+
+// testUint32 Encodes a uint32 in the host's byte order
+func testUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	nativeEndian.PutUint32(b, v)
+
+	return b
+}
+
+// testIP Returns an IP in its shortest form, as used in netlink messages
+func testIP(s string) []byte {
+	ip := net.ParseIP(s)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip
+}
+
+// testMessage Builds a netlink message from a header and attributes
+func testMessage(msgType uint16, header []byte, attrs ...[]byte) syscall.NetlinkMessage {
+	data := append([]byte{}, header...)
+
+	for _, attr := range attrs {
+		data = append(data, attr...)
+	}
+
+	return syscall.NetlinkMessage{
+		Header: syscall.NlMsghdr{
+			Type: msgType,
+		},
+		Data: data,
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	b := append(testAttr(1, []byte("eth0\x00")), testAttr(2, testUint32(42))...)
+
+	attributes, err := parseAttributes(b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attributes) != 2 {
+		t.Fatalf("expected 2 attributes, got %v", len(attributes))
+	}
+
+	if attributes[0].stringValue() != "eth0" {
+		t.Errorf("expected eth0, got %v", attributes[0].stringValue())
+	}
+
+	if attributes[1].uint32Value() != 42 {
+		t.Errorf("expected 42, got %v", attributes[1].uint32Value())
+	}
+
+	if _, err := parseAttributes([]byte{200, 0, 1, 0}); err == nil {
+		t.Error("expected error from invalid attribute length")
+	}
+}
+
+func TestReadTableNames(t *testing.T) {
+	tables := ReadTableNames([]string{"test/rt_tables", "test/missing"})
+
+	if tables.Name(100) != "vpn" {
+		t.Errorf("expected table 100 to be vpn, got %v", tables.Name(100))
+	}
+
+	if tables.Name(254) != "main" {
+		t.Errorf("expected table 254 to be main, got %v", tables.Name(254))
+	}
+
+	if tables.Name(5) != "5" {
+		t.Errorf("expected unnamed table to be 5, got %v", tables.Name(5))
+	}
+
+	if id, ok := tables.ID("vpn"); !ok || id != 100 {
+		t.Errorf("expected vpn to be 100, got %v", id)
+	}
+
+	if _, ok := tables.ID("foo"); ok {
+		t.Error("expected foo not to be found")
+	}
+}
@@ -0,0 +1,318 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// rtaVia The attribute used for gateways in a different address family,
+// e.g. IPv4 routes via an IPv6 next hop. Not defined by the syscall package
+const rtaVia = 18
+
+// sizeofRtNexthop The size of struct rtnexthop
+const sizeofRtNexthop = 8
+
+// routeProtocols Names of route protocols, see /etc/iproute2/rt_protos
+var routeProtocols = map[uint8]string{
+	syscall.RTPROT_UNSPEC:   "unspec",
+	syscall.RTPROT_REDIRECT: "redirect",
+	syscall.RTPROT_KERNEL:   "kernel",
+	syscall.RTPROT_BOOT:     "boot",
+	syscall.RTPROT_STATIC:   "static",
+	syscall.RTPROT_GATED:    "gated",
+	syscall.RTPROT_RA:       "ra",
+	syscall.RTPROT_MRT:      "mrt",
+	syscall.RTPROT_ZEBRA:    "zebra",
+	syscall.RTPROT_BIRD:     "bird",
+	syscall.RTPROT_DNROUTED: "dnrouted",
+	syscall.RTPROT_XORP:     "xorp",
+	syscall.RTPROT_NTK:      "ntk",
+	syscall.RTPROT_DHCP:     "dhcp",
+	17:                      "mrouted",
+	18:                      "keepalived",
+	42:                      "babel",
+	186:                     "bgp",
+	187:                     "isis",
+	188:                     "ospf",
+	189:                     "rip",
+	192:                     "eigrp",
+}
+
+// routeTypes Names of route types as used by `ip route`
+var routeTypes = map[uint8]string{
+	syscall.RTN_UNSPEC:      "none",
+	syscall.RTN_UNICAST:     "unicast",
+	syscall.RTN_LOCAL:       "local",
+	syscall.RTN_BROADCAST:   "broadcast",
+	syscall.RTN_ANYCAST:     "anycast",
+	syscall.RTN_MULTICAST:   "multicast",
+	syscall.RTN_BLACKHOLE:   "blackhole",
+	syscall.RTN_UNREACHABLE: "unreachable",
+	syscall.RTN_PROHIBIT:    "prohibit",
+	syscall.RTN_THROW:       "throw",
+	syscall.RTN_NAT:         "nat",
+	syscall.RTN_XRESOLVE:    "xresolve",
+}
+
+// routeScopes Names of route scopes
+var routeScopes = map[uint8]string{
+	syscall.RT_SCOPE_UNIVERSE: "global",
+	syscall.RT_SCOPE_SITE:     "site",
+	syscall.RT_SCOPE_LINK:     "link",
+	syscall.RT_SCOPE_HOST:     "host",
+	syscall.RT_SCOPE_NOWHERE:  "nowhere",
+}
+
+// lookupName Returns the name of a value, or the number if it has no name
+func lookupName(names map[uint8]string, value uint8) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Nexthop One of the next hops of a multipath route
+type Nexthop struct {
+	Gateway        net.IP
+	InterfaceIndex int
+	Weight         int
+}
+
+// Route A single route from any routing table
+type Route struct {
+	Family uint8
+
+	// The destination in CIDR notation, 0.0.0.0/0 or ::/0 for default routes
+	Destination     string
+	Source          string
+	Gateway         net.IP
+	PreferredSource net.IP
+	InterfaceIndex  int
+	Metric          uint32
+	Table           uint32
+	Protocol        uint8
+	Scope           uint8
+	Type            uint8
+
+	// The type of service that the route applies to, zero for any
+	TOS      uint8
+	Nexthops []Nexthop
+}
+
+// TypeName Returns the name of the type of the route e.g. unicast
+func (r Route) TypeName() string {
+	return lookupName(routeTypes, r.Type)
+}
+
+// ProtocolName Returns the name of the protocol that installed the route e.g.
+// kernel or dhcp
+func (r Route) ProtocolName() string {
+	return lookupName(routeProtocols, r.Protocol)
+}
+
+// ScopeName Returns the name of the scope of the route e.g. link
+func (r Route) ScopeName() string {
+	return lookupName(routeScopes, r.Scope)
+}
+
+// InterfaceIndexes Returns the indexes of all interfaces that this route
+// sends traffic out of, including those of any next hops
+func (r Route) InterfaceIndexes() []int {
+	indexes := make([]int, 0)
+
+	if r.InterfaceIndex != 0 {
+		indexes = append(indexes, r.InterfaceIndex)
+	}
+
+	for _, nh := range r.Nexthops {
+		if nh.InterfaceIndex != 0 {
+			indexes = append(indexes, nh.InterfaceIndex)
+		}
+	}
+
+	return indexes
+}
+
+// Gateways Returns all gateways of this route, including those of any next
+// hops
+func (r Route) Gateways() []net.IP {
+	gateways := make([]net.IP, 0)
+
+	if r.Gateway != nil {
+		gateways = append(gateways, r.Gateway)
+	}
+
+	for _, nh := range r.Nexthops {
+		if nh.Gateway != nil {
+			gateways = append(gateways, nh.Gateway)
+		}
+	}
+
+	return gateways
+}
+
+// IsDefault Returns whether this is a default route
+func (r Route) IsDefault() bool {
+	return r.Destination == "0.0.0.0/0" || r.Destination == "::/0"
+}
+
+// Contains Returns whether traffic to a given IP would match this route's
+// destination
+func (r Route) Contains(ip net.IP) bool {
+	_, network, err := net.ParseCIDR(r.Destination)
+
+	return err == nil && network.Contains(ip)
+}
+
+// ParseRoutes Parses the messages from an RTM_GETROUTE dump. Cached routes
+// and messages of other types are skipped
+func ParseRoutes(msgs []syscall.NetlinkMessage) ([]Route, error) {
+	routes := make([]Route, 0)
+
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWROUTE {
+			continue
+		}
+
+		if len(msg.Data) < syscall.SizeofRtMsg {
+			return nil, fmt.Errorf("route message too short: %v bytes", len(msg.Data))
+		}
+
+		// Skip multicast and MPLS routes
+		if family := msg.Data[0]; family != syscall.AF_INET && family != syscall.AF_INET6 {
+			continue
+		}
+
+		route, err := parseRoute(msg.Data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// parseRoute Parses the body of a single RTM_NEWROUTE message
+func parseRoute(b []byte) (Route, error) {
+	// struct rtmsg
+	dstLen := b[1]
+	srcLen := b[2]
+
+	route := Route{
+		Family:   b[0],
+		TOS:      b[3],
+		Table:    uint32(b[4]),
+		Protocol: b[5],
+		Scope:    b[6],
+		Type:     b[7],
+	}
+
+	attributes, err := parseAttributes(b[syscall.SizeofRtMsg:])
+
+	if err != nil {
+		return Route{}, err
+	}
+
+	for _, a := range attributes {
+		switch a.Type {
+		case syscall.RTA_DST:
+			route.Destination = prefix(a.Value, dstLen)
+		case syscall.RTA_SRC:
+			route.Source = prefix(a.Value, srcLen)
+		case syscall.RTA_GATEWAY:
+			route.Gateway = copyIP(a.Value)
+		case rtaVia:
+			// struct rtvia is a two byte family followed by the address
+			if len(a.Value) > 2 {
+				route.Gateway = copyIP(a.Value[2:])
+			}
+		case syscall.RTA_PREFSRC:
+			route.PreferredSource = copyIP(a.Value)
+		case syscall.RTA_OIF:
+			route.InterfaceIndex = int(a.uint32Value())
+		case syscall.RTA_PRIORITY:
+			route.Metric = a.uint32Value()
+		case syscall.RTA_TABLE:
+			route.Table = a.uint32Value()
+		case syscall.RTA_MULTIPATH:
+			if route.Nexthops, err = parseNexthops(a.Value); err != nil {
+				return Route{}, err
+			}
+		}
+	}
+
+	// Default routes have no destination attribute
+	if route.Destination == "" {
+		if route.Family == syscall.AF_INET {
+			route.Destination = prefix(net.IPv4zero.To4(), dstLen)
+		} else {
+			route.Destination = prefix(net.IPv6zero, dstLen)
+		}
+	}
+
+	return route, nil
+}
+
+// parseNexthops Parses the value of an RTA_MULTIPATH attribute, which is a
+// list of struct rtnexthop, each followed by its own attributes
+func parseNexthops(b []byte) ([]Nexthop, error) {
+	nexthops := make([]Nexthop, 0)
+
+	for len(b) >= sizeofRtNexthop {
+		length := int(nativeEndian.Uint16(b[0:2]))
+
+		if length < sizeofRtNexthop || length > len(b) {
+			return nil, fmt.Errorf("invalid nexthop length %v", length)
+		}
+
+		nh := Nexthop{
+			Weight:         int(b[3]) + 1,
+			InterfaceIndex: int(int32(nativeEndian.Uint32(b[4:8]))),
+		}
+
+		attributes, err := parseAttributes(b[sizeofRtNexthop:length])
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range attributes {
+			switch a.Type {
+			case syscall.RTA_GATEWAY:
+				nh.Gateway = copyIP(a.Value)
+			case rtaVia:
+				if len(a.Value) > 2 {
+					nh.Gateway = copyIP(a.Value[2:])
+				}
+			}
+		}
+
+		nexthops = append(nexthops, nh)
+
+		aligned := (length + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+
+		if aligned > len(b) {
+			break
+		}
+
+		b = b[aligned:]
+	}
+
+	return nexthops, nil
+}
+
+// copyIP Copies an address out of a message buffer
+func copyIP(b []byte) net.IP {
+	ip := make(net.IP, len(b))
+	copy(ip, b)
+
+	return ip
+}
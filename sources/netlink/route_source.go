@@ -0,0 +1,366 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// RouteSource Returns the routes from every routing table, as dumped over
+// netlink. This is equivalent to `ip route show table all`
+type RouteSource struct {
+	// Files that routing table names are read from (optional)
+	RTTablesLocations []string
+
+	// The function that is used to dump routes from the kernel (optional)
+	DumpFunction DumpFunction
+
+	// The function that is used to list interfaces so that their indexes can
+	// be converted to names (optional)
+	InterfacesFunction func() ([]net.Interface, error)
+}
+
+// Type is the type of items that this returns (Required)
+func (s *RouteSource) Type() string {
+	return "route"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *RouteSource) Name() string {
+	return "netlink"
+}
+
+// Weighting of duplicate sources
+func (s *RouteSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *RouteSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a route by its ID in the format
+// {table}/{interface}/{metric}/{destination} e.g. main/eth0/100/0.0.0.0/0
+func (s *RouteSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.routeItems(func(r Route, id string, tables TableNames, interfaces map[int]string) bool {
+		return id == query
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("route %v not found", query),
+			Context:     itemContext,
+		}
+	}
+
+	return items[0], nil
+}
+
+// Find Returns the routes from all routing tables
+func (s *RouteSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.routeItems(func(r Route, id string, tables TableNames, interfaces map[int]string) bool {
+		return true
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Search Accepts an IP address, which returns all routes whose destination
+// contains that address, a table name or number, which returns all routes in
+// that table, or an interface name, which returns all routes out of that
+// interface
+func (s *RouteSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	ip := net.ParseIP(query)
+
+	items, err := s.routeItems(func(r Route, id string, tables TableNames, interfaces map[int]string) bool {
+		if ip != nil {
+			return r.Contains(ip)
+		}
+
+		if table, ok := tables.ID(query); ok {
+			return r.Table == table
+		}
+
+		for _, index := range r.InterfaceIndexes() {
+			if interfaces[index] == query {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether routes can be dumped on this system
+func (s *RouteSource) Supported() bool {
+	_, err := s.dump()
+
+	return err == nil
+}
+
+// dump Dumps and parses all routes
+func (s *RouteSource) dump() ([]Route, error) {
+	dumpFunction := s.DumpFunction
+
+	if dumpFunction == nil {
+		dumpFunction = Dump
+	}
+
+	msgs, err := dumpFunction(syscall.RTM_GETROUTE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseRoutes(msgs)
+}
+
+// tableNames Returns the names of routing tables
+func (s *RouteSource) tableNames() TableNames {
+	if s.RTTablesLocations == nil {
+		return ReadTableNames(DefaultRTTablesLocations)
+	}
+
+	return ReadTableNames(s.RTTablesLocations)
+}
+
+// interfaceNames Returns a map of interface index to name
+func (s *RouteSource) interfaceNames() map[int]string {
+	return interfaceNames(s.InterfacesFunction)
+}
+
+// RouteID Returns the unique ID of a route. The interface is included since
+// IPv6 allows the same destination and metric on multiple interfaces. Routes
+// that aren't unicast have their type appended e.g.
+// main/-/0/192.0.2.0/24/blackhole, and those for a specific type of service
+// have it appended as tos{value} e.g. main/eth0/0/10.0.0.0/8/tos0x10
+func RouteID(r Route, tables TableNames, interfaces map[int]string) string {
+	iface := interfaces[r.InterfaceIndex]
+
+	if iface == "" {
+		iface = "-"
+	}
+
+	id := fmt.Sprintf("%v/%v/%v/%v", tables.Name(r.Table), iface, r.Metric, r.Destination)
+
+	if r.Type != syscall.RTN_UNICAST {
+		id += "/" + r.TypeName()
+	}
+
+	if r.TOS != 0 {
+		id += fmt.Sprintf("/tos%#x", r.TOS)
+	}
+
+	return id
+}
+
+// routeItems Returns items for all routes that match a filter
+func (s *RouteSource) routeItems(filter func(r Route, id string, tables TableNames, interfaces map[int]string) bool) ([]*sdp.Item, error) {
+	routes, err := s.dump()
+
+	if err != nil {
+		return nil, err
+	}
+
+	tables := s.tableNames()
+	interfaces := s.interfaceNames()
+	items := make([]*sdp.Item, 0)
+
+	for _, route := range routes {
+		id := RouteID(route, tables, interfaces)
+
+		if !filter(route, id, tables, interfaces) {
+			continue
+		}
+
+		if item, err := routeToItem(route, id, tables, interfaces); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// routeToItem Converts a route to an item
+func routeToItem(r Route, id string, tables TableNames, interfaces map[int]string) (*sdp.Item, error) {
+	attrs := map[string]interface{}{
+		"id":          id,
+		"family":      FamilyName(r.Family),
+		"destination": r.Destination,
+		"default":     r.IsDefault(),
+		"metric":      r.Metric,
+		"table":       tables.Name(r.Table),
+		"tableID":     r.Table,
+		"protocol":    r.ProtocolName(),
+		"scope":       r.ScopeName(),
+		"type":        r.TypeName(),
+	}
+
+	if r.Source != "" {
+		attrs["source"] = r.Source
+	}
+
+	if r.TOS != 0 {
+		attrs["tos"] = r.TOS
+	}
+
+	if r.Gateway != nil {
+		attrs["gateway"] = r.Gateway.String()
+	}
+
+	if r.PreferredSource != nil {
+		attrs["preferredSource"] = r.PreferredSource.String()
+	}
+
+	if name, ok := interfaces[r.InterfaceIndex]; ok {
+		attrs["interface"] = name
+	}
+
+	if len(r.Nexthops) > 0 {
+		nexthops := make([]map[string]interface{}, 0, len(r.Nexthops))
+
+		for _, nh := range r.Nexthops {
+			nexthop := map[string]interface{}{
+				"weight": nh.Weight,
+			}
+
+			if nh.Gateway != nil {
+				nexthop["gateway"] = nh.Gateway.String()
+			}
+
+			if name, ok := interfaces[nh.InterfaceIndex]; ok {
+				nexthop["interface"] = name
+			}
+
+			nexthops = append(nexthops, nexthop)
+		}
+
+		attrs["nexthops"] = nexthops
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "route",
+		UniqueAttribute: "id",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	linked := make(map[string]bool)
+
+	for _, index := range r.InterfaceIndexes() {
+		if name, ok := interfaces[index]; ok && !linked[name] {
+			linked[name] = true
+
+			item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "interface",
+				Method:  sdp.RequestMethod_GET,
+				Query:   name,
+				Context: util.LocalContext,
+			})
+		}
+	}
+
+	for _, gateway := range r.Gateways() {
+		// Link-local gateways are common for IPv6 but aren't globally unique
+		if gateway.IsLinkLocalUnicast() || gateway.IsLoopback() || linked[gateway.String()] {
+			continue
+		}
+
+		linked[gateway.String()] = true
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "ip",
+			Method:  sdp.RequestMethod_GET,
+			Query:   gateway.String(),
+			Context: "global",
+		})
+	}
+
+	return &item, nil
+}
+
+// interfaceNames Returns a map of interface index to name, using the supplied
+// function or net.Interfaces if it is nil
+func interfaceNames(interfacesFunction func() ([]net.Interface, error)) map[int]string {
+	if interfacesFunction == nil {
+		interfacesFunction = net.Interfaces
+	}
+
+	names := make(map[int]string)
+
+	if interfaces, err := interfacesFunction(); err == nil {
+		for _, iface := range interfaces {
+			names[iface.Index] = iface.Name
+		}
+	}
+
+	return names
+}
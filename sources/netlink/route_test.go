@@ -0,0 +1,327 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// testRtMsg Builds a struct rtmsg
+func testRtMsg(family, dstLen, table, protocol, scope, routeType uint8) []byte {
+	return []byte{family, dstLen, 0, 0, table, protocol, scope, routeType, 0, 0, 0, 0}
+}
+
+// testNexthop Builds a struct rtnexthop followed by a gateway attribute
+func testNexthop(ifindex uint32, hops uint8, gateway string) []byte {
+	gw := testAttr(syscall.RTA_GATEWAY, testIP(gateway))
+	b := make([]byte, sizeofRtNexthop)
+
+	nativeEndian.PutUint16(b[0:2], uint16(sizeofRtNexthop+len(gw)))
+	b[3] = hops
+	nativeEndian.PutUint32(b[4:8], ifindex)
+
+	return append(b, gw...)
+}
+
+func testRouteDump(request int) ([]syscall.NetlinkMessage, error) {
+	return []syscall.NetlinkMessage{
+		// default via 10.0.0.1 dev eth0 proto dhcp metric 100
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(syscall.AF_INET, 0, syscall.RT_TABLE_MAIN, syscall.RTPROT_DHCP, syscall.RT_SCOPE_UNIVERSE, syscall.RTN_UNICAST),
+			testAttr(syscall.RTA_TABLE, testUint32(syscall.RT_TABLE_MAIN)),
+			testAttr(syscall.RTA_PRIORITY, testUint32(100)),
+			testAttr(syscall.RTA_GATEWAY, testIP("10.0.0.1")),
+			testAttr(syscall.RTA_OIF, testUint32(2)),
+		),
+		// 10.0.0.0/24 dev eth0 proto kernel scope link src 10.0.0.5
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(syscall.AF_INET, 24, syscall.RT_TABLE_MAIN, syscall.RTPROT_KERNEL, syscall.RT_SCOPE_LINK, syscall.RTN_UNICAST),
+			testAttr(syscall.RTA_TABLE, testUint32(syscall.RT_TABLE_MAIN)),
+			testAttr(syscall.RTA_DST, testIP("10.0.0.0")),
+			testAttr(syscall.RTA_PREFSRC, testIP("10.0.0.5")),
+			testAttr(syscall.RTA_OIF, testUint32(2)),
+		),
+		// default table vpn proto static
+		//   nexthop via 10.8.0.1 dev wg0 weight 1
+		//   nexthop via 10.9.0.1 dev wg1 weight 2
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(syscall.AF_INET, 0, 100, syscall.RTPROT_STATIC, syscall.RT_SCOPE_UNIVERSE, syscall.RTN_UNICAST),
+			testAttr(syscall.RTA_TABLE, testUint32(100)),
+			testAttr(syscall.RTA_MULTIPATH, append(testNexthop(3, 0, "10.8.0.1"), testNexthop(4, 1, "10.9.0.1")...)),
+		),
+		// default via fe80::1 dev eth0 proto ra metric 100
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(syscall.AF_INET6, 0, syscall.RT_TABLE_MAIN, syscall.RTPROT_RA, syscall.RT_SCOPE_UNIVERSE, syscall.RTN_UNICAST),
+			testAttr(syscall.RTA_TABLE, testUint32(syscall.RT_TABLE_MAIN)),
+			testAttr(syscall.RTA_PRIORITY, testUint32(100)),
+			testAttr(syscall.RTA_GATEWAY, testIP("fe80::1")),
+			testAttr(syscall.RTA_OIF, testUint32(2)),
+		),
+		// local 10.0.0.5 dev eth0 table local proto kernel scope host
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(syscall.AF_INET, 32, syscall.RT_TABLE_LOCAL, syscall.RTPROT_KERNEL, syscall.RT_SCOPE_HOST, syscall.RTN_LOCAL),
+			testAttr(syscall.RTA_TABLE, testUint32(syscall.RT_TABLE_LOCAL)),
+			testAttr(syscall.RTA_DST, testIP("10.0.0.5")),
+			testAttr(syscall.RTA_OIF, testUint32(2)),
+		),
+		// Multicast routes should be skipped
+		testMessage(syscall.RTM_NEWROUTE,
+			testRtMsg(128, 0, syscall.RT_TABLE_DEFAULT, syscall.RTPROT_UNSPEC, syscall.RT_SCOPE_UNIVERSE, syscall.RTN_MULTICAST),
+		),
+		{
+			Header: syscall.NlMsghdr{Type: syscall.NLMSG_DONE},
+		},
+	}, nil
+}
+
+func testInterfaces() ([]net.Interface, error) {
+	return []net.Interface{
+		{Index: 1, Name: "lo"},
+		{Index: 2, Name: "eth0"},
+		{Index: 3, Name: "wg0"},
+		{Index: 4, Name: "wg1"},
+	}, nil
+}
+
+func TestParseRoutes(t *testing.T) {
+	msgs, _ := testRouteDump(syscall.RTM_GETROUTE)
+
+	routes, err := ParseRoutes(msgs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routes) != 5 {
+		t.Fatalf("expected 5 routes, got %v", len(routes))
+	}
+
+	def := routes[0]
+
+	if def.Destination != "0.0.0.0/0" || !def.IsDefault() {
+		t.Errorf("expected default route, got %v", def.Destination)
+	}
+
+	if def.Gateway.String() != "10.0.0.1" {
+		t.Errorf("expected gateway 10.0.0.1, got %v", def.Gateway)
+	}
+
+	if def.Metric != 100 || def.InterfaceIndex != 2 || def.ProtocolName() != "dhcp" {
+		t.Errorf("unexpected route %+v", def)
+	}
+
+	link := routes[1]
+
+	if link.Destination != "10.0.0.0/24" || link.ScopeName() != "link" || link.PreferredSource.String() != "10.0.0.5" {
+		t.Errorf("unexpected route %+v", link)
+	}
+
+	multipath := routes[2]
+
+	if len(multipath.Nexthops) != 2 {
+		t.Fatalf("expected 2 next hops, got %v", len(multipath.Nexthops))
+	}
+
+	if nh := multipath.Nexthops[1]; nh.Gateway.String() != "10.9.0.1" || nh.InterfaceIndex != 4 || nh.Weight != 2 {
+		t.Errorf("unexpected next hop %+v", nh)
+	}
+
+	if routes[3].Destination != "::/0" {
+		t.Errorf("expected IPv6 default route, got %v", routes[3].Destination)
+	}
+
+	if routes[4].TypeName() != "local" {
+		t.Errorf("expected local route, got %v", routes[4].TypeName())
+	}
+}
+
+func TestRouteID(t *testing.T) {
+	tables := TableNames{syscall.RT_TABLE_MAIN: "main"}
+	interfaces := map[int]string{2: "eth0"}
+
+	tests := []struct {
+		Route    Route
+		Expected string
+	}{
+		{
+			Route:    Route{Destination: "10.0.0.0/8", Table: syscall.RT_TABLE_MAIN, InterfaceIndex: 2, Type: syscall.RTN_UNICAST},
+			Expected: "main/eth0/0/10.0.0.0/8",
+		},
+		{
+			Route:    Route{Destination: "10.0.0.0/8", Table: syscall.RT_TABLE_MAIN, InterfaceIndex: 2, Type: syscall.RTN_UNICAST, TOS: 0x10},
+			Expected: "main/eth0/0/10.0.0.0/8/tos0x10",
+		},
+		{
+			Route:    Route{Destination: "192.0.2.0/24", Table: syscall.RT_TABLE_MAIN, Type: syscall.RTN_BLACKHOLE},
+			Expected: "main/-/0/192.0.2.0/24/blackhole",
+		},
+		{
+			Route:    Route{Destination: "192.0.2.0/24", Table: syscall.RT_TABLE_MAIN, Type: syscall.RTN_UNREACHABLE},
+			Expected: "main/-/0/192.0.2.0/24/unreachable",
+		},
+	}
+
+	for _, test := range tests {
+		if id := RouteID(test.Route, tables, interfaces); id != test.Expected {
+			t.Errorf("expected %v, got %v", test.Expected, id)
+		}
+	}
+}
+
+func TestRouteSource(t *testing.T) {
+	source := RouteSource{
+		RTTablesLocations:  []string{"test/rt_tables"},
+		DumpFunction:       testRouteDump,
+		InterfacesFunction: testInterfaces,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get default route",
+			ItemContext: util.LocalContext,
+			Query:       "main/eth0/100/0.0.0.0/0",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"family":    "ipv4",
+						"gateway":   "10.0.0.1",
+						"interface": "eth0",
+						"metric":    float64(100),
+						"table":     "main",
+						"protocol":  "dhcp",
+						"default":   true,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing route",
+			ItemContext: util.LocalContext,
+			Query:       "main/eth0/100/192.168.0.0/16",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search by table name",
+			ItemContext: util.LocalContext,
+			Query:       "vpn",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"id":      "vpn/-/0/0.0.0.0/0",
+						"tableID": float64(100),
+					},
+				},
+			},
+		},
+		{
+			Name:        "search by IP",
+			ItemContext: util.LocalContext,
+			Query:       "10.0.0.5",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				// Both default routes, the link route and the local route
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search by interface",
+			ItemContext: util.LocalContext,
+			Query:       "wg1",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestRouteLinks(t *testing.T) {
+	source := RouteSource{
+		RTTablesLocations:  []string{"test/rt_tables"},
+		DumpFunction:       testRouteDump,
+		InterfacesFunction: testInterfaces,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "vpn/-/0/0.0.0.0/0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		Type  string
+		Query string
+	}{
+		{"interface", "wg0"},
+		{"interface", "wg1"},
+		{"ip", "10.8.0.1"},
+		{"ip", "10.9.0.1"},
+	}
+
+	if len(item.LinkedItemRequests) != len(expected) {
+		t.Fatalf("expected %v links, got %v", len(expected), item.LinkedItemRequests)
+	}
+
+	for i, e := range expected {
+		link := item.LinkedItemRequests[i]
+
+		if link.Type != e.Type || link.Query != e.Query {
+			t.Errorf("expected link %v to be %v %v, got %v %v", i, e.Type, e.Query, link.Type, link.Query)
+		}
+	}
+
+	// Link-local gateways shouldn't be linked
+	item, err = source.Get(context.Background(), util.LocalContext, "main/eth0/100/::/0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 1 {
+		t.Errorf("expected only a link to the interface, got %v", item.LinkedItemRequests)
+	}
+}
+
+func TestDumpRoutes(t *testing.T) {
+	msgs, err := Dump(syscall.RTM_GETROUTE)
+
+	if err != nil {
+		t.Skipf("netlink not available: %v", err)
+	}
+
+	if _, err := ParseRoutes(msgs); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,176 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Attributes of fib rules, see include/uapi/linux/fib_rules.h
+const (
+	fraDst        = 1
+	fraSrc        = 2
+	fraIifname    = 3
+	fraGoto       = 4
+	fraPriority   = 6
+	fraFwmark     = 10
+	fraTable      = 15
+	fraFwmask     = 16
+	fraOifname    = 17
+	fraL3mdev     = 19
+	fraProtocol   = 21
+	fibRuleInvert = 0x2
+)
+
+// sizeofFibRuleHdr The size of struct fib_rule_hdr
+const sizeofFibRuleHdr = 12
+
+// ruleActions Names of rule actions as used by `ip rule`
+var ruleActions = map[uint8]string{
+	0: "unspec",
+	1: "lookup",
+	2: "goto",
+	3: "nop",
+	6: "blackhole",
+	7: "unreachable",
+	8: "prohibit",
+}
+
+// Rule A routing policy rule, as shown by `ip rule`
+type Rule struct {
+	Family   uint8
+	Priority uint32
+
+	// Source and destination selectors in CIDR notation, empty if the rule
+	// matches all addresses
+	Source      string
+	Destination string
+
+	InputInterface  string
+	OutputInterface string
+	FirewallMark    uint32
+	FirewallMask    uint32
+	Invert          bool
+	L3MDev          bool
+	Action          uint8
+	Table           uint32
+	Goto            uint32
+	Protocol        uint8
+}
+
+// ActionName Returns the name of the rule's action e.g. lookup
+func (r Rule) ActionName() string {
+	return lookupName(ruleActions, r.Action)
+}
+
+// ProtocolName Returns the name of the protocol that installed the rule
+func (r Rule) ProtocolName() string {
+	return lookupName(routeProtocols, r.Protocol)
+}
+
+// Selector Returns the part of the rule that decides which packets it
+// matches, in the same format as `ip rule` e.g. "from 10.0.0.0/8 fwmark 0x1"
+func (r Rule) Selector() string {
+	selector := ""
+
+	if r.Invert {
+		selector += "not "
+	}
+
+	if r.Source != "" {
+		selector += "from " + r.Source
+	} else {
+		selector += "from all"
+	}
+
+	if r.Destination != "" {
+		selector += " to " + r.Destination
+	}
+
+	if r.FirewallMark != 0 || r.FirewallMask != 0 {
+		selector += fmt.Sprintf(" fwmark %#x", r.FirewallMark)
+
+		if r.FirewallMask != 0 && r.FirewallMask != 0xffffffff {
+			selector += fmt.Sprintf("/%#x", r.FirewallMask)
+		}
+	}
+
+	if r.InputInterface != "" {
+		selector += " iif " + r.InputInterface
+	}
+
+	if r.OutputInterface != "" {
+		selector += " oif " + r.OutputInterface
+	}
+
+	return selector
+}
+
+// ParseRules Parses the messages from an RTM_GETRULE dump
+func ParseRules(msgs []syscall.NetlinkMessage) ([]Rule, error) {
+	rules := make([]Rule, 0)
+
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWRULE {
+			continue
+		}
+
+		b := msg.Data
+
+		if len(b) < sizeofFibRuleHdr {
+			return nil, fmt.Errorf("rule message too short: %v bytes", len(b))
+		}
+
+		// struct fib_rule_hdr
+		dstLen := b[1]
+		srcLen := b[2]
+
+		rule := Rule{
+			Family: b[0],
+			Table:  uint32(b[4]),
+			Action: b[7],
+			Invert: nativeEndian.Uint32(b[8:12])&fibRuleInvert != 0,
+		}
+
+		attributes, err := parseAttributes(b[sizeofFibRuleHdr:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range attributes {
+			switch a.Type {
+			case fraDst:
+				rule.Destination = prefix(a.Value, dstLen)
+			case fraSrc:
+				rule.Source = prefix(a.Value, srcLen)
+			case fraIifname:
+				rule.InputInterface = a.stringValue()
+			case fraOifname:
+				rule.OutputInterface = a.stringValue()
+			case fraGoto:
+				rule.Goto = a.uint32Value()
+			case fraPriority:
+				rule.Priority = a.uint32Value()
+			case fraFwmark:
+				rule.FirewallMark = a.uint32Value()
+			case fraFwmask:
+				rule.FirewallMask = a.uint32Value()
+			case fraTable:
+				rule.Table = a.uint32Value()
+			case fraL3mdev:
+				rule.L3MDev = len(a.Value) > 0 && a.Value[0] != 0
+			case fraProtocol:
+				if len(a.Value) > 0 {
+					rule.Protocol = a.Value[0]
+				}
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,284 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// RuleSource Returns routing policy rules, as dumped over netlink. This is
+// equivalent to `ip rule show` for both IPv4 and IPv6
+type RuleSource struct {
+	// Files that routing table names are read from (optional)
+	RTTablesLocations []string
+
+	// The function that is used to dump rules from the kernel (optional)
+	DumpFunction DumpFunction
+}
+
+// Type is the type of items that this returns (Required)
+func (s *RuleSource) Type() string {
+	return "routerule"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *RuleSource) Name() string {
+	return "netlink"
+}
+
+// Weighting of duplicate sources
+func (s *RuleSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *RuleSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a rule by its ID in the format {family}/{priority} e.g.
+// ipv4/32766. If there are multiple rules with the same priority the second
+// and subsequent ones have a suffix e.g. ipv4/100#2
+func (s *RuleSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.ruleItems(func(r Rule, id string, tables TableNames) bool {
+		return id == query
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("rule %v not found", query),
+			Context:     itemContext,
+		}
+	}
+
+	return items[0], nil
+}
+
+// Find Returns all routing policy rules
+func (s *RuleSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.ruleItems(func(r Rule, id string, tables TableNames) bool {
+		return true
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Search Accepts a table name or number and returns the rules that look up
+// that table
+func (s *RuleSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	items, err := s.ruleItems(func(r Rule, id string, tables TableNames) bool {
+		table, ok := tables.ID(query)
+
+		return ok && r.ActionName() == "lookup" && r.Table == table
+	})
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether rules can be dumped on this system
+func (s *RuleSource) Supported() bool {
+	_, err := s.dump()
+
+	return err == nil
+}
+
+// dump Dumps and parses all rules
+func (s *RuleSource) dump() ([]Rule, error) {
+	dumpFunction := s.DumpFunction
+
+	if dumpFunction == nil {
+		dumpFunction = Dump
+	}
+
+	msgs, err := dumpFunction(syscall.RTM_GETRULE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseRules(msgs)
+}
+
+// ruleItems Returns items for all rules that match a filter
+func (s *RuleSource) ruleItems(filter func(r Rule, id string, tables TableNames) bool) ([]*sdp.Item, error) {
+	rules, err := s.dump()
+
+	if err != nil {
+		return nil, err
+	}
+
+	locations := s.RTTablesLocations
+
+	if locations == nil {
+		locations = DefaultRTTablesLocations
+	}
+
+	tables := ReadTableNames(locations)
+	items := make([]*sdp.Item, 0)
+	seen := make(map[string]int)
+
+	for _, rule := range rules {
+		id := fmt.Sprintf("%v/%v", FamilyName(rule.Family), rule.Priority)
+
+		// Duplicate priorities are allowed, rules are evaluated in the order
+		// that the kernel returns them
+		seen[id]++
+
+		if n := seen[id]; n > 1 {
+			id = fmt.Sprintf("%v#%v", id, n)
+		}
+
+		if !filter(rule, id, tables) {
+			continue
+		}
+
+		if item, err := ruleToItem(rule, id, tables); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// ruleToItem Converts a rule to an item
+func ruleToItem(r Rule, id string, tables TableNames) (*sdp.Item, error) {
+	attrs := map[string]interface{}{
+		"id":       id,
+		"family":   FamilyName(r.Family),
+		"priority": r.Priority,
+		"selector": r.Selector(),
+		"action":   r.ActionName(),
+		"protocol": r.ProtocolName(),
+		"invert":   r.Invert,
+	}
+
+	if r.Source != "" {
+		attrs["source"] = r.Source
+	}
+
+	if r.Destination != "" {
+		attrs["destination"] = r.Destination
+	}
+
+	if r.InputInterface != "" {
+		attrs["inputInterface"] = r.InputInterface
+	}
+
+	if r.OutputInterface != "" {
+		attrs["outputInterface"] = r.OutputInterface
+	}
+
+	if r.FirewallMark != 0 {
+		attrs["fwmark"] = r.FirewallMark
+	}
+
+	if r.FirewallMask != 0 {
+		attrs["fwmask"] = r.FirewallMask
+	}
+
+	if r.ActionName() == "lookup" {
+		attrs["table"] = tables.Name(r.Table)
+		attrs["tableID"] = r.Table
+	}
+
+	if r.ActionName() == "goto" {
+		attrs["goto"] = r.Goto
+	}
+
+	if r.L3MDev {
+		attrs["l3mdev"] = true
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "routerule",
+		UniqueAttribute: "id",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	if r.ActionName() == "lookup" {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "route",
+			Method:  sdp.RequestMethod_SEARCH,
+			Query:   tables.Name(r.Table),
+			Context: util.LocalContext,
+		})
+	}
+
+	for _, iface := range []string{r.InputInterface, r.OutputInterface} {
+		if iface != "" {
+			item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "interface",
+				Method:  sdp.RequestMethod_GET,
+				Query:   iface,
+				Context: util.LocalContext,
+			})
+		}
+	}
+
+	return &item, nil
+}
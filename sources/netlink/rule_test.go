@@ -0,0 +1,193 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// testRuleHdr Builds a struct fib_rule_hdr
+func testRuleHdr(family, srcLen, table, action uint8, flags uint32) []byte {
+	b := []byte{family, 0, srcLen, 0, table, 0, 0, action, 0, 0, 0, 0}
+	nativeEndian.PutUint32(b[8:12], flags)
+
+	return b
+}
+
+func testRuleDump(request int) ([]syscall.NetlinkMessage, error) {
+	return []syscall.NetlinkMessage{
+		// 0: from all lookup local
+		testMessage(syscall.RTM_NEWRULE,
+			testRuleHdr(syscall.AF_INET, 0, syscall.RT_TABLE_LOCAL, 1, 0),
+			testAttr(fraTable, testUint32(syscall.RT_TABLE_LOCAL)),
+		),
+		// 100: from 10.8.0.0/24 iif wg0 lookup vpn
+		testMessage(syscall.RTM_NEWRULE,
+			testRuleHdr(syscall.AF_INET, 24, 100, 1, 0),
+			testAttr(fraPriority, testUint32(100)),
+			testAttr(fraSrc, testIP("10.8.0.0")),
+			testAttr(fraIifname, []byte("wg0\x00")),
+			testAttr(fraTable, testUint32(100)),
+		),
+		// 100: not from all fwmark 0xca6c lookup vpn
+		testMessage(syscall.RTM_NEWRULE,
+			testRuleHdr(syscall.AF_INET, 0, 100, 1, fibRuleInvert),
+			testAttr(fraPriority, testUint32(100)),
+			testAttr(fraFwmark, testUint32(0xca6c)),
+			testAttr(fraFwmask, testUint32(0xffffffff)),
+			testAttr(fraTable, testUint32(100)),
+		),
+		// 32766: from all lookup main
+		testMessage(syscall.RTM_NEWRULE,
+			testRuleHdr(syscall.AF_INET6, 0, syscall.RT_TABLE_MAIN, 1, 0),
+			testAttr(fraPriority, testUint32(32766)),
+			testAttr(fraTable, testUint32(syscall.RT_TABLE_MAIN)),
+		),
+		// 40000: from all unreachable
+		testMessage(syscall.RTM_NEWRULE,
+			testRuleHdr(syscall.AF_INET, 0, 0, 7, 0),
+			testAttr(fraPriority, testUint32(40000)),
+		),
+	}, nil
+}
+
+func TestParseRules(t *testing.T) {
+	msgs, _ := testRuleDump(syscall.RTM_GETRULE)
+
+	rules, err := ParseRules(msgs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 5 {
+		t.Fatalf("expected 5 rules, got %v", len(rules))
+	}
+
+	expected := []string{
+		"from all",
+		"from 10.8.0.0/24 iif wg0",
+		"not from all fwmark 0xca6c",
+		"from all",
+		"from all",
+	}
+
+	for i, selector := range expected {
+		if rules[i].Selector() != selector {
+			t.Errorf("expected rule %v selector to be %q, got %q", i, selector, rules[i].Selector())
+		}
+	}
+
+	if rules[4].ActionName() != "unreachable" {
+		t.Errorf("expected unreachable, got %v", rules[4].ActionName())
+	}
+}
+
+func TestRuleSource(t *testing.T) {
+	source := RuleSource{
+		RTTablesLocations: []string{"test/rt_tables"},
+		DumpFunction:      testRuleDump,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get rule",
+			ItemContext: util.LocalContext,
+			Query:       "ipv4/100",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"source":         "10.8.0.0/24",
+						"inputInterface": "wg0",
+						"action":         "lookup",
+						"table":          "vpn",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get duplicate priority",
+			ItemContext: util.LocalContext,
+			Query:       "ipv4/100#2",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"invert": true,
+						"fwmark": float64(0xca6c),
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing rule",
+			ItemContext: util.LocalContext,
+			Query:       "ipv6/100",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 5,
+			},
+		},
+		{
+			Name:        "search by table",
+			ItemContext: util.LocalContext,
+			Query:       "vpn",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 2,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestRuleLinks(t *testing.T) {
+	source := RuleSource{
+		RTTablesLocations: []string{"test/rt_tables"},
+		DumpFunction:      testRuleDump,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "ipv4/100")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 2 {
+		t.Fatalf("expected 2 links, got %v", item.LinkedItemRequests)
+	}
+
+	if l := item.LinkedItemRequests[0]; l.Type != "route" || l.Method != sdp.RequestMethod_SEARCH || l.Query != "vpn" {
+		t.Errorf("expected route search for vpn, got %v", l)
+	}
+
+	if l := item.LinkedItemRequests[1]; l.Type != "interface" || l.Query != "wg0" {
+		t.Errorf("expected link to wg0, got %v", l)
+	}
+}
@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package netlink
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRTTablesLocations The files that routing table names are read from,
+// later files override earlier ones
+var DefaultRTTablesLocations = []string{
+	"/usr/share/iproute2/rt_tables",
+	"/etc/iproute2/rt_tables",
+	"/etc/iproute2/rt_tables.d/*.conf",
+}
+
+// builtinTables Tables that are always named, even if rt_tables can't be read
+var builtinTables = map[uint32]string{
+	253: "default",
+	254: "main",
+	255: "local",
+}
+
+// TableNames Maps routing table IDs to their names
+type TableNames map[uint32]string
+
+// ReadTableNames Reads the names of routing tables from rt_tables files. Each
+// location may be a glob. Files that don't exist are ignored
+func ReadTableNames(locations []string) TableNames {
+	names := make(TableNames)
+
+	for id, name := range builtinTables {
+		names[id] = name
+	}
+
+	for _, location := range locations {
+		files, err := filepath.Glob(location)
+
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			if f, err := os.Open(file); err == nil {
+				scanner := bufio.NewScanner(f)
+
+				for scanner.Scan() {
+					parseTableLine(scanner.Text(), names)
+				}
+
+				f.Close()
+			}
+		}
+	}
+
+	return names
+}
+
+// parseTableLine Parses a single line of an rt_tables file e.g. "100 vpn"
+func parseTableLine(line string, names TableNames) {
+	if i := strings.Index(line, "#"); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 2 {
+		return
+	}
+
+	id, err := strconv.ParseUint(fields[0], 0, 32)
+
+	if err != nil {
+		return
+	}
+
+	names[uint32(id)] = fields[1]
+}
+
+// Name Returns the name of a table, or its ID if it has no name
+func (t TableNames) Name(id uint32) string {
+	if name, ok := t[id]; ok {
+		return name
+	}
+
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// ID Returns the ID of a table from its name or number
+func (t TableNames) ID(name string) (uint32, bool) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), true
+	}
+
+	for id, n := range t {
+		if n == name {
+			return id, true
+		}
+	}
+
+	return 0, false
+}
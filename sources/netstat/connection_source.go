@@ -0,0 +1,398 @@
+//go:build linux || windows
+// +build linux windows
+
+package netstat
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// ConnectionSource Returns established TCP connections, both inbound and
+// outbound. Connections are aggregated by local process, remote IP, service
+// port and direction so that items are stable and don't churn every time a new
+// ephemeral port is used
+type ConnectionSource struct {
+	// Whether to include connections in every state, such as TIME_WAIT and
+	// CLOSE_WAIT, rather than only those that are established
+	AllStates bool
+}
+
+// Directions of a connection
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Type is the type of items that this returns
+func (s *ConnectionSource) Type() string {
+	return "connection"
+}
+
+// Name Returns the name of the backend
+func (s *ConnectionSource) Name() string {
+	return "netstat"
+}
+
+// Supported returns whether the backend is supported on this platform
+func (s *ConnectionSource) Supported() bool {
+	return (runtime.GOOS == "linux" || runtime.GOOS == "windows")
+}
+
+// Weighting of duplicate sources
+func (s *ConnectionSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *ConnectionSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Connection A group of TCP connections between a local process and a remote
+// service
+type Connection struct {
+	// Either inbound or outbound. A connection is inbound if its local address
+	// and port are one that is listening
+	Direction string
+
+	// The PID of the local process, 0 if it couldn't be determined
+	PID int
+
+	// The name of the local process
+	ProcessName string
+
+	// The IP address of the remote end
+	RemoteIP net.IP
+
+	// The port of the service that is being connected to. For inbound
+	// connections this is the local port, for outbound it's the remote port
+	ServicePort uint16
+
+	// The local IPs that the connections use
+	LocalIPs map[string]bool
+
+	// The number of connections in each state
+	States map[string]int
+}
+
+// ID Returns the unique ID of the connection in the format
+// {direction}/{pid}/{remoteIP}/{servicePort}, or
+// {direction}/{remoteIP}/{servicePort} if the PID isn't known
+func (c *Connection) ID() string {
+	return ConnectionID(c.Direction, c.PID, c.RemoteIP, c.ServicePort)
+}
+
+// Count Returns the total number of connections
+func (c *Connection) Count() int {
+	var count int
+
+	for _, n := range c.States {
+		count += n
+	}
+
+	return count
+}
+
+// ConnectionID Returns the ID of a connection in the format
+// {direction}/{pid}/{remoteIP}/{servicePort} e.g.
+// outbound/812/10.0.0.5/5432. The PID is left out if it is 0 i.e. unknown
+func ConnectionID(direction string, pid int, remoteIP net.IP, servicePort uint16) string {
+	if pid == 0 {
+		return fmt.Sprintf("%v/%v/%v", direction, remoteIP, servicePort)
+	}
+
+	return fmt.Sprintf("%v/%v/%v/%v", direction, pid, remoteIP, servicePort)
+}
+
+// Get Gets a connection by its ID e.g. outbound/812/10.0.0.5/5432
+func (s *ConnectionSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	connections, err := currentConnections(s.AllStates)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	for _, c := range connections {
+		if c.ID() == query {
+			return connectionToItem(c)
+		}
+	}
+
+	return nil, &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOTFOUND,
+		ErrorString: fmt.Sprintf("connection %v not found", query),
+		Context:     itemContext,
+	}
+}
+
+// Find Returns all current connections
+func (s *ConnectionSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.Search(ctx, itemContext, "*")
+}
+
+// Search Accepts a PID and returns the connections for that process. A "*"
+// can also be passed which will return all items
+func (s *ConnectionSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	isWildcard := (query == "*")
+	var pid int
+	var err error
+
+	if !isWildcard {
+		pid, err = strconv.Atoi(query)
+
+		if err != nil {
+			return nil, &sdp.ItemRequestError{
+				ErrorType:   sdp.ItemRequestError_OTHER,
+				ErrorString: fmt.Sprintf("PID could not be converted to integer, encountered error: %v", err),
+				Context:     itemContext,
+			}
+		}
+	}
+
+	connections, err := currentConnections(s.AllStates)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, c := range connections {
+		if isWildcard || c.PID == pid {
+			if item, err := connectionToItem(c); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// currentConnections Reads the socket tables and aggregates the current
+// connections. Only established connections are included unless allStates is
+// true, since the others are closing or have already closed. Returns an error
+// if the listening sockets can't be read, since without them inbound
+// connections would be reported as outbound
+func currentConnections(allStates bool) ([]*Connection, error) {
+	accept := acceptEstablished
+
+	if allStates {
+		accept = acceptConnected
+	}
+
+	listening, err := listeningSockets(ProtocolTCP, acceptListening(ProtocolTCP))
+
+	if err != nil {
+		return nil, err
+	}
+
+	connected, err := listeningSockets(ProtocolTCP, accept)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateConnections(listening, connected), nil
+}
+
+// acceptConnected Accepts sockets that have a remote end, i.e. anything that
+// isn't listening
+func acceptConnected(s *ns.SockTabEntry) bool {
+	return s.State != ns.Listen && s.RemoteAddr != nil && s.RemoteAddr.Port != 0
+}
+
+// acceptEstablished Accepts sockets that are connected to a remote end
+func acceptEstablished(s *ns.SockTabEntry) bool {
+	return s.State == ns.Established && acceptConnected(s)
+}
+
+// isListening Returns whether a local address is one that a socket is
+// listening on. Sockets that listen on all addresses (0.0.0.0 or ::) match
+// any address with the same port
+func isListening(listening []ns.SockTabEntry, local *ns.SockAddr) bool {
+	for _, l := range listening {
+		if l.LocalAddr == nil || l.LocalAddr.Port != local.Port {
+			continue
+		}
+
+		if l.LocalAddr.IP == nil || l.LocalAddr.IP.IsUnspecified() || l.LocalAddr.IP.Equal(local.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aggregateConnections Groups connected sockets into connections. The
+// listening sockets are used to determine which connections are inbound,
+// since an outbound connection's ephemeral port can have the same number as a
+// port that is listening on a different address
+func aggregateConnections(listening []ns.SockTabEntry, sockets []ns.SockTabEntry) []*Connection {
+	connections := make(map[string]*Connection)
+
+	for _, socket := range sockets {
+		if socket.LocalAddr == nil || socket.RemoteAddr == nil {
+			continue
+		}
+
+		c := Connection{
+			Direction:   DirectionOutbound,
+			RemoteIP:    socket.RemoteAddr.IP,
+			ServicePort: socket.RemoteAddr.Port,
+		}
+
+		if isListening(listening, socket.LocalAddr) {
+			c.Direction = DirectionInbound
+			c.ServicePort = socket.LocalAddr.Port
+		}
+
+		// Sockets whose owner isn't known (e.g. due to permissions) aren't
+		// attributed to any process. PID 0 isn't a real process, on Windows
+		// it is used for connections in TIME_WAIT
+		if socket.Process != nil && socket.Process.Pid > 0 {
+			c.PID = socket.Process.Pid
+			c.ProcessName = socket.Process.Name
+		}
+
+		existing, ok := connections[c.ID()]
+
+		if !ok {
+			c.LocalIPs = make(map[string]bool)
+			c.States = make(map[string]int)
+			existing = &c
+			connections[c.ID()] = existing
+		}
+
+		existing.LocalIPs[socket.LocalAddr.IP.String()] = true
+		existing.States[socket.State.String()]++
+	}
+
+	ids := make([]string, 0, len(connections))
+
+	for id := range connections {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	sorted := make([]*Connection, 0, len(ids))
+
+	for _, id := range ids {
+		sorted = append(sorted, connections[id])
+	}
+
+	return sorted
+}
+
+// connectionToItem Converts a connection to an item
+func connectionToItem(c *Connection) (*sdp.Item, error) {
+	localIPs := make([]string, 0, len(c.LocalIPs))
+
+	for ip := range c.LocalIPs {
+		localIPs = append(localIPs, ip)
+	}
+
+	sort.Strings(localIPs)
+
+	attrs := map[string]interface{}{
+		"id":          c.ID(),
+		"direction":   c.Direction,
+		"protocol":    ProtocolTCP,
+		"remoteIP":    c.RemoteIP.String(),
+		"servicePort": uint32(c.ServicePort),
+		"localIPs":    localIPs,
+		"count":       c.Count(),
+		"states":      c.States,
+	}
+
+	if c.PID != 0 {
+		attrs["pid"] = c.PID
+		attrs["processName"] = c.ProcessName
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:               "connection",
+		UniqueAttribute:    "id",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: make([]*sdp.ItemRequest, 0),
+	}
+
+	if c.PID != 0 {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "process",
+			Method:  sdp.RequestMethod_GET,
+			Query:   strconv.Itoa(c.PID),
+			Context: util.LocalContext,
+		})
+	}
+
+	// The remote IP is always linked, but the remote socket is only relevant
+	// for outbound connections since inbound ones come from ephemeral ports
+	item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+		Type:    "ip",
+		Method:  sdp.RequestMethod_GET,
+		Query:   c.RemoteIP.String(),
+		Context: "global",
+	})
+
+	switch c.Direction {
+	case DirectionInbound:
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "port",
+			Method:  sdp.RequestMethod_GET,
+			Query:   PortID(ProtocolTCP, c.ServicePort),
+			Context: util.LocalContext,
+		})
+	case DirectionOutbound:
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "networksocket",
+			Method:  sdp.RequestMethod_GET,
+			Query:   net.JoinHostPort(c.RemoteIP.String(), fmt.Sprint(c.ServicePort)),
+			Context: "global",
+		})
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,264 @@
+//go:build linux || windows
+// +build linux windows
+
+package netstat
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"testing"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestAggregateConnections(t *testing.T) {
+	local := net.ParseIP("10.0.0.2")
+	db := net.ParseIP("10.0.0.5")
+	web := net.ParseIP("10.0.0.6")
+	client := net.ParseIP("192.168.1.20")
+	nginx := &ns.Process{Pid: 812, Name: "nginx"}
+
+	listening := []ns.SockTabEntry{
+		{LocalAddr: &ns.SockAddr{IP: net.IPv4zero, Port: 443}, RemoteAddr: &ns.SockAddr{IP: net.IPv4zero}, State: ns.Listen, Process: nginx},
+		{LocalAddr: &ns.SockAddr{IP: net.ParseIP("127.0.0.1"), Port: 8080}, RemoteAddr: &ns.SockAddr{IP: net.IPv4zero}, State: ns.Listen, Process: nginx},
+	}
+
+	sockets := []ns.SockTabEntry{
+		// Two outbound connections to the database from different ephemeral
+		// ports which should be merged
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 41000}, RemoteAddr: &ns.SockAddr{IP: db, Port: 5432}, State: ns.Established, Process: nginx},
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 41001}, RemoteAddr: &ns.SockAddr{IP: db, Port: 5432}, State: ns.TimeWait, Process: nginx},
+		// Two inbound connections from the same client
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 443}, RemoteAddr: &ns.SockAddr{IP: client, Port: 50000}, State: ns.Established, Process: nginx},
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 443}, RemoteAddr: &ns.SockAddr{IP: client, Port: 50001}, State: ns.Established, Process: nginx},
+		// An outbound connection whose ephemeral port is the same as a port
+		// that is only listening on localhost
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 8080}, RemoteAddr: &ns.SockAddr{IP: web, Port: 80}, State: ns.Established, Process: nginx},
+		// An outbound connection whose owner isn't known
+		{LocalAddr: &ns.SockAddr{IP: local, Port: 41002}, RemoteAddr: &ns.SockAddr{IP: db, Port: 5432}, State: ns.Established},
+	}
+
+	connections := make(map[string]*Connection)
+
+	for _, c := range aggregateConnections(listening, sockets) {
+		connections[c.ID()] = c
+	}
+
+	if len(connections) != 4 {
+		t.Fatalf("expected 4 connections, got %v", len(connections))
+	}
+
+	inbound, ok := connections["inbound/812/192.168.1.20/443"]
+
+	if !ok {
+		t.Fatalf("inbound connection not found in %v", connections)
+	}
+
+	if inbound.Count() != 2 || inbound.States["ESTABLISHED"] != 2 {
+		t.Errorf("expected 2 established inbound connections, got %v", inbound.States)
+	}
+
+	outbound, ok := connections["outbound/812/10.0.0.5/5432"]
+
+	if !ok {
+		t.Fatalf("outbound connection not found in %v", connections)
+	}
+
+	if outbound.Count() != 2 || outbound.States["ESTABLISHED"] != 1 || outbound.States["TIME_WAIT"] != 1 {
+		t.Errorf("expected 1 established and 1 time wait outbound connection, got %v", outbound.States)
+	}
+
+	if _, ok := connections["outbound/812/10.0.0.6/80"]; !ok {
+		t.Errorf("expected connection from an ephemeral port that matches a localhost listener to be outbound, got %v", connections)
+	}
+
+	unowned, ok := connections["outbound/10.0.0.5/5432"]
+
+	if !ok {
+		t.Fatalf("unowned connection not found in %v", connections)
+	}
+
+	unownedItem, err := connectionToItem(unowned)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := unownedItem.Attributes.Get("pid"); err == nil {
+		t.Error("expected unowned connection not to have a pid")
+	}
+
+	for _, lir := range unownedItem.LinkedItemRequests {
+		if lir.Type == "process" {
+			t.Errorf("expected unowned connection not to link to a process, got %v", lir.Query)
+		}
+	}
+
+	item, err := connectionToItem(inbound)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	util.RunItemValidationTest(t, item)
+
+	var foundPort bool
+
+	for _, lir := range item.LinkedItemRequests {
+		if lir.Type == "port" && lir.Query == "443" {
+			foundPort = true
+		}
+	}
+
+	if !foundPort {
+		t.Error("expected inbound connection to link to port 443")
+	}
+}
+
+func TestConnectionFind(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Error listening: %v", err.Error())
+	}
+
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+
+		if err == nil {
+			defer conn.Close()
+			// Hold the connection open until the listener is closed
+			conn.Read(make([]byte, 1))
+		}
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	pid := os.Getpid()
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get outbound connection",
+			ItemContext: util.LocalContext,
+			Query:       fmt.Sprintf("outbound/%v/127.0.0.1/%v", pid, port),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"direction": "outbound",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get inbound connection",
+			ItemContext: util.LocalContext,
+			Query:       fmt.Sprintf("inbound/%v/127.0.0.1/%v", pid, port),
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"direction": "inbound",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing connection",
+			ItemContext: util.LocalContext,
+			Query:       "outbound/1/192.0.2.1/1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "search bad pid",
+			ItemContext: util.LocalContext,
+			Query:       "nginx",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &ConnectionSource{})
+
+	src := ConnectionSource{}
+
+	items, err := src.Search(context.Background(), util.LocalContext, fmt.Sprint(pid))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) < 2 {
+		t.Errorf("expected at least 2 connections for pid %v, got %v", pid, len(items))
+	}
+}
+
+func TestAcceptEstablished(t *testing.T) {
+	remote := &ns.SockAddr{IP: net.ParseIP("10.0.0.5"), Port: 5432}
+
+	tests := []struct {
+		Socket      ns.SockTabEntry
+		Established bool
+		Connected   bool
+	}{
+		{ns.SockTabEntry{RemoteAddr: remote, State: ns.Established}, true, true},
+		{ns.SockTabEntry{RemoteAddr: remote, State: ns.TimeWait}, false, true},
+		{ns.SockTabEntry{RemoteAddr: remote, State: ns.CloseWait}, false, true},
+		{ns.SockTabEntry{RemoteAddr: &ns.SockAddr{IP: net.IPv4zero}, State: ns.Listen}, false, false},
+	}
+
+	for _, test := range tests {
+		if acceptEstablished(&test.Socket) != test.Established {
+			t.Errorf("%v: expected established to be %v", test.Socket.State, test.Established)
+		}
+
+		if acceptConnected(&test.Socket) != test.Connected {
+			t.Errorf("%v: expected connected to be %v", test.Socket.State, test.Connected)
+		}
+	}
+}
+
+func TestCurrentConnectionsListeningError(t *testing.T) {
+	listen := ns.SockTabEntry{LocalAddr: &ns.SockAddr{IP: net.IPv4zero, Port: 22}, State: ns.Listen}
+
+	// The listening sockets can't be read but the connected ones can, so an
+	// inbound connection to port 22 would look outbound
+	withProtocolSockets(t, func(accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+		if accept(&listen) {
+			return nil, &fs.PathError{Op: "open", Path: "/proc/net/tcp", Err: fs.ErrPermission}
+		}
+
+		return []ns.SockTabEntry{
+			{
+				LocalAddr:  &ns.SockAddr{IP: net.ParseIP("10.0.0.2"), Port: 22},
+				RemoteAddr: &ns.SockAddr{IP: net.ParseIP("10.0.0.5"), Port: 51234},
+				State:      ns.Established,
+			},
+		}, nil
+	})
+
+	if _, err := currentConnections(false); err == nil {
+		t.Error("expected an error when the listening sockets can't be read")
+	}
+}
@@ -0,0 +1,173 @@
+//go:build linux
+// +build linux
+
+package netstat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+)
+
+// namespaceTables The tables in /proc/{pid}/net that contain the IPv4 and IPv6
+// sockets for each protocol
+var namespaceTables = map[string][]string{
+	ProtocolTCP: {"tcp", "tcp6"},
+	ProtocolUDP: {"udp", "udp6"},
+}
+
+// namespaceSockets Returns the sockets that satisfy the accept function of
+// each protocol in all network namespaces other than the agent's own, keyed by
+// protocol. The socket tables of each namespace are read through
+// /proc/{pid}/net of one of the processes in that namespace, since /proc/net
+// only shows the sockets in the namespace of the caller. The namespaces and
+// socket owners are only listed once for all protocols since this means
+// reading every process. If the tables of a namespace can't be read through
+// any of its processes the other namespaces are still returned, along with an
+// error
+func (s *PortSource) namespaceSockets(ctx context.Context, accept map[string]ns.AcceptFn) (map[string][]namespacedSockets, error) {
+	proc := procfs.ProcFS{Location: s.ProcLocation}
+
+	self, err := proc.SelfNamespace("net")
+
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces, err := proc.NamespacePIDs(ctx, "net")
+
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := proc.SocketOwners(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the namespaces and protocols so that results are stable
+	inodes := make([]uint64, 0, len(namespaces))
+
+	for inode := range namespaces {
+		if inode != self {
+			inodes = append(inodes, inode)
+		}
+	}
+
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+
+	protocols := make([]string, 0, len(accept))
+
+	for protocol := range accept {
+		protocols = append(protocols, protocol)
+	}
+
+	sort.Strings(protocols)
+
+	results := make(map[string][]namespacedSockets)
+	var errs []string
+
+	for _, inode := range inodes {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		pids := namespaces[inode]
+		sort.Ints(pids)
+
+		netns := networkNamespace{
+			ID: procfs.NamespaceID("net", inode),
+		}
+
+		for _, pid := range pids {
+			if container, ok := proc.Container(pid); ok {
+				netns.Container = container.ID
+				netns.ContainerRuntime = container.Runtime
+				break
+			}
+		}
+
+		for _, protocol := range protocols {
+			var sockets []ns.SockTabEntry
+
+			for _, table := range namespaceTables[protocol] {
+				inetSockets, err := readNamespaceTable(proc, pids, table)
+
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("could not read %v sockets in %v: %v", table, netns.ID, err))
+					continue
+				}
+
+				for _, inetSocket := range inetSockets {
+					socket := ns.SockTabEntry{
+						LocalAddr:  &ns.SockAddr{IP: inetSocket.LocalIP, Port: inetSocket.LocalPort},
+						RemoteAddr: &ns.SockAddr{IP: inetSocket.RemoteIP, Port: inetSocket.RemotePort},
+						State:      ns.SkState(inetSocket.State),
+						UID:        inetSocket.UID,
+					}
+
+					if !accept[protocol](&socket) {
+						continue
+					}
+
+					socketPIDs := owners[inetSocket.Inode]
+
+					if len(socketPIDs) == 0 {
+						sockets = append(sockets, socket)
+						continue
+					}
+
+					// A socket can be shared by more than one process e.g.
+					// forked workers, so add one entry per owner like ss does
+					for _, pid := range socketPIDs {
+						owned := socket
+						owned.Process = &ns.Process{Pid: pid}
+						owned.Process.Name, _ = proc.Comm(pid)
+
+						sockets = append(sockets, owned)
+					}
+				}
+			}
+
+			results[protocol] = append(results[protocol], namespacedSockets{
+				Namespace: netns,
+				Sockets:   sockets,
+			})
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, errors.New(strings.Join(errs, " "))
+	}
+
+	return results, nil
+}
+
+// readNamespaceTable Reads a socket table through the first of the given
+// processes that it can be read through. Processes might have exited since
+// they were listed, or the agent might not have permission to read them, so
+// this only fails if none of them can be read
+func readNamespaceTable(proc procfs.ProcFS, pids []int, table string) ([]procfs.InetSocket, error) {
+	var err error
+	var sockets []procfs.InetSocket
+
+	for _, pid := range pids {
+		sockets, err = proc.InetSockets(pid, table)
+
+		if err == nil {
+			return sockets, nil
+		}
+	}
+
+	if err == nil {
+		err = errors.New("no processes in namespace")
+	}
+
+	return nil, err
+}
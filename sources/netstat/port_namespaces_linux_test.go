@@ -0,0 +1,215 @@
+//go:build linux
+// +build linux
+
+package netstat
+
+import (
+	"context"
+	"testing"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+const testContainerID = "3f4e8c1b2a9d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f"
+
+func TestNamespaceSockets(t *testing.T) {
+	s := PortSource{
+		ProcLocation: "test/proc",
+	}
+
+	all, err := s.namespaceSockets(context.Background(), map[string]ns.AcceptFn{
+		ProtocolTCP: acceptListening(ProtocolTCP),
+		ProtocolUDP: acceptListening(ProtocolUDP),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("tcp", func(t *testing.T) {
+		// The tables are read through 42 since 41 doesn't have them, as if
+		// it had exited
+		namespaces := all[ProtocolTCP]
+
+		// The host namespace (that of self) should be skipped
+		if len(namespaces) != 1 {
+			t.Fatalf("expected 1 namespace, got %v", len(namespaces))
+		}
+
+		netns := namespaces[0].Namespace
+
+		if netns.ID != "net:4026532281" {
+			t.Errorf("expected namespace net:4026532281, got %v", netns.ID)
+		}
+
+		if netns.Container != testContainerID || netns.ContainerRuntime != "docker" {
+			t.Errorf("unexpected container %v (%v)", netns.Container, netns.ContainerRuntime)
+		}
+
+		// The established connection should have been filtered out. Port 80
+		// is shared by 42 and 43 so has an entry for each
+		sockets := namespaces[0].Sockets
+
+		if len(sockets) != 3 {
+			t.Fatalf("expected 3 listening sockets, got %v", len(sockets))
+		}
+
+		if sockets[0].LocalAddr.Port != 80 || sockets[1].LocalAddr.Port != 80 || sockets[2].LocalAddr.Port != 8080 {
+			t.Errorf("unexpected ports %v, %v and %v", sockets[0].LocalAddr.Port, sockets[1].LocalAddr.Port, sockets[2].LocalAddr.Port)
+		}
+
+		if sockets[0].Process == nil || sockets[0].Process.Pid != 42 || sockets[0].Process.Name != "nginx" {
+			t.Errorf("unexpected process %+v", sockets[0].Process)
+		}
+
+		if sockets[1].Process == nil || sockets[1].Process.Pid != 43 || sockets[1].Process.Name != "bash" {
+			t.Errorf("unexpected process %+v", sockets[1].Process)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		namespaces := all[ProtocolUDP]
+
+		if len(namespaces) != 1 || len(namespaces[0].Sockets) != 1 {
+			t.Fatalf("expected 1 namespace with 1 socket, got %+v", namespaces)
+		}
+
+		socket := namespaces[0].Sockets[0]
+
+		if socket.LocalAddr.Port != 5353 || socket.UID != 101 {
+			t.Errorf("unexpected socket %v uid %v", socket.LocalAddr, socket.UID)
+		}
+
+		if socket.Process == nil || socket.Process.Pid != 43 || socket.Process.Name != "bash" {
+			t.Errorf("unexpected process %+v", socket.Process)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if _, err := s.namespaceSockets(ctx, map[string]ns.AcceptFn{ProtocolTCP: acceptListening(ProtocolTCP)}); err == nil {
+			t.Error("expected an error when the context is cancelled")
+		}
+	})
+}
+
+func TestPortGetNamespaced(t *testing.T) {
+	tests := []util.SourceTest{
+		{
+			Name:        "tcp port in container",
+			ItemContext: util.LocalContext,
+			Query:       "80@net:4026532281",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"id":               "80@net:4026532281",
+						"state":            "LISTEN",
+						"namespace":        "net:4026532281",
+						"container":        testContainerID,
+						"containerRuntime": "docker",
+						"pid":              float64(42),
+						"pids":             []interface{}{float64(42), float64(43)},
+					},
+				},
+			},
+		},
+		{
+			Name:        "udp port in container",
+			ItemContext: util.LocalContext,
+			Query:       "udp/5353@net:4026532281",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"id":    "udp/5353@net:4026532281",
+						"state": "UNCONN",
+					},
+				},
+			},
+		},
+		{
+			Name:        "port in unknown namespace",
+			ItemContext: util.LocalContext,
+			Query:       "80@net:1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &PortSource{ProcLocation: "test/proc"})
+}
+
+func TestPortNamespacedLinks(t *testing.T) {
+	s := PortSource{
+		ProcLocation: "test/proc",
+	}
+
+	item, err := s.Get(context.Background(), util.LocalContext, "80@net:4026532281")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var foundNamespace bool
+
+	for _, request := range item.LinkedItemRequests {
+		switch request.Type {
+		case "namespace":
+			foundNamespace = request.Query == "net:4026532281"
+		case "networksocket":
+			// 0.0.0.0 can't be expanded to the IPs of the container, since
+			// those aren't the IPs of the host
+			t.Errorf("unexpected networksocket link %v", request.Query)
+		}
+	}
+
+	if !foundNamespace {
+		t.Error("expected a link to namespace net:4026532281")
+	}
+
+	found, err := s.Find(context.Background(), util.LocalContext)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make(map[string]bool)
+
+	for _, i := range found {
+		ids[i.UniqueAttributeValue()] = true
+	}
+
+	for _, id := range []string{"80@net:4026532281", "8080@net:4026532281", "udp/5353@net:4026532281"} {
+		if !ids[id] {
+			t.Errorf("expected Find() to return %v", id)
+		}
+	}
+}
+
+func TestReadNamespaceTable(t *testing.T) {
+	proc := procfs.ProcFS{Location: "test/proc"}
+
+	sockets, err := readNamespaceTable(proc, []int{41, 42}, "tcp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) == 0 {
+		t.Error("expected the sockets to be read through 42")
+	}
+
+	if _, err := readNamespaceTable(proc, []int{41, 43}, "tcp"); err == nil {
+		t.Error("expected an error when no process can be read")
+	}
+}
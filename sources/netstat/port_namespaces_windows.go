@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package netstat
+
+import (
+	"context"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+)
+
+// namespaceSockets Windows doesn't have network namespaces, so there are never
+// any sockets outside of the host's
+func (s *PortSource) namespaceSockets(ctx context.Context, accept map[string]ns.AcceptFn) (map[string][]namespacedSockets, error) {
+	return nil, nil
+}
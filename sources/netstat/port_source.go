@@ -7,9 +7,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"net"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync/atomic"
 
 	ns "github.com/cakturk/go-netstat/netstat"
 	"github.com/overmindtech/overmind-agent/sources/util"
@@ -17,7 +20,19 @@ import (
 )
 
 // PortSource struct on which all methods are registered
-type PortSource struct{}
+type PortSource struct {
+	// The location of procfs, this is used to find ports that are listening
+	// inside other network namespaces e.g. containers. Defaults to /proc
+	ProcLocation string
+
+	// The function used to run `ss`, which is used to list ports if /proc/net
+	// can't be read. Defaults to util.RunContext
+	RunFunction util.RunFunction
+
+	// Whether /proc/net has been found to be unreadable, in which case `ss`
+	// is used from then on rather than trying /proc/net every time
+	procNetUnreadable atomic.Bool
+}
 
 // Type is the type of items that this returns
 func (s *PortSource) Type() string {
@@ -46,7 +61,89 @@ func (s *PortSource) Contexts() []string {
 	}
 }
 
-// Get calls back to the GetFunction to get a single item
+// Protocols that ports are reported for
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// socketsFunc A function that lists the sockets for a given protocol and
+// address family
+type socketsFunc func(accept ns.AcceptFn) ([]ns.SockTabEntry, error)
+
+// protocolSockets The functions that should be used to list the IPv4 and IPv6
+// sockets for each protocol
+var protocolSockets = map[string][]socketsFunc{
+	ProtocolTCP: {ns.TCPSocks, ns.TCP6Socks},
+	ProtocolUDP: {ns.UDPSocks, ns.UDP6Socks},
+}
+
+// PortID Returns the unique ID of a port. TCP ports are identified by just
+// their number (e.g. 22) so that existing queries continue to work, other
+// protocols are prefixed e.g. udp/53
+func PortID(protocol string, port uint16) string {
+	if protocol == ProtocolTCP {
+		return strconv.Itoa(int(port))
+	}
+
+	return fmt.Sprintf("%v/%v", protocol, port)
+}
+
+// networkNamespace A network namespace other than the one that the agent is
+// running in, and the container that it belongs to if any
+type networkNamespace struct {
+	// The ID of the namespace item e.g. net:4026532281
+	ID               string
+	Container        string
+	ContainerRuntime string
+}
+
+// namespacedSockets The sockets within a given network namespace
+type namespacedSockets struct {
+	Namespace networkNamespace
+	Sockets   []ns.SockTabEntry
+}
+
+// namespacedPortID Returns the ID of a port within a network namespace. Ports
+// in the host's namespace use the same ID as PortID, ports in other namespaces
+// have the namespace ID appended e.g. 8080@net:4026532281 since the same port
+// can be bound in many namespaces at once
+func namespacedPortID(protocol string, port uint16, netns *networkNamespace) string {
+	id := PortID(protocol, port)
+
+	if netns != nil {
+		id = id + "@" + netns.ID
+	}
+
+	return id
+}
+
+// parsePortID Parses a query in the format returned by PortID
+func parsePortID(query string) (string, uint16, error) {
+	protocol := ProtocolTCP
+	number := query
+
+	if p, n, found := strings.Cut(query, "/"); found {
+		if p != ProtocolUDP {
+			return "", 0, fmt.Errorf("unsupported protocol %v in %v, TCP ports should be queried by number alone and UDP ports as udp/{number}", p, query)
+		}
+
+		protocol = p
+		number = n
+	}
+
+	port, err := strconv.ParseUint(number, 10, 16)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("could not convert %v to uint16", number)
+	}
+
+	return protocol, uint16(port), nil
+}
+
+// Get Gets a listening port. TCP ports are queried by number e.g. 22 and UDP
+// ports are prefixed e.g. udp/53. Ports in other network namespaces have the
+// namespace appended e.g. 8080@net:4026532281
 func (s *PortSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
 	if itemContext != util.LocalContext {
 		return nil, &sdp.ItemRequestError{
@@ -56,34 +153,51 @@ func (s *PortSource) Get(ctx context.Context, itemContext string, query string)
 		}
 	}
 
-	var err error
-	var sockets4 []ns.SockTabEntry
-	var sockets6 []ns.SockTabEntry
-	var sockets []ns.SockTabEntry
-	var port int
-
-	port, err = strconv.Atoi(query)
+	portQuery, namespaceID, namespaced := strings.Cut(query, "@")
+	protocol, port, err := parsePortID(portQuery)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not convert %v to uint16", query)
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if namespaced {
+		namespaces, _ := s.namespaceSockets(ctx, map[string]ns.AcceptFn{
+			protocol: acceptNumber(protocol, port),
+		})
+
+		for _, n := range namespaces[protocol] {
+			if n.Namespace.ID == namespaceID && len(n.Sockets) > 0 {
+				return socketsToItem(protocol, &n.Namespace, n.Sockets...)
+			}
+		}
+
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("Port %v not found", query),
+			Context:     itemContext,
+		}
 	}
 
-	sockets4, _ = ns.TCPSocks(acceptNumber(uint16(port)))
-	sockets6, _ = ns.TCP6Socks(acceptNumber(uint16(port)))
-	sockets = append(sockets4, sockets6...)
+	sockets, _ := s.hostSockets(ctx, protocol, acceptNumber(protocol, port))
 
 	switch numSockets := len(sockets); numSockets {
 	case 0:
 		return nil, &sdp.ItemRequestError{
 			ErrorType:   sdp.ItemRequestError_NOTFOUND,
 			ErrorString: fmt.Sprintf("Port %v not found", query),
+			Context:     itemContext,
 		}
 	default:
-		return socketsToItem(sockets...)
+		return socketsToItem(protocol, nil, sockets...)
 	}
 }
 
-// Find calls back to the FindFunction to find all items
+// Find Returns all listening TCP ports and bound UDP ports, including those in
+// other network namespaces
 func (s *PortSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
 	if itemContext != util.LocalContext {
 		return nil, &sdp.ItemRequestError{
@@ -93,61 +207,276 @@ func (s *PortSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item,
 		}
 	}
 
+	return s.findPorts(ctx, func(ns.SockTabEntry) bool {
+		return true
+	})
+}
+
+// Search Returns the listening ports that match a query. The query can be in
+// one of the following formats:
+//
+//   - pid:{pid} Ports that are owned by a given process e.g. pid:1234
+//   - process:{name} Ports owned by processes with a given name e.g.
+//     process:nginx
+//   - An IP address: Ports that are listening on that IP, including those
+//     listening on all IPs
+//   - A CIDR: Ports that are listening on an IP within the range, including
+//     those listening on all IPs
+func (s *PortSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	filter, err := searchFilter(query)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return s.findPorts(ctx, filter)
+}
+
+// searchFilter Converts a search query into a function that returns true for
+// sockets that match the query
+func searchFilter(query string) (func(ns.SockTabEntry) bool, error) {
+	if strings.HasPrefix(query, "pid:") {
+		pid, err := strconv.Atoi(strings.TrimPrefix(query, "pid:"))
+
+		if err != nil {
+			return nil, fmt.Errorf("PID could not be converted to integer, encountered error: %v", err)
+		}
+
+		return func(socket ns.SockTabEntry) bool {
+			return socket.Process != nil && socket.Process.Pid == pid
+		}, nil
+	}
+
+	if strings.HasPrefix(query, "process:") {
+		name := strings.TrimPrefix(query, "process:")
+
+		return func(socket ns.SockTabEntry) bool {
+			return socket.Process != nil && socket.Process.Name == name
+		}, nil
+	}
+
+	if ip := net.ParseIP(query); ip != nil {
+		return func(socket ns.SockTabEntry) bool {
+			return listensOn(socket, ip.Equal, ip.To4() != nil)
+		}, nil
+	}
+
+	if _, ipNet, err := net.ParseCIDR(query); err == nil {
+		return func(socket ns.SockTabEntry) bool {
+			return listensOn(socket, ipNet.Contains, ipNet.IP.To4() != nil)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("could not parse search query %v, expected pid:{pid}, process:{name}, an IP address or a CIDR", query)
+}
+
+// listensOn Returns whether a socket is listening on an IP that satisfies the
+// matcher. Sockets that are listening on 0.0.0.0 match any IPv4 query. Sockets
+// listening on :: match everything since they also accept IPv4 connections on
+// dual-stack systems
+func listensOn(socket ns.SockTabEntry, matches func(net.IP) bool, ipv4 bool) bool {
+	if socket.LocalAddr == nil {
+		return false
+	}
+
+	ip := socket.LocalAddr.IP
+
+	if ip.IsUnspecified() {
+		return ip.To4() == nil || ipv4
+	}
+
+	return matches(ip)
+}
+
+// findPorts Returns items for all listening ports that have at least one
+// socket matching the filter. The items contain all of the sockets for that
+// port, not only those that matched. Ports in other network namespaces are
+// included, though failing to read them isn't treated as an error since the
+// agent often won't have permission to do so
+func (s *PortSource) findPorts(ctx context.Context, filter func(ns.SockTabEntry) bool) ([]*sdp.Item, error) {
 	var items []*sdp.Item
-	var errString string
+	var errs []string
 	var err error
 
-	ipv4Sockets, ipv4Err := ns.TCPSocks(acceptListening)
+	protocols := []string{ProtocolTCP, ProtocolUDP}
+	accept := make(map[string]ns.AcceptFn)
 
-	if ipv4Err != nil {
-		errString = fmt.Sprintf("error getting ipv4 sockets: %v ", ipv4Err)
+	for _, protocol := range protocols {
+		accept[protocol] = acceptListening(protocol)
 	}
 
-	ipv6Sockets, ipv6Err := ns.TCP6Socks(acceptListening)
+	namespaces, _ := s.namespaceSockets(ctx, accept)
+
+	for _, protocol := range protocols {
+		sockets, sErr := s.hostSockets(ctx, protocol, acceptListening(protocol))
+
+		if sErr != nil {
+			errs = append(errs, sErr.Error())
+		}
+
+		items = append(items, portItems(protocol, nil, sockets, filter)...)
 
-	if ipv6Err != nil {
-		errString = errString + fmt.Sprintf("error getting ipv6 sockets: %v", ipv6Err)
+		for i := range namespaces[protocol] {
+			n := &namespaces[protocol][i]
+			items = append(items, portItems(protocol, &n.Namespace, n.Sockets, filter)...)
+		}
 	}
 
-	if errString != "" {
-		err = errors.New(errString)
+	if len(errs) > 0 {
+		err = errors.New(strings.Join(errs, " "))
 	}
 
-	allSockets := append(ipv4Sockets, ipv6Sockets...)
+	return items, err
+}
+
+// portItems Groups sockets by port and returns an item for each port that has
+// at least one socket matching the filter
+func portItems(protocol string, netns *networkNamespace, sockets []ns.SockTabEntry, filter func(ns.SockTabEntry) bool) []*sdp.Item {
+	items := make([]*sdp.Item, 0)
 	socketMap := make(map[uint16][]ns.SockTabEntry)
+	matched := make(map[uint16]bool)
 
-	for _, socket := range allSockets {
+	for _, socket := range sockets {
 		if socket.LocalAddr != nil {
 			socketMap[socket.LocalAddr.Port] = append(socketMap[socket.LocalAddr.Port], socket)
+
+			if filter(socket) {
+				matched[socket.LocalAddr.Port] = true
+			}
 		}
 	}
 
-	for _, sockets := range socketMap {
-		item, err := socketsToItem(sockets...)
+	for port := range matched {
+		item, err := socketsToItem(protocol, netns, socketMap[port]...)
 
 		if err == nil {
 			items = append(items, item)
 		}
 	}
 
-	return items, err
+	return items
 }
 
-func acceptListening(s *ns.SockTabEntry) bool {
-	return s.State.String() == "LISTEN"
+// socketErrors The errors from reading the socket tables of each address
+// family. These are kept rather than being flattened into a string so that
+// callers can check why the tables couldn't be read
+type socketErrors []error
+
+func (e socketErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, " ")
+}
+
+// Is Returns whether any of the errors is target
+func (e socketErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listeningSockets Returns the IPv4 and IPv6 sockets for a given protocol that
+// satisfy the accept function. A family whose table doesn't exist is skipped
+// if another family could be read, since that means the family is disabled
+// e.g. /proc/net/tcp6 doesn't exist when IPv6 is disabled
+func listeningSockets(protocol string, accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+	var sockets []ns.SockTabEntry
+	var errs socketErrors
+	var read bool
+
+	for i, f := range protocolSockets[protocol] {
+		familySockets, err := f(accept)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error getting %v sockets (family %v): %w", protocol, i, err))
+			continue
+		}
+
+		read = true
+		sockets = append(sockets, familySockets...)
+	}
+
+	if read {
+		// Only keep the errors for tables that exist but couldn't be read
+		var unreadable socketErrors
+
+		for _, err := range errs {
+			if !errors.Is(err, fs.ErrNotExist) {
+				unreadable = append(unreadable, err)
+			}
+		}
+
+		errs = unreadable
+	}
+
+	if len(errs) > 0 {
+		return sockets, errs
+	}
+
+	return sockets, nil
+}
+
+// acceptListening Returns a function that accepts sockets that are listening
+// for a given protocol. For TCP this is sockets in the LISTEN state, for UDP
+// (which is connectionless) it is sockets that are bound but not connected to
+// a remote address
+func acceptListening(protocol string) ns.AcceptFn {
+	switch protocol {
+	case ProtocolUDP:
+		return func(s *ns.SockTabEntry) bool {
+			return s.State == ns.Close && (s.RemoteAddr == nil || s.RemoteAddr.Port == 0)
+		}
+	default:
+		return func(s *ns.SockTabEntry) bool {
+			return s.State == ns.Listen
+		}
+	}
 }
 
 // acceptNumber Accepts a given port nuymber that is also listening
-func acceptNumber(p uint16) func(*ns.SockTabEntry) bool {
+func acceptNumber(protocol string, p uint16) ns.AcceptFn {
+	listening := acceptListening(protocol)
+
 	return func(n *ns.SockTabEntry) bool {
-		return n.LocalAddr.Port == p && acceptListening(n)
+		return n.LocalAddr.Port == p && listening(n)
 	}
 }
 
+// socketState Returns the state of a socket as a string. Bound UDP sockets are
+// reported as UNCONN in the same way as `ss`
+func socketState(protocol string, s ns.SockTabEntry) string {
+	if protocol == ProtocolUDP && s.State == ns.Close {
+		return "UNCONN"
+	}
+
+	return s.State.String()
+}
+
 // socketsToItem Will merge details of sockets into a single item, this assumes
 // that all sockets already have the same state and port and will simply merge
-// the local and remote addresses
-func socketsToItem(s ...ns.SockTabEntry) (*sdp.Item, error) {
+// the local and remote addresses. netns should be nil for sockets in the
+// agent's own network namespace
+func socketsToItem(protocol string, netns *networkNamespace, s ...ns.SockTabEntry) (*sdp.Item, error) {
 	var err error
 	var item sdp.Item
 
@@ -160,34 +489,94 @@ func socketsToItem(s ...ns.SockTabEntry) (*sdp.Item, error) {
 	// Prepare the item
 	item = sdp.Item{}
 	item.Type = "port"
-	item.UniqueAttribute = "port"
+	item.UniqueAttribute = "id"
 	item.Context = util.LocalContext
 	item.LinkedItemRequests = make([]*sdp.ItemRequest, 0)
 
 	// Get most of the details from the first socket since they should be the same
-	attributes["state"] = s[0].State.String()
+	attributes["id"] = namespacedPortID(protocol, s[0].LocalAddr.Port, netns)
+	attributes["protocol"] = protocol
+	attributes["state"] = socketState(protocol, s[0])
 	attributes["port"] = uint32(s[0].LocalAddr.Port)
 	localIPs := make([]string, 0)
 
-	if s[0].Process != nil {
-		attributes["pid"] = s[0].Process.Pid
+	if netns != nil {
+		attributes["namespace"] = netns.ID
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Method:  sdp.RequestMethod_GET,
+			Query:   netns.ID,
+			Type:    "namespace",
+			Context: util.LocalContext,
+		})
+
+		if netns.Container != "" {
+			attributes["container"] = netns.Container
+			attributes["containerRuntime"] = netns.ContainerRuntime
+		}
+	}
+
+	// A port can be owned by more than one process e.g. the workers of a
+	// pre-forking server, in which case all of them are linked
+	pids := make([]int, 0)
+	seenPIDs := make(map[int]bool)
+
+	for _, socket := range s {
+		if socket.Process != nil && !seenPIDs[socket.Process.Pid] {
+			seenPIDs[socket.Process.Pid] = true
+			pids = append(pids, socket.Process.Pid)
+		}
+	}
+
+	if len(pids) > 0 {
+		attributes["pid"] = pids[0]
+	}
+
+	if len(pids) > 1 {
+		attributes["pids"] = pids
+	}
 
+	for _, pid := range pids {
 		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
 			Method:  sdp.RequestMethod_GET,
-			Query:   strconv.Itoa(s[0].Process.Pid),
+			Query:   strconv.Itoa(pid),
 			Type:    "process",
 			Context: util.LocalContext,
 		})
+
+		// Link to the service that owns the process. The service source
+		// resolves a PID to the systemd unit that it belongs to
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Method:  sdp.RequestMethod_SEARCH,
+			Query:   strconv.Itoa(pid),
+			Type:    "service",
+			Context: util.LocalContext,
+		})
 	}
 
+	seenIPs := make(map[string]bool)
+
 	// Merge the IPs and anything else that might be different
 	for _, socket := range s {
 		if socket.LocalAddr != nil {
+			// The same address appears once per process if the socket is
+			// shared
+			if seenIPs[socket.LocalAddr.IP.String()] {
+				continue
+			}
+
+			seenIPs[socket.LocalAddr.IP.String()] = true
 			localIPs = append(localIPs, socket.LocalAddr.IP.String())
 
 			// If the IP is unspecified (0.0.0.0 or ::) it means that the port will
-			// be listening on all IPs, therefore we need to do some expansion
+			// be listening on all IPs, therefore we need to do some expansion.
+			// This can only be done for the host's namespace since the
+			// interfaces of other namespaces aren't visible to the agent
 			if socket.LocalAddr.IP.IsUnspecified() {
+				if netns != nil {
+					continue
+				}
+
 				unicastAddresses, err := net.InterfaceAddrs()
 
 				if err == nil {
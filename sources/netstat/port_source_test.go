@@ -5,7 +5,9 @@ package netstat
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"os"
 	"testing"
 
 	"github.com/overmindtech/overmind-agent/sources/util"
@@ -131,3 +133,192 @@ func TestPortFind(t *testing.T) {
 		t.Errorf("couldn't find port %v", testPort)
 	}
 }
+
+func TestPortGetUDP(t *testing.T) {
+	// Bind to a random UDP port
+	l, err := net.ListenPacket("udp", "0.0.0.0:0")
+
+	if err != nil {
+		t.Fatalf("Error listening: %v", err.Error())
+	}
+
+	defer l.Close()
+
+	_, testPort, _ := net.SplitHostPort(l.LocalAddr().String())
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get bound udp port",
+			ItemContext: util.LocalContext,
+			Query:       "udp/" + testPort,
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"id":       "udp/" + testPort,
+						"protocol": "udp",
+						"state":    "UNCONN",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get bad protocol",
+			ItemContext: util.LocalContext,
+			Query:       "sctp/" + testPort,
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &PortSource{})
+
+	src := PortSource{}
+
+	items, err := src.Find(context.Background(), util.LocalContext)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	var found bool
+
+	for _, item := range items {
+		if item.UniqueAttributeValue() == "udp/"+testPort {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("couldn't find port udp/%v", testPort)
+	}
+}
+
+func TestParsePortID(t *testing.T) {
+	tests := []struct {
+		Query    string
+		Protocol string
+		Port     uint16
+		Error    bool
+	}{
+		{Query: "22", Protocol: ProtocolTCP, Port: 22},
+		{Query: "udp/53", Protocol: ProtocolUDP, Port: 53},
+		{Query: "tcp/22", Error: true},
+		{Query: "udp/http", Error: true},
+		{Query: "70000", Error: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Query, func(t *testing.T) {
+			protocol, port, err := parsePortID(test.Query)
+
+			if test.Error {
+				if err == nil {
+					t.Error("expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if protocol != test.Protocol || port != test.Port {
+				t.Errorf("expected %v/%v, got %v/%v", test.Protocol, test.Port, protocol, port)
+			}
+
+			if id := PortID(protocol, port); id != test.Query {
+				t.Errorf("expected PortID to round trip to %v, got %v", test.Query, id)
+			}
+		})
+	}
+}
+
+func TestPortSearch(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Error listening: %v", err.Error())
+	}
+
+	defer l.Close()
+
+	_, testPort, _ := net.SplitHostPort(l.Addr().String())
+	pid := os.Getpid()
+
+	src := PortSource{}
+
+	for _, query := range []string{fmt.Sprintf("pid:%v", pid), "127.0.0.1", "127.0.0.0/8"} {
+		t.Run(query, func(t *testing.T) {
+			items, err := src.Search(context.Background(), util.LocalContext, query)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found bool
+
+			for _, item := range items {
+				util.RunItemValidationTest(t, item)
+
+				if item.UniqueAttributeValue() == testPort {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Errorf("couldn't find port %v", testPort)
+			}
+		})
+	}
+
+	t.Run("other IP", func(t *testing.T) {
+		items, err := src.Search(context.Background(), util.LocalContext, "192.0.2.1")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, item := range items {
+			if item.UniqueAttributeValue() == testPort {
+				t.Errorf("found port %v which is only listening on 127.0.0.1", testPort)
+			}
+		}
+	})
+
+	tests := []util.SourceTest{
+		{
+			Name:        "bad query",
+			ItemContext: util.LocalContext,
+			Query:       "nginx",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+		{
+			Name:        "bad pid",
+			ItemContext: util.LocalContext,
+			Query:       "pid:nginx",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Query:       "127.0.0.1",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
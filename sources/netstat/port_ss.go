@@ -0,0 +1,90 @@
+//go:build linux || windows
+// +build linux windows
+
+package netstat
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+	"github.com/overmindtech/overmind-agent/sources/util/network"
+)
+
+// hostSockets Returns the sockets in the agent's own network namespace for a
+// given protocol that satisfy the accept function. These are read from
+// /proc/net, falling back to `ss` if /proc/net is unreadable e.g. on systems
+// where procfs is restricted. Once /proc/net has been found to be unreadable
+// `ss` is always used, since that won't change
+func (s *PortSource) hostSockets(ctx context.Context, protocol string, accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+	if !s.procNetUnreadable.Load() {
+		sockets, err := listeningSockets(protocol, accept)
+
+		if err == nil || !(errors.Is(err, fs.ErrPermission) || errors.Is(err, fs.ErrNotExist)) {
+			return sockets, err
+		}
+
+		s.procNetUnreadable.Store(true)
+	}
+
+	return s.ssSockets(ctx, protocol, accept)
+}
+
+// ssSockets Returns the sockets for a given protocol that satisfy the accept
+// function, as reported by `ss`. Sockets that are shared by more than one
+// process are returned once for each process
+func (s *PortSource) ssSockets(ctx context.Context, protocol string, accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+	ports, err := network.ListeningPorts(ctx, s.RunFunction)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sockets := make([]ns.SockTabEntry, 0)
+
+	for _, port := range ports {
+		if port.Protocol != protocol {
+			continue
+		}
+
+		socket := ns.SockTabEntry{
+			LocalAddr:  &ns.SockAddr{IP: port.Listen, Port: port.Number},
+			RemoteAddr: &ns.SockAddr{},
+			State:      ssState(port.State),
+		}
+
+		if !accept(&socket) {
+			continue
+		}
+
+		if len(port.Processes) == 0 {
+			sockets = append(sockets, socket)
+			continue
+		}
+
+		for _, process := range port.Processes {
+			s := socket
+			s.Process = &ns.Process{
+				Pid:  process.PID,
+				Name: process.Name,
+			}
+
+			sockets = append(sockets, s)
+		}
+	}
+
+	return sockets, nil
+}
+
+// ssState Converts the state of a socket as reported by ss into the state that
+// would have been read from /proc/net. ss is only ever run to list listening
+// sockets, so anything other than LISTEN is a bound UDP socket, which is
+// reported as UNCONN
+func ssState(state string) ns.SkState {
+	if state == "LISTEN" {
+		return ns.Listen
+	}
+
+	return ns.Close
+}
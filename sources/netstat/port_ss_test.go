@@ -0,0 +1,190 @@
+//go:build linux || windows
+// +build linux windows
+
+package netstat
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"testing"
+
+	ns "github.com/cakturk/go-netstat/netstat"
+)
+
+func testSS(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return os.ReadFile("test/ss")
+}
+
+func TestSSSockets(t *testing.T) {
+	s := PortSource{
+		RunFunction: testSS,
+	}
+
+	t.Run("tcp", func(t *testing.T) {
+		sockets, err := s.ssSockets(context.Background(), ProtocolTCP, acceptNumber(ProtocolTCP, 80))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// One for each nginx process
+		if len(sockets) != 3 {
+			t.Fatalf("expected 3 sockets, got %v", len(sockets))
+		}
+
+		item, err := socketsToItem(ProtocolTCP, nil, sockets...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if pid, _ := item.Attributes.Get("pid"); pid != float64(1199) {
+			t.Errorf("expected pid 1199, got %v", pid)
+		}
+
+		pids, _ := item.Attributes.Get("pids")
+
+		if p, ok := pids.([]interface{}); !ok || len(p) != 3 {
+			t.Errorf("expected 3 pids, got %v", pids)
+		}
+
+		localIPs, _ := item.Attributes.Get("localIPs")
+
+		if l, ok := localIPs.([]interface{}); !ok || len(l) != 1 {
+			t.Errorf("expected localIPs to be deduplicated, got %v", localIPs)
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		sockets, err := s.ssSockets(context.Background(), ProtocolUDP, acceptListening(ProtocolUDP))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sockets) != 3 {
+			t.Fatalf("expected 3 sockets, got %v", len(sockets))
+		}
+
+		if state := socketState(ProtocolUDP, sockets[0]); state != "UNCONN" {
+			t.Errorf("expected UNCONN, got %v", state)
+		}
+	})
+}
+
+// withProtocolSockets Replaces the functions that read the TCP socket tables
+// for the duration of a test
+func withProtocolSockets(t *testing.T, funcs ...socketsFunc) {
+	original := protocolSockets[ProtocolTCP]
+	protocolSockets[ProtocolTCP] = funcs
+
+	t.Cleanup(func() {
+		protocolSockets[ProtocolTCP] = original
+	})
+}
+
+func TestHostSockets(t *testing.T) {
+	ipv4 := func(accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+		return []ns.SockTabEntry{
+			{LocalAddr: &ns.SockAddr{IP: net.IPv4zero, Port: 22}, State: ns.Listen},
+		}, nil
+	}
+
+	notExist := func(accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+		return nil, &fs.PathError{Op: "open", Path: "/proc/net/tcp6", Err: fs.ErrNotExist}
+	}
+
+	permission := func(accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+		return nil, &fs.PathError{Op: "open", Path: "/proc/net/tcp", Err: fs.ErrPermission}
+	}
+
+	t.Run("IPv6 disabled", func(t *testing.T) {
+		withProtocolSockets(t, ipv4, notExist)
+
+		var ran bool
+
+		s := PortSource{
+			RunFunction: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				ran = true
+				return nil, errors.New("ss should not be run")
+			},
+		}
+
+		sockets, err := s.hostSockets(context.Background(), ProtocolTCP, acceptListening(ProtocolTCP))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sockets) != 1 || sockets[0].LocalAddr.Port != 22 {
+			t.Errorf("expected the IPv4 sockets to be returned, got %v", sockets)
+		}
+
+		if ran {
+			t.Error("expected ss not to be run")
+		}
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		withProtocolSockets(t, permission, permission)
+
+		var runs int
+
+		s := PortSource{
+			RunFunction: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				runs++
+				return testSS(ctx, name, args...)
+			},
+		}
+
+		for i := 0; i < 2; i++ {
+			sockets, err := s.hostSockets(context.Background(), ProtocolTCP, acceptNumber(ProtocolTCP, 80))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(sockets) != 3 {
+				t.Errorf("expected 3 sockets from ss, got %v", len(sockets))
+			}
+		}
+
+		if runs != 2 || !s.procNetUnreadable.Load() {
+			t.Errorf("expected ss to be used for every request, ran %v times", runs)
+		}
+
+		// /proc/net isn't tried again once it has been found to be
+		// unreadable
+		withProtocolSockets(t, ipv4)
+
+		if sockets, _ := s.hostSockets(context.Background(), ProtocolTCP, acceptListening(ProtocolTCP)); runs != 3 || len(sockets) == 1 {
+			t.Errorf("expected ss to be used, got %v", sockets)
+		}
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		withProtocolSockets(t, ipv4, func(accept ns.AcceptFn) ([]ns.SockTabEntry, error) {
+			return nil, errors.New("could not parse socket table")
+		})
+
+		s := PortSource{
+			RunFunction: func(ctx context.Context, name string, args ...string) ([]byte, error) {
+				t.Error("expected ss not to be run")
+				return nil, nil
+			},
+		}
+
+		sockets, err := s.hostSockets(context.Background(), ProtocolTCP, acceptListening(ProtocolTCP))
+
+		if err == nil {
+			t.Error("expected an error")
+		}
+
+		if len(sockets) != 1 {
+			t.Errorf("expected the sockets that were read to be kept, got %v", sockets)
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package pacman
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedb"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// DefaultLocalLocation The default location of the pacman local database,
+// which contains a directory for each installed package
+const DefaultLocalLocation = "/var/lib/pacman/local"
+
+// Package represents information about a pacman package
+type Package struct {
+	Name         string
+	Version      string
+	Base         string
+	Summary      string
+	URL          *url.URL
+	Architecture string
+	BuildTime    time.Time
+	InstallTime  time.Time
+	Packager     string
+	Size         int
+	Licenses     []string
+
+	// Whether the package was installed explicitly rather than as a
+	// dependency of another package
+	Explicit bool
+
+	Depends    []packagedeps.Dependency
+	OptDepends []packagedeps.Dependency
+	Conflicts  []packagedeps.Dependency
+	Provides   []packagedeps.Dependency
+	Replaces   []packagedeps.Dependency
+	Files      []packagefiles.File
+}
+
+// entry Returns the parts of the package that it is looked up by
+func (p Package) entry() packagedb.Entry {
+	return packagedb.Entry{
+		Name:     p.Name,
+		Provides: p.Provides,
+		Depends:  p.Depends,
+		Files:    p.Files,
+	}
+}
+
+// NotFoundError will be returned when the package isn't installed
+type NotFoundError = packagedb.NotFoundError
+
+// Database Reads packages from the pacman local database. Every package is
+// read once and kept in memory until the database changes, which happens
+// whenever a package is installed, upgraded or removed
+type Database struct {
+	// The location of the local database. Defaults to DefaultLocalLocation
+	LocalLocation string
+
+	mutex sync.Mutex
+	state map[string]fileState
+	index *packagedb.Index[Package]
+}
+
+// fileState The modification time and size of a file in the local database,
+// used to tell whether it has changed since it was read
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Available Returns whether the local database can be read. Every version of
+// the database contains an ALPM_DB_VERSION file
+func (d *Database) Available() bool {
+	_, err := os.Stat(filepath.Join(d.localLocation(), "ALPM_DB_VERSION"))
+
+	return err == nil
+}
+
+// Packages Returns all installed packages
+func (d *Database) Packages() ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	return d.index.Packages(), nil
+}
+
+// Package Returns a package by name
+func (d *Database) Package(name string) (Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return Package{}, err
+	}
+
+	p, ok := d.index.Package(name)
+
+	if !ok {
+		return Package{}, NotFoundError{
+			Message: fmt.Sprintf("package %v is not in %v", name, d.localLocation()),
+		}
+	}
+
+	return p, nil
+}
+
+// Search Returns the packages that own a file, in the same way as `pacman
+// -Qo`. If the path contains shell wildcards it returns all packages that own
+// a matching file
+func (d *Database) Search(pattern string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	ps := d.index.Owners(pattern)
+
+	if len(ps) == 0 {
+		return nil, NotFoundError{
+			Message: fmt.Sprintf("no path found matching pattern %v", pattern),
+		}
+	}
+
+	return ps, nil
+}
+
+// WhatProvides Returns the packages that provide something, which can be the
+// name of a package or something that it provides such as a virtual package
+// (sh) or a shared library (libreadline.so)
+func (d *Database) WhatProvides(name string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	ps := d.index.WhatProvides(name)
+
+	if len(ps) == 0 {
+		return nil, NotFoundError{
+			Message: fmt.Sprintf("no package provides %v", name),
+		}
+	}
+
+	return ps, nil
+}
+
+// ReverseDepends Returns the packages that depend on the named package, either
+// directly or through something that it provides
+func (d *Database) ReverseDepends(name string) ([]Package, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+
+	return d.index.ReverseDepends(name), nil
+}
+
+// localLocation Returns the location of the local database, or the default
+func (d *Database) localLocation() string {
+	if d.LocalLocation == "" {
+		return DefaultLocalLocation
+	}
+
+	return d.LocalLocation
+}
+
+// refresh Re-reads the database if it has changed since it was last read.
+// The mutex must be held when calling this
+func (d *Database) refresh() error {
+	entries, err := os.ReadDir(d.localLocation())
+
+	if err != nil {
+		return err
+	}
+
+	state := readState(d.localLocation(), entries)
+
+	if d.index != nil && sameState(state, d.state) {
+		return nil
+	}
+
+	packages := make([]Package, 0, len(entries))
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		p, err := ReadPackage(filepath.Join(d.localLocation(), entry.Name()))
+
+		// Packages that are being installed might not be complete, they will
+		// be read again once their desc or files file changes
+		if err != nil || p.Name == "" {
+			continue
+		}
+
+		packages = append(packages, p)
+	}
+
+	d.state = state
+	d.index = packagedb.NewIndex(packages, Package.entry)
+
+	return nil
+}
+
+// readState Returns the state of the desc and files file of every package in
+// the local database. Pacman writes these after creating the package's
+// directory, which doesn't change the modification time of the database
+// directory itself, so they are checked individually in order to notice
+// packages that were read while they were being installed
+func readState(localLocation string, entries []os.DirEntry) map[string]fileState {
+	state := make(map[string]fileState)
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		for _, name := range []string{"desc", "files"} {
+			path := filepath.Join(entry.Name(), name)
+
+			if info, err := os.Stat(filepath.Join(localLocation, path)); err == nil {
+				state[path] = fileState{
+					modTime: info.ModTime(),
+					size:    info.Size(),
+				}
+			}
+		}
+	}
+
+	return state
+}
+
+// sameState Returns whether the local database is in the same state
+func sameState(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for path, s := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(s.modTime) || other.size != s.size {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadPackage Reads a package from its directory in the local database. The
+// desc file contains details of the package and the files file lists the
+// files that it owns, along with its config files (%BACKUP%)
+func ReadPackage(directory string) (Package, error) {
+	desc, err := readSections(filepath.Join(directory, "desc"))
+
+	if err != nil {
+		return Package{}, err
+	}
+
+	p := descToPackage(desc)
+
+	// Packages can exist without a files file, for example if they were
+	// installed by an old version of pacman
+	files, err := readSections(filepath.Join(directory, "files"))
+
+	if err == nil {
+		p.Files = sectionsToFiles(files)
+	}
+
+	return p, nil
+}
+
+// readSections Reads and parses a file from the local database
+func readSections(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseSections(file)
+}
+
+// ParseSections Parses a file from the local database. Each section starts
+// with its name between percent signs e.g. %NAME%, followed by one value per
+// line, and ends with a blank line
+func ParseSections(r io.Reader) (map[string][]string, error) {
+	sections := make(map[string][]string)
+	scanner := bufio.NewScanner(r)
+
+	var section string
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			section = ""
+		case section == "" && len(line) > 2 && strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			section = strings.Trim(line, "%")
+			sections[section] = make([]string, 0)
+		case section != "":
+			sections[section] = append(sections[section], line)
+		default:
+			return nil, fmt.Errorf("could not parse line %q, expected a section name e.g. %%NAME%%", line)
+		}
+	}
+
+	return sections, scanner.Err()
+}
+
+// descToPackage Converts the sections of a desc file into a package
+func descToPackage(desc map[string][]string) Package {
+	first := func(section string) string {
+		if values := desc[section]; len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+
+	unix := func(section string) time.Time {
+		if seconds, err := strconv.ParseInt(first(section), 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+
+		return time.Time{}
+	}
+
+	p := Package{
+		Name:         first("NAME"),
+		Version:      first("VERSION"),
+		Base:         first("BASE"),
+		Summary:      first("DESC"),
+		Architecture: first("ARCH"),
+		BuildTime:    unix("BUILDDATE"),
+		InstallTime:  unix("INSTALLDATE"),
+		Packager:     first("PACKAGER"),
+		Licenses:     desc["LICENSE"],
+		Depends:      parseDependencies(desc["DEPENDS"]),
+		OptDepends:   parseDependencies(desc["OPTDEPENDS"]),
+		Conflicts:    parseDependencies(desc["CONFLICTS"]),
+		Provides:     parseDependencies(desc["PROVIDES"]),
+		Replaces:     parseDependencies(desc["REPLACES"]),
+	}
+
+	if u := first("URL"); u != "" {
+		p.URL, _ = url.Parse(u)
+	}
+
+	p.Size, _ = strconv.Atoi(first("SIZE"))
+
+	// The reason is 0 for explicitly installed packages and 1 for
+	// dependencies, and is left out if it is 0
+	p.Explicit = first("REASON") != "1"
+
+	return p
+}
+
+// sectionsToFiles Converts the sections of a files file into the files that a
+// package owns. Paths are relative to the root and directories end with a
+// slash
+func sectionsToFiles(sections map[string][]string) []packagefiles.File {
+	backup := make(map[string]bool)
+
+	// Each line is the path and the MD5 sum of the file when it was installed,
+	// separated by a tab
+	for _, line := range sections["BACKUP"] {
+		path, _, _ := strings.Cut(line, "\t")
+		backup["/"+path] = true
+	}
+
+	files := make([]packagefiles.File, 0, len(sections["FILES"]))
+
+	for _, line := range sections["FILES"] {
+		path := "/" + strings.TrimSuffix(line, "/")
+
+		files = append(files, packagefiles.File{
+			Path:      path,
+			Conffile:  backup[path],
+			Directory: strings.HasSuffix(line, "/"),
+		})
+	}
+
+	return files
+}
+
+// parseDependencies Parses dependencies e.g. "glibc" or "libreadline.so=8-64".
+// Optional dependencies also have a description e.g. "bash-completion: for
+// tab completion" which is ignored
+func parseDependencies(lines []string) []packagedeps.Dependency {
+	deps := make([]packagedeps.Dependency, 0, len(lines))
+
+	for _, line := range lines {
+		name, _, _ := strings.Cut(line, ":")
+
+		if name = strings.TrimSpace(name); name != "" {
+			deps = append(deps, packagedeps.ParseConstraint(name))
+		}
+	}
+
+	return deps
+}
@@ -0,0 +1,247 @@
+package pacman
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+func TestParseSections(t *testing.T) {
+	sections, err := ParseSections(strings.NewReader("%NAME%\nbash\n\n%DEPENDS%\nreadline\nglibc\n\n%EMPTY%\n\n"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"NAME":    {"bash"},
+		"DEPENDS": {"readline", "glibc"},
+		"EMPTY":   {},
+	}
+
+	if !reflect.DeepEqual(sections, expected) {
+		t.Errorf("expected %v, got %v", expected, sections)
+	}
+
+	if _, err := ParseSections(strings.NewReader("bash\n")); err == nil {
+		t.Error("expected an error for a value without a section")
+	}
+}
+
+func TestReadPackage(t *testing.T) {
+	p, err := ReadPackage("test/local/bash-5.2.015-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Name != "bash" || p.Version != "5.2.015-1" || p.Architecture != "x86_64" || p.Size != 9254912 || !p.Explicit {
+		t.Errorf("unexpected package %+v", p)
+	}
+
+	if !p.InstallTime.Equal(time.Unix(1675953042, 0)) {
+		t.Errorf("unexpected install time %v", p.InstallTime)
+	}
+
+	expectedDepends := []packagedeps.Dependency{
+		{Name: "readline"},
+		{Name: "libreadline.so", Operator: "=", Version: "8-64"},
+		{Name: "glibc"},
+		{Name: "ncurses"},
+	}
+
+	if !reflect.DeepEqual(p.Depends, expectedDepends) {
+		t.Errorf("expected depends %+v, got %+v", expectedDepends, p.Depends)
+	}
+
+	if expected := []packagedeps.Dependency{{Name: "bash-completion"}}; !reflect.DeepEqual(p.OptDepends, expected) {
+		t.Errorf("expected optional depends %+v, got %+v", expected, p.OptDepends)
+	}
+
+	if len(p.Files) != 13 {
+		t.Fatalf("expected 13 files, got %v", len(p.Files))
+	}
+
+	if f := p.Files[0]; f != (packagefiles.File{Path: "/etc", Directory: true}) {
+		t.Errorf("unexpected file %+v", f)
+	}
+
+	if f := p.Files[2]; f != (packagefiles.File{Path: "/etc/bash.bashrc", Conffile: true}) {
+		t.Errorf("unexpected file %+v", f)
+	}
+
+	if f := p.Files[7]; f != (packagefiles.File{Path: "/usr/bin/bash"}) {
+		t.Errorf("unexpected file %+v", f)
+	}
+
+	glibc, err := ReadPackage("test/local/glibc-2.38-7")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if glibc.Explicit || !reflect.DeepEqual(glibc.Licenses, []string{"GPL-2.0-or-later", "LGPL-2.1-or-later"}) {
+		t.Errorf("unexpected package %+v", glibc)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	db := Database{
+		LocalLocation: "test/local",
+	}
+
+	names := func(ps []Package) []string {
+		n := make([]string, 0)
+
+		for _, p := range ps {
+			n = append(n, p.Name)
+		}
+
+		return n
+	}
+
+	if !db.Available() {
+		t.Fatal("expected database to be available")
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		if p, err := db.Package("readline"); err != nil || p.Version != "8.2.001-2" {
+			t.Errorf("unexpected package %+v, error %v", p, err)
+		}
+
+		if _, err := db.Package("notapackage"); err == nil {
+			t.Error("expected an error")
+		} else if _, ok := err.(NotFoundError); !ok {
+			t.Errorf("expected NotFoundError, got %T", err)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		tests := map[string][]string{
+			"/usr/bin/bash":     {"bash"},
+			"/usr/lib/*":        {"glibc", "readline"},
+			"/etc/*":            {"bash", "readline"},
+			"/usr/lib":          nil,
+			"/usr/bin/notafile": nil,
+		}
+
+		for pattern, expected := range tests {
+			ps, err := db.Search(pattern)
+
+			if expected == nil {
+				if _, ok := err.(NotFoundError); !ok {
+					t.Errorf("%v: expected NotFoundError, got %v", pattern, err)
+				}
+
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%v: %v", pattern, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", pattern, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("WhatProvides", func(t *testing.T) {
+		tests := map[string][]string{
+			"sh":             {"bash"},
+			"libreadline.so": {"readline"},
+			"glibc-locales":  {"glibc"},
+			"glibc":          {"glibc"},
+		}
+
+		for name, expected := range tests {
+			ps, err := db.WhatProvides(name)
+
+			if err != nil {
+				t.Errorf("%v: %v", name, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", name, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("ReverseDepends", func(t *testing.T) {
+		tests := map[string][]string{
+			"glibc":    {"bash", "readline"},
+			"readline": {"bash"},
+		}
+
+		for name, expected := range tests {
+			ps, err := db.ReverseDepends(name)
+
+			if err != nil {
+				t.Errorf("%v: %v", name, err)
+				continue
+			}
+
+			if !reflect.DeepEqual(names(ps), expected) {
+				t.Errorf("%v: expected %v, got %v", name, expected, names(ps))
+			}
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		local := t.TempDir()
+
+		db := Database{
+			LocalLocation: local,
+		}
+
+		if ps, err := db.Packages(); err != nil || len(ps) != 0 {
+			t.Fatalf("expected no packages, got %v, error %v", len(ps), err)
+		}
+
+		directory := filepath.Join(local, "newpkg-1.0-1")
+
+		if err := os.Mkdir(directory, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(directory, "desc"), []byte("%NAME%\nnewpkg\n\n%VERSION%\n1.0-1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Make sure that the mtime changes even on filesystems with coarse
+		// timestamps
+		future := time.Now().Add(time.Minute)
+
+		if err := os.Chtimes(local, future, future); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := db.Package("newpkg"); err != nil {
+			t.Errorf("expected newpkg to be found after the database changed: %v", err)
+		}
+
+		// Writing the files file only changes the package's directory, not
+		// the database directory
+		if err := os.WriteFile(filepath.Join(directory, "files"), []byte("%FILES%\nusr/\nusr/bin/\nusr/bin/newpkg\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		later := future.Add(time.Minute)
+
+		if err := os.Chtimes(filepath.Join(directory, "files"), later, later); err != nil {
+			t.Fatal(err)
+		}
+
+		if p, err := db.Package("newpkg"); err != nil || len(p.Files) != 3 {
+			t.Errorf("expected the files of newpkg to be read after they were written, got %v, error %v", p.Files, err)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package pacman
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedb"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/sdp-go"
+)
+
+// PacmanSource struct on which all methods are registered. Packages are read
+// directly from the pacman local database so the pacman command isn't needed
+type PacmanSource struct {
+	// The location of the pacman local database. Defaults to
+	// DefaultLocalLocation
+	LocalLocation string
+
+	// The maximum number of files that each package links to. Zero uses
+	// packagefiles.DefaultLinkLimit and a negative number disables links
+	FileLinkLimit int
+
+	database     *Database
+	databaseOnce sync.Once
+}
+
+// Type is the type of items that this returns (Required)
+func (s *PacmanSource) Type() string {
+	return "package"
+}
+
+// Descriptive name for the source, used in logging and metadata
+func (s *PacmanSource) Name() string {
+	return "pacman"
+}
+
+// Weighting of duplicate sources
+func (s *PacmanSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *PacmanSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get takes the UniqueAttribute value as a parameter (also referred to as the
+// "name" of the item) and returns a full item will all details. This function
+// must return an item whose UniqueAttribute value exactly matches the supplied
+// parameter. If the item cannot be found it should return an ItemNotFoundError
+// (Required)
+func (s *PacmanSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	p, err := s.db().Package(query)
+
+	if e, ok := err.(NotFoundError); ok {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: e.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return mapPackageToItem(p, s.FileLinkLimit)
+}
+
+// Find Gets information about all item that the source can possibly find. If
+// nothing is found then just return an empty list (Required)
+func (s *PacmanSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	ps, err := s.db().Packages()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return packagedb.MapItems(ps, func(p Package) (*sdp.Item, error) {
+		return mapPackageToItem(p, s.FileLinkLimit)
+	}), nil
+}
+
+// Search takes a file path and returns the package that owns it, in the same
+// way as `pacman -Qo`. Paths containing shell wildcards return all packages
+// that own a matching file. If the query isn't a path it returns the packages
+// that provide it, which can be a package name, a virtual package such as sh
+// or a library such as libreadline.so. If the query is in the format
+// rdepends:{name} it instead returns the packages that depend on the given
+// package
+func (s *PacmanSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	var ps []Package
+	var err error
+
+	switch {
+	case strings.HasPrefix(query, "rdepends:"):
+		ps, err = s.db().ReverseDepends(strings.TrimPrefix(query, "rdepends:"))
+	case strings.HasPrefix(query, "/"):
+		ps, err = s.db().Search(query)
+	default:
+		ps, err = s.db().WhatProvides(query)
+	}
+
+	if e, ok := err.(NotFoundError); ok {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: e.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return packagedb.MapItems(ps, func(p Package) (*sdp.Item, error) {
+		return mapPackageToItem(p, s.FileLinkLimit)
+	}), nil
+}
+
+// Supported Returns whether the pacman local database exists
+func (s *PacmanSource) Supported() bool {
+	return s.db().Available()
+}
+
+// db Returns the local database
+func (s *PacmanSource) db() *Database {
+	s.databaseOnce.Do(func() {
+		s.database = &Database{
+			LocalLocation: s.LocalLocation,
+		}
+	})
+
+	return s.database
+}
+
+func mapPackageToItem(p Package, fileLinkLimit int) (*sdp.Item, error) {
+	reason := "dependency"
+
+	if p.Explicit {
+		reason = "explicit"
+	}
+
+	attrMap := map[string]interface{}{
+		"name":         p.Name,
+		"status":       "installed",
+		"version":      p.Version,
+		"architecture": p.Architecture,
+		"size":         p.Size,
+		"license":      strings.Join(p.Licenses, ", "),
+		"origin":       p.Base,
+		"maintainer":   p.Packager,
+		"buildtime":    p.BuildTime,
+		"installtime":  p.InstallTime,
+		"reason":       reason,
+		"summary":      p.Summary,
+		"depends":      packagedeps.Attributes(p.Depends),
+		"optDepends":   packagedeps.Attributes(p.OptDepends),
+		"conflicts":    packagedeps.Attributes(p.Conflicts),
+		"provides":     packagedeps.Attributes(p.Provides),
+		"replaces":     packagedeps.Attributes(p.Replaces),
+	}
+
+	// The URL is parsed in the same way as the other package sources
+	if p.URL != nil {
+		attrMap["url"] = p.URL
+	}
+
+	for k, v := range packagefiles.Attributes(p.Files) {
+		attrMap[k] = v
+	}
+
+	attributes, err := sdp.ToAttributes(attrMap)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Dependencies can be virtual packages or libraries such as
+	// libreadline.so rather than package names, so they are linked using a
+	// search which returns the package that provides them. Optional
+	// dependencies aren't linked since they don't need to be installed, and
+	// neither are dependencies that the package satisfies itself
+	linkedItemRequests := packagedeps.LinkedItemRequests(sdp.RequestMethod_SEARCH, p.entry().Matches, p.Depends)
+	linkedItemRequests = append(linkedItemRequests, packagefiles.LinkedItemRequests(p.Files, fileLinkLimit)...)
+
+	return &sdp.Item{
+		Type:               "package",
+		UniqueAttribute:    "name",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: linkedItemRequests,
+	}, nil
+}
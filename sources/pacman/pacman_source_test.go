@@ -0,0 +1,171 @@
+package pacman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestPacmanSource(t *testing.T) {
+	src := PacmanSource{
+		LocalLocation: "test/local",
+	}
+
+	if !src.Supported() {
+		t.Fatal("expected source to be supported")
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get",
+			ItemContext: util.LocalContext,
+			Query:       "bash",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"version":       "5.2.015-1",
+						"status":        "installed",
+						"reason":        "explicit",
+						"fileCount":     float64(6),
+						"conffileCount": float64(3),
+						"url.Host":      "www.gnu.org",
+						"url.Path":      "/software/bash/bash.html",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get dependency",
+			ItemContext: util.LocalContext,
+			Query:       "glibc",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"reason":  "dependency",
+						"license": "GPL-2.0-or-later, LGPL-2.1-or-later",
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing",
+			ItemContext: util.LocalContext,
+			Query:       "notapackage",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get wrong context",
+			ItemContext: "foo",
+			Query:       "bash",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 3,
+			},
+		},
+		{
+			Name:        "search file",
+			ItemContext: util.LocalContext,
+			Query:       "/usr/lib/libreadline.so.8",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name": "readline",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search provides",
+			ItemContext: util.LocalContext,
+			Query:       "libreadline.so",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name": "readline",
+					},
+				},
+			},
+		},
+		{
+			Name:        "search rdepends",
+			ItemContext: util.LocalContext,
+			Query:       "rdepends:readline",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+			},
+		},
+		{
+			Name:        "search missing",
+			ItemContext: util.LocalContext,
+			Query:       "/usr/bin/notafile",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &src)
+}
+
+func TestLinkedItemRequests(t *testing.T) {
+	p, err := ReadPackage("test/local/bash-5.2.015-1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := mapPackageToItem(p, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queries []string
+
+	for _, r := range item.LinkedItemRequests {
+		if r.Type == "package" {
+			if r.Method != sdp.RequestMethod_SEARCH {
+				t.Errorf("expected dependencies to be linked with a search, got %v", r.Method)
+			}
+
+			queries = append(queries, r.Query)
+		}
+	}
+
+	// Optional dependencies aren't linked
+	if expected := "readline,libreadline.so,glibc,ncurses"; strings.Join(queries, ",") != expected {
+		t.Errorf("expected %v, got %v", expected, queries)
+	}
+
+	// Followed by the 6 files, with the conffiles first
+	if len(item.LinkedItemRequests) != 10 {
+		t.Fatalf("expected 10 links, got %v", len(item.LinkedItemRequests))
+	}
+
+	if file := item.LinkedItemRequests[4]; file.Type != "file" || file.Query != "/etc/bash.bash_logout" {
+		t.Errorf("expected a conffile to be linked first, got %v", file)
+	}
+}
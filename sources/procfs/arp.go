@@ -0,0 +1,79 @@
+package procfs
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Flags of ARP entries, see include/uapi/linux/if_arp.h
+const (
+	ATFComplete  = 0x02
+	ATFPermanent = 0x04
+)
+
+// ARPEntry A single entry from /proc/net/arp
+type ARPEntry struct {
+	IP     string
+	HWType uint64
+	Flags  uint64
+	MAC    string
+	Device string
+}
+
+// ARP Reads /proc/net/arp
+func (p ProcFS) ARP() ([]ARPEntry, error) {
+	file, err := os.Open(p.Path("net", "arp"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseARP(file)
+}
+
+// ParseARP Parses the contents of /proc/net/arp. The format is:
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.1      0x1         0x2         52:54:00:12:34:56     *        eth0
+func ParseARP(r io.Reader) ([]ARPEntry, error) {
+	entries := make([]ARPEntry, 0)
+	scanner := bufio.NewScanner(r)
+
+	// Discard the header
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 6 {
+			continue
+		}
+
+		hwType, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "0x"), 16, 64)
+
+		if err != nil {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 64)
+
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, ARPEntry{
+			IP:     fields[0],
+			HWType: hwType,
+			Flags:  flags,
+			MAC:    fields[3],
+			Device: fields[5],
+		})
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,30 @@
+package procfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestARP(t *testing.T) {
+	entries, err := testProc.ARP()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %v", len(entries))
+	}
+
+	expected := ARPEntry{
+		IP:     "192.168.100.1",
+		HWType: 1,
+		Flags:  ATFComplete | ATFPermanent,
+		MAC:    "52:54:00:aa:bb:cc",
+		Device: "bond0.100",
+	}
+
+	if !reflect.DeepEqual(entries[3], expected) {
+		t.Errorf("expected %+v, got %+v", expected, entries[3])
+	}
+}
@@ -0,0 +1,90 @@
+package procfs
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Container The container that a process is running in, as worked out from
+// its cgroup
+type Container struct {
+	ID      string
+	Runtime string
+}
+
+// containerRegex Matches the ID of a container in a cgroup path. This
+// handles both the systemd cgroup driver (e.g. docker-{id}.scope) and the
+// cgroupfs driver (e.g. /docker/{id})
+var containerRegex = regexp.MustCompile(`(?:^|/)(?:(docker|cri-containerd|crio|libpod)-|(docker|kubepods|lxc|machine)/(?:.*/)?)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// containerRuntimes Names of container runtimes by the prefix that they use
+// in cgroup paths
+var containerRuntimes = map[string]string{
+	"docker":         "docker",
+	"cri-containerd": "containerd",
+	"crio":           "cri-o",
+	"libpod":         "podman",
+	"kubepods":       "kubernetes",
+	"lxc":            "lxc",
+	"machine":        "systemd-nspawn",
+}
+
+// Container Returns the container that a process is running in. The returned
+// bool is false if the process isn't in a container
+func (p ProcFS) Container(pid int) (Container, bool) {
+	file, err := os.Open(p.PIDPath(pid, "cgroup"))
+
+	if err != nil {
+		return Container{}, false
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		// Lines are in the format {hierarchy}:{controllers}:{path}
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+
+		if len(fields) != 3 {
+			continue
+		}
+
+		if container, ok := ParseContainer(fields[2]); ok {
+			return container, true
+		}
+	}
+
+	return Container{}, false
+}
+
+// ParseContainer Extracts the container from a cgroup path e.g.
+// /system.slice/docker-{id}.scope
+func ParseContainer(cgroup string) (Container, bool) {
+	matches := containerRegex.FindStringSubmatch(cgroup)
+
+	if matches == nil {
+		return Container{}, false
+	}
+
+	prefix := matches[1]
+
+	if prefix == "" {
+		prefix = matches[2]
+	}
+
+	runtime := containerRuntimes[prefix]
+
+	// Kubernetes cgroups name the runtime in the leaf e.g.
+	// /kubepods.slice/.../cri-containerd-{id}.scope
+	if runtime == "" && strings.Contains(cgroup, "kubepods") {
+		runtime = "kubernetes"
+	}
+
+	return Container{
+		ID:      matches[3],
+		Runtime: runtime,
+	}, true
+}
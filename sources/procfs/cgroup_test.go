@@ -0,0 +1,84 @@
+package procfs
+
+import (
+	"context"
+	"testing"
+)
+
+const testContainerID = "3f4e8c1b2a9d7e6f5a4b3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f"
+
+func TestParseContainer(t *testing.T) {
+	tests := map[string]Container{
+		"/system.slice/docker-" + testContainerID + ".scope":                                                            {ID: testContainerID, Runtime: "docker"},
+		"/docker/" + testContainerID:                                                                                    {ID: testContainerID, Runtime: "docker"},
+		"/kubepods/besteffort/pod1234/" + testContainerID:                                                               {ID: testContainerID, Runtime: "kubernetes"},
+		"/kubepods.slice/kubepods-besteffort.slice/cri-containerd-" + testContainerID + ".scope":                        {ID: testContainerID, Runtime: "containerd"},
+		"/machine.slice/libpod-" + testContainerID + ".scope":                                                           {ID: testContainerID, Runtime: "podman"},
+		"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod12_34.slice/crio-" + testContainerID + ".scope": {ID: testContainerID, Runtime: "cri-o"},
+	}
+
+	for cgroup, expected := range tests {
+		container, ok := ParseContainer(cgroup)
+
+		if !ok {
+			t.Errorf("expected container in %v", cgroup)
+			continue
+		}
+
+		if container != expected {
+			t.Errorf("expected %+v from %v, got %+v", expected, cgroup, container)
+		}
+	}
+
+	for _, cgroup := range []string{"/", "/init.scope", "/user.slice/user-1000.slice/session-2.scope"} {
+		if container, ok := ParseContainer(cgroup); ok {
+			t.Errorf("expected no container in %v, got %+v", cgroup, container)
+		}
+	}
+}
+
+func TestContainer(t *testing.T) {
+	container, ok := testProc.Container(42)
+
+	if !ok || container.ID != testContainerID || container.Runtime != "docker" {
+		t.Errorf("unexpected container %+v", container)
+	}
+
+	if _, ok := testProc.Container(1); ok {
+		t.Error("expected pid 1 not to be in a container")
+	}
+}
+
+func TestNamespacePIDs(t *testing.T) {
+	namespaces, err := testProc.NamespacePIDs(context.Background(), "net")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pids := namespaces[4026532281]; len(pids) != 2 || pids[0] != 42 || pids[1] != 43 {
+		t.Errorf("expected pids 42 and 43, got %v", pids)
+	}
+
+	self, err := testProc.SelfNamespace("net")
+
+	if err != nil || self != 4026531992 {
+		t.Errorf("expected self to be in 4026531992, got %v %v", self, err)
+	}
+}
+
+func TestAllNamespacePIDs(t *testing.T) {
+	namespaces, err := testProc.AllNamespacePIDs(context.Background(), "net", "mnt")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pids := namespaces["net"][4026532281]; len(pids) != 2 || pids[0] != 42 || pids[1] != 43 {
+		t.Errorf("expected pids 42 and 43 in the net namespace, got %v", pids)
+	}
+
+	if pids := namespaces["mnt"][4026531856]; len(pids) != 3 {
+		t.Errorf("expected pids 1, 42 and 43 in the mnt namespace, got %v", pids)
+	}
+}
@@ -0,0 +1,137 @@
+package procfs
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InetSocket A single TCP or UDP socket from /proc/net/{tcp,tcp6,udp,udp6}
+type InetSocket struct {
+	LocalIP    net.IP
+	LocalPort  uint16
+	RemoteIP   net.IP
+	RemotePort uint16
+
+	// The state of the socket as defined in include/net/tcp_states.h e.g.
+	// 0x0A for TCP_LISTEN
+	State uint8
+	UID   uint32
+	Inode uint64
+}
+
+// InetSockets Reads a socket table of a process e.g. /proc/{pid}/net/tcp6.
+// This shows the sockets of the network namespace that the process is in,
+// rather than that of the caller. The table is one of tcp, tcp6, udp or udp6
+func (p ProcFS) InetSockets(pid int, table string) ([]InetSocket, error) {
+	file, err := os.Open(p.PIDPath(pid, "net", table))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseInetSockets(file)
+}
+
+// ParseInetSockets Parses a socket table in the format of /proc/net/tcp. The
+// format is:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	 0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20731 1 ...
+func ParseInetSockets(r io.Reader) ([]InetSocket, error) {
+	sockets := make([]InetSocket, 0)
+	scanner := bufio.NewScanner(r)
+
+	// Discard the header
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 10 {
+			continue
+		}
+
+		var socket InetSocket
+		var err error
+
+		if socket.LocalIP, socket.LocalPort, err = parseInetAddr(fields[1]); err != nil {
+			return nil, err
+		}
+
+		if socket.RemoteIP, socket.RemotePort, err = parseInetAddr(fields[2]); err != nil {
+			return nil, err
+		}
+
+		state, err := strconv.ParseUint(fields[3], 16, 8)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not parse socket state %v: %v", fields[3], err)
+		}
+
+		socket.State = uint8(state)
+
+		if uid, err := strconv.ParseUint(fields[7], 10, 32); err == nil {
+			socket.UID = uint32(uid)
+		}
+
+		if socket.Inode, err = parseUint(fields[9]); err != nil {
+			return nil, fmt.Errorf("could not parse socket inode %v: %v", fields[9], err)
+		}
+
+		sockets = append(sockets, socket)
+	}
+
+	return sockets, scanner.Err()
+}
+
+// parseInetAddr Parses an address from a socket table e.g. 0100007F:0016.
+// The IP is stored as a series of 32 bit words in host byte order, which is
+// little endian on all platforms that we support
+func parseInetAddr(s string) (net.IP, uint16, error) {
+	ipHex, portHex, found := strings.Cut(s, ":")
+
+	if !found {
+		return nil, 0, fmt.Errorf("could not parse socket address %v", s)
+	}
+
+	b, err := hex.DecodeString(ipHex)
+
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("could not parse socket address %v", s)
+	}
+
+	ip := make(net.IP, len(b))
+
+	for word := 0; word < len(b); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = b[word+3-i]
+		}
+	}
+
+	port, err := strconv.ParseUint(portHex, 16, 16)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse socket port %v: %v", portHex, err)
+	}
+
+	return ip, uint16(port), nil
+}
+
+// Comm Returns the command name of a process from /proc/{pid}/comm
+func (p ProcFS) Comm(pid int) (string, error) {
+	b, err := os.ReadFile(p.PIDPath(pid, "comm"))
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
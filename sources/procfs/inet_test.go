@@ -0,0 +1,60 @@
+package procfs
+
+import (
+	"net"
+	"testing"
+)
+
+func TestInetSockets(t *testing.T) {
+	sockets, err := testProc.InetSockets(42, "tcp")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 2 {
+		t.Fatalf("expected 2 sockets, got %v", len(sockets))
+	}
+
+	listening := sockets[0]
+
+	if !listening.LocalIP.Equal(net.IPv4zero) || listening.LocalPort != 80 || listening.State != 0x0A || listening.Inode != 50001 {
+		t.Errorf("unexpected socket %+v", listening)
+	}
+
+	established := sockets[1]
+
+	if established.LocalIP.String() != "10.17.0.2" || established.RemoteIP.String() != "10.17.0.1" || established.RemotePort != 54321 || established.UID != 33 {
+		t.Errorf("unexpected socket %+v", established)
+	}
+
+	sockets, err = testProc.InetSockets(42, "tcp6")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 1 || sockets[0].LocalIP.String() != "::1" || sockets[0].LocalPort != 8080 {
+		t.Errorf("unexpected IPv6 sockets %+v", sockets)
+	}
+}
+
+func TestParseInetAddrErrors(t *testing.T) {
+	for _, addr := range []string{"0100007F", "0100007F:XYZ", "01007F:0016", "zz00007F:0016"} {
+		if _, _, err := parseInetAddr(addr); err == nil {
+			t.Errorf("expected error parsing %v", addr)
+		}
+	}
+}
+
+func TestComm(t *testing.T) {
+	comm, err := testProc.Comm(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if comm != "nginx" {
+		t.Errorf("expected nginx, got %v", comm)
+	}
+}
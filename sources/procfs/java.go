@@ -0,0 +1,214 @@
+package procfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Cmdline Returns the arguments of a process from /proc/{pid}/cmdline. Unlike
+// splitting the command line on spaces this keeps arguments that contain
+// spaces intact
+func (p ProcFS) Cmdline(pid int) ([]string, error) {
+	b, err := os.ReadFile(p.PIDPath(pid, "cmdline"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return splitNull(b), nil
+}
+
+// Environ Returns the environment of a process from /proc/{pid}/environ. This
+// is the environment that the process was started with, and is only readable
+// by the owner of the process or root
+func (p ProcFS) Environ(pid int) (map[string]string, error) {
+	b, err := os.ReadFile(p.PIDPath(pid, "environ"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	environ := make(map[string]string)
+
+	for _, variable := range splitNull(b) {
+		if name, value, found := strings.Cut(variable, "="); found {
+			environ[name] = value
+		}
+	}
+
+	return environ, nil
+}
+
+// splitNull Splits the contents of a file that contains null terminated
+// strings
+func splitNull(b []byte) []string {
+	fields := make([]string, 0)
+
+	for _, field := range bytes.Split(bytes.TrimRight(b, "\x00"), []byte{0}) {
+		if len(field) > 0 {
+			fields = append(fields, string(field))
+		}
+	}
+
+	return fields
+}
+
+// JavaClasspath Returns the archives (jar, war and ear files) on the classpath
+// of a java process, as paths that can be read by the agent. Relative entries
+// are resolved against the working directory of the process, and paths are
+// resolved against its root if it is in a container. Entries that end with *
+// are expanded to the jars in that directory. Returns an error if the process
+// isn't java
+func (p ProcFS) JavaClasspath(pid int) ([]string, error) {
+	args, err := p.Cmdline(pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 || filepath.Base(args[0]) != "java" {
+		return nil, fmt.Errorf("process %v is not java", pid)
+	}
+
+	// The environment isn't always readable, in which case CLASSPATH is
+	// ignored
+	environ, _ := p.Environ(pid)
+
+	cwd, _ := os.Readlink(p.PIDPath(pid, "cwd"))
+
+	return ResolveClasspath(ParseJavaClasspath(args, environ["CLASSPATH"]), cwd, p.Root(pid)), nil
+}
+
+// javaOptionsWithValues The options of the java command that take their value
+// as a separate argument, other than those that set the classpath. These need
+// to be known so that their values aren't mistaken for the main class
+var javaOptionsWithValues = map[string]bool{
+	"--add-exports":          true,
+	"--add-modules":          true,
+	"--add-opens":            true,
+	"--add-reads":            true,
+	"--enable-native-access": true,
+	"--limit-modules":        true,
+	"--patch-module":         true,
+	"--source":               true,
+	"--upgrade-module-path":  true,
+}
+
+// ParseJavaClasspath Returns the classpath entries from the arguments of the
+// java command. These are set by -cp, -classpath or --class-path, or by -jar
+// in which case the jar is the only entry. Entries on the module path (-p or
+// --module-path) are also returned since they are libraries too. If the
+// classpath isn't set by an argument the CLASSPATH environment variable is
+// used. Arguments after the main class or jar are passed to the application so
+// are ignored
+func ParseJavaClasspath(args []string, classpathEnv string) []string {
+	var classpath []string
+	var modulePath []string
+	var jar string
+	var classpathSet bool
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		var value string
+
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+
+		switch {
+		case arg == "-cp" || arg == "-classpath" || arg == "--class-path":
+			classpath = filepath.SplitList(value)
+			classpathSet = true
+			i++
+		case strings.HasPrefix(arg, "--class-path="):
+			classpath = filepath.SplitList(strings.TrimPrefix(arg, "--class-path="))
+			classpathSet = true
+		case arg == "-p" || arg == "--module-path":
+			modulePath = append(modulePath, filepath.SplitList(value)...)
+			i++
+		case strings.HasPrefix(arg, "--module-path="):
+			modulePath = append(modulePath, filepath.SplitList(strings.TrimPrefix(arg, "--module-path="))...)
+		case arg == "-jar":
+			jar = value
+		case javaOptionsWithValues[arg]:
+			i++
+		case strings.HasPrefix(arg, "-"):
+			continue
+		}
+
+		// The main class, jar or module is the last argument for java itself
+		if arg == "-jar" || arg == "-m" || arg == "--module" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+	}
+
+	// When -jar is used the classpath is ignored, and only comes from the
+	// manifest of the jar
+	if jar != "" {
+		return append([]string{jar}, modulePath...)
+	}
+
+	if !classpathSet {
+		classpath = filepath.SplitList(classpathEnv)
+	}
+
+	return append(classpath, modulePath...)
+}
+
+// ResolveClasspath Converts classpath entries into the paths of the archives
+// that they refer to. Entries that aren't archives, such as directories of
+// classes, are left out
+func ResolveClasspath(entries []string, cwd string, root string) []string {
+	archives := make(map[string]bool)
+
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(entry) {
+			if cwd == "" {
+				continue
+			}
+
+			entry = filepath.Join(cwd, entry)
+		}
+
+		if root != "" {
+			entry = filepath.Join(root, entry)
+		}
+
+		// A * on its own expands to all jars in a directory
+		if filepath.Base(entry) == "*" {
+			matches, _ := filepath.Glob(filepath.Join(filepath.Dir(entry), "*"))
+
+			for _, m := range matches {
+				if strings.EqualFold(filepath.Ext(m), ".jar") {
+					archives[m] = true
+				}
+			}
+
+			continue
+		}
+
+		switch strings.ToLower(filepath.Ext(entry)) {
+		case ".jar", ".war", ".ear":
+			archives[entry] = true
+		}
+	}
+
+	paths := make([]string, 0, len(archives))
+
+	for path := range archives {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
@@ -0,0 +1,142 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCmdline(t *testing.T) {
+	args, err := testProc.Cmdline(44)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(args) != 10 || args[0] != "/usr/lib/jvm/java-17-openjdk-amd64/bin/java" || args[5] != "-cp" {
+		t.Errorf("unexpected args %q", args)
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	environ, err := testProc.Environ(44)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if environ["JAVA_HOME"] != "/usr/lib/jvm/java-17-openjdk-amd64" || len(environ) != 3 {
+		t.Errorf("unexpected environment %v", environ)
+	}
+}
+
+func TestParseJavaClasspath(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Args     []string
+		Env      string
+		Expected []string
+	}{
+		{
+			Name:     "cp",
+			Args:     []string{"java", "-Xmx1g", "-cp", "a.jar:b.jar", "com.example.Main", "-cp", "c.jar"},
+			Expected: []string{"a.jar", "b.jar"},
+		},
+		{
+			Name:     "class-path with equals",
+			Args:     []string{"java", "--class-path=a.jar", "com.example.Main"},
+			Env:      "ignored.jar",
+			Expected: []string{"a.jar"},
+		},
+		{
+			Name:     "jar",
+			Args:     []string{"java", "-cp", "ignored.jar", "-jar", "/opt/app/app.jar", "-cp", "arg.jar"},
+			Expected: []string{"/opt/app/app.jar"},
+		},
+		{
+			Name:     "module path",
+			Args:     []string{"java", "-p", "mods/a.jar", "--add-modules", "b", "-classpath", "c.jar", "-m", "com.example/com.example.Main"},
+			Expected: []string{"c.jar", "mods/a.jar"},
+		},
+		{
+			Name:     "environment",
+			Args:     []string{"java", "com.example.Main", "-cp", "arg.jar"},
+			Env:      "/usr/share/java/a.jar:lib/*",
+			Expected: []string{"/usr/share/java/a.jar", "lib/*"},
+		},
+		{
+			Name:     "no classpath",
+			Args:     []string{"java", "-version"},
+			Expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			classpath := ParseJavaClasspath(test.Args, test.Env)
+
+			if len(classpath) == 0 && len(test.Expected) == 0 {
+				return
+			}
+
+			if !reflect.DeepEqual(classpath, test.Expected) {
+				t.Errorf("expected %q, got %q", test.Expected, classpath)
+			}
+		})
+	}
+}
+
+func TestResolveClasspath(t *testing.T) {
+	root := t.TempDir()
+	lib := filepath.Join(root, "opt/app/lib")
+
+	if err := os.MkdirAll(lib, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.jar", "B.JAR", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(lib, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archives := ResolveClasspath([]string{"lib/*", "app.war", "classes", "/usr/share/java/c.jar", ""}, "/opt/app", root)
+
+	expected := []string{
+		filepath.Join(root, "opt/app/app.war"),
+		filepath.Join(lib, "B.JAR"),
+		filepath.Join(lib, "a.jar"),
+		filepath.Join(root, "usr/share/java/c.jar"),
+	}
+
+	if !reflect.DeepEqual(archives, expected) {
+		t.Errorf("expected %q, got %q", expected, archives)
+	}
+
+	// Relative entries can't be resolved without the working directory
+	if archives := ResolveClasspath([]string{"app.jar", "/app.jar"}, "", ""); !reflect.DeepEqual(archives, []string{"/app.jar"}) {
+		t.Errorf("expected [/app.jar], got %q", archives)
+	}
+}
+
+func TestJavaClasspath(t *testing.T) {
+	archives, err := testProc.JavaClasspath(44)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The application's arguments and the CLASSPATH environment variable are
+	// ignored, as is the directory of classes. /opt/app/lib/* doesn't exist
+	// so doesn't expand to anything
+	expected := []string{"/srv/app/lib/app.jar", "/usr/share/java/log4j-core.jar"}
+
+	if !reflect.DeepEqual(archives, expected) {
+		t.Errorf("expected %q, got %q", expected, archives)
+	}
+
+	if _, err := testProc.JavaClasspath(43); err == nil {
+		t.Error("expected an error for a process that isn't java")
+	}
+}
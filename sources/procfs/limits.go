@@ -0,0 +1,73 @@
+package procfs
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+)
+
+// Limit A single resource limit from /proc/{pid}/limits
+type Limit struct {
+	Name  string
+	Soft  string
+	Hard  string
+	Units string
+}
+
+// ToMap Converts the limit to a map so that it can be stored as an attribute
+func (l Limit) ToMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"name": l.Name,
+		"soft": l.Soft,
+		"hard": l.Hard,
+	}
+
+	if l.Units != "" {
+		m["units"] = l.Units
+	}
+
+	return m
+}
+
+// limitRegex Matches a line of /proc/{pid}/limits e.g.
+//
+//	Max open files            1024                 524288               files
+var limitRegex = regexp.MustCompile(`^(?P<name>Max [a-z ]+?)\s{2,}(?P<soft>\S+)\s+(?P<hard>\S+)\s*(?P<units>\S*)\s*$`)
+
+// Limits Reads /proc/{pid}/limits for a given process
+func (p ProcFS) Limits(pid int) ([]Limit, error) {
+	file, err := os.Open(p.PIDPath(pid, "limits"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseLimits(file)
+}
+
+// ParseLimits Parses the contents of a /proc/{pid}/limits file. The header
+// line is ignored
+func ParseLimits(r io.Reader) ([]Limit, error) {
+	limits := make([]Limit, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		matches := limitRegex.FindStringSubmatch(scanner.Text())
+
+		if matches == nil {
+			continue
+		}
+
+		limits = append(limits, Limit{
+			Name:  matches[1],
+			Soft:  matches[2],
+			Hard:  matches[3],
+			Units: matches[4],
+		})
+	}
+
+	return limits, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package procfs
+
+import (
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	limits, err := testProc.Limits(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(limits) != 16 {
+		t.Fatalf("expected 16 limits, got %v: %v", len(limits), limits)
+	}
+
+	expected := map[string]Limit{
+		"Max open files": {
+			Name:  "Max open files",
+			Soft:  "20000",
+			Hard:  "20000",
+			Units: "files",
+		},
+		"Max cpu time": {
+			Name:  "Max cpu time",
+			Soft:  "unlimited",
+			Hard:  "unlimited",
+			Units: "seconds",
+		},
+		"Max nice priority": {
+			Name: "Max nice priority",
+			Soft: "0",
+			Hard: "0",
+		},
+	}
+
+	for _, limit := range limits {
+		if e, ok := expected[limit.Name]; ok {
+			if e != limit {
+				t.Errorf("expected %v, got %v", e, limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package procfs
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Mapping A single memory mapping from /proc/{pid}/maps
+type Mapping struct {
+	// The permissions of the mapping e.g. r-xp
+	Perms string
+
+	// The inode of the file that is mapped, 0 for anonymous mappings
+	Inode uint64
+
+	// The path of the mapped file. Pseudo-paths such as [heap] are also
+	// included. The " (deleted)" suffix is removed
+	Path string
+
+	// Whether the file has been deleted (or replaced) since it was mapped
+	Deleted bool
+}
+
+// deletedSuffix The suffix that the kernel adds to paths which have been
+// deleted since they were opened
+const deletedSuffix = " (deleted)"
+
+// IsFile Returns whether the mapping is backed by a real file on disk, as
+// opposed to an anonymous mapping, shared memory or a pseudo-path such as
+// [stack]
+func (m Mapping) IsFile() bool {
+	if !strings.HasPrefix(m.Path, "/") {
+		return false
+	}
+
+	for _, prefix := range []string{"/memfd:", "/SYSV", "/dev/", "/[aio]", "/anon_hugepage"} {
+		if strings.HasPrefix(m.Path, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsExecutable Returns whether the mapping contains code, i.e. it is part of an
+// executable or shared object that has been loaded rather than data that has
+// been mapped into memory
+func (m Mapping) IsExecutable() bool {
+	return len(m.Perms) > 2 && m.Perms[2] == 'x'
+}
+
+// Maps Reads /proc/{pid}/maps for a given process
+func (p ProcFS) Maps(pid int) ([]Mapping, error) {
+	file, err := os.Open(p.PIDPath(pid, "maps"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseMaps(file)
+}
+
+// ParseMaps Parses the contents of a /proc/{pid}/maps file. The format of each
+// line is:
+//
+//	address           perms offset  dev   inode       pathname
+//	00400000-00452000 r-xp 00000000 08:02 173521      /usr/bin/dbus-daemon
+func ParseMaps(r io.Reader) ([]Mapping, error) {
+	mappings := make([]Mapping, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		// Split into the 5 fixed fields and the path, which can contain spaces
+		fields := strings.SplitN(scanner.Text(), " ", 6)
+
+		if len(fields) < 5 {
+			continue
+		}
+
+		m := Mapping{
+			Perms: fields[1],
+		}
+
+		m.Inode, _ = parseUint(fields[4])
+
+		if len(fields) == 6 {
+			m.Path = strings.TrimLeft(fields[5], " ")
+
+			if strings.HasSuffix(m.Path, deletedSuffix) {
+				m.Path = strings.TrimSuffix(m.Path, deletedSuffix)
+				m.Deleted = true
+			}
+		}
+
+		mappings = append(mappings, m)
+	}
+
+	return mappings, scanner.Err()
+}
+
+// Exe Returns the path of the executable of a process, and whether it has been
+// deleted or replaced since the process was started
+func (p ProcFS) Exe(pid int) (string, bool, error) {
+	target, err := os.Readlink(p.PIDPath(pid, "exe"))
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if strings.HasSuffix(target, deletedSuffix) {
+		return strings.TrimSuffix(target, deletedSuffix), true, nil
+	}
+
+	return target, false, nil
+}
+
+// StaleFiles Returns the distinct, sorted list of on-disk files that a process
+// is running from that have been deleted or replaced since they were loaded.
+// This includes the executable itself and any mapped libraries. This usually
+// happens after a package upgrade and means that the process needs to be
+// restarted to pick up the new version. Only executable mappings are counted
+// since processes often map data files (e.g. shared memory or temporary
+// files) and then delete them on purpose
+func (p ProcFS) StaleFiles(pid int) ([]string, error) {
+	stale := make(map[string]bool)
+
+	exe, deleted, err := p.Exe(pid)
+
+	if err == nil && deleted {
+		stale[exe] = true
+	}
+
+	mappings, err := p.Maps(pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mappings {
+		if m.Deleted && m.IsFile() && (m.IsExecutable() || m.Path == exe) {
+			stale[m.Path] = true
+		}
+	}
+
+	return sortedKeys(stale), nil
+}
+
+// sortedKeys Returns the keys of a map in sorted order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// sharedObjectRegex Matches the paths of shared objects e.g. libc.so.6 or
+// libfoo-1.2.so
+var sharedObjectRegex = regexp.MustCompile(`\.so(\.[0-9.]+)?$`)
+
+// Libraries Returns the distinct, sorted list of shared objects that a process
+// has mapped
+func (p ProcFS) Libraries(pid int) ([]string, error) {
+	libraries := make(map[string]bool)
+
+	mappings, err := p.Maps(pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mappings {
+		if m.IsFile() && sharedObjectRegex.MatchString(m.Path) {
+			libraries[m.Path] = true
+		}
+	}
+
+	return sortedKeys(libraries), nil
+}
@@ -0,0 +1,105 @@
+package procfs
+
+import (
+	"testing"
+)
+
+func TestMaps(t *testing.T) {
+	mappings, err := testProc.Maps(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mappings) != 19 {
+		t.Fatalf("expected 19 mappings, got %v", len(mappings))
+	}
+
+	if m := mappings[0]; m.Path != "/usr/sbin/nginx" || !m.Deleted || m.Perms != "r--p" || m.Inode != 1835123 {
+		t.Errorf("unexpected first mapping: %+v", m)
+	}
+
+	if m := mappings[1]; !m.IsExecutable() {
+		t.Errorf("expected text mapping to be executable: %+v", m)
+	}
+
+	if m := mappings[3]; m.Path != "[heap]" || m.IsFile() {
+		t.Errorf("expected heap to not be a file: %+v", m)
+	}
+
+	if m := mappings[11]; m.Path != "/var/lib/nginx/cache/my file.bin" || m.Deleted {
+		t.Errorf("expected path with spaces to be parsed: %+v", m)
+	}
+}
+
+func TestExe(t *testing.T) {
+	exe, deleted, err := testProc.Exe(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exe != "/usr/sbin/nginx" || !deleted {
+		t.Errorf("expected deleted /usr/sbin/nginx, got %v deleted=%v", exe, deleted)
+	}
+
+	exe, deleted, err = testProc.Exe(43)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exe != "/usr/bin/bash" || deleted {
+		t.Errorf("expected /usr/bin/bash, got %v deleted=%v", exe, deleted)
+	}
+}
+
+func TestStaleFiles(t *testing.T) {
+	stale, err := testProc.StaleFiles(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleted data files that are mapped without execute permission, such as
+	// /var/lib/nginx/tmp/0000000001, aren't stale
+	expected := []string{
+		"/usr/lib/x86_64-linux-gnu/libssl.so.3",
+		"/usr/sbin/nginx",
+	}
+
+	if len(stale) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, stale)
+	}
+
+	for i, f := range stale {
+		if f != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], f)
+		}
+	}
+}
+
+func TestLibraries(t *testing.T) {
+	libraries, err := testProc.Libraries(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"/usr/lib/x86_64-linux-gnu/ld-linux-x86-64.so.2",
+		"/usr/lib/x86_64-linux-gnu/libc.so.6",
+		"/usr/lib/x86_64-linux-gnu/libpcre2-8.so.0.11.2",
+		"/usr/lib/x86_64-linux-gnu/libssl.so.3",
+	}
+
+	if len(libraries) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, libraries)
+	}
+
+	for i, l := range libraries {
+		if l != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], l)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package procfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// NamespaceSource Returns details of the Linux namespaces that processes are
+// running in. This allows processes that share a namespace (e.g. all processes
+// in the same container share a network namespace) to be linked together
+type NamespaceSource struct {
+	// Where procfs is mounted if not the default location (optional)
+	ProcLocation string
+}
+
+// Proc Returns the ProcFS that should be used by the source
+func (s *NamespaceSource) Proc() ProcFS {
+	return ProcFS{Location: s.ProcLocation}
+}
+
+// Type is the type of items that this returns (Required)
+func (s *NamespaceSource) Type() string {
+	return "namespace"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *NamespaceSource) Name() string {
+	return "procfs"
+}
+
+// Weighting of duplicate sources
+func (s *NamespaceSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *NamespaceSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// NamespaceID Returns the unique ID of a namespace in the format
+// {type}:{inode} e.g. net:4026531992. This is the format that is used as the
+// query for Get()
+func NamespaceID(nsType string, inode uint64) string {
+	return fmt.Sprintf("%v:%v", nsType, inode)
+}
+
+// parseNamespaceID Parses a namespace ID as returned by NamespaceID
+func parseNamespaceID(id string) (string, uint64, error) {
+	nsType, inodeString, found := strings.Cut(id, ":")
+
+	if !found {
+		return "", 0, fmt.Errorf("namespace %v is not in the format {type}:{inode}", id)
+	}
+
+	inode, err := strconv.ParseUint(strings.Trim(inodeString, "[]"), 10, 64)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("could not parse namespace inode %v: %v", inodeString, err)
+	}
+
+	return nsType, inode, nil
+}
+
+// Get Gets a namespace by its ID e.g. net:4026531992
+func (s *NamespaceSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	nsType, inode, err := parseNamespaceID(query)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	namespaces, err := s.namespacePIDs(ctx, nsType)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	pids, ok := namespaces[inode]
+
+	if !ok {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("namespace %v not found", query),
+			Context:     itemContext,
+		}
+	}
+
+	return s.namespaceToItem(nsType, inode, pids)
+}
+
+// Find Returns all namespaces that have at least one visible process in them
+func (s *NamespaceSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	all, err := s.Proc().AllNamespacePIDs(ctx, NamespaceTypes...)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, nsType := range NamespaceTypes {
+		for inode, pids := range all[nsType] {
+			if item, err := s.namespaceToItem(nsType, inode, pids); err == nil {
+				items = append(items, item)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// Search Accepts a PID and returns all namespaces that the process is in
+func (s *NamespaceSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	pid, err := strconv.Atoi(query)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: fmt.Sprintf("PID could not be converted to integer, encountered error: %v", err),
+			Context:     itemContext,
+		}
+	}
+
+	processNamespaces, err := s.Proc().Namespaces(pid)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	// Read the members of all of the process' namespaces in one pass over
+	// /proc rather than once for each namespace
+	nsTypes := make([]string, 0, len(processNamespaces))
+
+	for _, nsType := range NamespaceTypes {
+		if _, ok := processNamespaces[nsType]; ok {
+			nsTypes = append(nsTypes, nsType)
+		}
+	}
+
+	all, err := s.Proc().AllNamespacePIDs(ctx, nsTypes...)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, nsType := range nsTypes {
+		inode := processNamespaces[nsType]
+
+		if item, err := s.namespaceToItem(nsType, inode, all[nsType][inode]); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether namespaces can be read on this system
+func (s *NamespaceSource) Supported() bool {
+	_, err := os.Stat(s.Proc().Path("self", "ns"))
+
+	return err == nil
+}
+
+// namespacePIDs Returns a map of namespace inode to the PIDs that are in that
+// namespace, for a given type of namespace
+func (s *NamespaceSource) namespacePIDs(ctx context.Context, nsType string) (map[uint64][]int, error) {
+	return s.Proc().NamespacePIDs(ctx, nsType)
+}
+
+// namespaceToItem Converts a namespace and its PIDs to an item
+func (s *NamespaceSource) namespaceToItem(nsType string, inode uint64, pids []int) (*sdp.Item, error) {
+	sort.Ints(pids)
+
+	attrs := map[string]interface{}{
+		"id":           NamespaceID(nsType, inode),
+		"type":         nsType,
+		"inode":        inode,
+		"pids":         pids,
+		"numProcesses": len(pids),
+	}
+
+	// PID 1 always lives in the host's namespaces, unless the agent is itself
+	// running inside a container
+	if initInode, err := s.Proc().Namespace(1, nsType); err == nil {
+		attrs["host"] = (initInode == inode)
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:               "namespace",
+		UniqueAttribute:    "id",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: make([]*sdp.ItemRequest, 0),
+	}
+
+	for _, pid := range pids {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "process",
+			Method:  sdp.RequestMethod_GET,
+			Query:   strconv.Itoa(pid),
+			Context: util.LocalContext,
+		})
+	}
+
+	return &item, nil
+}
@@ -0,0 +1,116 @@
+package procfs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestNamespaceSource(t *testing.T) {
+	source := NamespaceSource{
+		ProcLocation: "test/proc",
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get shared netns",
+			ItemContext: util.LocalContext,
+			Query:       "net:4026532281",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"type":         "net",
+						"numProcesses": float64(2),
+						"host":         false,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get host netns",
+			ItemContext: util.LocalContext,
+			Query:       "net:4026531992",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"host": true,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing namespace",
+			ItemContext: util.LocalContext,
+			Query:       "net:1",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "get bad query",
+			ItemContext: util.LocalContext,
+			Query:       "foo",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_OTHER,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				// Every type is shared except for net
+				NumItems: len(NamespaceTypes) + 1,
+			},
+		},
+		{
+			Name:        "search by pid",
+			ItemContext: util.LocalContext,
+			Query:       "43",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: len(NamespaceTypes),
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestNamespaceLinks(t *testing.T) {
+	source := NamespaceSource{
+		ProcLocation: "test/proc",
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "net:4026532281")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 2 {
+		t.Fatalf("expected 2 linked processes, got %v", len(item.LinkedItemRequests))
+	}
+
+	for i, pid := range []string{"42", "43"} {
+		if item.LinkedItemRequests[i].Query != pid {
+			t.Errorf("expected link to process %v, got %v", pid, item.LinkedItemRequests[i].Query)
+		}
+	}
+}
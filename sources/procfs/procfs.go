@@ -0,0 +1,216 @@
+package procfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// This package reads process information directly from procfs. It is used by
+// sources that need details that aren't exposed by gopsutil. Everything in here
+// will simply return errors on systems that don't have a procfs mount, so it is
+// safe to call from code that compiles on all platforms
+
+// DefaultProcLocation The default location where procfs is mounted
+const DefaultProcLocation = "/proc"
+
+// ProcFS Reads information from a procfs mount
+type ProcFS struct {
+	// Where procfs is mounted if not the default location (optional)
+	Location string
+}
+
+// Path Returns the path to a file within procfs
+func (p ProcFS) Path(elem ...string) string {
+	location := p.Location
+
+	if location == "" {
+		location = DefaultProcLocation
+	}
+
+	return filepath.Join(append([]string{location}, elem...)...)
+}
+
+// PIDPath Returns the path to a file within the procfs directory of a given
+// process
+func (p ProcFS) PIDPath(pid int, elem ...string) string {
+	return p.Path(append([]string{strconv.Itoa(pid)}, elem...)...)
+}
+
+// PIDs Returns the PIDs of all processes that are currently visible, sorted
+// in ascending order
+func (p ProcFS) PIDs() ([]int, error) {
+	entries, err := os.ReadDir(p.Path())
+
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0)
+
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	sort.Ints(pids)
+
+	return pids, nil
+}
+
+// NamespaceTypes The types of namespace that are reported for each process
+var NamespaceTypes = []string{
+	"cgroup",
+	"ipc",
+	"mnt",
+	"net",
+	"pid",
+	"user",
+	"uts",
+}
+
+// nsLinkRegex Matches the target of a /proc/{pid}/ns/* symlink e.g.
+// net:[4026531992]
+var nsLinkRegex = regexp.MustCompile(`^(?P<type>\w+):\[(?P<inode>\d+)\]$`)
+
+// Namespace Returns the inode of the namespace of a given type that a process
+// belongs to
+func (p ProcFS) Namespace(pid int, nsType string) (uint64, error) {
+	target, err := os.Readlink(p.PIDPath(pid, "ns", nsType))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return parseNamespaceLink(target)
+}
+
+// SelfNamespace Returns the inode of the namespace of a given type that the
+// calling process (i.e. the agent) belongs to
+func (p ProcFS) SelfNamespace(nsType string) (uint64, error) {
+	target, err := os.Readlink(p.Path("self", "ns", nsType))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return parseNamespaceLink(target)
+}
+
+// Root Returns the path through which the root filesystem of a process can be
+// accessed, or an empty string if it is the same as the agent's. Processes in
+// containers have their own mount namespace, and since the root link of a
+// container that uses pivot_root usually still reads "/" the mount namespaces
+// are compared. Processes that have been chrooted are in the same mount
+// namespace but their root link is different
+func (p ProcFS) Root(pid int) string {
+	root := p.PIDPath(pid, "root")
+	target, err := os.Readlink(root)
+
+	if err != nil {
+		return ""
+	}
+
+	if target != "/" {
+		return root
+	}
+
+	mnt, err := p.Namespace(pid, "mnt")
+
+	if err != nil {
+		return ""
+	}
+
+	if self, err := p.SelfNamespace("mnt"); err == nil && self != mnt {
+		return root
+	}
+
+	return ""
+}
+
+// Namespaces Returns the inodes of all namespaces in NamespaceTypes that the
+// process belongs to. Namespaces that can't be read (usually due to
+// permissions, or because the kernel doesn't support them) are omitted
+func (p ProcFS) Namespaces(pid int) (map[string]uint64, error) {
+	namespaces := make(map[string]uint64)
+
+	for _, nsType := range NamespaceTypes {
+		if inode, err := p.Namespace(pid, nsType); err == nil {
+			namespaces[nsType] = inode
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return nil, fmt.Errorf("could not read any namespaces for pid %v", pid)
+	}
+
+	return namespaces, nil
+}
+
+// NamespacePIDs Returns a map of namespace inode to the PIDs that are in that
+// namespace, for a given type of namespace. Processes that can't be read are
+// ignored
+func (p ProcFS) NamespacePIDs(ctx context.Context, nsType string) (map[uint64][]int, error) {
+	namespaces, err := p.AllNamespacePIDs(ctx, nsType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return namespaces[nsType], nil
+}
+
+// AllNamespacePIDs Returns the same as NamespacePIDs for several types of
+// namespace, keyed by type, while only reading each process once. Every type
+// that is asked for is in the result even if no processes are in a namespace
+// of that type
+func (p ProcFS) AllNamespacePIDs(ctx context.Context, nsTypes ...string) (map[string]map[uint64][]int, error) {
+	pids, err := p.PIDs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]map[uint64][]int)
+
+	for _, nsType := range nsTypes {
+		namespaces[nsType] = make(map[uint64][]int)
+	}
+
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		// Processes can disappear while we are looking at them, and we might
+		// not have permission to read some of them, therefore ignore errors
+		for _, nsType := range nsTypes {
+			if inode, err := p.Namespace(pid, nsType); err == nil {
+				namespaces[nsType][inode] = append(namespaces[nsType][inode], pid)
+			}
+		}
+	}
+
+	return namespaces, nil
+}
+
+// parseNamespaceLink Parses the target of a namespace symlink into its inode
+func parseNamespaceLink(target string) (uint64, error) {
+	matches := nsLinkRegex.FindStringSubmatch(target)
+
+	if matches == nil {
+		return 0, fmt.Errorf("could not parse namespace link %v", target)
+	}
+
+	return parseUint(matches[2])
+}
+
+// parseUint Parses a base 10 unsigned integer
+func parseUint(s string) (uint64, error) {
+	return strconv.ParseUint(s, 10, 64)
+}
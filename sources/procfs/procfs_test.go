@@ -0,0 +1,110 @@
+package procfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testProc = ProcFS{Location: "test/proc"}
+
+func TestPIDs(t *testing.T) {
+	pids, err := testProc.PIDs()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{1, 42, 43, 44}
+
+	if len(pids) != len(expected) {
+		t.Fatalf("expected pids %v, got %v", expected, pids)
+	}
+
+	for i, pid := range pids {
+		if pid != expected[i] {
+			t.Errorf("expected pid %v, got %v", expected[i], pid)
+		}
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	namespaces, err := testProc.Namespaces(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(namespaces) != len(NamespaceTypes) {
+		t.Errorf("expected %v namespaces, got %v", len(NamespaceTypes), len(namespaces))
+	}
+
+	if namespaces["net"] != 4026532281 {
+		t.Errorf("expected net namespace 4026532281, got %v", namespaces["net"])
+	}
+
+	_, err = testProc.Namespaces(891726304)
+
+	if err == nil {
+		t.Error("expected error for non-existent pid")
+	}
+}
+
+func TestRoot(t *testing.T) {
+	proc := ProcFS{Location: t.TempDir()}
+
+	links := map[string]string{
+		"self/ns/mnt": "mnt:[4026531840]",
+		"1/root":      "/",
+		"1/ns/mnt":    "mnt:[4026531840]",
+		"2/root":      "/",
+		"2/ns/mnt":    "mnt:[4026532500]",
+		"3/root":      "/srv/chroot",
+		"3/ns/mnt":    "mnt:[4026531840]",
+	}
+
+	for link, target := range links {
+		path := proc.Path(link)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[int]string{
+		// Same mount namespace and root as the agent
+		1: "",
+		// A container that has used pivot_root, whose root link reads "/"
+		2: proc.PIDPath(2, "root"),
+		// Chrooted
+		3: proc.PIDPath(3, "root"),
+		// Doesn't exist
+		4: "",
+	}
+
+	for pid, expected := range tests {
+		if root := proc.Root(pid); root != expected {
+			t.Errorf("pid %v: expected root %q, got %q", pid, expected, root)
+		}
+	}
+}
+
+func TestParseNamespaceLink(t *testing.T) {
+	inode, err := parseNamespaceLink("net:[4026531992]")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inode != 4026531992 {
+		t.Errorf("expected 4026531992, got %v", inode)
+	}
+
+	if _, err = parseNamespaceLink("/some/file"); err == nil {
+		t.Error("expected error parsing bad link")
+	}
+}
@@ -0,0 +1,158 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Status Security-relevant details from /proc/{pid}/status
+type Status struct {
+	// Capability sets, decoded to their names e.g. CAP_NET_ADMIN
+	CapEffective []string
+	CapPermitted []string
+	CapBounding  []string
+
+	// The seccomp mode of the process: disabled, strict or filter
+	Seccomp string
+
+	// Whether the no_new_privs bit is set
+	NoNewPrivs bool
+}
+
+// capabilityNames Names of the capabilities, indexed by their bit number. See
+// capabilities(7)
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// seccompModes Names of the values of the Seccomp field
+var seccompModes = map[string]string{
+	"0": "disabled",
+	"1": "strict",
+	"2": "filter",
+}
+
+// DecodeCapabilities Decodes a hex capability mask as found in
+// /proc/{pid}/status into a list of capability names. Bits that this agent
+// doesn't know the name of are returned as e.g. CAP_41
+func DecodeCapabilities(mask string) ([]string, error) {
+	bits, err := strconv.ParseUint(mask, 16, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse capability mask %v: %v", mask, err)
+	}
+
+	names := make([]string, 0)
+
+	for i := 0; i < 64; i++ {
+		if bits&(1<<uint(i)) == 0 {
+			continue
+		}
+
+		if i < len(capabilityNames) {
+			names = append(names, capabilityNames[i])
+		} else {
+			names = append(names, fmt.Sprintf("CAP_%v", i))
+		}
+	}
+
+	return names, nil
+}
+
+// Status Reads /proc/{pid}/status for a given process
+func (p ProcFS) Status(pid int) (Status, error) {
+	file, err := os.Open(p.PIDPath(pid, "status"))
+
+	if err != nil {
+		return Status{}, err
+	}
+
+	defer file.Close()
+
+	return ParseStatus(file)
+}
+
+// ParseStatus Parses the contents of a /proc/{pid}/status file
+func ParseStatus(r io.Reader) (Status, error) {
+	var status Status
+	var err error
+
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+
+		if !found {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "CapEff":
+			status.CapEffective, err = DecodeCapabilities(value)
+		case "CapPrm":
+			status.CapPermitted, err = DecodeCapabilities(value)
+		case "CapBnd":
+			status.CapBounding, err = DecodeCapabilities(value)
+		case "Seccomp":
+			if mode, ok := seccompModes[value]; ok {
+				status.Seccomp = mode
+			} else {
+				status.Seccomp = value
+			}
+		case "NoNewPrivs":
+			status.NoNewPrivs = (value == "1")
+		}
+
+		if err != nil {
+			return Status{}, err
+		}
+	}
+
+	return status, scanner.Err()
+}
@@ -0,0 +1,59 @@
+package procfs
+
+import (
+	"testing"
+)
+
+func TestDecodeCapabilities(t *testing.T) {
+	caps, err := DecodeCapabilities("0000000000003000")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(caps) != 2 || caps[0] != "CAP_NET_ADMIN" || caps[1] != "CAP_NET_RAW" {
+		t.Errorf("expected [CAP_NET_ADMIN CAP_NET_RAW], got %v", caps)
+	}
+
+	caps, err = DecodeCapabilities("0000020000000000")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(caps) != 1 || caps[0] != "CAP_41" {
+		t.Errorf("expected [CAP_41], got %v", caps)
+	}
+
+	if _, err = DecodeCapabilities("notHex"); err == nil {
+		t.Error("expected error decoding bad mask")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	status, err := testProc.Status(42)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status.CapEffective) != 1 || status.CapEffective[0] != "CAP_NET_BIND_SERVICE" {
+		t.Errorf("expected effective capabilities [CAP_NET_BIND_SERVICE], got %v", status.CapEffective)
+	}
+
+	if len(status.CapPermitted) != 1 {
+		t.Errorf("expected 1 permitted capability, got %v", status.CapPermitted)
+	}
+
+	if len(status.CapBounding) != 41 {
+		t.Errorf("expected 41 bounding capabilities, got %v", len(status.CapBounding))
+	}
+
+	if status.Seccomp != "filter" {
+		t.Errorf("expected seccomp mode filter, got %v", status.Seccomp)
+	}
+
+	if !status.NoNewPrivs {
+		t.Error("expected NoNewPrivs to be set")
+	}
+}
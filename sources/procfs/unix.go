@@ -0,0 +1,192 @@
+package procfs
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// UnixSocket A single Unix domain socket from /proc/net/unix
+type UnixSocket struct {
+	Inode uint64
+
+	// Path that the socket is bound to. Abstract sockets start with @, and
+	// unbound sockets have an empty path
+	Path string
+
+	// The type of socket: stream, dgram or seqpacket
+	Type string
+
+	// The state of the socket: unconnected, connecting, connected or
+	// disconnecting
+	State string
+
+	// Whether the socket is listening for connections
+	Listening bool
+}
+
+// Abstract Returns whether the socket is bound in the abstract namespace
+// rather than to a file on disk
+func (u UnixSocket) Abstract() bool {
+	return strings.HasPrefix(u.Path, "@")
+}
+
+// soAcceptCon The flag that is set on listening sockets
+const soAcceptCon = 0x00010000
+
+// unixSocketTypes Names of socket types, see socket(2)
+var unixSocketTypes = map[string]string{
+	"0001": "stream",
+	"0002": "dgram",
+	"0005": "seqpacket",
+}
+
+// unixSocketStates Names of socket states
+var unixSocketStates = map[string]string{
+	"01": "unconnected",
+	"02": "connecting",
+	"03": "connected",
+	"04": "disconnecting",
+}
+
+// UnixSockets Reads /proc/net/unix
+func (p ProcFS) UnixSockets() ([]UnixSocket, error) {
+	file, err := os.Open(p.Path("net", "unix"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseUnixSockets(file)
+}
+
+// ParseUnixSockets Parses the contents of /proc/net/unix. The format is:
+//
+//	Num       RefCount Protocol Flags    Type St Inode Path
+//	ffff8f1a3b2c4000: 00000002 00000000 00010000 0001 01 23456 /run/docker.sock
+func ParseUnixSockets(r io.Reader) ([]UnixSocket, error) {
+	sockets := make([]UnixSocket, 0)
+	scanner := bufio.NewScanner(r)
+
+	// Discard the header
+	scanner.Scan()
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		if len(fields) < 7 {
+			continue
+		}
+
+		var socket UnixSocket
+		var err error
+
+		if socket.Inode, err = parseUint(fields[6]); err != nil {
+			continue
+		}
+
+		if flags, err := strconv.ParseUint(fields[3], 16, 32); err == nil {
+			socket.Listening = (flags&soAcceptCon != 0)
+		}
+
+		socket.Type = unixSocketTypes[fields[4]]
+		socket.State = unixSocketStates[fields[5]]
+
+		if socket.Type == "" {
+			socket.Type = fields[4]
+		}
+
+		if socket.State == "" {
+			socket.State = fields[5]
+		}
+
+		// The path can contain spaces so take everything after the fixed
+		// fields rather than using the split fields
+		socket.Path = skipFields(scanner.Text(), 7)
+
+		sockets = append(sockets, socket)
+	}
+
+	return sockets, scanner.Err()
+}
+
+// SocketOwners Returns a map of socket inode to the PIDs of the processes that
+// have that socket open, by looking at the file descriptors in /proc/{pid}/fd.
+// Processes whose file descriptors can't be read (usually due to permissions)
+// are ignored
+func (p ProcFS) SocketOwners(ctx context.Context) (map[uint64][]int, error) {
+	pids, err := p.PIDs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[uint64][]int)
+
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		for _, inode := range p.socketInodes(pid) {
+			// A process can have the same socket open more than once
+			if o := owners[inode]; len(o) > 0 && o[len(o)-1] == pid {
+				continue
+			}
+
+			owners[inode] = append(owners[inode], pid)
+		}
+	}
+
+	return owners, nil
+}
+
+// socketInodes Returns the inodes of all sockets that a process has open
+func (p ProcFS) socketInodes(pid int) []uint64 {
+	fdDir := p.PIDPath(pid, "fd")
+
+	entries, err := os.ReadDir(fdDir)
+
+	if err != nil {
+		return nil
+	}
+
+	inodes := make([]uint64, 0)
+
+	for _, entry := range entries {
+		target, err := os.Readlink(fdDir + "/" + entry.Name())
+
+		if err != nil || !strings.HasPrefix(target, "socket:[") {
+			continue
+		}
+
+		if inode, err := parseUint(strings.TrimSuffix(strings.TrimPrefix(target, "socket:["), "]")); err == nil {
+			inodes = append(inodes, inode)
+		}
+	}
+
+	return inodes
+}
+
+// skipFields Returns the remainder of a line after skipping n
+// whitespace-separated fields
+func skipFields(line string, n int) string {
+	remainder := strings.TrimLeft(line, " \t")
+
+	for i := 0; i < n; i++ {
+		end := strings.IndexAny(remainder, " \t")
+
+		if end < 0 {
+			return ""
+		}
+
+		remainder = strings.TrimLeft(remainder[end:], " \t")
+	}
+
+	return remainder
+}
@@ -0,0 +1,68 @@
+package procfs
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUnixSockets(t *testing.T) {
+	sockets, err := testProc.UnixSockets()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sockets) != 7 {
+		t.Fatalf("expected 7 sockets, got %v", len(sockets))
+	}
+
+	docker := sockets[0]
+
+	if docker.Path != "/run/docker.sock" || !docker.Listening || docker.Type != "stream" || docker.State != "unconnected" || docker.Inode != 23456 {
+		t.Errorf("unexpected docker socket: %+v", docker)
+	}
+
+	if x11 := sockets[3]; !x11.Abstract() || x11.Path != "@/tmp/.X11-unix/X0" {
+		t.Errorf("expected abstract X11 socket, got %+v", x11)
+	}
+
+	if journal := sockets[4]; journal.Type != "dgram" || journal.Listening {
+		t.Errorf("expected non-listening dgram socket, got %+v", journal)
+	}
+
+	if unbound := sockets[5]; unbound.Path != "" {
+		t.Errorf("expected unbound socket to have no path, got %v", unbound.Path)
+	}
+
+	if spaces := sockets[6]; spaces.Path != "/run/my app/seq packet.sock" || spaces.Type != "seqpacket" {
+		t.Errorf("expected path with spaces to be parsed, got %+v", spaces)
+	}
+}
+
+func TestSocketOwners(t *testing.T) {
+	owners, err := testProc.SocketOwners(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[uint64]int{
+		23456: 42,
+		23501: 42,
+		23502: 43,
+		24001: 43,
+		50001: 42,
+		50002: 43,
+		50004: 42,
+	}
+
+	if len(owners) != len(expected) {
+		t.Errorf("expected %v owned sockets, got %v", len(expected), owners)
+	}
+
+	for inode, pid := range expected {
+		if pids := owners[inode]; len(pids) != 1 || pids[0] != pid {
+			t.Errorf("expected socket %v to be owned by %v, got %v", inode, pid, pids)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package procfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// UnixSocketSource Returns details of bound Unix domain sockets e.g.
+// /run/docker.sock. Sockets are resolved to the processes that own them by
+// matching socket inodes to the file descriptors of each process
+type UnixSocketSource struct {
+	// Where procfs is mounted if not the default location (optional)
+	ProcLocation string
+}
+
+// Proc Returns the ProcFS that should be used by the source
+func (s *UnixSocketSource) Proc() ProcFS {
+	return ProcFS{Location: s.ProcLocation}
+}
+
+// Type is the type of items that this returns (Required)
+func (s *UnixSocketSource) Type() string {
+	return "unixsocket"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *UnixSocketSource) Name() string {
+	return "procfs"
+}
+
+// Weighting of duplicate sources
+func (s *UnixSocketSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *UnixSocketSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets a socket by its path. Abstract sockets are prefixed with @
+func (s *UnixSocketSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	items, err := s.Search(ctx, itemContext, "*")
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.UniqueAttributeValue() == query {
+			return item, nil
+		}
+	}
+
+	return nil, &sdp.ItemRequestError{
+		ErrorType:   sdp.ItemRequestError_NOTFOUND,
+		ErrorString: fmt.Sprintf("unix socket %v not found", query),
+		Context:     itemContext,
+	}
+}
+
+// Find Returns all bound Unix domain sockets
+func (s *UnixSocketSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	return s.Search(ctx, itemContext, "*")
+}
+
+// Search Accepts a PID and returns the sockets that the process owns. A "*"
+// can also be passed which will return all items
+func (s *UnixSocketSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	isWildcard := (query == "*")
+	var pid int
+	var err error
+
+	if !isWildcard {
+		pid, err = strconv.Atoi(query)
+
+		if err != nil {
+			return nil, &sdp.ItemRequestError{
+				ErrorType:   sdp.ItemRequestError_OTHER,
+				ErrorString: fmt.Sprintf("PID could not be converted to integer, encountered error: %v", err),
+				Context:     itemContext,
+			}
+		}
+	}
+
+	sockets, err := s.Proc().UnixSockets()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	owners, err := s.Proc().SocketOwners(ctx)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, group := range groupUnixSockets(sockets) {
+		pids := group.PIDs(owners)
+
+		if !isWildcard && !containsInt(pids, pid) {
+			continue
+		}
+
+		if item, err := group.ToItem(pids); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether /proc/net/unix can be read on this system
+func (s *UnixSocketSource) Supported() bool {
+	_, err := os.Stat(s.Proc().Path("net", "unix"))
+
+	return err == nil
+}
+
+// unixSocketGroup All of the sockets that are bound to the same path. When a
+// listening socket accepts a connection the new socket has the same path,
+// therefore these are grouped into a single item
+type unixSocketGroup struct {
+	Path string
+
+	// The socket that represents the group. This is the listening socket if
+	// there is one, otherwise the first one found
+	Primary UnixSocket
+
+	// Number of sockets with this path in the connected state
+	Connections int
+}
+
+// PIDs Returns the PIDs of the processes that own the primary socket
+func (g unixSocketGroup) PIDs(owners map[uint64][]int) []int {
+	pids := owners[g.Primary.Inode]
+
+	if pids == nil {
+		return []int{}
+	}
+
+	return pids
+}
+
+// ToItem Converts the group to an item
+func (g unixSocketGroup) ToItem(pids []int) (*sdp.Item, error) {
+	attributes, err := sdp.ToAttributes(map[string]interface{}{
+		"path":        g.Path,
+		"type":        g.Primary.Type,
+		"state":       g.Primary.State,
+		"listening":   g.Primary.Listening,
+		"abstract":    g.Primary.Abstract(),
+		"inode":       g.Primary.Inode,
+		"connections": g.Connections,
+		"pids":        pids,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:               "unixsocket",
+		UniqueAttribute:    "path",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: make([]*sdp.ItemRequest, 0),
+	}
+
+	// Abstract sockets don't exist on the filesystem
+	if !g.Primary.Abstract() {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "file",
+			Method:  sdp.RequestMethod_GET,
+			Query:   g.Path,
+			Context: util.LocalContext,
+		})
+	}
+
+	for _, pid := range pids {
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "process",
+			Method:  sdp.RequestMethod_GET,
+			Query:   strconv.Itoa(pid),
+			Context: util.LocalContext,
+		})
+	}
+
+	return &item, nil
+}
+
+// groupUnixSockets Groups bound sockets by their path. Unbound sockets are
+// ignored
+func groupUnixSockets(sockets []UnixSocket) []unixSocketGroup {
+	groups := make(map[string]*unixSocketGroup)
+
+	for _, socket := range sockets {
+		if socket.Path == "" {
+			continue
+		}
+
+		group, ok := groups[socket.Path]
+
+		if !ok {
+			group = &unixSocketGroup{
+				Path:    socket.Path,
+				Primary: socket,
+			}
+			groups[socket.Path] = group
+		} else if socket.Listening && !group.Primary.Listening {
+			group.Primary = socket
+		}
+
+		if socket.State == "connected" {
+			group.Connections++
+		}
+	}
+
+	paths := make([]string, 0, len(groups))
+
+	for path := range groups {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	sorted := make([]unixSocketGroup, 0, len(paths))
+
+	for _, path := range paths {
+		sorted = append(sorted, *groups[path])
+	}
+
+	return sorted
+}
+
+// containsInt Returns whether a slice contains a given int
+func containsInt(s []int, i int) bool {
+	for _, v := range s {
+		if v == i {
+			return true
+		}
+	}
+
+	return false
+}
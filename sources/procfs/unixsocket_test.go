@@ -0,0 +1,119 @@
+package procfs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestUnixSocketSource(t *testing.T) {
+	source := UnixSocketSource{
+		ProcLocation: "test/proc",
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get docker socket",
+			ItemContext: util.LocalContext,
+			Query:       "/run/docker.sock",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"listening":   true,
+						"abstract":    false,
+						"type":        "stream",
+						"connections": float64(2),
+						"pids":        []interface{}{float64(42)},
+					},
+				},
+			},
+		},
+		{
+			Name:        "get abstract socket",
+			ItemContext: util.LocalContext,
+			Query:       "@/tmp/.X11-unix/X0",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"abstract": true,
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing socket",
+			ItemContext: util.LocalContext,
+			Query:       "/run/missing.sock",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 4,
+			},
+		},
+		{
+			Name:        "search by pid",
+			ItemContext: util.LocalContext,
+			Query:       "43",
+			Method:      sdp.RequestMethod_SEARCH,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"path": "@/tmp/.X11-unix/X0",
+					},
+				},
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestUnixSocketLinks(t *testing.T) {
+	source := UnixSocketSource{
+		ProcLocation: "test/proc",
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "@/tmp/.X11-unix/X0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lir := range item.LinkedItemRequests {
+		if lir.Type == "file" {
+			t.Errorf("abstract socket should not link to a file: %v", lir.Query)
+		}
+	}
+
+	item, err = source.Get(context.Background(), util.LocalContext, "/run/docker.sock")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(item.LinkedItemRequests) != 2 {
+		t.Errorf("expected links to file and process, got %v", item.LinkedItemRequests)
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/overmindtech/overmind-agent/sources/procfs"
 	"github.com/overmindtech/overmind-agent/sources/util"
 	"github.com/overmindtech/sdp-go"
 	"github.com/shirou/gopsutil/net"
@@ -16,7 +18,12 @@ import (
 )
 
 // ProcessSource struct on which all methods are registered
-type ProcessSource struct{}
+type ProcessSource struct {
+	// Whether to list the shared libraries that each process has mapped, and
+	// link them to their files and packages. This is off by default since it
+	// can generate a large number of linked items (optional)
+	Libraries bool
+}
 
 // Type is the type of items that this returns (Required)
 func (s *ProcessSource) Type() string {
@@ -88,6 +95,7 @@ func (s *ProcessSource) Get(ctx context.Context, itemContext string, query strin
 	// Process attributes
 	var cpuPercent float64
 	var cmdline string
+	var args []string
 	var connections []net.ConnectionStat
 	var createTime int64
 	var cwd string
@@ -134,41 +142,30 @@ func (s *ProcessSource) Get(ctx context.Context, itemContext string, query strin
 
 	if cmdline, err = p.CmdlineWithContext(ctx); err == nil {
 		attributes["cmdline"] = cmdline
+	}
 
-		// Try to parse out related files from the command line
-		args := strings.Split(cmdline, " ")
-
-		if len(args) > 1 {
-			// Loop over all but the first argument
-			for i := 1; i < len(args); i++ {
-				var path string
-
-				// Check if the file path is relative
-				if filepath.IsAbs(args[i]) {
-					path = args[i]
-				} else {
-					// If it is, join to the CWD to get the full path
-					path, err = filepath.Rel(cwd, args[i])
-
-					if err != nil {
-						// If we can't join the path it's probably it's not a
-						// real path, therefore ignore
-						continue
-					}
-				}
-
-				// Check if the file exists
-				_, err := os.Stat(path)
-
-				if err == nil {
-					item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
-						Type:    "file",
-						Method:  sdp.RequestMethod_GET,
-						Query:   path,
-						Context: itemContext,
-					})
-				}
+	if args, err = p.CmdlineSliceWithContext(ctx); err == nil {
+		// Try to parse out related files from the real argv so that quoted
+		// paths containing spaces are not split apart. Processes in a
+		// container or chroot are resolved through their own root so that
+		// the links point at the files that they actually use
+		files := cmdlineFiles(args, cwd, processRoot(p.Pid))
+
+		if len(files) > 0 {
+			argFiles := make([]interface{}, 0)
+
+			for _, f := range files {
+				argFiles = append(argFiles, f.ToMap())
+
+				item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+					Type:    "file",
+					Method:  sdp.RequestMethod_GET,
+					Query:   f.Path,
+					Context: itemContext,
+				})
 			}
+
+			attributes["cmdlineFiles"] = argFiles
 		}
 	}
 
@@ -195,7 +192,8 @@ func (s *ProcessSource) Get(ctx context.Context, itemContext string, query strin
 			Context: itemContext,
 		})
 
-		// Check what package provies the executable
+		// Check what package provies the executable. This also returns the Go
+		// modules or Rust crates that it was built from
 		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
 			Type:    "package",
 			Method:  sdp.RequestMethod_SEARCH,
@@ -311,6 +309,75 @@ func (s *ProcessSource) Get(ctx context.Context, itemContext string, query strin
 		})
 	}
 
+	// Security-relevant details that gopsutil doesn't expose, these come
+	// straight from procfs and will therefore only be present on Linux
+	proc := procfs.ProcFS{}
+
+	if procStatus, err := proc.Status(int(p.Pid)); err == nil {
+		attributes["capabilities"] = map[string]interface{}{
+			"effective": procStatus.CapEffective,
+			"permitted": procStatus.CapPermitted,
+			"bounding":  procStatus.CapBounding,
+		}
+		attributes["seccomp"] = procStatus.Seccomp
+		attributes["noNewPrivs"] = procStatus.NoNewPrivs
+	}
+
+	if namespaces, err := proc.Namespaces(int(p.Pid)); err == nil {
+		attributes["namespaces"] = namespaces
+
+		// Link to the namespaces so that processes sharing a namespace can be
+		// found
+		for _, nsType := range procfs.NamespaceTypes {
+			if inode, ok := namespaces[nsType]; ok {
+				item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+					Type:    "namespace",
+					Method:  sdp.RequestMethod_GET,
+					Query:   procfs.NamespaceID(nsType, inode),
+					Context: itemContext,
+				})
+			}
+		}
+	}
+
+	// Find files that the process is running from that have since been
+	// deleted or replaced, usually by a package upgrade
+	if stale, err := proc.StaleFiles(int(p.Pid)); err == nil {
+		attributes["needsRestart"] = stale
+	}
+
+	if s.Libraries {
+		if libraries, err := proc.Libraries(int(p.Pid)); err == nil {
+			attributes["libraries"] = libraries
+			item.LinkedItemRequests = append(item.LinkedItemRequests, libraryLinks(libraries, processRoot(p.Pid), itemContext)...)
+		}
+	}
+
+	// The archives on the classpath of a java process are its libraries. They
+	// are linked using a search since each one can contain many libraries
+	if classpath, err := proc.JavaClasspath(int(p.Pid)); err == nil {
+		attributes["classpath"] = classpath
+
+		for _, archive := range classpath {
+			item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "package",
+				Method:  sdp.RequestMethod_SEARCH,
+				Query:   archive,
+				Context: itemContext,
+			})
+		}
+	}
+
+	if limits, err := proc.Limits(int(p.Pid)); err == nil {
+		limitsInterface := make([]interface{}, 0)
+
+		for _, limit := range limits {
+			limitsInterface = append(limitsInterface, limit.ToMap())
+		}
+
+		attributes["limits"] = limitsInterface
+	}
+
 	// Save the attributes
 	item.Attributes, err = sdp.ToAttributes(attributes)
 
@@ -339,3 +406,174 @@ func (s *ProcessSource) Find(ctx context.Context, itemContext string) ([]*sdp.It
 
 	return make([]*sdp.Item, 0), nil
 }
+
+// CmdlineFile A file that was referenced by one of the arguments of a process
+type CmdlineFile struct {
+	// The argument that the file was discovered from, e.g. `--config=x.yaml`
+	Arg string
+
+	// The flag that the path was supplied to, if any e.g. `--config` or `-c`
+	Flag string
+
+	// The full path to the file as it can be seen by the agent
+	Path string
+}
+
+// ToMap Converts the file to a map so that it can be stored as an attribute
+func (f CmdlineFile) ToMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"arg":  f.Arg,
+		"path": f.Path,
+	}
+
+	if f.Flag != "" {
+		m["flag"] = f.Flag
+	}
+
+	return m
+}
+
+// processRoot Returns the path through which the root filesystem of the given
+// process can be accessed. This will be different from "/" when the process
+// has been chrooted or is running in a container with its own mount namespace.
+// If the root can't be determined an empty string is returned and paths should
+// be treated as being relative to the agent's own root
+func processRoot(pid int32) string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+
+	return procfs.ProcFS{}.Root(int(pid))
+}
+
+// libraryLinks Returns the links to the files of a process' shared libraries
+// and the packages that own them, so that we can find which processes use a
+// given package. Like cmdline files, the libraries of a process in a
+// container or chroot are linked through its root. They aren't linked to
+// packages since they are owned by the container's packages rather than the
+// host's
+func libraryLinks(libraries []string, root string, itemContext string) []*sdp.ItemRequest {
+	links := make([]*sdp.ItemRequest, 0)
+
+	for _, library := range libraries {
+		if root != "" {
+			links = append(links, &sdp.ItemRequest{
+				Type:    "file",
+				Method:  sdp.RequestMethod_GET,
+				Query:   filepath.Join(root, library),
+				Context: itemContext,
+			})
+
+			continue
+		}
+
+		links = append(links, &sdp.ItemRequest{
+			Type:    "file",
+			Method:  sdp.RequestMethod_GET,
+			Query:   library,
+			Context: itemContext,
+		})
+
+		links = append(links, &sdp.ItemRequest{
+			Type:    "package",
+			Method:  sdp.RequestMethod_SEARCH,
+			Query:   library,
+			Context: itemContext,
+		})
+	}
+
+	return links
+}
+
+// cmdlineFiles Parses the arguments of a process and returns the files that
+// they reference. Relative paths are resolved against the process' working
+// directory, and all paths are resolved against the root of the process. The
+// following styles of argument are recognised:
+//
+//   - /path/to/file
+//   - relative/file
+//   - --flag=/path/to/file
+//   - -c /path/to/file
+//   - --flag /path/to/file
+//
+// The first argument is ignored since it is the executable, which is already
+// linked via `exe`
+func cmdlineFiles(args []string, cwd string, root string) []CmdlineFile {
+	files := make([]CmdlineFile, 0)
+	seen := make(map[string]bool)
+
+	for i := 1; i < len(args); i++ {
+		var arg string
+		var flag string
+		var value string
+
+		arg = args[i]
+		value = arg
+
+		if strings.HasPrefix(arg, "-") {
+			if name, v, found := strings.Cut(arg, "="); found {
+				// --flag=/path style
+				flag = name
+				value = v
+			} else {
+				// This is a flag on its own, the value (if any) will be handled
+				// when we look at the next argument
+				continue
+			}
+		} else if i > 1 && strings.HasPrefix(args[i-1], "-") && !strings.Contains(args[i-1], "=") {
+			// -c path or --flag path style
+			flag = args[i-1]
+			arg = args[i-1] + " " + arg
+		}
+
+		path := resolveArgPath(value, cwd, root)
+
+		if path == "" || seen[path] {
+			continue
+		}
+
+		seen[path] = true
+
+		files = append(files, CmdlineFile{
+			Arg:  arg,
+			Flag: flag,
+			Path: path,
+		})
+	}
+
+	return files
+}
+
+// resolveArgPath Resolves a single argument value to a path that exists,
+// returning an empty string if the argument doesn't point to a real file. If
+// root is set the path is resolved through it e.g. /proc/<pid>/root
+func resolveArgPath(value string, cwd string, root string) string {
+	var path string
+
+	if value == "" {
+		return ""
+	}
+
+	if filepath.IsAbs(value) {
+		path = value
+	} else {
+		if cwd == "" {
+			// Without the working directory we can't know where a relative
+			// path points to
+			return ""
+		}
+
+		path = filepath.Join(cwd, value)
+	}
+
+	if root != "" {
+		path = filepath.Join(root, path)
+	}
+
+	// Check if the file exists
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 
@@ -88,3 +89,144 @@ func TestProcessGet(t *testing.T) {
 	util.RunSourceTests(t, tests, &source)
 
 }
+
+func TestCmdlineFiles(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"etc/app", "srv/app", "srv/my app"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, file := range []string{"etc/app/config.yaml", "etc/app/extra.yaml", "etc/app/data.db", "srv/app/data.db", "srv/my app/file.txt"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{
+		"/usr/bin/app",
+		"--config=" + filepath.Join(root, "etc/app/config.yaml"),
+		"-c",
+		filepath.Join(root, "etc/app/extra.yaml"),
+		"--data",
+		filepath.Join(root, "etc/app/data.db"),
+		"data.db",
+		filepath.Join(root, "srv/my app/file.txt"),
+		"not-a-file",
+	}
+
+	files := cmdlineFiles(args, filepath.Join(root, "srv/app"), "")
+
+	expected := []CmdlineFile{
+		{
+			Arg:  "--config=" + filepath.Join(root, "etc/app/config.yaml"),
+			Flag: "--config",
+			Path: filepath.Join(root, "etc/app/config.yaml"),
+		},
+		{
+			Arg:  "-c " + filepath.Join(root, "etc/app/extra.yaml"),
+			Flag: "-c",
+			Path: filepath.Join(root, "etc/app/extra.yaml"),
+		},
+		{
+			Arg:  "--data " + filepath.Join(root, "etc/app/data.db"),
+			Flag: "--data",
+			Path: filepath.Join(root, "etc/app/data.db"),
+		},
+		{
+			Arg:  "data.db",
+			Path: filepath.Join(root, "srv/app/data.db"),
+		},
+		{
+			Arg:  filepath.Join(root, "srv/my app/file.txt"),
+			Path: filepath.Join(root, "srv/my app/file.txt"),
+		},
+	}
+
+	if len(files) != len(expected) {
+		t.Fatalf("expected %v files, got %v: %v", len(expected), len(files), files)
+	}
+
+	for i, f := range files {
+		if f != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], f)
+		}
+	}
+
+	t.Run("with a different root", func(t *testing.T) {
+		// The paths are as the process sees them, so only exist once they
+		// are resolved through its root
+		args := []string{
+			"/usr/bin/app",
+			"-c",
+			"/etc/app/config.yaml",
+			"data.db",
+		}
+
+		files := cmdlineFiles(args, "/srv/app", root)
+
+		expected := []CmdlineFile{
+			{
+				Arg:  "-c /etc/app/config.yaml",
+				Flag: "-c",
+				Path: filepath.Join(root, "etc/app/config.yaml"),
+			},
+			{
+				Arg:  "data.db",
+				Path: filepath.Join(root, "srv/app/data.db"),
+			},
+		}
+
+		if len(files) != len(expected) {
+			t.Fatalf("expected %v files, got %v: %v", len(expected), len(files), files)
+		}
+
+		for i, f := range files {
+			if f != expected[i] {
+				t.Errorf("expected %v, got %v", expected[i], f)
+			}
+		}
+	})
+
+	t.Run("without cwd", func(t *testing.T) {
+		files := cmdlineFiles([]string{"/usr/bin/app", "data.db"}, "", "")
+
+		if len(files) != 0 {
+			t.Errorf("expected relative paths to be ignored without a cwd, got %v", files)
+		}
+	})
+}
+
+func TestLibraryLinks(t *testing.T) {
+	libraries := []string{"/usr/lib/x86_64-linux-gnu/libssl.so.3"}
+
+	t.Run("same root", func(t *testing.T) {
+		links := libraryLinks(libraries, "", util.LocalContext)
+
+		if len(links) != 2 {
+			t.Fatalf("expected 2 links, got %v", links)
+		}
+
+		if links[0].Type != "file" || links[0].Query != libraries[0] {
+			t.Errorf("expected a file link to %v, got %v %v", libraries[0], links[0].Type, links[0].Query)
+		}
+
+		if links[1].Type != "package" || links[1].Query != libraries[0] {
+			t.Errorf("expected a package link to %v, got %v %v", libraries[0], links[1].Type, links[1].Query)
+		}
+	})
+
+	t.Run("container", func(t *testing.T) {
+		links := libraryLinks(libraries, "/proc/1234/root", util.LocalContext)
+
+		if len(links) != 1 {
+			t.Fatalf("expected only a file link, got %v", links)
+		}
+
+		if expected := "/proc/1234/root/usr/lib/x86_64-linux-gnu/libssl.so.3"; links[0].Type != "file" || links[0].Query != expected {
+			t.Errorf("expected a file link to %v, got %v %v", expected, links[0].Type, links[0].Query)
+		}
+	})
+}
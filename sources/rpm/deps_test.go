@@ -0,0 +1,116 @@
+package rpm
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/sdp-go"
+)
+
+func testPackages(t *testing.T) []Package {
+	b, err := os.ReadFile("test/rpm-query")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := parseRPMOutput(string(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packages
+}
+
+func TestParseDependencies(t *testing.T) {
+	packages := testPackages(t)
+
+	if len(packages) != 3 {
+		t.Fatalf("expected 3 packages, got %v", len(packages))
+	}
+
+	libs := packages[0]
+
+	if len(libs.Requires) != 6 || len(libs.Provides) != 5 || len(libs.Obsoletes) != 1 {
+		t.Fatalf("unexpected relationships %+v", libs)
+	}
+
+	if d := libs.Requires[0]; d.Name != "ca-certificates" || d.Constraint() != ">= 2008-5" {
+		t.Errorf("unexpected requirement %+v", d)
+	}
+
+	if d := libs.Requires[2]; d.Name != "ld-linux-x86-64.so.2()(64bit)" || d.Constraint() != "" {
+		t.Errorf("unexpected requirement %+v", d)
+	}
+
+	if libs.Summary != "A general purpose cryptography library with TLS implementation" {
+		t.Errorf("unexpected summary %v", libs.Summary)
+	}
+
+	if ssh := packages[1]; len(ssh.Obsoletes) != 0 || !strings.HasPrefix(ssh.Description, "SSH (Secure SHell) is a program for logging into and executing\ncommands") {
+		t.Errorf("unexpected package %+v", ssh)
+	}
+}
+
+func TestReverseDepends(t *testing.T) {
+	packages := testPackages(t)
+
+	t.Run("capability", func(t *testing.T) {
+		rdepends := reverseDepends(packages, packages[0], nil)
+
+		if len(rdepends) != 1 || rdepends[0].Name != "openssh" {
+			t.Errorf("expected openssh, got %v", rdepends)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		bash := packages[2]
+		bash.Provides = nil
+
+		rdepends := reverseDepends(packages, bash, []packagefiles.File{{Path: "/bin/sh"}})
+
+		if len(rdepends) != 1 || rdepends[0].Name != "openssh" {
+			t.Errorf("expected openssh, got %v", rdepends)
+		}
+	})
+}
+
+func TestMapPackageDependencies(t *testing.T) {
+	packages := testPackages(t)
+
+	item, err := mapPackageToItem(packages[0], nil, 0, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queries []string
+
+	for _, r := range item.LinkedItemRequests {
+		if r.Type == "package" && r.Method == sdp.RequestMethod_SEARCH {
+			queries = append(queries, r.Query)
+		}
+	}
+
+	// libcrypto is provided by the package itself and rpmlib() by rpm
+	expected := []string{"ca-certificates", "crypto-policies", "ld-linux-x86-64.so.2()(64bit)", "libc.so.6()(64bit)"}
+
+	if len(queries) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, queries)
+	}
+
+	for i := range expected {
+		if queries[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], queries[i])
+		}
+	}
+
+	requires, _ := item.Attributes.Get("requires")
+
+	if r, ok := requires.([]interface{}); !ok || len(r) != 6 {
+		t.Errorf("expected 6 requirements, got %v", requires)
+	}
+}
@@ -0,0 +1,41 @@
+package rpm
+
+import (
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// parseFiles Parses the list of files from the output of QueryFormat. Each
+// file is separated by a tab and is in the format {path}|{flags}|{perms}, where
+// flags include "c" for config files and perms start with "d" for directories
+func parseFiles(line string) []packagefiles.File {
+	files := make([]packagefiles.File, 0)
+
+	for _, entry := range strings.Split(line, "\t") {
+		fields := strings.Split(entry, "|")
+
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+
+		files = append(files, packagefiles.File{
+			Path:      fields[0],
+			Conffile:  strings.Contains(fields[1], "c"),
+			Directory: strings.HasPrefix(fields[2], "d"),
+		})
+	}
+
+	return files
+}
+
+// packageFiles Returns the files of each package keyed by package name
+func packageFiles(ps []Package) map[string][]packagefiles.File {
+	files := make(map[string][]packagefiles.File)
+
+	for _, p := range ps {
+		files[p.Name] = append(files[p.Name], p.Files...)
+	}
+
+	return files
+}
@@ -0,0 +1,88 @@
+package rpm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// testFiles Returns the files of the packages in test/files, keyed by name
+func testFiles(t *testing.T) map[string][]packagefiles.File {
+	b, err := os.ReadFile("test/files")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := parseRPMOutput(string(b))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return packageFiles(packages)
+}
+
+func TestParseFiles(t *testing.T) {
+	files := testFiles(t)
+
+	if len(files) != 2 {
+		t.Fatalf("expected files for 2 packages, got %v", len(files))
+	}
+
+	ssh := files["openssh-server"]
+
+	if len(ssh) != 5 {
+		t.Fatalf("expected 5 files, got %v", len(ssh))
+	}
+
+	if !ssh[0].Directory || ssh[0].Conffile {
+		t.Errorf("expected /etc/ssh to be a directory, got %+v", ssh[0])
+	}
+
+	if !ssh[1].Conffile || ssh[1].Directory {
+		t.Errorf("expected sshd_config to be a conffile, got %+v", ssh[1])
+	}
+
+	if ssh[4].Conffile {
+		t.Errorf("expected doc file not to be a conffile")
+	}
+
+	item, err := mapPackageToItem(Package{Name: "openssh-server"}, ssh, 0, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fileCount, _ := item.Attributes.Get("fileCount"); fileCount != float64(4) {
+		t.Errorf("expected fileCount 4, got %v", fileCount)
+	}
+
+	if len(item.LinkedItemRequests) != 4 {
+		t.Fatalf("expected 4 links, got %v", len(item.LinkedItemRequests))
+	}
+
+	if q := item.LinkedItemRequests[0].Query; q != "/etc/ssh/sshd_config" {
+		t.Errorf("expected the conffile to be linked first, got %v", q)
+	}
+
+	// Packages that don't own any files have an empty list
+	packages := testPackages(t)
+
+	if len(packages[0].Files) != 0 {
+		t.Errorf("expected openssl-libs to have no files, got %+v", packages[0].Files)
+	}
+
+	if bash := packages[2]; len(bash.Files) != 3 || !bash.Files[2].Directory {
+		t.Errorf("unexpected files %+v", bash.Files)
+	}
+}
+
+func TestParseFilesSkipsBadEntries(t *testing.T) {
+	files := parseFiles("/usr/bin/bash\t/usr/bin/sh||lrwxrwxrwx\t")
+
+	if len(files) != 1 || files[0].Path != "/usr/bin/sh" {
+		t.Errorf("expected only /usr/bin/sh, got %+v", files)
+	}
+}
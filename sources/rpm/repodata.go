@@ -0,0 +1,327 @@
+package rpm
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
+)
+
+// DefaultCacheLocations The directories where dnf and yum keep the metadata
+// that they download from repositories
+var DefaultCacheLocations = []string{
+	"/var/cache/dnf",
+	"/var/cache/libdnf5",
+	"/var/cache/yum",
+}
+
+// maxCacheDepth How deep the cache directories are searched for metadata.
+// yum keeps it in {cache}/{arch}/{releasever}/{repo}
+const maxCacheDepth = 4
+
+// Repodata Reads the versions of packages that are available from the
+// repositories using the metadata that dnf or yum has already downloaded, so
+// that updates can be found without accessing the repositories. Packages come
+// from the primary metadata (*primary.xml.gz), and security updates from the
+// advisories in *updateinfo.xml.gz. The metadata is parsed once and kept in
+// memory until it changes
+type Repodata struct {
+	// The directories to search for metadata. Defaults to
+	// DefaultCacheLocations
+	CacheLocations []string
+
+	mutex     sync.Mutex
+	signature string
+	index     *packageupdates.Index
+}
+
+// RepoPackage A package that is available from a repository, or that is
+// fixed by an advisory
+type RepoPackage struct {
+	Name    string
+	Arch    string
+	Epoch   int
+	Version string
+	Release string
+}
+
+// EVR Returns the version of the package as [epoch:]version-release
+func (p RepoPackage) EVR() string {
+	return FormatEVR(p.Epoch, p.Version, p.Release)
+}
+
+// Candidates Returns the newest version of each package that is available,
+// keyed by name and architecture. Returns nil if there isn't any metadata,
+// e.g. because the cache has been cleaned, since it isn't known which updates
+// are available
+func (r *Repodata) Candidates() (*packageupdates.Index, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	primary, updateinfo, signature := r.metadata()
+
+	if len(primary) == 0 {
+		r.index = nil
+		r.signature = ""
+
+		return nil, nil
+	}
+
+	if r.index != nil && signature == r.signature {
+		return r.index, nil
+	}
+
+	// Advisories can refer to packages in any repository, so these are read
+	// first. Metadata that can't be read is left out rather than hiding the
+	// rest
+	security := make(map[string]bool)
+
+	for _, path := range updateinfo {
+		readMetadata(path, func(rd io.Reader) error {
+			return ReadSecurityUpdates(rd, func(p RepoPackage) {
+				security[p.Name+"\x00"+p.Arch+"\x00"+p.EVR()] = true
+			})
+		})
+	}
+
+	index := packageupdates.Index{
+		Compare: CompareEVR,
+	}
+
+	for _, path := range primary {
+		repo := RepoID(path)
+
+		readMetadata(path, func(rd io.Reader) error {
+			return ReadPrimary(rd, func(p RepoPackage) {
+				index.Add(p.Name, p.Arch, packageupdates.Candidate{
+					Version:  p.EVR(),
+					Origin:   repo,
+					Security: security[p.Name+"\x00"+p.Arch+"\x00"+p.EVR()],
+				})
+			})
+		})
+	}
+
+	r.index = &index
+	r.signature = signature
+
+	return r.index, nil
+}
+
+// metadata Returns the paths of the primary and updateinfo metadata in the
+// cache, along with a signature made from their names, sizes and modification
+// times that changes whenever they are downloaded again. Compressed metadata
+// other than gzip (e.g. zstd on recent Fedora) and yum's sqlite databases
+// can't be read so are ignored
+func (r *Repodata) metadata() ([]string, []string, string) {
+	locations := r.CacheLocations
+
+	if locations == nil {
+		locations = DefaultCacheLocations
+	}
+
+	primary := make([]string, 0)
+	updateinfo := make([]string, 0)
+	signature := new(strings.Builder)
+
+	for _, location := range locations {
+		filepath.WalkDir(location, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if d.IsDir() {
+				if strings.Count(strings.TrimPrefix(path, location), string(filepath.Separator)) > maxCacheDepth {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			name := strings.TrimSuffix(d.Name(), ".gz")
+
+			switch {
+			case strings.HasSuffix(name, "primary.xml"):
+				primary = append(primary, path)
+			case strings.HasSuffix(name, "updateinfo.xml"):
+				updateinfo = append(updateinfo, path)
+			default:
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil {
+				fmt.Fprintf(signature, "%v %v %v\n", path, info.Size(), info.ModTime().UnixNano())
+			}
+
+			return nil
+		})
+	}
+
+	sort.Strings(primary)
+	sort.Strings(updateinfo)
+
+	return primary, updateinfo, signature.String()
+}
+
+// readMetadata Opens a metadata file, decompressing it if it is gzipped, and
+// passes it to f
+func readMetadata(path string, f func(io.Reader) error) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		return f(file)
+	}
+
+	gz, err := gzip.NewReader(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer gz.Close()
+
+	return f(gz)
+}
+
+// repoCacheSuffix Matches the hash that dnf appends to the names of the
+// directories that it caches repositories in e.g. baseos-8b1a3d2e7f9c0a44
+var repoCacheSuffix = regexp.MustCompile(`-[0-9a-f]{16}$`)
+
+// RepoID Returns the ID of the repository that a metadata file belongs to,
+// from the directory that it is cached in. dnf caches metadata in
+// {cache}/{repo}-{hash}/repodata and yum in {cache}/{arch}/{releasever}/{repo}
+func RepoID(path string) string {
+	dir := filepath.Dir(path)
+
+	if filepath.Base(dir) == "repodata" {
+		dir = filepath.Dir(dir)
+	}
+
+	return repoCacheSuffix.ReplaceAllString(filepath.Base(dir), "")
+}
+
+// xmlVersion The version of a package in primary.xml e.g. <version epoch="1"
+// ver="3.0.7" rel="27.el9"/>
+type xmlVersion struct {
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+// xmlPackage A package in primary.xml. Only the fields that are needed are
+// decoded
+type xmlPackage struct {
+	Name    string     `xml:"name"`
+	Arch    string     `xml:"arch"`
+	Version xmlVersion `xml:"version"`
+}
+
+// xmlAdvisory An advisory in updateinfo.xml, and the packages that fix it
+type xmlAdvisory struct {
+	Type     string `xml:"type,attr"`
+	Packages []struct {
+		Name    string `xml:"name,attr"`
+		Arch    string `xml:"arch,attr"`
+		Epoch   string `xml:"epoch,attr"`
+		Version string `xml:"version,attr"`
+		Release string `xml:"release,attr"`
+	} `xml:"pkglist>collection>package"`
+}
+
+// ReadPrimary Reads the packages from the primary metadata of a repository
+// and calls f for each one. Source packages are skipped since they can't be
+// installed
+func ReadPrimary(r io.Reader, f func(RepoPackage)) error {
+	return decodeElements(r, "package", func(d *xml.Decoder, start xml.StartElement) error {
+		var p xmlPackage
+
+		if err := d.DecodeElement(&p, &start); err != nil {
+			return err
+		}
+
+		if p.Name != "" && p.Arch != "src" {
+			epoch, _ := strconv.Atoi(p.Version.Epoch)
+
+			f(RepoPackage{
+				Name:    p.Name,
+				Arch:    p.Arch,
+				Epoch:   epoch,
+				Version: p.Version.Ver,
+				Release: p.Version.Rel,
+			})
+		}
+
+		return nil
+	})
+}
+
+// ReadSecurityUpdates Reads the advisories from the updateinfo metadata of a
+// repository and calls f for each package that is part of a security
+// advisory
+func ReadSecurityUpdates(r io.Reader, f func(RepoPackage)) error {
+	return decodeElements(r, "update", func(d *xml.Decoder, start xml.StartElement) error {
+		var a xmlAdvisory
+
+		if err := d.DecodeElement(&a, &start); err != nil {
+			return err
+		}
+
+		if a.Type != "security" {
+			return nil
+		}
+
+		for _, p := range a.Packages {
+			epoch, _ := strconv.Atoi(p.Epoch)
+
+			f(RepoPackage{
+				Name:    p.Name,
+				Arch:    p.Arch,
+				Epoch:   epoch,
+				Version: p.Version,
+				Release: p.Release,
+			})
+		}
+
+		return nil
+	})
+}
+
+// decodeElements Streams an XML document and calls f for each element with
+// the given name, so that large metadata doesn't have to be held in memory
+func decodeElements(r io.Reader, name string, f func(*xml.Decoder, xml.StartElement) error) error {
+	d := xml.NewDecoder(r)
+
+	for {
+		token, err := d.Token()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == name {
+			if err := f(d, start); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package rpm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
+)
+
+func TestRepoID(t *testing.T) {
+	tests := map[string]string{
+		"/var/cache/dnf/baseos-8b1a3d2e7f9c0a44/repodata/a1b2c3-primary.xml.gz": "baseos",
+		"/var/cache/dnf/epel-next-0123456789abcdef/repodata/primary.xml.gz":     "epel-next",
+		"/var/cache/yum/x86_64/7/base/primary.xml.gz":                           "base",
+	}
+
+	for path, expected := range tests {
+		if id := RepoID(path); id != expected {
+			t.Errorf("expected repository of %v to be %v, got %v", path, expected, id)
+		}
+	}
+}
+
+func TestRepodataCandidates(t *testing.T) {
+	repodata := Repodata{
+		CacheLocations: []string{"test/cache/dnf"},
+	}
+
+	index, err := repodata.Candidates()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Source packages aren't included
+	if index.Len() != 3 {
+		t.Errorf("expected 3 packages, got %v", index.Len())
+	}
+
+	tests := []struct {
+		Name     string
+		Arch     string
+		Expected *packageupdates.Candidate
+	}{
+		{
+			// The advisory is in the baseos metadata, but the package is
+			// in updates
+			Name:     "openssl",
+			Arch:     "x86_64",
+			Expected: &packageupdates.Candidate{Version: "1:3.0.7-27.el9", Origin: "updates", Security: true},
+		},
+		{
+			// Only security advisories count
+			Name:     "bash",
+			Arch:     "x86_64",
+			Expected: &packageupdates.Candidate{Version: "5.1.8-9.el9", Origin: "updates"},
+		},
+		{
+			Name:     "tzdata",
+			Arch:     "noarch",
+			Expected: &packageupdates.Candidate{Version: "2024a-1.el9", Origin: "updates"},
+		},
+	}
+
+	for _, test := range tests {
+		if c := index.Candidate(test.Name, test.Arch); !reflect.DeepEqual(c, test.Expected) {
+			t.Errorf("expected candidate for %v.%v to be %v, got %v", test.Name, test.Arch, test.Expected, c)
+		}
+	}
+
+	// The index is kept until the metadata changes
+	if again, _ := repodata.Candidates(); again != index {
+		t.Error("expected the index to be reused")
+	}
+
+	empty := Repodata{
+		CacheLocations: []string{"test/cache/dnf/empty", "test/missing"},
+	}
+
+	if index, err := empty.Candidates(); index != nil || err != nil {
+		t.Errorf("expected no index and no error without metadata, got %v, %v", index, err)
+	}
+}
+
+func TestMapPackageUpdate(t *testing.T) {
+	repodata := Repodata{
+		CacheLocations: []string{"test/cache/dnf"},
+	}
+
+	index, err := repodata.Candidates()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	openssl := Package{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "24.el9", Arch: "x86_64"}
+
+	update := packageUpdate(index, openssl)
+
+	if !update.Available || !update.Security() {
+		t.Errorf("expected a security update for openssl, got %+v", update)
+	}
+
+	item, err := mapPackageToItem(openssl, nil, 0, nil, update)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"updateAvailable":   true,
+		"candidateVersion":  "1:3.0.7-27.el9",
+		"candidateOrigin":   "updates",
+		"candidateSecurity": true,
+	}
+
+	for k, v := range expected {
+		if value, _ := item.Attributes.Get(k); value != v {
+			t.Errorf("expected %v to be %v, got %v", k, v, value)
+		}
+	}
+
+	// The package isn't in any repository
+	if update := packageUpdate(index, Package{Name: "local", Version: "1.0", Release: "1", Arch: "x86_64"}); update.Available || update.Candidate != nil {
+		t.Errorf("expected no update for a local package, got %+v", update)
+	}
+
+	// An older package in the repositories isn't an update
+	if update := packageUpdate(index, Package{Name: "tzdata", Version: "2024b", Release: "1.el9", Arch: "noarch"}); update.Available {
+		t.Errorf("expected no update for tzdata, got %+v", update)
+	}
+
+	if update := packageUpdate(nil, openssl); update != nil {
+		t.Errorf("expected nil without metadata, got %+v", update)
+	}
+}
@@ -2,14 +2,37 @@ package rpm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/overmind-agent/sources/util/packageupdates"
 	"github.com/overmindtech/sdp-go"
 )
 
-// RPMSource struct on which all methods are registered
-type RPMSource struct{}
+// RPMSource struct on which all methods are registered. Whether updates are
+// available is read from the repository metadata that dnf or yum has already
+// downloaded
+type RPMSource struct {
+	// The maximum number of files that each package links to. Zero uses
+	// packagefiles.DefaultLinkLimit and a negative number disables links
+	FileLinkLimit int
+
+	// Whether to verify the files owned by each package using `rpm -V`. This
+	// reads every file so is disabled by default
+	Verify bool
+
+	// The directories that dnf and yum cache repository metadata in, which
+	// is used to find updates. Defaults to DefaultCacheLocations
+	CacheLocations []string
+
+	repodata     *Repodata
+	repodataOnce sync.Once
+}
 
 // Type is the type of items that this returns (Required)
 func (bc *RPMSource) Type() string {
@@ -65,7 +88,15 @@ func (bc *RPMSource) Get(ctx context.Context, itemContext string, query string)
 		return nil, err
 	}
 
-	return mapPackageToItem(p)
+	var problems []packagefiles.Problem
+
+	if bc.Verify {
+		// If verification fails to run the package is returned without the
+		// results
+		problems, _ = Verify(ctx, p.Name)
+	}
+
+	return mapPackageToItem(p, p.Files, bc.FileLinkLimit, problems, packageUpdate(bc.candidates(), p))
 }
 
 // Find Gets information about all item that the source can possibly find. If
@@ -94,8 +125,16 @@ func (bc *RPMSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item,
 		}
 	}
 
+	var problems map[string][]packagefiles.Problem
+
+	if bc.Verify {
+		problems, _ = verifyAll(ctx, packageFiles(ps))
+	}
+
+	candidates := bc.candidates()
+
 	for _, p := range ps {
-		item, err = mapPackageToItem(p)
+		item, err = mapPackageToItem(p, p.Files, bc.FileLinkLimit, problems[p.Name], packageUpdate(candidates, p))
 
 		if err == nil {
 			items = append(items, item)
@@ -106,7 +145,12 @@ func (bc *RPMSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item,
 }
 
 // Search takes a file name or binary name and runs this through rpm -q
-// --whatprovides
+// --whatprovides. If the query is in the format rdepends:{name} it instead
+// returns the packages that require the given package. The query
+// integrity:failed verifies all packages and returns only those with modified,
+// missing or permission-changed files, regardless of whether Verify is enabled.
+// The queries update:available and update:security return the packages that
+// have an update, or a security update, available
 func (bc *RPMSource) Search(ctx context.Context, itemContext string, query string) ([]*sdp.Item, error) {
 	if itemContext != util.LocalContext {
 		return nil, &sdp.ItemRequestError{
@@ -121,7 +165,39 @@ func (bc *RPMSource) Search(ctx context.Context, itemContext string, query strin
 	var item *sdp.Item
 	var items []*sdp.Item
 
-	ps, err = WhatProvides(ctx, query)
+	if query == "integrity:failed" {
+		items, err = bc.integrityFailures(ctx)
+
+		if err != nil {
+			return nil, &sdp.ItemRequestError{
+				ErrorType:   sdp.ItemRequestError_OTHER,
+				ErrorString: err.Error(),
+				Context:     itemContext,
+			}
+		}
+
+		return items, nil
+	}
+
+	if query == "update:available" || query == "update:security" {
+		items, err = bc.updates(ctx, query == "update:security")
+
+		if err != nil {
+			return nil, &sdp.ItemRequestError{
+				ErrorType:   sdp.ItemRequestError_OTHER,
+				ErrorString: err.Error(),
+				Context:     itemContext,
+			}
+		}
+
+		return items, nil
+	}
+
+	if strings.HasPrefix(query, "rdepends:") {
+		ps, err = ReverseDepends(ctx, strings.TrimPrefix(query, "rdepends:"))
+	} else {
+		ps, err = WhatProvides(ctx, query)
+	}
 
 	if err != nil {
 		return nil, &sdp.ItemRequestError{
@@ -131,8 +207,24 @@ func (bc *RPMSource) Search(ctx context.Context, itemContext string, query strin
 		}
 	}
 
+	var problems map[string][]packagefiles.Problem
+
+	if bc.Verify && len(ps) > 0 {
+		names := make([]string, 0, len(ps))
+
+		for _, p := range ps {
+			names = append(names, p.Name)
+		}
+
+		if found, err := Verify(ctx, names...); err == nil {
+			problems = problemsByPackage(found, packageFiles(ps))
+		}
+	}
+
+	candidates := bc.candidates()
+
 	for _, p := range ps {
-		item, err = mapPackageToItem(p)
+		item, err = mapPackageToItem(p, p.Files, bc.FileLinkLimit, problems[p.Name], packageUpdate(candidates, p))
 
 		if err == nil {
 			items = append(items, item)
@@ -142,6 +234,131 @@ func (bc *RPMSource) Search(ctx context.Context, itemContext string, query strin
 	return items, nil
 }
 
+// integrityFailures Verifies all packages and returns items for the ones that
+// have failed
+func (bc *RPMSource) integrityFailures(ctx context.Context) ([]*sdp.Item, error) {
+	ps, err := QueryAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	problems, err := verifyAll(ctx, packageFiles(ps))
+
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := bc.candidates()
+	items := make([]*sdp.Item, 0)
+
+	for _, p := range ps {
+		if !packagefiles.Failed(problems[p.Name]) {
+			continue
+		}
+
+		item, err := mapPackageToItem(p, p.Files, bc.FileLinkLimit, problems[p.Name], packageUpdate(candidates, p))
+
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// updates Returns items for the packages that have an update available. If
+// security is true only security updates are included. Returns an error if
+// there isn't any repository metadata, since then it isn't known which
+// packages can be updated
+func (bc *RPMSource) updates(ctx context.Context, security bool) ([]*sdp.Item, error) {
+	candidates := bc.candidates()
+
+	if candidates == nil {
+		return nil, errors.New("no repository metadata found in the dnf or yum cache, run dnf makecache to download it")
+	}
+
+	ps, err := QueryAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var outdated []Package
+	var names []string
+
+	for _, p := range ps {
+		update := packageUpdate(candidates, p)
+
+		if update.Available && (!security || update.Security()) {
+			outdated = append(outdated, p)
+			names = append(names, p.Name)
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	if len(outdated) == 0 {
+		return items, nil
+	}
+
+	var problems map[string][]packagefiles.Problem
+
+	if bc.Verify {
+		if found, err := Verify(ctx, names...); err == nil {
+			problems = problemsByPackage(found, packageFiles(outdated))
+		}
+	}
+
+	for _, p := range outdated {
+		item, err := mapPackageToItem(p, p.Files, bc.FileLinkLimit, problems[p.Name], packageUpdate(candidates, p))
+
+		if err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// candidates Returns the candidate version of each package that is available
+// from the repositories, or nil if there isn't any metadata to read them from
+func (bc *RPMSource) candidates() *packageupdates.Index {
+	bc.repodataOnce.Do(func() {
+		bc.repodata = &Repodata{
+			CacheLocations: bc.CacheLocations,
+		}
+	})
+
+	index, err := bc.repodata.Candidates()
+
+	if err != nil {
+		return nil
+	}
+
+	return index
+}
+
+// packageUpdate Returns whether a package can be updated. noarch is also
+// checked in case a package has changed to be architecture independent in a
+// newer version
+func packageUpdate(candidates *packageupdates.Index, p Package) *packageupdates.Update {
+	return candidates.Update(p.Name, FormatEVR(int(p.Epoch), p.Version, p.Release), p.Arch, "noarch")
+}
+
+// verifyAll Verifies all packages at once and returns the problems for each
+// package, keyed by name. The files of all packages are needed to work out
+// which package each problem belongs to
+func verifyAll(ctx context.Context, files map[string][]packagefiles.File) (map[string][]packagefiles.Problem, error) {
+	problems, err := Verify(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return problemsByPackage(problems, files), nil
+}
+
 // Supported A function that can be executed to see if the backend is supported
 // in the current environment, if it returns false the backend simply won't be
 // loaded (Optional)
@@ -149,8 +366,11 @@ func (bc *RPMSource) Supported() bool {
 	return Supported()
 }
 
-func mapPackageToItem(p Package) (*sdp.Item, error) {
-	attributes, err := sdp.ToAttributes(map[string]interface{}{
+// mapPackageToItem Converts a package to an item. If problems is not nil the
+// package has been verified and the results are included, and if update is
+// not nil so is whether it can be updated
+func mapPackageToItem(p Package, files []packagefiles.File, fileLinkLimit int, problems []packagefiles.Problem, update *packageupdates.Update) (*sdp.Item, error) {
+	attrMap := map[string]interface{}{
 		"name":         p.Name,
 		"epoch":        p.Epoch,
 		"version":      p.Version,
@@ -165,16 +385,64 @@ func mapPackageToItem(p Package) (*sdp.Item, error) {
 		"url":          p.URL,
 		"summary":      p.Summary,
 		"description":  p.Description,
-	})
+		"requires":     packagedeps.Attributes(p.Requires),
+		"provides":     packagedeps.Attributes(p.Provides),
+		"obsoletes":    packagedeps.Attributes(p.Obsoletes),
+	}
+
+	if files != nil {
+		for k, v := range packagefiles.Attributes(files) {
+			attrMap[k] = v
+		}
+	}
+
+	if problems != nil {
+		for k, v := range packagefiles.VerificationAttributes(problems) {
+			attrMap[k] = v
+		}
+	}
+
+	if update != nil {
+		for k, v := range packageupdates.Attributes(update) {
+			attrMap[k] = v
+		}
+	}
+
+	attributes, err := sdp.ToAttributes(attrMap)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// Requirements are capabilities rather than package names, so they are
+	// linked using a search which runs `rpm -q --whatprovides`
+	linkedItemRequests := packagedeps.LinkedItemRequests(sdp.RequestMethod_SEARCH, func(name string) bool {
+		return skipRequirement(p, name)
+	}, p.Requires)
+	linkedItemRequests = append(linkedItemRequests, packagefiles.LinkedItemRequests(files, fileLinkLimit)...)
+
 	return &sdp.Item{
-		Type:            "package",
-		UniqueAttribute: "name",
-		Attributes:      attributes,
-		Context:         util.LocalContext,
+		Type:               "package",
+		UniqueAttribute:    "name",
+		Attributes:         attributes,
+		Context:            util.LocalContext,
+		LinkedItemRequests: linkedItemRequests,
 	}, nil
 }
+
+// skipRequirement Returns whether a requirement shouldn't be linked because it is
+// satisfied by the package itself, by rpm (e.g. rpmlib(PayloadIsZstd)), or is
+// a rich dependency such as "(foo if bar)" that can't be queried directly
+func skipRequirement(p Package, name string) bool {
+	if name == p.Name || strings.HasPrefix(name, "rpmlib(") || strings.HasPrefix(name, "(") {
+		return true
+	}
+
+	for _, provided := range p.Provides {
+		if provided.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -9,10 +9,14 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
 )
 
-// QueryFormat is used when querying the RPM database
-const QueryFormat = `%{NAME}\n%{EPOCH}\n%{VERSION}\n%{RELEASE}\n%{ARCH}\n%{INSTALLTIME}\n%{GROUP}\n%{SIZE}\n%{LICENSE}\n%{SOURCERPM}\n%{VENDOR}\n%{URL}\n%{SUMMARY}\n%{DESCRIPTION}\n---OVERMINDEND---\n`
+// QueryFormat is used when querying the RPM database. This includes the files
+// owned by each package so that they don't need to be listed separately
+const QueryFormat = `%{NAME}\n%{EPOCH}\n%{VERSION}\n%{RELEASE}\n%{ARCH}\n%{INSTALLTIME}\n%{GROUP}\n%{SIZE}\n%{LICENSE}\n%{SOURCERPM}\n%{VENDOR}\n%{URL}\n[%{REQUIRENAME}|%{REQUIREFLAGS:depflags}|%{REQUIREVERSION}\t]\n[%{PROVIDENAME}|%{PROVIDEFLAGS:depflags}|%{PROVIDEVERSION}\t]\n[%{OBSOLETENAME}|%{OBSOLETEFLAGS:depflags}|%{OBSOLETEVERSION}\t]\n[%{FILENAMES}|%{FILEFLAGS:fflags}|%{FILEMODE:perms}\t]\n%{SUMMARY}\n%{DESCRIPTION}\n---OVERMINDEND---\n`
 
 // Package represents information about an RPM package
 type Package struct {
@@ -28,6 +32,10 @@ type Package struct {
 	SourceRPM   string
 	Vendor      string
 	URL         *url.URL
+	Requires    []packagedeps.Dependency
+	Provides    []packagedeps.Dependency
+	Obsoletes   []packagedeps.Dependency
+	Files       []packagefiles.File
 	Summary     string
 	Description string
 }
@@ -165,6 +173,80 @@ func WhatProvides(ctx context.Context, query string) ([]Package, error) {
 	return parseRPMOutput(string(output))
 }
 
+// ReverseDepends Returns the installed packages that require a given package.
+// A package is required if something requires its name, one of the
+// capabilities that it provides (e.g. a shared library) or one of its files
+// (e.g. /bin/sh)
+func ReverseDepends(ctx context.Context, name string) ([]Package, error) {
+	p, err := Query(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := QueryAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return reverseDepends(all, p, p.Files), nil
+}
+
+// reverseDepends Filters a list of packages to those that require a given
+// package
+func reverseDepends(all []Package, target Package, files []packagefiles.File) []Package {
+	names := map[string]bool{
+		target.Name: true,
+	}
+
+	for _, provided := range target.Provides {
+		names[provided.Name] = true
+	}
+
+	for _, f := range files {
+		names[f.Path] = true
+	}
+
+	packages := make([]Package, 0)
+
+	for _, p := range all {
+		if p.Name != target.Name && packagedeps.Satisfies(p.Requires, names) {
+			packages = append(packages, p)
+		}
+	}
+
+	return packages
+}
+
+// parseDependencies Parses a list of dependencies from the output of
+// QueryFormat. Each dependency is separated by a tab and is in the format
+// {name}|{operator}|{version}
+func parseDependencies(line string) []packagedeps.Dependency {
+	deps := make([]packagedeps.Dependency, 0)
+
+	for _, entry := range strings.Split(line, "\t") {
+		fields := strings.Split(entry, "|")
+
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+
+		dep := packagedeps.Dependency{
+			Name: fields[0],
+		}
+
+		if operator := strings.TrimSpace(fields[1]); operator != "" {
+			dep.Operator = operator
+			dep.Version = fields[2]
+		}
+
+		deps = append(deps, dep)
+	}
+
+	return deps
+}
+
 func parseRPMOutput(out string) ([]Package, error) {
 	var packages []Package
 
@@ -181,8 +263,8 @@ func parseRPMOutput(out string) ([]Package, error) {
 		lines := strings.Split(infoString, "\n")
 
 		// Ensure that we have enough sections
-		if len(lines) < 14 {
-			return nil, fmt.Errorf("could not parse output, expected at least 14 lines, got %v. Output: %v", len(lines), lines)
+		if len(lines) < 18 {
+			return nil, fmt.Errorf("could not parse output, expected at least 18 lines, got %v. Output: %v", len(lines), lines)
 		}
 		// Extract data
 		pkg.Name = lines[0]
@@ -213,10 +295,14 @@ func parseRPMOutput(out string) ([]Package, error) {
 			pkg.URL = u
 		}
 
-		pkg.Summary = lines[12]
+		pkg.Requires = parseDependencies(lines[12])
+		pkg.Provides = parseDependencies(lines[13])
+		pkg.Obsoletes = parseDependencies(lines[14])
+		pkg.Files = parseFiles(lines[15])
+		pkg.Summary = lines[16]
 
 		// Description need to be re-joined
-		pkg.Description = strings.Join(lines[13:], "\n")
+		pkg.Description = strings.Join(lines[17:], "\n")
 
 		packages = append(packages, pkg)
 	}
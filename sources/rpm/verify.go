@@ -0,0 +1,150 @@
+package rpm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+// verifyLine Matches a line of `rpm -V` output e.g. "S.5....T.  c /etc/foo" or
+// "missing     /usr/bin/bar". The first group is either "missing" or the
+// result of each test, the second is the file's attribute marker (c for config
+// files, d for documentation etc.) and the third is the path
+var verifyLine = regexp.MustCompile(`^(missing|[SM5DLUGTP.?]{8,9})\s+(?:([cdglr])\s+)?(/.*)$`)
+
+// verifyReasons The reason for each character in the output of `rpm -V`. A "."
+// means that the test passed and "?" means that it couldn't be performed
+var verifyReasons = map[rune]string{
+	'S': packagefiles.ReasonSize,
+	'M': packagefiles.ReasonMode,
+	'5': packagefiles.ReasonChecksum,
+	'D': packagefiles.ReasonDevice,
+	'L': packagefiles.ReasonSymlink,
+	'U': packagefiles.ReasonOwner,
+	'G': packagefiles.ReasonGroup,
+	'T': packagefiles.ReasonMtime,
+	'P': packagefiles.ReasonCapabilities,
+}
+
+// Verify Runs `rpm -V` for the given packages and returns the files that
+// differ from the way they were installed. If no names are given all packages
+// are verified. Note that the output doesn't say which package a file belongs
+// to
+func Verify(ctx context.Context, names ...string) ([]packagefiles.Problem, error) {
+	if !Supported() {
+		return nil, errors.New("RPM command not found")
+	}
+
+	args := []string{"-V"}
+
+	if len(names) == 0 {
+		args = append(args, "-a")
+	} else {
+		args = append(args, names...)
+	}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "rpm", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+
+	return verifyResult(args, output, stderr.String(), err)
+}
+
+// verifyResult Returns the problems found by `rpm -V` given its output and
+// the error from running it. rpm exits with a non-zero code if verification
+// fails, which still produces useful output. It also exits with a non-zero
+// code if it can't run at all (e.g. the database is locked or a package isn't
+// installed) in which case there is no output to parse, so this is only
+// treated as a verification failure if nothing was written to stderr and at
+// least one problem was found. Otherwise a failure to verify would be
+// reported as a pass
+func verifyResult(args []string, output []byte, stderr string, err error) ([]packagefiles.Problem, error) {
+	var exitErr *exec.ExitError
+
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("Error running command `rpm %v`: %v", strings.Join(args, " "), err)
+	}
+
+	problems, parseErr := ParseVerify(strings.NewReader(string(output)))
+
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if err != nil && (strings.TrimSpace(stderr) != "" || len(problems) == 0) {
+		return nil, fmt.Errorf("Error running command `rpm %v`: %v\nOutput: %v", strings.Join(args, " "), err, strings.TrimSpace(stderr))
+	}
+
+	return problems, nil
+}
+
+// ParseVerify Parses the output of `rpm -V`. Lines that aren't about files,
+// such as unsatisfied dependencies, are ignored
+func ParseVerify(r io.Reader) ([]packagefiles.Problem, error) {
+	problems := make([]packagefiles.Problem, 0)
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		matches := verifyLine.FindStringSubmatch(scanner.Text())
+
+		if matches == nil {
+			continue
+		}
+
+		problem := packagefiles.Problem{
+			Path:     matches[3],
+			Reasons:  make([]string, 0),
+			Conffile: matches[2] == "c",
+		}
+
+		if matches[1] == "missing" {
+			problem.Reasons = append(problem.Reasons, packagefiles.ReasonMissing)
+		} else {
+			for _, c := range matches[1] {
+				if reason, ok := verifyReasons[c]; ok {
+					problem.Reasons = append(problem.Reasons, reason)
+				}
+			}
+		}
+
+		if len(problem.Reasons) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems, scanner.Err()
+}
+
+// problemsByPackage Assigns problems to the packages that own the files, since
+// `rpm -Va` doesn't say which package each file belongs to. Every package in
+// files is included, with an empty list if it has no problems
+func problemsByPackage(problems []packagefiles.Problem, files map[string][]packagefiles.File) map[string][]packagefiles.Problem {
+	owners := make(map[string][]string)
+	byPackage := make(map[string][]packagefiles.Problem)
+
+	for name, fs := range files {
+		byPackage[name] = []packagefiles.Problem{}
+
+		for _, f := range fs {
+			owners[f.Path] = append(owners[f.Path], name)
+		}
+	}
+
+	for _, p := range problems {
+		for _, name := range owners[p.Path] {
+			byPackage[name] = append(byPackage[name], p)
+		}
+	}
+
+	return byPackage
+}
@@ -0,0 +1,151 @@
+package rpm
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+func TestParseVerify(t *testing.T) {
+	f, err := os.Open("test/rpm-verify")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	problems, err := ParseVerify(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []packagefiles.Problem{
+		{
+			Path:     "/etc/ssh/sshd_config",
+			Reasons:  []string{packagefiles.ReasonSize, packagefiles.ReasonChecksum, packagefiles.ReasonMtime},
+			Conffile: true,
+		},
+		{
+			Path:    "/usr/sbin/sshd",
+			Reasons: []string{packagefiles.ReasonMode},
+		},
+		{
+			Path:    "/usr/lib/systemd/system/sshd.service",
+			Reasons: []string{packagefiles.ReasonMissing},
+		},
+		{
+			Path:    "/usr/share/doc/openssh-server/README",
+			Reasons: []string{packagefiles.ReasonMtime},
+		},
+		{
+			Path:     "/etc/hosts",
+			Reasons:  []string{packagefiles.ReasonMissing},
+			Conffile: true,
+		},
+	}
+
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected %+v, got %+v", expected, problems)
+	}
+}
+
+func TestVerifyResult(t *testing.T) {
+	args := []string{"-V", "openssh-server"}
+	failed := &exec.ExitError{}
+
+	t.Run("verification failed", func(t *testing.T) {
+		problems, err := verifyResult(args, []byte("S.5....T.  c /etc/ssh/sshd_config\n"), "", failed)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(problems) != 1 {
+			t.Errorf("expected 1 problem, got %v", problems)
+		}
+	})
+
+	t.Run("passed", func(t *testing.T) {
+		problems, err := verifyResult(args, nil, "", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if problems == nil || len(problems) != 0 {
+			t.Errorf("expected no problems, got %v", problems)
+		}
+	})
+
+	t.Run("rpm failed", func(t *testing.T) {
+		_, err := verifyResult(args, nil, "error: can't create transaction lock on /var/lib/rpm/.rpm.lock\n", failed)
+
+		if err == nil {
+			t.Error("expected an error when rpm writes to stderr")
+		}
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		_, err := verifyResult(args, nil, "", failed)
+
+		if err == nil {
+			t.Error("expected an error when rpm fails without any output")
+		}
+	})
+}
+
+func TestProblemsByPackage(t *testing.T) {
+	files := testFiles(t)
+
+	v, err := os.Open("test/rpm-verify")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer v.Close()
+
+	problems, err := ParseVerify(v)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byPackage := problemsByPackage(problems, files)
+
+	if len(byPackage["openssh-server"]) != 4 || len(byPackage["setup"]) != 1 {
+		t.Fatalf("unexpected problems %+v", byPackage)
+	}
+
+	// Only the missing conffile is wrong with setup, which isn't a failure
+	if packagefiles.Failed(byPackage["setup"]) {
+		t.Error("expected setup not to have failed")
+	}
+
+	item, err := mapPackageToItem(Package{Name: "openssh-server"}, files["openssh-server"], 0, byPackage["openssh-server"], nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if integrity, _ := item.Attributes.Get("integrity"); integrity != "failed" {
+		t.Errorf("expected integrity failed, got %v", integrity)
+	}
+
+	if missing, _ := item.Attributes.Get("missingFiles"); !reflect.DeepEqual(missing, []interface{}{"/usr/lib/systemd/system/sshd.service"}) {
+		t.Errorf("unexpected missing files %v", missing)
+	}
+
+	if changed, _ := item.Attributes.Get("permissionChangedFiles"); !reflect.DeepEqual(changed, []interface{}{"/usr/sbin/sshd"}) {
+		t.Errorf("unexpected permission changed files %v", changed)
+	}
+
+	if conffiles, _ := item.Attributes.Get("modifiedConffiles"); !reflect.DeepEqual(conffiles, []interface{}{"/etc/ssh/sshd_config"}) {
+		t.Errorf("unexpected modified conffiles %v", conffiles)
+	}
+}
@@ -0,0 +1,177 @@
+package rpm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions Compares two version or release strings in the same way as
+// rpmvercmp. Returns a negative number if a is older than b, zero if they are
+// the same and a positive number if a is newer. Versions are split into
+// segments of digits or letters, with anything else acting as a separator.
+// Numeric segments are compared as numbers and are newer than alphabetic
+// ones. ~ sorts before anything (so 1.0~rc1 is older than 1.0) and ^ sorts
+// after the end of the version but before anything else
+func CompareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, isSeparator)
+		b = strings.TrimLeftFunc(b, isSeparator)
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+
+			a, b = a[1:], b[1:]
+
+			continue
+		}
+
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+
+			if b == "" {
+				return 1
+			}
+
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+
+			a, b = a[1:], b[1:]
+
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var segmentA, segmentB string
+		numeric := isDigit(rune(a[0]))
+
+		if numeric {
+			segmentA, a = cutSegment(a, isDigit)
+			segmentB, b = cutSegment(b, isDigit)
+		} else {
+			segmentA, a = cutSegment(a, isLetter)
+			segmentB, b = cutSegment(b, isLetter)
+		}
+
+		// The segments are different types, numbers are newer
+		if segmentB == "" {
+			if numeric {
+				return 1
+			}
+
+			return -1
+		}
+
+		if numeric {
+			segmentA = strings.TrimLeft(segmentA, "0")
+			segmentB = strings.TrimLeft(segmentB, "0")
+
+			if len(segmentA) != len(segmentB) {
+				return len(segmentA) - len(segmentB)
+			}
+		}
+
+		if cmp := strings.Compare(segmentA, segmentB); cmp != 0 {
+			return cmp
+		}
+	}
+
+	// Whichever version has segments left is newer
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// CompareEVR Compares two versions in the format [epoch:]version-release,
+// which is how installed and available versions are represented. A missing
+// epoch is the same as zero
+func CompareEVR(a, b string) int {
+	epochA, versionA, releaseA := parseEVR(a)
+	epochB, versionB, releaseB := parseEVR(b)
+
+	if epochA != epochB {
+		return epochA - epochB
+	}
+
+	if cmp := CompareVersions(versionA, versionB); cmp != 0 {
+		return cmp
+	}
+
+	return CompareVersions(releaseA, releaseB)
+}
+
+// FormatEVR Formats an epoch, version and release as [epoch:]version-release.
+// The epoch is left out if it is zero, in the same way as dnf
+func FormatEVR(epoch int, version string, release string) string {
+	if epoch == 0 {
+		return fmt.Sprintf("%v-%v", version, release)
+	}
+
+	return fmt.Sprintf("%v:%v-%v", epoch, version, release)
+}
+
+// parseEVR Splits [epoch:]version-release into its parts
+func parseEVR(evr string) (int, string, string) {
+	epoch := 0
+
+	if e, rest, found := strings.Cut(evr, ":"); found {
+		epoch, _ = strconv.Atoi(e)
+		evr = rest
+	}
+
+	if i := strings.LastIndex(evr, "-"); i >= 0 {
+		return epoch, evr[:i], evr[i+1:]
+	}
+
+	return epoch, evr, ""
+}
+
+// cutSegment Splits the leading characters that match f from the rest of the
+// string
+func cutSegment(s string, f func(rune) bool) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !f(r) })
+
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], s[i:]
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// isSeparator Returns whether a character separates the segments of a version
+func isSeparator(r rune) bool {
+	return !isDigit(r) && !isLetter(r) && r != '~' && r != '^'
+}
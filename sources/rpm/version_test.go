@@ -0,0 +1,78 @@
+package rpm
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		A        string
+		B        string
+		Expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.01", "1.1", 0},
+		{"1.0", "1.0.1", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0.1", -1},
+		{"2.0", "2a", 1},
+		{"1_0", "1.0", 0},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1.0^", "1.0^", 0},
+		{"27.el9", "24.el9", 1},
+		{"6.el9", "6.el9_2", -1},
+		{"2023c", "2024a", -1},
+		{"a", "b", -1},
+	}
+
+	for _, test := range tests {
+		cmp := CompareVersions(test.A, test.B)
+
+		switch {
+		case cmp < 0:
+			cmp = -1
+		case cmp > 0:
+			cmp = 1
+		}
+
+		if cmp != test.Expected {
+			t.Errorf("expected %v compared to %v to be %v, got %v", test.A, test.B, test.Expected, cmp)
+		}
+
+		if reverse := CompareVersions(test.B, test.A); (reverse < 0) != (test.Expected > 0) || (reverse == 0) != (test.Expected == 0) {
+			t.Errorf("expected %v compared to %v to be the opposite of %v, got %v", test.B, test.A, test.Expected, reverse)
+		}
+	}
+}
+
+func TestCompareEVR(t *testing.T) {
+	tests := []struct {
+		A        string
+		B        string
+		Expected int
+	}{
+		{"1:3.0.7-24.el9", "1:3.0.7-27.el9", -1},
+		{"1:1.0-1", "2.0-1", 1},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0-10", "1.0-9", 1},
+	}
+
+	for _, test := range tests {
+		cmp := CompareEVR(test.A, test.B)
+
+		if (cmp < 0) != (test.Expected < 0) || (cmp == 0) != (test.Expected == 0) {
+			t.Errorf("expected %v compared to %v to be %v, got %v", test.A, test.B, test.Expected, cmp)
+		}
+	}
+
+	if evr := FormatEVR(0, "5.1.8", "6.el9"); evr != "5.1.8-6.el9" {
+		t.Errorf("unexpected EVR %v", evr)
+	}
+
+	if evr := FormatEVR(1, "3.0.7", "27.el9"); evr != "1:3.0.7-27.el9" {
+		t.Errorf("unexpected EVR %v", evr)
+	}
+}
@@ -2,10 +2,13 @@ package sources
 
 import (
 	"github.com/overmindtech/discovery"
+	"github.com/overmindtech/overmind-agent/sources/apk"
 	"github.com/overmindtech/overmind-agent/sources/command"
 	"github.com/overmindtech/overmind-agent/sources/dpkg"
 	"github.com/overmindtech/overmind-agent/sources/etcdata"
 	"github.com/overmindtech/overmind-agent/sources/file_content"
+	"github.com/overmindtech/overmind-agent/sources/langpkg"
+	"github.com/overmindtech/overmind-agent/sources/pacman"
 	"github.com/overmindtech/overmind-agent/sources/psutil"
 	"github.com/overmindtech/overmind-agent/sources/rpm"
 	"github.com/overmindtech/overmind-agent/sources/system"
@@ -13,6 +16,87 @@ import (
 
 var Sources []discovery.Source
 
+// Config Options that change the behaviour of sources. This is populated from
+// the agent's config and applied using Configure()
+type Config struct {
+	// Whether process items should list their shared libraries
+	ProcessLibraries bool
+
+	// The maximum number of files that each package links to. Zero uses the
+	// default and a negative number disables the links
+	PackageFileLinks int
+
+	// Whether to verify the files owned by each package against the
+	// checksums recorded when it was installed
+	PackageVerify bool
+
+	// Whether to return connections in every state, such as TIME_WAIT and
+	// CLOSE_WAIT, rather than only those that are established
+	ConnectionAllStates bool
+
+	// The directories that are searched for Python, Node and Ruby packages,
+	// Go and Rust binaries, and Java archives. Nil uses the defaults
+	PythonPaths []string
+	NodePaths   []string
+	RubyPaths   []string
+	BinaryPaths []string
+	JavaPaths   []string
+}
+
+// Configure Applies config to all sources that support it, and removes the
+// sources that aren't supported with that config. This must be called before
+// the sources are added to the engine
+func Configure(c Config) {
+	configured := make([]discovery.Source, 0, len(Sources))
+
+	for _, source := range Sources {
+		// Whether language package sources are supported depends on their
+		// search paths, so isn't known until they have been configured
+		supported := true
+
+		switch s := source.(type) {
+		case *psutil.ProcessSource:
+			s.Libraries = c.ProcessLibraries
+		case *dpkg.DpkgSource:
+			s.FileLinkLimit = c.PackageFileLinks
+			s.Verify = c.PackageVerify
+		case *rpm.RPMSource:
+			s.FileLinkLimit = c.PackageFileLinks
+			s.Verify = c.PackageVerify
+		case *apk.ApkSource:
+			s.FileLinkLimit = c.PackageFileLinks
+		case *pacman.PacmanSource:
+			s.FileLinkLimit = c.PackageFileLinks
+		case *langpkg.PythonSource:
+			s.SearchPaths = c.PythonPaths
+			supported = s.Supported()
+		case *langpkg.NodeSource:
+			s.SearchPaths = c.NodePaths
+			supported = s.Supported()
+		case *langpkg.RubySource:
+			s.SearchPaths = c.RubyPaths
+			supported = s.Supported()
+		case *langpkg.GoSource:
+			s.SearchPaths = c.BinaryPaths
+			supported = s.Supported()
+		case *langpkg.RustSource:
+			s.SearchPaths = c.BinaryPaths
+			supported = s.Supported()
+		case *langpkg.JavaSource:
+			s.SearchPaths = c.JavaPaths
+			supported = s.Supported()
+		default:
+			configureOS(source, c)
+		}
+
+		if supported {
+			configured = append(configured, source)
+		}
+	}
+
+	Sources = configured
+}
+
 // Load sources that are abe to compile on all operating systems, burt check
 // that they are supported before actually loading them
 func init() {
@@ -22,6 +106,12 @@ func init() {
 	Sources = append(Sources, &system.SystemSource{})
 	Sources = append(Sources, &file_content.FileContentSource{})
 	Sources = append(Sources, &command.CommandSource{})
+	Sources = append(Sources, &langpkg.PythonSource{})
+	Sources = append(Sources, &langpkg.NodeSource{})
+	Sources = append(Sources, &langpkg.RubySource{})
+	Sources = append(Sources, &langpkg.GoSource{})
+	Sources = append(Sources, &langpkg.RustSource{})
+	Sources = append(Sources, &langpkg.JavaSource{})
 
 	dpkgSource := dpkg.DpkgSource{}
 
@@ -35,6 +125,18 @@ func init() {
 		Sources = append(Sources, &rpmSource)
 	}
 
+	apkSource := apk.ApkSource{}
+
+	if apkSource.Supported() {
+		Sources = append(Sources, &apkSource)
+	}
+
+	pacmanSource := pacman.PacmanSource{}
+
+	if pacmanSource.Supported() {
+		Sources = append(Sources, &pacmanSource)
+	}
+
 	groupsSource := etcdata.GroupsSource{}
 
 	if groupsSource.Supported() {
@@ -52,4 +154,10 @@ func init() {
 	if usersSource.Supported() {
 		Sources = append(Sources, &usersSource)
 	}
+
+	resolverSource := etcdata.ResolverSource{}
+
+	if resolverSource.Supported() {
+		Sources = append(Sources, &resolverSource)
+	}
 }
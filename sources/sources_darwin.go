@@ -1,7 +1,14 @@
 package sources
 
-import "github.com/overmindtech/overmind-agent/sources/unix"
+import (
+	"github.com/overmindtech/discovery"
+	"github.com/overmindtech/overmind-agent/sources/unix"
+)
 
 func init() {
 	Sources = append(Sources, &unix.FileSource{})
 }
+
+// configureOS Applies config to sources that are specific to this operating
+// system. There aren't any on darwin
+func configureOS(source discovery.Source, c Config) {}
@@ -1,7 +1,12 @@
 package sources
 
 import (
+	"github.com/overmindtech/discovery"
+	"github.com/overmindtech/overmind-agent/sources/firewall"
+	"github.com/overmindtech/overmind-agent/sources/netlink"
 	"github.com/overmindtech/overmind-agent/sources/netstat"
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+	"github.com/overmindtech/overmind-agent/sources/sysfs"
 	"github.com/overmindtech/overmind-agent/sources/systemd"
 	"github.com/overmindtech/overmind-agent/sources/unix"
 )
@@ -15,9 +20,65 @@ func init() {
 		Sources = append(Sources, &netstatSource)
 	}
 
+	connectionSource := netstat.ConnectionSource{}
+
+	if connectionSource.Supported() {
+		Sources = append(Sources, &connectionSource)
+	}
+
+	namespaceSource := procfs.NamespaceSource{}
+
+	if namespaceSource.Supported() {
+		Sources = append(Sources, &namespaceSource)
+	}
+
+	unixSocketSource := procfs.UnixSocketSource{}
+
+	if unixSocketSource.Supported() {
+		Sources = append(Sources, &unixSocketSource)
+	}
+
+	interfaceSource := sysfs.InterfaceSource{}
+
+	if interfaceSource.Supported() {
+		Sources = append(Sources, &interfaceSource)
+	}
+
+	routeSource := netlink.RouteSource{}
+
+	if routeSource.Supported() {
+		Sources = append(Sources, &routeSource)
+	}
+
+	ruleSource := netlink.RuleSource{}
+
+	if ruleSource.Supported() {
+		Sources = append(Sources, &ruleSource)
+	}
+
+	neighbourSource := netlink.NeighbourSource{}
+
+	if neighbourSource.Supported() {
+		Sources = append(Sources, &neighbourSource)
+	}
+
+	firewallRuleSource := firewall.FirewallRuleSource{}
+
+	if firewallRuleSource.Supported() {
+		Sources = append(Sources, &firewallRuleSource)
+	}
+
 	systemdSource := systemd.ServiceSource{}
 
 	if systemdSource.Supported() {
 		Sources = append(Sources, &systemdSource)
 	}
 }
+
+// configureOS Applies config to sources that are specific to this operating
+// system
+func configureOS(source discovery.Source, c Config) {
+	if s, ok := source.(*netstat.ConnectionSource); ok {
+		s.AllStates = c.ConnectionAllStates
+	}
+}
@@ -1,6 +1,9 @@
 package sources
 
-import "github.com/overmindtech/overmind-agent/sources/netstat"
+import (
+	"github.com/overmindtech/discovery"
+	"github.com/overmindtech/overmind-agent/sources/netstat"
+)
 
 func init() {
 	netstatSource := netstat.PortSource{}
@@ -8,4 +11,18 @@ func init() {
 	if netstatSource.Supported() {
 		Sources = append(Sources, &netstatSource)
 	}
+
+	connectionSource := netstat.ConnectionSource{}
+
+	if connectionSource.Supported() {
+		Sources = append(Sources, &connectionSource)
+	}
+}
+
+// configureOS Applies config to sources that are specific to this operating
+// system
+func configureOS(source discovery.Source, c Config) {
+	if s, ok := source.(*netstat.ConnectionSource); ok {
+		s.AllStates = c.ConnectionAllStates
+	}
 }
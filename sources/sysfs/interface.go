@@ -0,0 +1,226 @@
+package sysfs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of interface
+const (
+	KindLoopback = "loopback"
+	KindPhysical = "physical"
+	KindBridge   = "bridge"
+	KindBond     = "bond"
+	KindVLAN     = "vlan"
+	KindVeth     = "veth"
+	KindTun      = "tun"
+	KindTap      = "tap"
+	KindVirtual  = "virtual"
+)
+
+// arphrdLoopback The hardware type of loopback interfaces, see
+// include/uapi/linux/if_arp.h
+const arphrdLoopback = 772
+
+// iffTap The flag that is set in tun_flags for tap (rather than tun) devices
+const iffTap = 0x0002
+
+// Interface Details of a network interface from /sys/class/net/{name}
+type Interface struct {
+	Name      string
+	Index     int64
+	Kind      string
+	MTU       int64
+	MAC       string
+	OperState string
+
+	// Speed in Mb/s, 0 if unknown
+	Speed int64
+
+	// The name of the interface that this one is enslaved to e.g. the bridge or
+	// bond
+	Master string
+
+	// The name of the interface that this one is stacked on top of, e.g. the
+	// parent of a VLAN. Empty for veths, and if the parent is in another
+	// namespace
+	Parent string
+
+	// Counters from the statistics directory e.g. rx_bytes
+	Statistics map[string]int64
+}
+
+// StatisticsCounters The counters that are read from the statistics directory
+var StatisticsCounters = []string{
+	"rx_bytes",
+	"tx_bytes",
+	"rx_packets",
+	"tx_packets",
+	"rx_errors",
+	"tx_errors",
+	"rx_dropped",
+	"tx_dropped",
+}
+
+// InterfaceNames Returns the names of all network interfaces, sorted
+func (s SysFS) InterfaceNames() ([]string, error) {
+	entries, err := os.ReadDir(s.Path("class", "net"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Interface Reads the details of a single network interface
+func (s SysFS) Interface(name string) (Interface, error) {
+	var err error
+
+	dir := []string{"class", "net", name}
+	iface := Interface{
+		Name:       name,
+		Statistics: make(map[string]int64),
+	}
+
+	if iface.Index, err = s.ReadInt(append(dir, "ifindex")...); err != nil {
+		return Interface{}, err
+	}
+
+	iface.MTU, _ = s.ReadInt(append(dir, "mtu")...)
+	iface.MAC, _ = s.ReadString(append(dir, "address")...)
+	iface.OperState, _ = s.ReadString(append(dir, "operstate")...)
+
+	// Reading the speed of virtual interfaces returns an error, and
+	// disconnected interfaces report -1
+	if speed, err := s.ReadInt(append(dir, "speed")...); err == nil && speed > 0 {
+		iface.Speed = speed
+	}
+
+	if master, err := os.Readlink(s.Path(append(dir, "master")...)); err == nil {
+		iface.Master = filepath.Base(master)
+	}
+
+	for _, counter := range StatisticsCounters {
+		if value, err := s.ReadInt(append(dir, "statistics", counter)...); err == nil {
+			iface.Statistics[counter] = value
+		}
+	}
+
+	iface.Kind = s.interfaceKind(name)
+
+	// iflink is the index of the interface that this one is linked to. For
+	// VLANs this is the parent, for veths it's the peer, which isn't a parent
+	// and is usually in another namespace. If link_netnsid exists the linked
+	// interface is in another namespace, so its index can't be looked up here
+	if iface.Kind == KindVeth || s.Exists(append(dir, "link_netnsid")...) {
+		return iface, nil
+	}
+
+	if iflink, err := s.ReadInt(append(dir, "iflink")...); err == nil && iflink != iface.Index {
+		if parent, err := s.interfaceByIndex(iflink); err == nil {
+			iface.Parent = parent
+		}
+	}
+
+	return iface, nil
+}
+
+// interfaceKind Works out what kind of interface this is from the files that
+// are present in sysfs
+func (s SysFS) interfaceKind(name string) string {
+	dir := []string{"class", "net", name}
+
+	if hwType, err := s.ReadInt(append(dir, "type")...); err == nil && hwType == arphrdLoopback {
+		return KindLoopback
+	}
+
+	switch s.devType(name) {
+	case "vlan":
+		return KindVLAN
+	case "bridge":
+		return KindBridge
+	case "bond":
+		return KindBond
+	}
+
+	if s.Exists(append(dir, "bridge")...) {
+		return KindBridge
+	}
+
+	if s.Exists(append(dir, "bonding")...) {
+		return KindBond
+	}
+
+	if flags, err := s.ReadString(append(dir, "tun_flags")...); err == nil {
+		if f, err := strconv.ParseInt(strings.TrimPrefix(flags, "0x"), 16, 64); err == nil && f&iffTap != 0 {
+			return KindTap
+		}
+
+		return KindTun
+	}
+
+	if s.Exists(append(dir, "device")...) {
+		return KindPhysical
+	}
+
+	// veths are linked to their peer, which is a different interface
+	index, iErr := s.ReadInt(append(dir, "ifindex")...)
+	iflink, lErr := s.ReadInt(append(dir, "iflink")...)
+
+	if iErr == nil && lErr == nil && index != iflink {
+		return KindVeth
+	}
+
+	return KindVirtual
+}
+
+// devType Returns the DEVTYPE from the uevent file of an interface
+func (s SysFS) devType(name string) string {
+	file, err := os.Open(s.Path("class", "net", name, "uevent"))
+
+	if err != nil {
+		return ""
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "DEVTYPE=") {
+			return strings.TrimPrefix(line, "DEVTYPE=")
+		}
+	}
+
+	return ""
+}
+
+// interfaceByIndex Returns the name of the interface with a given index
+func (s SysFS) interfaceByIndex(index int64) (string, error) {
+	names, err := s.InterfaceNames()
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range names {
+		if i, err := s.ReadInt("class", "net", name, "ifindex"); err == nil && i == index {
+			return name, nil
+		}
+	}
+
+	return "", os.ErrNotExist
+}
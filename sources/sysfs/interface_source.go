@@ -0,0 +1,268 @@
+package sysfs
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// InterfaceSource Returns details of the network interfaces on the host, as
+// read from /sys/class/net
+type InterfaceSource struct {
+	// Where sysfs is mounted if not the default location (optional)
+	SysLocation string
+
+	// The function that is used to get the addresses of an interface. If this
+	// is nil the addresses will be looked up using the net package (optional)
+	AddrsFunction func(name string) ([]net.Addr, error)
+}
+
+// Sys Returns the SysFS that should be used by the source
+func (s *InterfaceSource) Sys() SysFS {
+	return SysFS{Location: s.SysLocation}
+}
+
+// Type is the type of items that this returns (Required)
+func (s *InterfaceSource) Type() string {
+	return "interface"
+}
+
+// Name Returns the name of the backend package. This is used for
+// debugging and logging (Required)
+func (s *InterfaceSource) Name() string {
+	return "sysfs"
+}
+
+// Weighting of duplicate sources
+func (s *InterfaceSource) Weight() int {
+	return 100
+}
+
+// List of contexts that this source is capable of find items for
+func (s *InterfaceSource) Contexts() []string {
+	return []string{
+		util.LocalContext,
+	}
+}
+
+// Get Gets an interface by its name e.g. eth0
+func (s *InterfaceSource) Get(ctx context.Context, itemContext string, query string) (*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	sys := s.Sys()
+
+	iface, err := sys.Interface(query)
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOTFOUND,
+			ErrorString: fmt.Sprintf("interface %v not found: %v", query, err),
+			Context:     itemContext,
+		}
+	}
+
+	// Slaves aren't recorded against the master in a consistent way, so
+	// instead look at all interfaces to see which are enslaved to this one
+	all, err := s.interfaces()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	return s.interfaceToItem(iface, all)
+}
+
+// Find Returns all network interfaces
+func (s *InterfaceSource) Find(ctx context.Context, itemContext string) ([]*sdp.Item, error) {
+	if itemContext != util.LocalContext {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_NOCONTEXT,
+			ErrorString: fmt.Sprintf("context %v not available, local context is %v", itemContext, util.LocalContext),
+			Context:     itemContext,
+		}
+	}
+
+	all, err := s.interfaces()
+
+	if err != nil {
+		return nil, &sdp.ItemRequestError{
+			ErrorType:   sdp.ItemRequestError_OTHER,
+			ErrorString: err.Error(),
+			Context:     itemContext,
+		}
+	}
+
+	items := make([]*sdp.Item, 0)
+
+	for _, iface := range all {
+		if item, err := s.interfaceToItem(iface, all); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items, nil
+}
+
+// Supported Returns whether network interfaces can be read from sysfs on this
+// system
+func (s *InterfaceSource) Supported() bool {
+	_, err := os.Stat(s.Sys().Path("class", "net"))
+
+	return err == nil
+}
+
+// interfaces Reads all interfaces. Interfaces that can't be read, usually
+// because they were removed while we were looking, are skipped
+func (s *InterfaceSource) interfaces() ([]Interface, error) {
+	sys := s.Sys()
+
+	names, err := sys.InterfaceNames()
+
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make([]Interface, 0, len(names))
+
+	for _, name := range names {
+		if iface, err := sys.Interface(name); err == nil {
+			interfaces = append(interfaces, iface)
+		}
+	}
+
+	return interfaces, nil
+}
+
+// addresses Returns the addresses of an interface in CIDR notation
+func (s *InterfaceSource) addresses(name string) []string {
+	addrsFunction := s.AddrsFunction
+
+	if addrsFunction == nil {
+		addrsFunction = func(name string) ([]net.Addr, error) {
+			iface, err := net.InterfaceByName(name)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return iface.Addrs()
+		}
+	}
+
+	addrs, err := addrsFunction(name)
+
+	if err != nil {
+		return nil
+	}
+
+	addresses := make([]string, 0, len(addrs))
+
+	for _, addr := range addrs {
+		addresses = append(addresses, addr.String())
+	}
+
+	return addresses
+}
+
+// interfaceToItem Converts an interface to an item, all is the full list of
+// interfaces which is used to find slaves
+func (s *InterfaceSource) interfaceToItem(iface Interface, all []Interface) (*sdp.Item, error) {
+	var slaves []string
+
+	for _, other := range all {
+		if other.Master == iface.Name {
+			slaves = append(slaves, other.Name)
+		}
+	}
+
+	addresses := s.addresses(iface.Name)
+
+	attrs := map[string]interface{}{
+		"name":       iface.Name,
+		"index":      iface.Index,
+		"kind":       iface.Kind,
+		"mtu":        iface.MTU,
+		"operstate":  iface.OperState,
+		"addresses":  addresses,
+		"statistics": iface.Statistics,
+	}
+
+	if iface.MAC != "" {
+		attrs["mac"] = iface.MAC
+	}
+
+	if iface.Speed > 0 {
+		attrs["speed"] = iface.Speed
+	}
+
+	if iface.Master != "" {
+		attrs["master"] = iface.Master
+	}
+
+	if iface.Parent != "" {
+		attrs["parent"] = iface.Parent
+	}
+
+	if len(slaves) > 0 {
+		attrs["slaves"] = slaves
+	}
+
+	attributes, err := sdp.ToAttributes(attrs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	item := sdp.Item{
+		Type:            "interface",
+		UniqueAttribute: "name",
+		Attributes:      attributes,
+		Context:         util.LocalContext,
+	}
+
+	for _, address := range addresses {
+		ip, _, err := net.ParseCIDR(address)
+
+		if err != nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "ip",
+			Method:  sdp.RequestMethod_GET,
+			Query:   ip.String(),
+			Context: "global",
+		})
+	}
+
+	related := append([]string{iface.Master, iface.Parent}, slaves...)
+
+	for _, name := range related {
+		if name == "" {
+			continue
+		}
+
+		item.LinkedItemRequests = append(item.LinkedItemRequests, &sdp.ItemRequest{
+			Type:    "interface",
+			Method:  sdp.RequestMethod_GET,
+			Query:   name,
+			Context: util.LocalContext,
+		})
+	}
+
+	return &item, nil
+}
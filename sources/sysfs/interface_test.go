@@ -0,0 +1,217 @@
+package sysfs
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+var testSys = SysFS{Location: "test/sys"}
+
+// testAddrs Returns fixed addresses so that tests don't depend on the
+// interfaces of the machine running them
+func testAddrs(name string) ([]net.Addr, error) {
+	addrs := map[string][]string{
+		"lo":        {"127.0.0.1/8", "::1/128"},
+		"bond0":     {"10.0.0.5/24", "fe80::1/64"},
+		"bond0.100": {"192.168.100.2/24"},
+	}
+
+	result := make([]net.Addr, 0)
+
+	for _, cidr := range addrs[name] {
+		ip, ipNet, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ipNet.IP = ip
+		result = append(result, ipNet)
+	}
+
+	return result, nil
+}
+
+func TestInterfaceKinds(t *testing.T) {
+	expected := map[string]string{
+		"lo":        KindLoopback,
+		"eth0":      KindPhysical,
+		"eth1":      KindPhysical,
+		"bond0":     KindBond,
+		"bond0.100": KindVLAN,
+		"br0":       KindBridge,
+		"veth1a2b":  KindVeth,
+		"tun0":      KindTun,
+		"tap0":      KindTap,
+	}
+
+	for name, kind := range expected {
+		iface, err := testSys.Interface(name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if iface.Kind != kind {
+			t.Errorf("expected %v to be %v, got %v", name, kind, iface.Kind)
+		}
+	}
+}
+
+func TestInterface(t *testing.T) {
+	iface, err := testSys.Interface("eth0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if iface.Master != "bond0" {
+		t.Errorf("expected master bond0, got %v", iface.Master)
+	}
+
+	if iface.Speed != 1000 {
+		t.Errorf("expected speed 1000, got %v", iface.Speed)
+	}
+
+	if iface.Statistics["rx_bytes"] != 2000 {
+		t.Errorf("expected rx_bytes 2000, got %v", iface.Statistics["rx_bytes"])
+	}
+
+	vlan, err := testSys.Interface("bond0.100")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vlan.Parent != "bond0" {
+		t.Errorf("expected parent bond0, got %v", vlan.Parent)
+	}
+
+	// The peer of this veth is in another namespace, and has the same index
+	// as eth0 does in this one
+	veth, err := testSys.Interface("veth1a2b")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if veth.Parent != "" {
+		t.Errorf("expected no parent, got %v", veth.Parent)
+	}
+}
+
+func TestInterfaceSource(t *testing.T) {
+	source := InterfaceSource{
+		SysLocation:   "test/sys",
+		AddrsFunction: testAddrs,
+	}
+
+	tests := []util.SourceTest{
+		{
+			Name:        "get bond",
+			ItemContext: util.LocalContext,
+			Query:       "bond0",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 1,
+				ExpectedAttributes: []map[string]interface{}{
+					{
+						"name":      "bond0",
+						"kind":      KindBond,
+						"mtu":       float64(1500),
+						"operstate": "up",
+						"slaves":    []interface{}{"eth0", "eth1"},
+						"addresses": []interface{}{"10.0.0.5/24", "fe80::1/64"},
+					},
+				},
+			},
+		},
+		{
+			Name:        "get missing interface",
+			ItemContext: util.LocalContext,
+			Query:       "eth9",
+			Method:      sdp.RequestMethod_GET,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOTFOUND,
+			},
+		},
+		{
+			Name:        "find",
+			ItemContext: util.LocalContext,
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedItems: &util.ExpectedItems{
+				NumItems: 9,
+			},
+		},
+		{
+			Name:        "bad context",
+			ItemContext: "bad",
+			Method:      sdp.RequestMethod_FIND,
+			ExpectedError: &util.ExpectedError{
+				Type: sdp.ItemRequestError_NOCONTEXT,
+			},
+		},
+	}
+
+	util.RunSourceTests(t, tests, &source)
+}
+
+func TestInterfaceLinks(t *testing.T) {
+	source := InterfaceSource{
+		SysLocation:   "test/sys",
+		AddrsFunction: testAddrs,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "bond0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The link-local address shouldn't be linked
+	expected := []struct {
+		Type  string
+		Query string
+	}{
+		{"ip", "10.0.0.5"},
+		{"interface", "eth0"},
+		{"interface", "eth1"},
+	}
+
+	if len(item.LinkedItemRequests) != len(expected) {
+		t.Fatalf("expected %v links, got %v", len(expected), item.LinkedItemRequests)
+	}
+
+	for i, e := range expected {
+		link := item.LinkedItemRequests[i]
+
+		if link.Type != e.Type || link.Query != e.Query {
+			t.Errorf("expected link %v to be %v %v, got %v %v", i, e.Type, e.Query, link.Type, link.Query)
+		}
+	}
+}
+
+func TestInterfaceLinksVeth(t *testing.T) {
+	source := InterfaceSource{
+		SysLocation:   "test/sys",
+		AddrsFunction: testAddrs,
+	}
+
+	item, err := source.Get(context.Background(), util.LocalContext, "veth1a2b")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the bridge should be linked, not eth0 which has the same index as
+	// the peer
+	for _, link := range item.LinkedItemRequests {
+		if link.Type == "interface" && link.Query != "br0" {
+			t.Errorf("expected only a link to br0, got %v %v", link.Type, link.Query)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// This package reads information from sysfs. Everything in here will simply
+// return errors on systems that don't have a sysfs mount
+
+// DefaultSysLocation The default location where sysfs is mounted
+const DefaultSysLocation = "/sys"
+
+// SysFS Reads information from a sysfs mount
+type SysFS struct {
+	// Where sysfs is mounted if not the default location (optional)
+	Location string
+}
+
+// Path Returns the path to a file within sysfs
+func (s SysFS) Path(elem ...string) string {
+	location := s.Location
+
+	if location == "" {
+		location = DefaultSysLocation
+	}
+
+	return filepath.Join(append([]string{location}, elem...)...)
+}
+
+// ReadString Reads a single-value sysfs file, with surrounding whitespace
+// removed
+func (s SysFS) ReadString(elem ...string) (string, error) {
+	b, err := os.ReadFile(s.Path(elem...))
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// ReadInt Reads a single-value sysfs file containing a base 10 integer
+func (s SysFS) ReadInt(elem ...string) (int64, error) {
+	value, err := s.ReadString(elem...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Exists Returns whether a file exists within sysfs
+func (s SysFS) Exists(elem ...string) bool {
+	_, err := os.Lstat(s.Path(elem...))
+
+	return err == nil
+}
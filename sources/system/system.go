@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"runtime"
 
 	"github.com/elastic/go-sysinfo"
 	"github.com/elastic/go-sysinfo/types"
@@ -167,6 +168,19 @@ func (s *SystemSource) Find(ctx context.Context, itemContext string) ([]*sdp.Ite
 		})
 	}
 
+	// Create a linked item for each network interface. The interface source
+	// reads sysfs so these can only be resolved on linux
+	if interfaces, err := net.Interfaces(); err == nil && runtime.GOOS == "linux" {
+		for _, iface := range interfaces {
+			systemItem.LinkedItemRequests = append(systemItem.LinkedItemRequests, &sdp.ItemRequest{
+				Type:    "interface",
+				Method:  sdp.RequestMethod_GET,
+				Query:   iface.Name,
+				Context: util.LocalContext,
+			})
+		}
+	}
+
 	foundItems = []*sdp.Item{
 		systemItem,
 	}
@@ -0,0 +1,135 @@
+//go:build linux
+// +build linux
+
+package systemd
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/overmindtech/overmind-agent/sources/procfs"
+)
+
+// CgroupLocations The places that the cgroup hierarchy managed by systemd can
+// be mounted. The first is used on systems with cgroup v2 (unified), the rest
+// are used on v1 and hybrid systems
+var CgroupLocations = []string{
+	"/sys/fs/cgroup",
+	"/sys/fs/cgroup/unified",
+	"/sys/fs/cgroup/systemd",
+}
+
+// cgroupPIDs Returns the PIDs of all processes in a given control group e.g.
+// /system.slice/nginx.service, including those in the cgroups below it. Units
+// with Delegate=yes, such as container runtimes, create their own child
+// cgroups and move their processes into them
+func cgroupPIDs(cgroup string) ([]int, error) {
+	var err error
+	var root string
+
+	for _, location := range CgroupLocations {
+		root = filepath.Join(location, cgroup)
+
+		if _, err = os.Stat(filepath.Join(root, "cgroup.procs")); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make([]int, 0)
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Child cgroups can be removed while walking
+			if path != root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		procs, err := readCgroupProcs(filepath.Join(path, "cgroup.procs"))
+
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		pids = append(pids, procs...)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(pids)
+
+	return pids, nil
+}
+
+// readCgroupProcs Reads the PIDs from a cgroup.procs file
+func readCgroupProcs(path string) ([]int, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	pids := make([]int, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if pid, err := strconv.Atoi(scanner.Text()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids, scanner.Err()
+}
+
+// staleFiles Returns the distinct list of deleted or replaced files that
+// processes in a given control group are still running from. Returns an
+// error if the processes in the cgroup can't be listed
+func staleFiles(cgroup string) ([]string, error) {
+	proc := procfs.ProcFS{}
+	stale := make(map[string]bool)
+
+	pids, err := cgroupPIDs(cgroup)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pid := range pids {
+		if files, err := proc.StaleFiles(pid); err == nil {
+			for _, f := range files {
+				stale[f] = true
+			}
+		}
+	}
+
+	files := make([]string, 0, len(stale))
+
+	for f := range stale {
+		files = append(files, f)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,52 @@
+//go:build linux
+// +build linux
+
+package systemd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgroupPIDs(t *testing.T) {
+	root := t.TempDir()
+	cgroup := "/system.slice/nginx.service"
+
+	if err := os.MkdirAll(filepath.Join(root, cgroup), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, cgroup, "cgroup.procs"), []byte("812\n813\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A delegated child cgroup, as created by container runtimes
+	delegated := filepath.Join(root, cgroup, "payload", "app")
+
+	if err := os.MkdirAll(delegated, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(delegated, "cgroup.procs"), []byte("900\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultLocations := CgroupLocations
+	CgroupLocations = []string{filepath.Join(root, "missing"), root}
+	defer func() { CgroupLocations = defaultLocations }()
+
+	pids, err := cgroupPIDs(cgroup)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pids) != 3 || pids[0] != 812 || pids[1] != 813 || pids[2] != 900 {
+		t.Errorf("expected [812 813 900], got %v", pids)
+	}
+
+	if _, err = cgroupPIDs("/system.slice/missing.service"); err == nil {
+		t.Error("expected error for missing cgroup")
+	}
+}
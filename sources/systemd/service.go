@@ -589,6 +589,17 @@ func (s *ServiceSource) mapUnitToItem(ctx context.Context, u dbus.UnitStatus) (*
 		}
 	}
 
+	// Check whether any of the processes in the service are running deleted
+	// binaries or libraries, meaning that the service needs to be restarted to
+	// pick up the new versions e.g. after a security patch
+	if prop, err := c.GetUnitTypePropertyContext(ctx, u.Name, "Service", "ControlGroup"); err == nil {
+		if cgroup, ok := prop.Value.Value().(string); ok && cgroup != "" {
+			if stale, err := staleFiles(cgroup); err == nil {
+				a["NeedsRestart"] = stale
+			}
+		}
+	}
+
 	attributes, err = sdp.ToAttributes(a)
 
 	if err != nil {
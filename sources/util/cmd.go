@@ -1,9 +1,11 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // Run runs a command and returns only the bytemap, panicing on error
@@ -28,3 +30,30 @@ func Run(args []string) []byte {
 
 	return out
 }
+
+// RunFunction Runs a command and returns its output. Sources that run
+// commands accept one of these so that tests can replace the command
+type RunFunction func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+// RunContext Runs a command and returns its stdout, including stderr in the
+// error if the command fails. The command is killed if the context is
+// cancelled
+func RunContext(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+
+	if err != nil {
+		if e, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf(
+				"Error running command `%v %v`: %v\nOutput: %v",
+				name,
+				strings.Join(args, " "),
+				err,
+				string(e.Stderr),
+			)
+		}
+
+		return nil, err
+	}
+
+	return output, nil
+}
@@ -1,71 +1,207 @@
 package network
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/overmindtech/overmind-agent/sources/util"
 )
 
-// Port represents the details of a port
-type Port struct {
-	Number int
-	Listen string
-	PID    int
+// Protocols that ports can be reported for
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// Process A process that has a socket open
+type Process struct {
+	PID  int
+	Name string
 }
 
-// Compile the regex for looking at ports once only then store in memory
-var tcpRegex = regexp.MustCompile(`(?m)^\d+\s+\d+\s+(?P<listenAddress>.*?):(?P<port>\d+)\s.*?pid=(?P<pid>\d+)`)
+// Port represents the details of a listening TCP port or a bound UDP port
+type Port struct {
+	Protocol string
+	Number   uint16
 
-func ssCmd(mode string) []string {
-	switch mode {
-	case "tcp":
-		return []string{"ss", "--tcp", "--listening", "--numeric", "--no-header", "--process", "--all", "state", "listening"}
-	}
+	// The IP that the port is listening on, this will be unspecified (0.0.0.0
+	// or ::) if it is listening on all IPs
+	Listen net.IP
+
+	// The state as reported by ss e.g. LISTEN or UNCONN
+	State string
 
-	// Return an empty string if nothing is  matched
-	return []string{}
+	// All of the processes that have the socket open, sorted by PID. There
+	// can be more than one if the socket is shared e.g. between the workers of
+	// a pre-forking server
+	Processes []Process
 }
 
-// Supported tells us if Puppet works on the current machine
+// ssArgs The arguments that are passed to ss. --no-header isn't used since it
+// isn't supported by older versions, the header is skipped when parsing
+// instead
+var ssArgs = []string{"--tcp", "--udp", "--listening", "--numeric", "--processes"}
+
+// processRegex Matches a process in the users:(...) column of ss e.g.
+// ("sshd",pid=812,fd=3)
+var processRegex = regexp.MustCompile(`\("((?:[^"\\]|\\.)*)",pid=(\d+),`)
+
+// Supported tells us if ss is installed on the current machine
 func Supported() bool {
 	_, err := exec.LookPath("ss")
 	return err == nil
 }
 
-// ListeningPorts returns the TCP ports that are listingin on the system
-func ListeningPorts() []Port {
-	var ports []Port
-	// tcpRegex := regexp.MustCompile(`(?m)^\d+\s+\d+\s+(?P<listenAddress>.*?):(?P<port>\d+)\s.*?pid=(?P<pid>\d+)`)
+// ListeningPorts returns the TCP ports that are listening and the UDP ports
+// that are bound on the system, as reported by ss. If run is nil the command
+// is executed using util.RunContext
+func ListeningPorts(ctx context.Context, run util.RunFunction) ([]Port, error) {
+	if run == nil {
+		run = util.RunContext
+	}
+
+	out, err := run(ctx, "ss", ssArgs...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSS(strings.NewReader(string(out)))
+}
+
+// ParseSS Parses the output of `ss --tcp --udp --listening --numeric
+// --processes`. The format is:
+//
+//	Netid State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+//	udp   UNCONN 0      0      127.0.0.53%lo:53   0.0.0.0:*         users:(("systemd-resolve",pid=612,fd=13))
+//	tcp   LISTEN 0      128    [::]:22            [::]:*            users:(("sshd",pid=812,fd=4))
+//
+// Sockets that aren't TCP or UDP are ignored, as are lines that can't be
+// parsed
+func ParseSS(r io.Reader) ([]Port, error) {
+	ports := make([]Port, 0)
+	scanner := bufio.NewScanner(r)
 
-	// Run the ss command
-	out := string(util.Run(ssCmd("tcp")))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
 
-	// Match the output
-	caps := util.RegexpNamedCaptures(tcpRegex, out)
+		if len(fields) == 0 || fields[0] == "Netid" {
+			continue
+		}
 
-	// Convert to []Port
-	for _, details := range caps {
-		// Convert integers
-		number, err := strconv.Atoi(details["port"])
+		if len(fields) < 6 {
+			continue
+		}
+
+		protocol := fields[0]
+
+		if protocol != ProtocolTCP && protocol != ProtocolUDP {
+			continue
+		}
+
+		ip, number, err := parseSSAddress(fields[4])
 
 		if err != nil {
-			panic(fmt.Sprintf("Could not convert port details to integers: %v. Capture: %v", details["port"], details))
+			continue
 		}
 
-		pid, err := strconv.Atoi(details["pid"])
+		processes, err := parseSSProcesses(strings.Join(fields[6:], " "))
 
 		if err != nil {
-			panic(fmt.Sprintf("Could not convert port details to integers: %v. Capture: %v", details["pid"], details))
+			continue
 		}
 
 		ports = append(ports, Port{
-			Number: number,
-			Listen: details["listenAddress"],
-			PID:    pid,
+			Protocol:  protocol,
+			Number:    number,
+			Listen:    ip,
+			State:     fields[1],
+			Processes: processes,
+		})
+	}
+
+	return ports, scanner.Err()
+}
+
+// parseSSAddress Parses a local address from ss. These can be in any of the
+// following formats:
+//
+//   - 0.0.0.0:22
+//   - 127.0.0.53%lo:53 (bound to an interface)
+//   - [::]:22 or [fe80::1]%eth0:546
+//   - :::22 (IPv6 from older versions of ss)
+//   - *:22 (listening on all IPv4 and IPv6 addresses)
+func parseSSAddress(address string) (net.IP, uint16, error) {
+	i := strings.LastIndex(address, ":")
+
+	if i == -1 {
+		return nil, 0, fmt.Errorf("address %v does not contain a port", address)
+	}
+
+	host := address[:i]
+	number, err := strconv.ParseUint(address[i+1:], 10, 16)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse port in %v: %v", address, err)
+	}
+
+	// Remove the interface that the socket is bound to. Newer versions of ss
+	// put this outside of the brackets e.g. [fe80::1]%eth0:546
+	if h, _, found := strings.Cut(host, "%"); found {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if host == "*" || host == "::" || host == "" {
+		return net.IPv6unspecified, uint16(number), nil
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil {
+		return nil, 0, fmt.Errorf("could not parse IP %v in %v", host, address)
+	}
+
+	return ip, uint16(number), nil
+}
+
+// parseSSProcesses Parses the process column from ss e.g.
+// users:(("nginx",pid=1201,fd=6),("nginx",pid=1200,fd=6)). The column is
+// empty if the caller doesn't have permission to see the process
+func parseSSProcesses(column string) ([]Process, error) {
+	processes := make([]Process, 0)
+	seen := make(map[int]bool)
+
+	for _, match := range processRegex.FindAllStringSubmatch(column, -1) {
+		pid, err := strconv.Atoi(match[2])
+
+		if err != nil {
+			return nil, fmt.Errorf("could not convert PID %v to integer: %v", match[2], err)
+		}
+
+		// A process appears once per file descriptor
+		if seen[pid] {
+			continue
+		}
+
+		seen[pid] = true
+
+		processes = append(processes, Process{
+			PID:  pid,
+			Name: match[1],
 		})
 	}
-	return ports
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+
+	return processes, nil
 }
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSS(t *testing.T) {
+	f, err := os.Open("test/ss")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ports, err := ParseSS(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ports) != 9 {
+		t.Fatalf("expected 9 ports, got %v", len(ports))
+	}
+
+	t.Run("udp bound to interface", func(t *testing.T) {
+		p := ports[0]
+
+		if p.Protocol != ProtocolUDP || p.Number != 53 || p.State != "UNCONN" {
+			t.Errorf("unexpected port %+v", p)
+		}
+
+		if !p.Listen.Equal(net.ParseIP("127.0.0.53")) {
+			t.Errorf("expected 127.0.0.53, got %v", p.Listen)
+		}
+
+		if !reflect.DeepEqual(p.Processes, []Process{{PID: 612, Name: "systemd-resolve"}}) {
+			t.Errorf("unexpected processes %v", p.Processes)
+		}
+	})
+
+	t.Run("bracketed ipv6 with zone", func(t *testing.T) {
+		p := ports[2]
+
+		if p.Number != 546 || !p.Listen.Equal(net.ParseIP("fe80::a00:27ff:fe4e:66a1")) {
+			t.Errorf("unexpected port %v on %v", p.Number, p.Listen)
+		}
+	})
+
+	t.Run("multiple processes", func(t *testing.T) {
+		p := ports[5]
+
+		expected := []Process{
+			{PID: 1199, Name: "nginx"},
+			{PID: 1200, Name: "nginx"},
+			{PID: 1201, Name: "nginx"},
+		}
+
+		if !reflect.DeepEqual(p.Processes, expected) {
+			t.Errorf("expected %v, got %v", expected, p.Processes)
+		}
+	})
+
+	t.Run("ipv6 unspecified", func(t *testing.T) {
+		for _, p := range ports[6:8] {
+			if !p.Listen.Equal(net.IPv6unspecified) {
+				t.Errorf("expected %v to be listening on ::, got %v", p.Number, p.Listen)
+			}
+		}
+	})
+
+	t.Run("no process", func(t *testing.T) {
+		p := ports[8]
+
+		if p.Number != 3306 || p.State != "LISTEN" || len(p.Processes) != 0 {
+			t.Errorf("unexpected port %+v", p)
+		}
+	})
+}
+
+func TestParseSSLegacy(t *testing.T) {
+	f, err := os.Open("test/ss-legacy")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ports, err := ParseSS(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 ports, got %v", len(ports))
+	}
+
+	if ports[0].Number != 22 || !ports[0].Listen.Equal(net.IPv6unspecified) {
+		t.Errorf("unexpected port %v on %v", ports[0].Number, ports[0].Listen)
+	}
+}
+
+func TestParseSSSkipsBadLines(t *testing.T) {
+	lines := []string{
+		"tcp LISTEN 0 128 0.0.0.0",
+		"tcp LISTEN 0 128 0.0.0.0:http 0.0.0.0:*",
+		"tcp LISTEN 0 128 0.0.0.0:99999 0.0.0.0:*",
+		"tcp LISTEN 0 128 not-an-ip:22 0.0.0.0:*",
+		"tcp LISTEN 0 128 0.0.0.0:22 0.0.0.0:*",
+	}
+
+	ports, err := ParseSS(strings.NewReader(strings.Join(lines, "\n")))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ports) != 1 || ports[0].Number != 22 {
+		t.Errorf("expected only port 22 to be parsed, got %+v", ports)
+	}
+}
+
+func TestListeningPorts(t *testing.T) {
+	t.Run("with output", func(t *testing.T) {
+		run := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return os.ReadFile("test/ss")
+		}
+
+		ports, err := ListeningPorts(context.Background(), run)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(ports) != 9 {
+			t.Errorf("expected 9 ports, got %v", len(ports))
+		}
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		run := func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			return nil, errors.New("ss: command not found")
+		}
+
+		if _, err := ListeningPorts(context.Background(), run); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,195 @@
+// Package packagedb contains the logic that is shared between package sources
+// that read a package manager's database of installed packages directly,
+// rather than running the package manager
+package packagedb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+	"github.com/overmindtech/sdp-go"
+)
+
+// NotFoundError will be returned when the package isn't installed
+type NotFoundError struct {
+	Message string
+}
+
+func (m NotFoundError) Error() string {
+	return fmt.Sprintf("Package not found. Message: %v", m.Message)
+}
+
+// Entry The parts of a package that are needed to look it up
+type Entry struct {
+	Name     string
+	Provides []packagedeps.Dependency
+	Depends  []packagedeps.Dependency
+	Files    []packagefiles.File
+}
+
+// Matches Returns whether the package is called name or provides it, in
+// which case it satisfies a dependency on name
+func (e Entry) Matches(name string) bool {
+	if e.Name == name {
+		return true
+	}
+
+	for _, provided := range e.Provides {
+		if provided.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Index Indexes the installed packages of a database by name and by the files
+// that they own. Packages are returned in the order that they are in the
+// database
+type Index[P any] struct {
+	packages []P
+	entries  []Entry
+	byName   map[string]int
+	owners   map[string][]int
+}
+
+// NewIndex Indexes packages, using entry to get the parts of each one that
+// are needed to look it up
+func NewIndex[P any](packages []P, entry func(P) Entry) *Index[P] {
+	x := Index[P]{
+		packages: packages,
+		entries:  make([]Entry, 0, len(packages)),
+		byName:   make(map[string]int),
+		owners:   make(map[string][]int),
+	}
+
+	for i, p := range packages {
+		e := entry(p)
+		x.entries = append(x.entries, e)
+		x.byName[e.Name] = i
+
+		for _, f := range e.Files {
+			if !f.Directory {
+				x.owners[f.Path] = append(x.owners[f.Path], i)
+			}
+		}
+	}
+
+	return &x
+}
+
+// Packages Returns all packages
+func (x *Index[P]) Packages() []P {
+	return x.packages
+}
+
+// Package Returns a package by name
+func (x *Index[P]) Package(name string) (P, bool) {
+	i, ok := x.byName[name]
+
+	if !ok {
+		var p P
+		return p, false
+	}
+
+	return x.packages[i], true
+}
+
+// Owners Returns the packages that own files matching a pattern. If the
+// pattern contains shell wildcards it must match the whole path, otherwise it
+// must match exactly
+func (x *Index[P]) Owners(pattern string) []P {
+	found := make(map[int]bool)
+
+	if strings.ContainsAny(pattern, "*?[") {
+		for path, owners := range x.owners {
+			if matched, _ := filepath.Match(pattern, path); matched {
+				for _, i := range owners {
+					found[i] = true
+				}
+			}
+		}
+	} else {
+		for _, i := range x.owners[pattern] {
+			found[i] = true
+		}
+	}
+
+	return x.byIndex(found)
+}
+
+// WhatProvides Returns the packages that are called name or provide it
+func (x *Index[P]) WhatProvides(name string) []P {
+	found := make(map[int]bool)
+
+	for i, e := range x.entries {
+		if e.Matches(name) {
+			found[i] = true
+		}
+	}
+
+	return x.byIndex(found)
+}
+
+// ReverseDepends Returns the packages that depend on the named package, either
+// directly or through something that it provides
+func (x *Index[P]) ReverseDepends(name string) []P {
+	names := map[string]bool{
+		name: true,
+	}
+
+	for _, e := range x.entries {
+		if e.Name == name {
+			for _, provided := range e.Provides {
+				names[provided.Name] = true
+			}
+		}
+	}
+
+	found := make(map[int]bool)
+
+	for i, e := range x.entries {
+		if e.Name != name && packagedeps.Satisfies(e.Depends, names) {
+			found[i] = true
+		}
+	}
+
+	return x.byIndex(found)
+}
+
+// byIndex Returns the packages at the given indexes, in the order that they
+// are in the database
+func (x *Index[P]) byIndex(found map[int]bool) []P {
+	indexes := make([]int, 0, len(found))
+
+	for i := range found {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	packages := make([]P, 0, len(indexes))
+
+	for _, i := range indexes {
+		packages = append(packages, x.packages[i])
+	}
+
+	return packages
+}
+
+// MapItems Converts packages to items, skipping any that fail
+func MapItems[P any](packages []P, mapItem func(P) (*sdp.Item, error)) []*sdp.Item {
+	items := make([]*sdp.Item, 0, len(packages))
+
+	for _, p := range packages {
+		if item, err := mapItem(p); err == nil {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
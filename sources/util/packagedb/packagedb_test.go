@@ -0,0 +1,116 @@
+package packagedb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/overmind-agent/sources/util/packagedeps"
+	"github.com/overmindtech/overmind-agent/sources/util/packagefiles"
+)
+
+func TestIndex(t *testing.T) {
+	entries := []Entry{
+		{
+			Name:     "musl",
+			Provides: []packagedeps.Dependency{{Name: "so:libc.musl-x86_64.so.1"}},
+			Files: []packagefiles.File{
+				{Path: "/lib", Directory: true},
+				{Path: "/lib/ld-musl-x86_64.so.1"},
+			},
+		},
+		{
+			Name:     "busybox",
+			Provides: []packagedeps.Dependency{{Name: "/bin/sh"}},
+			Depends:  []packagedeps.Dependency{{Name: "so:libc.musl-x86_64.so.1"}},
+			Files: []packagefiles.File{
+				{Path: "/bin/busybox"},
+				{Path: "/lib/libbusybox.so"},
+			},
+		},
+		{
+			Name:    "nginx",
+			Depends: []packagedeps.Dependency{{Name: "/bin/sh"}, {Name: "musl"}},
+			Files: []packagefiles.File{
+				{Path: "/usr/sbin/nginx"},
+			},
+		},
+	}
+
+	index := NewIndex(entries, func(e Entry) Entry { return e })
+
+	names := func(es []Entry) []string {
+		n := make([]string, 0)
+
+		for _, e := range es {
+			n = append(n, e.Name)
+		}
+
+		return n
+	}
+
+	t.Run("Package", func(t *testing.T) {
+		if e, ok := index.Package("nginx"); !ok || e.Name != "nginx" {
+			t.Errorf("unexpected package %+v", e)
+		}
+
+		if _, ok := index.Package("notapackage"); ok {
+			t.Error("expected notapackage not to be found")
+		}
+	})
+
+	t.Run("Owners", func(t *testing.T) {
+		tests := map[string][]string{
+			"/lib/*":          {"musl", "busybox"},
+			"/usr/sbin/nginx": {"nginx"},
+			"/lib":            {},
+		}
+
+		for pattern, expected := range tests {
+			if found := names(index.Owners(pattern)); !reflect.DeepEqual(found, expected) {
+				t.Errorf("%v: expected %v, got %v", pattern, expected, found)
+			}
+		}
+	})
+
+	t.Run("WhatProvides", func(t *testing.T) {
+		tests := map[string][]string{
+			"so:libc.musl-x86_64.so.1": {"musl"},
+			"/bin/sh":                  {"busybox"},
+			"nginx":                    {"nginx"},
+			"notapackage":              {},
+		}
+
+		for name, expected := range tests {
+			if found := names(index.WhatProvides(name)); !reflect.DeepEqual(found, expected) {
+				t.Errorf("%v: expected %v, got %v", name, expected, found)
+			}
+		}
+	})
+
+	t.Run("ReverseDepends", func(t *testing.T) {
+		tests := map[string][]string{
+			"musl":    {"busybox", "nginx"},
+			"busybox": {"nginx"},
+			"nginx":   {},
+		}
+
+		for name, expected := range tests {
+			if found := names(index.ReverseDepends(name)); !reflect.DeepEqual(found, expected) {
+				t.Errorf("%v: expected %v, got %v", name, expected, found)
+			}
+		}
+	})
+}
+
+func TestMatches(t *testing.T) {
+	e := Entry{
+		Name:     "bash",
+		Provides: []packagedeps.Dependency{{Name: "sh"}},
+	}
+
+	for name, expected := range map[string]bool{"bash": true, "sh": true, "zsh": false} {
+		if e.Matches(name) != expected {
+			t.Errorf("%v: expected %v", name, expected)
+		}
+	}
+}
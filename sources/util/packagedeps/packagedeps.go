@@ -0,0 +1,220 @@
+// Package packagedeps contains the logic that is shared between package
+// sources for describing the relationships between packages
+package packagedeps
+
+import (
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// Dependency A relationship from one package to another (or to a virtual
+// package or capability), optionally constrained by version
+type Dependency struct {
+	Name string
+
+	// The comparison operator of the version constraint e.g. >= or <<. This
+	// is empty if the dependency isn't constrained
+	Operator string
+	Version  string
+
+	// Architecture qualifier e.g. "any" for python3:any. Only used by dpkg
+	Arch string
+
+	// Other packages that can satisfy the dependency instead of this one e.g.
+	// "default-mta | mail-transport-agent". Only used by dpkg
+	Alternatives []Dependency
+}
+
+// Constraint Returns the version constraint as a string e.g. ">= 2.34", or an
+// empty string if the dependency isn't constrained
+func (d Dependency) Constraint() string {
+	if d.Operator == "" {
+		return ""
+	}
+
+	return d.Operator + " " + d.Version
+}
+
+// Names Returns the name of the dependency and of its alternatives
+func (d Dependency) Names() []string {
+	names := []string{d.Name}
+
+	for _, a := range d.Alternatives {
+		names = append(names, a.Name)
+	}
+
+	return names
+}
+
+// attributes Returns the dependency in a format that can be used in
+// sdp.ToAttributes(). Empty values are omitted
+func (d Dependency) attributes() map[string]interface{} {
+	attributes := map[string]interface{}{
+		"name": d.Name,
+	}
+
+	if d.Operator != "" {
+		attributes["operator"] = d.Operator
+		attributes["version"] = d.Version
+	}
+
+	if d.Arch != "" {
+		attributes["arch"] = d.Arch
+	}
+
+	if len(d.Alternatives) > 0 {
+		alternatives := make([]interface{}, 0, len(d.Alternatives))
+
+		for _, a := range d.Alternatives {
+			alternatives = append(alternatives, a.attributes())
+		}
+
+		attributes["alternatives"] = alternatives
+	}
+
+	return attributes
+}
+
+// Attributes Converts a list of dependencies to a value that can be used in
+// sdp.ToAttributes() e.g.
+//
+//	[{"name": "libc6", "operator": ">=", "version": "2.34"}]
+func Attributes(deps []Dependency) []interface{} {
+	attributes := make([]interface{}, 0, len(deps))
+
+	for _, d := range deps {
+		attributes = append(attributes, d.attributes())
+	}
+
+	return attributes
+}
+
+// Satisfies Returns whether any of the dependencies (or their alternatives)
+// refer to one of the given names. Version constraints aren't checked
+func Satisfies(deps []Dependency, names map[string]bool) bool {
+	for _, d := range deps {
+		for _, name := range d.Names() {
+			if names[name] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// LinkedItemRequests Returns requests for the packages that satisfy the
+// dependencies, including alternatives. Each name is only requested once
+// and names for which skip returns true are left out
+func LinkedItemRequests(method sdp.RequestMethod, skip func(name string) bool, deps ...[]Dependency) []*sdp.ItemRequest {
+	requests := make([]*sdp.ItemRequest, 0)
+	seen := make(map[string]bool)
+
+	for _, list := range deps {
+		for _, d := range list {
+			for _, name := range d.Names() {
+				if seen[name] || (skip != nil && skip(name)) {
+					continue
+				}
+
+				seen[name] = true
+
+				requests = append(requests, &sdp.ItemRequest{
+					Type:    "package",
+					Method:  method,
+					Query:   name,
+					Context: util.LocalContext,
+				})
+			}
+		}
+	}
+
+	return requests
+}
+
+// ParseDebian Parses a relationship field in the Debian format as used by
+// dpkg (and apt) e.g.
+//
+//	libc6 (>= 2.34), libssl3 (>= 3.0.0) | libssl1.1, python3:any
+func ParseDebian(field string) []Dependency {
+	deps := make([]Dependency, 0)
+
+	for _, group := range strings.Split(field, ",") {
+		var dep Dependency
+
+		for i, alternative := range strings.Split(group, "|") {
+			a, ok := parseDebianDependency(alternative)
+
+			if !ok {
+				continue
+			}
+
+			if i == 0 {
+				dep = a
+			} else {
+				dep.Alternatives = append(dep.Alternatives, a)
+			}
+		}
+
+		if dep.Name != "" {
+			deps = append(deps, dep)
+		}
+	}
+
+	return deps
+}
+
+// parseDebianDependency Parses a single dependency e.g. "libc6:amd64 (>= 2.34)"
+func parseDebianDependency(s string) (Dependency, bool) {
+	s = strings.TrimSpace(s)
+
+	if s == "" {
+		return Dependency{}, false
+	}
+
+	var dep Dependency
+
+	name, constraint, constrained := strings.Cut(s, "(")
+	name = strings.TrimSpace(name)
+
+	if n, arch, found := strings.Cut(name, ":"); found {
+		name = n
+		dep.Arch = arch
+	}
+
+	dep.Name = name
+
+	if constrained {
+		constraint = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(constraint), ")"))
+
+		// The operator is made up of the characters <, = and >, and there
+		// might not be a space between it and the version
+		version := strings.TrimLeft(constraint, "<=>")
+		dep.Operator = strings.TrimSpace(constraint[:len(constraint)-len(version)])
+		dep.Version = strings.TrimSpace(version)
+	}
+
+	return dep, true
+}
+
+// ParseConstraint Parses a dependency where the version constraint directly
+// follows the name, as used by apk and pacman e.g. "pcre2>=10.40" or
+// "libreadline.so=8-64". The operator is made up of the characters <, >, = and
+// ~, and the name can't start with one
+func ParseConstraint(s string) Dependency {
+	dep := Dependency{
+		Name: s,
+	}
+
+	if i := strings.IndexAny(s, "<>=~"); i > 0 {
+		version := strings.TrimLeft(s[i:], "<>=~")
+
+		dep.Name = s[:i]
+		dep.Operator = s[i : len(s)-len(version)]
+		dep.Version = version
+	}
+
+	return dep
+}
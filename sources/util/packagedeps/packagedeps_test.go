@@ -0,0 +1,114 @@
+package packagedeps
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestParseDebian(t *testing.T) {
+	deps := ParseDebian("libc6 (>= 2.34), libssl3 (>= 3.0.0) | libssl1.1, python3:any, debconf (>=0.5) | debconf-2.0, libzstd1 (<< 1.6)")
+
+	expected := []Dependency{
+		{Name: "libc6", Operator: ">=", Version: "2.34"},
+		{
+			Name:         "libssl3",
+			Operator:     ">=",
+			Version:      "3.0.0",
+			Alternatives: []Dependency{{Name: "libssl1.1"}},
+		},
+		{Name: "python3", Arch: "any"},
+		{
+			Name:         "debconf",
+			Operator:     ">=",
+			Version:      "0.5",
+			Alternatives: []Dependency{{Name: "debconf-2.0"}},
+		},
+		{Name: "libzstd1", Operator: "<<", Version: "1.6"},
+	}
+
+	if !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected %+v, got %+v", expected, deps)
+	}
+
+	if empty := ParseDebian(""); len(empty) != 0 {
+		t.Errorf("expected no dependencies, got %v", empty)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	attributes := Attributes(ParseDebian("libssl3 (>= 3.0.0) | libssl1.1"))
+
+	expected := []interface{}{
+		map[string]interface{}{
+			"name":     "libssl3",
+			"operator": ">=",
+			"version":  "3.0.0",
+			"alternatives": []interface{}{
+				map[string]interface{}{
+					"name": "libssl1.1",
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected %v, got %v", expected, attributes)
+	}
+
+	// Make sure that the result can actually be converted
+	if _, err := sdp.ToAttributes(map[string]interface{}{"depends": attributes}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	deps := ParseDebian("libc6 (>= 2.34), default-mta | mail-transport-agent")
+
+	if !Satisfies(deps, map[string]bool{"mail-transport-agent": true}) {
+		t.Error("expected alternative to satisfy dependencies")
+	}
+
+	if Satisfies(deps, map[string]bool{"libssl3": true}) {
+		t.Error("expected libssl3 not to satisfy dependencies")
+	}
+}
+
+func TestLinkedItemRequests(t *testing.T) {
+	depends := ParseDebian("libc6 (>= 2.34), default-mta | mail-transport-agent")
+	recommends := ParseDebian("libc6, ca-certificates")
+
+	requests := LinkedItemRequests(sdp.RequestMethod_GET, func(name string) bool {
+		return name == "ca-certificates"
+	}, depends, recommends)
+
+	var queries []string
+
+	for _, r := range requests {
+		queries = append(queries, r.Query)
+	}
+
+	expected := []string{"libc6", "default-mta", "mail-transport-agent"}
+
+	if !reflect.DeepEqual(queries, expected) {
+		t.Errorf("expected %v, got %v", expected, queries)
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	tests := map[string]Dependency{
+		"glibc":                    {Name: "glibc"},
+		"pcre2>=10.40":             {Name: "pcre2", Operator: ">=", Version: "10.40"},
+		"libreadline.so=8-64":      {Name: "libreadline.so", Operator: "=", Version: "8-64"},
+		"so:libc.musl-x86_64.so.1": {Name: "so:libc.musl-x86_64.so.1"},
+		"python<3.12":              {Name: "python", Operator: "<", Version: "3.12"},
+		"busybox~1.36":             {Name: "busybox", Operator: "~", Version: "1.36"},
+	}
+
+	for s, expected := range tests {
+		if dep := ParseConstraint(s); !reflect.DeepEqual(dep, expected) {
+			t.Errorf("%v: expected %+v, got %+v", s, expected, dep)
+		}
+	}
+}
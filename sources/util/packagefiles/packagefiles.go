@@ -0,0 +1,160 @@
+// Package packagefiles contains the logic that is shared between package
+// sources for describing the files that a package owns
+package packagefiles
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/overmindtech/overmind-agent/sources/util"
+	"github.com/overmindtech/sdp-go"
+)
+
+// DefaultLinkLimit The maximum number of files that a package will link to if
+// a limit isn't configured
+const DefaultLinkLimit = 100
+
+// File A file that is owned by a package
+type File struct {
+	Path string
+
+	// Whether the file is a config file. Package managers preserve changes to
+	// these files on upgrade rather than overwriting them
+	Conffile bool
+
+	// Whether the path is a directory. Directories are usually shared between
+	// many packages so aren't counted or linked to
+	Directory bool
+}
+
+// binaryDirectories Directories whose contents are executables
+var binaryDirectories = map[string]bool{
+	"/bin":            true,
+	"/sbin":           true,
+	"/usr/bin":        true,
+	"/usr/sbin":       true,
+	"/usr/local/bin":  true,
+	"/usr/local/sbin": true,
+	"/usr/libexec":    true,
+}
+
+// unitDirectories Directories that contain systemd units
+var unitDirectories = []string{
+	"/lib/systemd/system/",
+	"/usr/lib/systemd/system/",
+	"/etc/systemd/system/",
+	"/lib/systemd/user/",
+	"/usr/lib/systemd/user/",
+}
+
+// MarkDirectories Marks paths that are the parent of another path as
+// directories. This is used for package managers such as dpkg that list
+// directories along with files but don't say which is which
+func MarkDirectories(files []File) {
+	parents := make(map[string]bool)
+
+	for _, f := range files {
+		parents[filepath.Dir(f.Path)] = true
+	}
+
+	for i := range files {
+		if parents[files[i].Path] || files[i].Path == "/." {
+			files[i].Directory = true
+		}
+	}
+}
+
+// Attributes Returns the attributes that describe the files that a package
+// owns. These are:
+//
+//   - fileCount: The number of files that the package owns, excluding
+//     directories
+//   - conffileCount: The number of those files that are config files
+//   - conffiles: The paths of the config files
+func Attributes(files []File) map[string]interface{} {
+	var fileCount int
+	conffiles := make([]string, 0)
+
+	for _, f := range files {
+		if f.Directory {
+			continue
+		}
+
+		fileCount++
+
+		if f.Conffile {
+			conffiles = append(conffiles, f.Path)
+		}
+	}
+
+	return map[string]interface{}{
+		"fileCount":     fileCount,
+		"conffileCount": len(conffiles),
+		"conffiles":     conffiles,
+	}
+}
+
+// LinkedItemRequests Returns requests for the `file` items of the files that
+// a package owns, up to a given limit. A limit of zero uses DefaultLinkLimit
+// and a negative limit disables links altogether. Since a package can own
+// thousands of files the most interesting ones are linked first: config files,
+// then systemd units, then binaries
+func LinkedItemRequests(files []File, limit int) []*sdp.ItemRequest {
+	if limit == 0 {
+		limit = DefaultLinkLimit
+	}
+
+	requests := make([]*sdp.ItemRequest, 0)
+
+	if limit < 0 {
+		return requests
+	}
+
+	linkable := make([]File, 0, len(files))
+
+	for _, f := range files {
+		if !f.Directory {
+			linkable = append(linkable, f)
+		}
+	}
+
+	sort.SliceStable(linkable, func(i, j int) bool {
+		return priority(linkable[i]) < priority(linkable[j])
+	})
+
+	for _, f := range linkable {
+		if len(requests) >= limit {
+			break
+		}
+
+		requests = append(requests, &sdp.ItemRequest{
+			Type:    "file",
+			Method:  sdp.RequestMethod_GET,
+			Query:   f.Path,
+			Context: util.LocalContext,
+		})
+	}
+
+	return requests
+}
+
+// priority Returns the order in which a file should be linked, lower numbers
+// are linked first
+func priority(f File) int {
+	if f.Conffile {
+		return 0
+	}
+
+	for _, dir := range unitDirectories {
+		if strings.HasPrefix(f.Path, dir) {
+			return 1
+		}
+	}
+
+	if binaryDirectories[filepath.Dir(f.Path)] {
+		return 2
+	}
+
+	return 3
+}
@@ -0,0 +1,109 @@
+package packagefiles
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testFiles() []File {
+	return []File{
+		{Path: "/."},
+		{Path: "/etc"},
+		{Path: "/etc/nginx"},
+		{Path: "/etc/nginx/nginx.conf", Conffile: true},
+		{Path: "/usr"},
+		{Path: "/usr/share/doc/nginx/copyright"},
+		{Path: "/usr/sbin"},
+		{Path: "/usr/sbin/nginx"},
+		{Path: "/lib/systemd/system/nginx.service"},
+		{Path: "/var/www/html"},
+	}
+}
+
+func TestMarkDirectories(t *testing.T) {
+	files := testFiles()
+
+	MarkDirectories(files)
+
+	var directories []string
+
+	for _, f := range files {
+		if f.Directory {
+			directories = append(directories, f.Path)
+		}
+	}
+
+	// An empty directory (/var/www/html) can't be detected since nothing is
+	// inside it
+	expected := []string{"/.", "/etc", "/etc/nginx", "/usr", "/usr/sbin"}
+
+	if !reflect.DeepEqual(directories, expected) {
+		t.Errorf("expected %v, got %v", expected, directories)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	files := testFiles()
+
+	MarkDirectories(files)
+
+	attributes := Attributes(files)
+
+	if attributes["fileCount"] != 5 {
+		t.Errorf("expected 5 files, got %v", attributes["fileCount"])
+	}
+
+	if attributes["conffileCount"] != 1 {
+		t.Errorf("expected 1 conffile, got %v", attributes["conffileCount"])
+	}
+
+	if !reflect.DeepEqual(attributes["conffiles"], []string{"/etc/nginx/nginx.conf"}) {
+		t.Errorf("unexpected conffiles %v", attributes["conffiles"])
+	}
+}
+
+func TestLinkedItemRequests(t *testing.T) {
+	files := testFiles()
+
+	MarkDirectories(files)
+
+	t.Run("ordering", func(t *testing.T) {
+		requests := LinkedItemRequests(files, 0)
+
+		var queries []string
+
+		for _, r := range requests {
+			queries = append(queries, r.Query)
+		}
+
+		expected := []string{
+			"/etc/nginx/nginx.conf",
+			"/lib/systemd/system/nginx.service",
+			"/usr/sbin/nginx",
+			"/usr/share/doc/nginx/copyright",
+			"/var/www/html",
+		}
+
+		if !reflect.DeepEqual(queries, expected) {
+			t.Errorf("expected %v, got %v", expected, queries)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		requests := LinkedItemRequests(files, 2)
+
+		if len(requests) != 2 {
+			t.Fatalf("expected 2 requests, got %v", len(requests))
+		}
+
+		if requests[1].Query != "/lib/systemd/system/nginx.service" {
+			t.Errorf("expected unit to be linked second, got %v", requests[1].Query)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		if requests := LinkedItemRequests(files, -1); len(requests) != 0 {
+			t.Errorf("expected no requests, got %v", len(requests))
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package packagefiles
+
+// Reasons that a file can fail verification
+const (
+	ReasonMissing      = "missing"
+	ReasonChecksum     = "checksum"
+	ReasonSize         = "size"
+	ReasonSymlink      = "symlink"
+	ReasonDevice       = "device"
+	ReasonMode         = "mode"
+	ReasonOwner        = "owner"
+	ReasonGroup        = "group"
+	ReasonCapabilities = "capabilities"
+	ReasonMtime        = "mtime"
+)
+
+// Problem A file that differs from the way that it was installed by its
+// package
+type Problem struct {
+	Path     string
+	Reasons  []string
+	Conffile bool
+}
+
+// has Returns whether the problem has any of the given reasons
+func (p Problem) has(reasons ...string) bool {
+	for _, r := range p.Reasons {
+		for _, reason := range reasons {
+			if r == reason {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Missing Returns whether the file has been deleted
+func (p Problem) Missing() bool {
+	return p.has(ReasonMissing)
+}
+
+// Modified Returns whether the contents of the file have changed
+func (p Problem) Modified() bool {
+	return p.has(ReasonChecksum, ReasonSize, ReasonSymlink, ReasonDevice)
+}
+
+// PermissionChanged Returns whether the permissions or ownership of the file
+// have changed
+func (p Problem) PermissionChanged() bool {
+	return p.has(ReasonMode, ReasonOwner, ReasonGroup, ReasonCapabilities)
+}
+
+// Failed Returns whether the problem counts as an integrity failure. Config
+// files are expected to be changed by administrators so never fail, and
+// neither do files whose only change is their modification time
+func (p Problem) Failed() bool {
+	return !p.Conffile && (p.Missing() || p.Modified() || p.PermissionChanged())
+}
+
+// Failed Returns whether any of the problems count as integrity failures
+func Failed(problems []Problem) bool {
+	for _, p := range problems {
+		if p.Failed() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerificationAttributes Returns the attributes that describe the result of
+// verifying a package. These are:
+//
+//   - integrity: "ok" or "failed"
+//   - integrityFailures: The files that failed and the reasons why e.g.
+//     [{"path": "/usr/bin/ls", "reasons": ["checksum", "size"]}]
+//   - modifiedFiles, missingFiles and permissionChangedFiles: The paths of
+//     the files that failed for each reason
+//   - modifiedConffiles: Config files that have been changed, which aren't
+//     counted as failures
+func VerificationAttributes(problems []Problem) map[string]interface{} {
+	failures := make([]interface{}, 0)
+	modified := make([]string, 0)
+	missing := make([]string, 0)
+	permissionChanged := make([]string, 0)
+	modifiedConffiles := make([]string, 0)
+
+	for _, p := range problems {
+		if p.Conffile {
+			if p.Missing() || p.Modified() || p.PermissionChanged() {
+				modifiedConffiles = append(modifiedConffiles, p.Path)
+			}
+
+			continue
+		}
+
+		if !p.Failed() {
+			continue
+		}
+
+		reasons := make([]interface{}, 0, len(p.Reasons))
+
+		for _, r := range p.Reasons {
+			reasons = append(reasons, r)
+		}
+
+		failures = append(failures, map[string]interface{}{
+			"path":    p.Path,
+			"reasons": reasons,
+		})
+
+		if p.Missing() {
+			missing = append(missing, p.Path)
+		}
+
+		if p.Modified() {
+			modified = append(modified, p.Path)
+		}
+
+		if p.PermissionChanged() {
+			permissionChanged = append(permissionChanged, p.Path)
+		}
+	}
+
+	integrity := "ok"
+
+	if len(failures) > 0 {
+		integrity = "failed"
+	}
+
+	return map[string]interface{}{
+		"integrity":              integrity,
+		"integrityFailures":      failures,
+		"modifiedFiles":          modified,
+		"missingFiles":           missing,
+		"permissionChangedFiles": permissionChanged,
+		"modifiedConffiles":      modifiedConffiles,
+	}
+}
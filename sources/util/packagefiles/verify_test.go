@@ -0,0 +1,59 @@
+package packagefiles
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/overmindtech/sdp-go"
+)
+
+func TestVerificationAttributes(t *testing.T) {
+	problems := []Problem{
+		{Path: "/usr/bin/a", Reasons: []string{ReasonSize, ReasonChecksum, ReasonMtime}},
+		{Path: "/usr/bin/b", Reasons: []string{ReasonMode}},
+		{Path: "/usr/lib/c", Reasons: []string{ReasonMissing}},
+		{Path: "/usr/lib/d", Reasons: []string{ReasonMtime}},
+		{Path: "/etc/e.conf", Reasons: []string{ReasonChecksum}, Conffile: true},
+	}
+
+	attributes := VerificationAttributes(problems)
+
+	expected := map[string]interface{}{
+		"integrity": "failed",
+		"integrityFailures": []interface{}{
+			map[string]interface{}{
+				"path":    "/usr/bin/a",
+				"reasons": []interface{}{"size", "checksum", "mtime"},
+			},
+			map[string]interface{}{
+				"path":    "/usr/bin/b",
+				"reasons": []interface{}{"mode"},
+			},
+			map[string]interface{}{
+				"path":    "/usr/lib/c",
+				"reasons": []interface{}{"missing"},
+			},
+		},
+		"modifiedFiles":          []string{"/usr/bin/a"},
+		"missingFiles":           []string{"/usr/lib/c"},
+		"permissionChangedFiles": []string{"/usr/bin/b"},
+		"modifiedConffiles":      []string{"/etc/e.conf"},
+	}
+
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected %v, got %v", expected, attributes)
+	}
+
+	if _, err := sdp.ToAttributes(attributes); err != nil {
+		t.Error(err)
+	}
+
+	// Changes to conffiles and mtimes alone aren't failures
+	if Failed(problems[3:]) {
+		t.Error("expected conffile and mtime changes not to fail")
+	}
+
+	if VerificationAttributes(nil)["integrity"] != "ok" {
+		t.Error("expected no problems to be ok")
+	}
+}
@@ -0,0 +1,140 @@
+// Package packageupdates contains the logic that is shared between package
+// sources for describing whether a newer version of a package is available
+// from the repositories that the package manager has downloaded metadata for
+package packageupdates
+
+// Compare Compares two versions using the rules of a package manager,
+// returning a negative number if a is older than b, zero if they are the same
+// and a positive number if a is newer than b
+type Compare func(a, b string) int
+
+// Candidate The newest version of a package that is available from a
+// repository, which is the version that an upgrade would install
+type Candidate struct {
+	Version string
+
+	// Where the candidate comes from e.g. the jammy-security suite or the
+	// baseos repository
+	Origin string
+
+	// Whether the candidate is a security update
+	Security bool
+}
+
+// Update Whether a package that is installed can be updated
+type Update struct {
+	// The candidate, or nil if the package isn't available from any
+	// repository e.g. because it was installed from a local file
+	Candidate *Candidate
+
+	// Whether the candidate is newer than the version that is installed
+	Available bool
+}
+
+// Security Returns whether the update is available and is a security update
+func (u *Update) Security() bool {
+	return u.Available && u.Candidate.Security
+}
+
+// Index The candidates for each package, keyed by name and architecture. Once
+// it has been built it isn't modified, so it can be shared
+type Index struct {
+	// The function used to compare versions
+	Compare Compare
+
+	candidates map[string]*Candidate
+}
+
+// key Returns the key of a package in the index
+func key(name string, arch string) string {
+	return name + "\x00" + arch
+}
+
+// Add Adds a version of a package that is available from a repository. If the
+// package is already in the index the newest version is kept. When the same
+// version is available from more than one place (e.g. Ubuntu publishes
+// security updates in both -updates and -security) it is a security update if
+// any of them are
+func (i *Index) Add(name string, arch string, c Candidate) {
+	if i.candidates == nil {
+		i.candidates = make(map[string]*Candidate)
+	}
+
+	existing, ok := i.candidates[key(name, arch)]
+
+	if !ok {
+		i.candidates[key(name, arch)] = &c
+		return
+	}
+
+	switch cmp := i.Compare(c.Version, existing.Version); {
+	case cmp > 0:
+		*existing = c
+	case cmp == 0 && c.Security && !existing.Security:
+		existing.Origin = c.Origin
+		existing.Security = true
+	}
+}
+
+// Len Returns the number of packages in the index
+func (i *Index) Len() int {
+	return len(i.candidates)
+}
+
+// Candidate Returns the candidate for a package, trying each architecture in
+// turn. Returns nil if the package isn't in the index
+func (i *Index) Candidate(name string, arches ...string) *Candidate {
+	for _, arch := range arches {
+		if c, ok := i.candidates[key(name, arch)]; ok {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Update Returns whether a package with the given version installed can be
+// updated, trying each architecture in turn to find its candidate. If the
+// index is nil there is no repository metadata so this returns nil, since it
+// isn't known whether there are updates
+func (i *Index) Update(name string, installed string, arches ...string) *Update {
+	if i == nil {
+		return nil
+	}
+
+	c := i.Candidate(name, arches...)
+
+	if c == nil {
+		return &Update{}
+	}
+
+	return &Update{
+		Candidate: c,
+		Available: i.Compare(c.Version, installed) > 0,
+	}
+}
+
+// Attributes Returns the attributes that describe whether a package can be
+// updated. These are:
+//
+//   - updateAvailable: Whether the candidate is newer than the version that is
+//     installed
+//   - candidateVersion: The version that an upgrade would install
+//   - candidateOrigin: The repository (or suite) that the candidate comes from
+//   - candidateSecurity: Whether the candidate is a security update
+//
+// The candidate attributes are only present if the package is available from
+// a repository
+func Attributes(u *Update) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"updateAvailable": u.Available,
+	}
+
+	if u.Candidate != nil {
+		attributes["candidateVersion"] = u.Candidate.Version
+		attributes["candidateOrigin"] = u.Candidate.Origin
+		attributes["candidateSecurity"] = u.Candidate.Security
+	}
+
+	return attributes
+}
@@ -0,0 +1,103 @@
+package packageupdates
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/overmindtech/sdp-go"
+)
+
+// compareInts Compares versions that are plain integers
+func compareInts(a, b string) int {
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+
+	return x - y
+}
+
+func TestIndex(t *testing.T) {
+	index := Index{Compare: compareInts}
+
+	index.Add("a", "amd64", Candidate{Version: "2", Origin: "release"})
+	index.Add("a", "amd64", Candidate{Version: "10", Origin: "updates"})
+	index.Add("a", "amd64", Candidate{Version: "10", Origin: "security", Security: true})
+	index.Add("a", "amd64", Candidate{Version: "3", Origin: "security", Security: true})
+	index.Add("b", "all", Candidate{Version: "1", Origin: "release"})
+
+	if index.Len() != 2 {
+		t.Errorf("expected 2 packages, got %v", index.Len())
+	}
+
+	t.Run("newest version is kept", func(t *testing.T) {
+		c := index.Candidate("a", "amd64")
+
+		expected := &Candidate{Version: "10", Origin: "security", Security: true}
+
+		if !reflect.DeepEqual(c, expected) {
+			t.Errorf("expected %v, got %v", expected, c)
+		}
+	})
+
+	t.Run("architectures are tried in turn", func(t *testing.T) {
+		if c := index.Candidate("b", "amd64", "all"); c == nil || c.Version != "1" {
+			t.Errorf("expected b to fall back to all, got %v", c)
+		}
+
+		if c := index.Candidate("a", "i386"); c != nil {
+			t.Errorf("expected no candidate for i386, got %v", c)
+		}
+	})
+
+	t.Run("updates", func(t *testing.T) {
+		if u := index.Update("a", "9", "amd64"); !u.Available || !u.Security() {
+			t.Errorf("expected a security update for a, got %v", u)
+		}
+
+		if u := index.Update("a", "10", "amd64"); u.Available || u.Security() {
+			t.Errorf("expected no update for a, got %v", u)
+		}
+
+		if u := index.Update("c", "1", "amd64"); u == nil || u.Available || u.Candidate != nil {
+			t.Errorf("expected no candidate for c, got %v", u)
+		}
+
+		var missing *Index
+
+		if u := missing.Update("a", "9", "amd64"); u != nil {
+			t.Errorf("expected nil without an index, got %v", u)
+		}
+	})
+}
+
+func TestAttributes(t *testing.T) {
+	index := Index{Compare: compareInts}
+	index.Add("a", "amd64", Candidate{Version: "10", Origin: "jammy-security", Security: true})
+
+	attributes := Attributes(index.Update("a", "9", "amd64"))
+
+	expected := map[string]interface{}{
+		"updateAvailable":   true,
+		"candidateVersion":  "10",
+		"candidateOrigin":   "jammy-security",
+		"candidateSecurity": true,
+	}
+
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected %v, got %v", expected, attributes)
+	}
+
+	if _, err := sdp.ToAttributes(attributes); err != nil {
+		t.Error(err)
+	}
+
+	attributes = Attributes(index.Update("b", "1", "amd64"))
+
+	expected = map[string]interface{}{
+		"updateAvailable": false,
+	}
+
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("expected %v, got %v", expected, attributes)
+	}
+}